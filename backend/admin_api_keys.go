@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/apikey"
+	"password-recovery/audit"
+	"password-recovery/i18n"
+	"password-recovery/rbac"
+)
+
+// apiKeyError responde los errores de validación con sus campos; msg
+// describe la operación en el log si el error es interno
+func apiKeyError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, apikey.ErrNotFound):
+		apierror.Abort(c, apierror.New(apierror.APIKeyNotFound))
+	case errors.Is(err, apikey.ErrInvalid):
+		apierror.Abort(c, apierror.New(apierror.APIKeyInactive))
+	default:
+		apierror.Abort(c, apierror.From(fmt.Errorf("%s: %w", msg, err)))
+	}
+}
+
+// apiKeyFromParam carga la llave :id del tenant de la petición
+func apiKeyFromParam(c *gin.Context) (*apikey.Key, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		apierror.Abort(c, apierror.InvalidField("id", "se esperaba un entero positivo"))
+		return nil, false
+	}
+	audit.SetTarget(c.Request.Context(), "api_key:"+strconv.FormatInt(id, 10))
+	k, err := apikey.Get(db, dbDialect, currentTenant(c).ID, id)
+	if err != nil {
+		apiKeyError(c, err, "Error al obtener la llave")
+		return nil, false
+	}
+	audit.SetTarget(c.Request.Context(), "api_key:"+k.Prefix)
+	return k, true
+}
+
+func listAPIKeysHandler(c *gin.Context) {
+	list, err := apikey.List(db, dbDialect, currentTenant(c).ID)
+	if err != nil {
+		apiKeyError(c, err, "Error al listar las llaves")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": list, "scopes": apikey.Grantable()})
+}
+
+// createAPIKeyHandler crea una llave en el tenant de la petición. Nadie
+// puede dar a una llave un permiso que no tiene.
+func createAPIKeyHandler(c *gin.Context) {
+	var request struct {
+		Name             string            `json:"name"`
+		Scopes           []rbac.Permission `json:"scopes"`
+		ExpiresAt        *time.Time        `json:"expires_at"`
+		RequireSignature bool              `json:"require_signature"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	audit.SetTarget(c.Request.Context(), "api_key:"+request.Name)
+
+	p, _ := rbac.PrincipalFrom(c.Request.Context())
+	k := &apikey.Key{
+		TenantID:         currentTenant(c).ID,
+		Name:             request.Name,
+		Scopes:           request.Scopes,
+		ExpiresAt:        request.ExpiresAt,
+		RequireSignature: request.RequireSignature,
+		CreatedBy:        p.ID,
+	}
+	k.Normalize()
+	for _, scope := range k.Scopes {
+		if scope != rbac.PermRecoverySend && !p.Can(scope) {
+			apierror.Abort(c, apierror.New(apierror.ScopeNotHeld).With("permission", scope))
+			return
+		}
+	}
+
+	key, secret, err := apikey.Create(db, dbDialect, k)
+	if err != nil {
+		apiKeyError(c, err, "Error al crear la llave")
+		return
+	}
+	audit.SetTarget(c.Request.Context(), "api_key:"+k.Prefix)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        i18n.Text(c.Request.Context(), "msg.api_key_created"),
+		"api_key":        key,
+		"signing_secret": secret,
+		"key":            k,
+	})
+}
+
+func getAPIKeyHandler(c *gin.Context) {
+	if k, ok := apiKeyFromParam(c); ok {
+		c.JSON(http.StatusOK, k)
+	}
+}
+
+// rotateAPIKeyHandler cambia la llave y el secreto; los anteriores dejan
+// de valer de inmediato
+func rotateAPIKeyHandler(c *gin.Context) {
+	k, ok := apiKeyFromParam(c)
+	if !ok {
+		return
+	}
+	key, secret, err := apikey.Rotate(db, dbDialect, k)
+	if err != nil {
+		apiKeyError(c, err, "Error al rotar la llave")
+		return
+	}
+	audit.SetTarget(c.Request.Context(), "api_key:"+k.Prefix)
+	c.JSON(http.StatusOK, gin.H{
+		"message":        i18n.Text(c.Request.Context(), "msg.api_key_created"),
+		"api_key":        key,
+		"signing_secret": secret,
+		"key":            k,
+	})
+}
+
+func revokeAPIKeyHandler(c *gin.Context) {
+	k, ok := apiKeyFromParam(c)
+	if !ok {
+		return
+	}
+	if err := apikey.Revoke(db, dbDialect, k); err != nil {
+		apiKeyError(c, err, "Error al revocar la llave")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.api_key_revoked"), "key": k})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/logging"
+	"password-recovery/rbac"
+)
+
+// parseAuditFilter lee los filtros de la query string:
+// actor, action (prefijo), target, result, since, until (RFC 3339),
+// page y page_size. Solo se ven los eventos de los tenants que administra
+// quien consulta.
+func parseAuditFilter(c *gin.Context) (audit.Filter, error) {
+	p, _ := rbac.PrincipalFrom(c.Request.Context())
+	f := audit.Filter{
+		Tenants: append([]int64{}, p.Tenants...),
+		Actor:   c.Query("actor"),
+		Action:  c.Query("action"),
+		Target:  c.Query("target"),
+		Result:  c.Query("result"),
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return f, apierror.InvalidField(name, "fecha inválida (use RFC 3339)")
+			}
+			*dst = t
+		}
+	}
+
+	page, pageSize := 1, audit.DefaultPageSize
+	if value := c.Query("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return f, apierror.InvalidField("page", "debe ser un entero mayor que 0")
+		}
+		page = n
+	}
+	if value := c.Query("page_size"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > audit.MaxPageSize {
+			return f, apierror.InvalidField("page_size", fmt.Sprintf("debe estar entre 1 y %d", audit.MaxPageSize))
+		}
+		pageSize = n
+	}
+	f.Limit = pageSize
+	f.Offset = (page - 1) * pageSize
+	return f, nil
+}
+
+func listAuditEventsHandler(c *gin.Context) {
+	f, err := parseAuditFilter(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	events, total, err := audit.List(c.Request.Context(), f)
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, fmt.Errorf("consultar auditoría: %w", err)))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"total":     total,
+		"page":      f.Offset/f.Limit + 1,
+		"page_size": f.Limit,
+	})
+}
+
+// exportAuditEventsHandler descarga los eventos filtrados como CSV
+// (format=csv) o JSON Lines (format=jsonl, por defecto)
+func exportAuditEventsHandler(c *gin.Context) {
+	f, err := parseAuditFilter(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	format := c.DefaultQuery("format", audit.FormatJSONLines)
+	if format != audit.FormatCSV && format != audit.FormatJSONLines {
+		apierror.Abort(c, apierror.InvalidField("format", "valor no permitido (use csv o jsonl)"))
+		return
+	}
+
+	filename := fmt.Sprintf("audit-events-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Header("Content-Type", audit.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := audit.Export(c.Request.Context(), c.Writer, format, f); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Error al exportar auditoría", "error", err)
+		c.Status(http.StatusInternalServerError)
+	}
+}
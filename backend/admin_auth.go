@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/auth"
+	"password-recovery/i18n"
+	"password-recovery/rbac"
+	"password-recovery/secrets"
+)
+
+// hash de relleno para que un correo inexistente tarde lo mismo en fallar
+var dummyPasswordHash, _ = secrets.HashPassword("dummy-password")
+
+// adminLoginHandler abre una sesión para una cuenta con rol administrativo
+// (admin, helpdesk o auditor) y devuelve el token Bearer
+func adminLoginHandler(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.Invalid(map[string]string{"email": "obligatorio", "password": "obligatorio"}))
+		return
+	}
+	email, _ := normalizeEmail(request.Email)
+	audit.SetActor(c.Request.Context(), email)
+	audit.SetTarget(c.Request.Context(), "admin")
+
+	var id int64
+	var password, role, status string
+	// La cuenta se busca en el tenant de la petición: el mismo correo
+	// puede existir en otros tenants
+	err := db.QueryRow(q("SELECT id, password, role, status FROM users WHERE tenant_id = $1 AND email = $2"), currentTenant(c).ID, email).
+		Scan(&id, &password, &role, &status)
+	if err != nil && err != sql.ErrNoRows {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	if err == sql.ErrNoRows {
+		password = dummyPasswordHash
+	}
+	// Las contraseñas sin hash Argon2id (cuentas importadas o pendientes
+	// de cambio) no son válidas: VerifyPassword devuelve error
+	valid, _ := secrets.VerifyPassword(password, request.Password)
+	if err == sql.ErrNoRows || !valid || status == userStatusDisabled {
+		apierror.Abort(c, apierror.New(apierror.InvalidCredentials))
+		return
+	}
+	if len(rbac.Permissions(role)) == 0 {
+		apierror.Abort(c, apierror.New(apierror.NotAdminAccount))
+		return
+	}
+
+	principal := rbac.Principal{ID: id, Subject: email, Role: role}
+	token, expires, err := sessions.Create(principal, auth.TTLFromEnv())
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, fmt.Errorf("crear sesión: %w", err)))
+		return
+	}
+	db.Exec(q("UPDATE users SET last_login = $1 WHERE id = $2"), time.Now().UTC(), id)
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":       token,
+		"expires_at":  expires,
+		"user":        principal,
+		"permissions": rbac.Permissions(role),
+	})
+}
+
+func adminLogoutHandler(c *gin.Context) {
+	if token := auth.BearerToken(c.Request); token != "" {
+		if p, err := sessions.Lookup(token); err == nil {
+			audit.SetActor(c.Request.Context(), p.Subject)
+		}
+		sessions.Revoke(token)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.session_closed")})
+}
+
+func adminMeHandler(c *gin.Context) {
+	p, err := auth.Authenticate(sessions, c.Request)
+	if err != nil {
+		apierror.Abort(c, auth.APIError(err))
+		return
+	}
+	perms := rbac.Permissions(p.Role)
+	if p.Scopes != nil {
+		perms = p.Scopes
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user":        p,
+		"permissions": perms,
+	})
+}
+
+func listRolesHandler(c *gin.Context) {
+	roles := make([]gin.H, 0, len(rbac.AccountRoles()))
+	for _, role := range rbac.AccountRoles() {
+		roles = append(roles, gin.H{"role": role, "permissions": rbac.Permissions(role)})
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// setUserRoleHandler asigna un rol; las sesiones abiertas de la cuenta se
+// cierran para que el cambio aplique de inmediato
+func setUserRoleHandler(c *gin.Context) {
+	u, ok := userFromParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || !rbac.ValidAccountRole(request.Role) {
+		apierror.Abort(c, apierror.New(apierror.RoleInvalid).With("roles", rbac.AccountRoles()))
+		return
+	}
+	if request.Role != rbac.RoleAdmin && wouldRemoveLastAdmin(currentTenant(c).ID, u) {
+		apierror.Abort(c, apierror.New(apierror.LastAdmin))
+		return
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(q("UPDATE users SET role = $1, updated_at = $2 WHERE id = $3"), request.Role, time.Now().UTC(), u.ID); err != nil {
+			return err
+		}
+		return auth.RevokeUserSessions(tx, dbDialect, int64(u.ID))
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, fmt.Errorf("asignar rol: %w", err)))
+		return
+	}
+
+	u.Role = request.Role
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.role_updated"), "user": u})
+}
+
+// wouldRemoveLastAdmin indica si quitarle el rol o deshabilitar a u deja su
+// tenant sin administradores activos
+func wouldRemoveLastAdmin(tenantID int64, u User) bool {
+	if u.Role != rbac.RoleAdmin || u.Status == userStatusDisabled {
+		return false
+	}
+	var others int
+	err := db.QueryRow(q("SELECT COUNT(*) FROM users WHERE tenant_id = $1 AND role = $2 AND status <> $3 AND id <> $4"),
+		tenantID, rbac.RoleAdmin, userStatusDisabled, u.ID).Scan(&others)
+	return err == nil && others == 0
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// Los administradores de otro tenant no cuentan para el último
+// administrador
+func TestWouldRemoveLastAdminPerTenant(t *testing.T) {
+	setupRecovery(t)
+	if _, err := db.Exec(`INSERT INTO tenants (slug, name) VALUES ('acme', 'Acme')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO users (tenant_id, email, password, role) VALUES
+		(1, 'root@example.com', '!', 'admin'),
+		(2, 'admin@acme.com', '!', 'admin')`); err != nil {
+		t.Fatal(err)
+	}
+
+	const acme int64 = 2
+	var u User
+	if err := db.QueryRow("SELECT id FROM users WHERE email = 'admin@acme.com'").Scan(&u.ID); err != nil {
+		t.Fatal(err)
+	}
+	u.Role, u.Status = rbac.RoleAdmin, userStatusActive
+	if !wouldRemoveLastAdmin(acme, u) {
+		t.Error("el administrador del tenant por defecto contó como otro administrador de acme")
+	}
+
+	if _, err := db.Exec(`INSERT INTO users (tenant_id, email, password, role) VALUES (2, 'otro@acme.com', '!', 'admin')`); err != nil {
+		t.Fatal(err)
+	}
+	if wouldRemoveLastAdmin(acme, u) {
+		t.Error("acme tiene otro administrador activo")
+	}
+
+	var root User
+	db.QueryRow("SELECT id FROM users WHERE email = 'root@example.com'").Scan(&root.ID)
+	root.Role, root.Status = rbac.RoleAdmin, userStatusActive
+	if !wouldRemoveLastAdmin(tenant.DefaultID, root) {
+		t.Error("los administradores de acme contaron para el tenant por defecto")
+	}
+}
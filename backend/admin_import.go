@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/logging"
+	"password-recovery/rbac"
+	"password-recovery/userimport"
+)
+
+// maxImportSize limita el archivo de importación
+const maxImportSize = 5 << 20
+
+// importUsersHandler importa usuarios desde CSV o JSON. El archivo llega
+// como multipart (campo "file") o directo en el cuerpo con Content-Type
+// text/csv o application/json. Parámetros: format, dry_run e invite.
+// Responde 422 (IMPORT_INVALID_ROWS, con el reporte en details.report)
+// si alguna fila no es válida; en ese caso no se guarda nada.
+func importUsersHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	opts := userimport.Options{
+		TenantID:        currentTenant(c).ID,
+		AllowPrivileged: rbac.Can(c.Request.Context(), rbac.PermRolesAssign),
+	}
+	for name, dst := range map[string]*bool{"dry_run": &opts.DryRun, "invite": &opts.Invite} {
+		if value := c.Query(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				apierror.Abort(c, apierror.InvalidField(name, "se esperaba true o false"))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	body, format, err := importSource(c)
+	if err != nil {
+		apierror.Abort(c, apierror.New(apierror.ImportInvalidFile).With("reason", err.Error()))
+		return
+	}
+	defer body.Close()
+
+	rows, err := userimport.Parse(body, format)
+	if err != nil {
+		apierror.Abort(c, apierror.New(apierror.ImportInvalidFile).With("reason", err.Error()))
+		return
+	}
+	audit.SetTarget(c.Request.Context(), fmt.Sprintf("%d filas", len(rows)))
+
+	report, err := userimport.Import(db, dbDialect, rows, opts)
+	if errors.Is(err, userimport.ErrLastAdmin) {
+		apierror.Abort(c, apierror.New(apierror.LastAdmin))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, fmt.Errorf("importar usuarios: %w", err)))
+		return
+	}
+	if len(report.Errors) > 0 {
+		apierror.Abort(c, apierror.New(apierror.ImportInvalidRows).With("report", report))
+		return
+	}
+
+	logging.FromContext(c.Request.Context()).Info("Usuarios importados",
+		"dry_run", report.DryRun, "created", report.Created, "updated", report.Updated, "invited", report.Invited)
+	c.JSON(http.StatusOK, report)
+}
+
+// importSource devuelve el archivo y su formato
+func importSource(c *gin.Context) (io.ReadCloser, string, error) {
+	format := c.Query("format")
+
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		if format == "" {
+			format = userimport.FormatFromName(header.Filename)
+		}
+		if format == "" {
+			format = userimport.FormatFromName(header.Header.Get("Content-Type"))
+		}
+		if format == "" {
+			file.Close()
+			return nil, "", userimport.ErrUnknownFormat
+		}
+		return file, format, nil
+	}
+
+	if format == "" {
+		format = userimport.FormatFromName(c.ContentType())
+	}
+	if format == "" {
+		return nil, "", errors.New("envíe el archivo en el campo file o con Content-Type text/csv o application/json")
+	}
+	return c.Request.Body, format, nil
+}
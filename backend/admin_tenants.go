@@ -0,0 +1,323 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/i18n"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// tenantError responde los errores de validación con sus campos; msg
+// describe la operación en el log si el error es interno
+func tenantError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		apierror.Abort(c, apierror.New(apierror.TenantNotFound))
+	case errors.Is(err, tenant.ErrUnknownTemplate):
+		apierror.Abort(c, apierror.New(apierror.TemplateNotFound).With("kinds", tenant.TemplateKinds()))
+	default:
+		apierror.Abort(c, apierror.From(fmt.Errorf("%s: %w", msg, err)))
+	}
+}
+
+// tenantFromParam carga el tenant de :id si la cuenta lo administra
+func tenantFromParam(c *gin.Context) (*tenant.Tenant, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		apierror.Abort(c, apierror.InvalidField("id", "se esperaba un entero positivo"))
+		return nil, false
+	}
+	audit.SetTarget(c.Request.Context(), "tenant:"+strconv.FormatInt(id, 10))
+
+	p, _ := rbac.PrincipalFrom(c.Request.Context())
+	if !p.InTenant(id) {
+		apierror.Abort(c, apierror.New(apierror.TenantAccessDenied))
+		return nil, false
+	}
+	t, err := tenant.Get(db, dbDialect, id)
+	if err != nil {
+		tenantError(c, err, "Error al obtener el tenant")
+		return nil, false
+	}
+	audit.SetTarget(c.Request.Context(), "tenant:"+t.Slug)
+	return t, true
+}
+
+// listTenantsHandler devuelve los tenants que administra la cuenta
+func listTenantsHandler(c *gin.Context) {
+	p, _ := rbac.PrincipalFrom(c.Request.Context())
+	list, err := tenant.List(db, dbDialect, p.Tenants)
+	if err != nil {
+		tenantError(c, err, "Error al listar tenants")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tenants": list,
+		"current": currentTenant(c).Slug,
+	})
+}
+
+// createTenantHandler crea el tenant y da acceso a quien lo creó
+func createTenantHandler(c *gin.Context) {
+	var t tenant.Tenant
+	if err := c.ShouldBindJSON(&t); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	t.ID = 0
+	audit.SetTarget(c.Request.Context(), "tenant:"+t.Slug)
+
+	p, _ := rbac.PrincipalFrom(c.Request.Context())
+	err := withTx(func(tx *sql.Tx) error {
+		if err := tenant.Create(tx, dbDialect, &t); err != nil {
+			return err
+		}
+		return tenant.GrantAdmin(tx, dbDialect, t.ID, p.ID)
+	})
+	if err != nil {
+		tenantError(c, err, "Error al crear el tenant")
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+func getTenantHandler(c *gin.Context) {
+	if t, ok := tenantFromParam(c); ok {
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// updateTenantHandler cambia solo los campos presentes en el cuerpo
+func updateTenantHandler(c *gin.Context) {
+	t, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name              *string   `json:"name"`
+		Hosts             *[]string `json:"hosts"`
+		CodeLength        *int      `json:"code_length"`
+		CodeTTLSeconds    *int      `json:"code_ttl_seconds"`
+		RedirectAllowlist *[]string `json:"redirect_allowlist"`
+		IsActive          *bool     `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	if request.Name != nil {
+		t.Name = *request.Name
+	}
+	if request.Hosts != nil {
+		t.Hosts = *request.Hosts
+	}
+	if request.CodeLength != nil {
+		t.CodeLength = *request.CodeLength
+	}
+	if request.CodeTTLSeconds != nil {
+		t.CodeTTLSeconds = *request.CodeTTLSeconds
+	}
+	if request.RedirectAllowlist != nil {
+		t.RedirectAllowlist = *request.RedirectAllowlist
+	}
+	if request.IsActive != nil {
+		t.IsActive = *request.IsActive
+	}
+
+	if err := tenant.Update(db, dbDialect, t); err != nil {
+		tenantError(c, err, "Error al actualizar el tenant")
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// rotateTenantKeyHandler genera la API key del tenant; solo se muestra una
+// vez
+func rotateTenantKeyHandler(c *gin.Context) {
+	t, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+	key, err := tenant.RotateAPIKey(db, dbDialect, t.ID)
+	if err != nil {
+		tenantError(c, err, "Error al generar la API key")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Text(c.Request.Context(), "msg.tenant_key_created"),
+		"api_key": key,
+		"header":  tenant.HeaderAPIKey,
+	})
+}
+
+func revokeTenantKeyHandler(c *gin.Context) {
+	t, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+	if err := tenant.RevokeAPIKey(db, dbDialect, t.ID); err != nil {
+		tenantError(c, err, "Error al revocar la API key")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.tenant_key_revoked")})
+}
+
+// listTenantAdminsHandler lista las cuentas con acceso administrativo al
+// tenant: las propias del tenant y las asignadas
+func listTenantAdminsHandler(c *gin.Context) {
+	t, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+	rows, err := db.Query(q(`
+		SELECT id, email, role, 'tenant' FROM users WHERE tenant_id = $1 AND role <> $2
+		UNION
+		SELECT u.id, u.email, u.role, 'assigned' FROM admin_tenants a JOIN users u ON u.id = a.user_id
+		WHERE a.tenant_id = $3 AND u.tenant_id <> $4
+		ORDER BY 1`), t.ID, rbac.RoleUser, t.ID, t.ID)
+	if err != nil {
+		tenantError(c, err, "Error al listar administradores")
+		return
+	}
+	defer rows.Close()
+
+	admins := []gin.H{}
+	for rows.Next() {
+		var id int64
+		var email, role, source string
+		if err := rows.Scan(&id, &email, &role, &source); err != nil {
+			tenantError(c, err, "Error al listar administradores")
+			return
+		}
+		admins = append(admins, gin.H{"user_id": id, "email": email, "role": role, "source": source})
+	}
+	c.JSON(http.StatusOK, gin.H{"tenant": t.Slug, "admins": admins})
+}
+
+// tenantAdminTarget valida :user_id; la cuenta debe tener un rol
+// administrativo y pertenecer a un tenant que administra quien hace el
+// cambio
+func tenantAdminTarget(c *gin.Context) (*tenant.Tenant, int64, bool) {
+	t, ok := tenantFromParam(c)
+	if !ok {
+		return nil, 0, false
+	}
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID < 1 {
+		apierror.Abort(c, apierror.InvalidField("user_id", "se esperaba un entero positivo"))
+		return nil, 0, false
+	}
+
+	var email, role string
+	var home int64
+	err = db.QueryRow(q("SELECT email, role, tenant_id FROM users WHERE id = $1"), userID).Scan(&email, &role, &home)
+	p, _ := rbac.PrincipalFrom(c.Request.Context())
+	if err == sql.ErrNoRows || (err == nil && !p.InTenant(home)) {
+		apierror.Abort(c, apierror.New(apierror.UserNotFound))
+		return nil, 0, false
+	}
+	if err != nil {
+		tenantError(c, err, "Error al obtener usuario")
+		return nil, 0, false
+	}
+	audit.SetTarget(c.Request.Context(), "tenant:"+t.Slug+":"+email)
+	if len(rbac.Permissions(role)) == 0 {
+		apierror.Abort(c, apierror.New(apierror.AdminRoleRequired))
+		return nil, 0, false
+	}
+	if home == t.ID {
+		apierror.Abort(c, apierror.New(apierror.TenantMember))
+		return nil, 0, false
+	}
+	return t, userID, true
+}
+
+func grantTenantAdminHandler(c *gin.Context) {
+	t, userID, ok := tenantAdminTarget(c)
+	if !ok {
+		return
+	}
+	if err := tenant.GrantAdmin(db, dbDialect, t.ID, userID); err != nil {
+		tenantError(c, err, "Error al asignar el tenant")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.tenant_access_granted")})
+}
+
+func revokeTenantAdminHandler(c *gin.Context) {
+	t, userID, ok := tenantAdminTarget(c)
+	if !ok {
+		return
+	}
+	if err := tenant.RevokeAdmin(db, dbDialect, t.ID, userID); err != nil {
+		tenantError(c, err, "Error al quitar el tenant")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.tenant_access_revoked")})
+}
+
+// listTemplatesHandler devuelve las plantillas del tenant de la petición en
+// el idioma de ?locale (por defecto el de la petición)
+func listTemplatesHandler(c *gin.Context) {
+	locale, ok := localeParam(c)
+	if !ok {
+		return
+	}
+	list, err := tenant.Templates(db, dbDialect, currentTenant(c).ID, locale)
+	if err != nil {
+		tenantError(c, err, "Error al obtener las plantillas")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"templates": list,
+		"variables": []string{tenant.VarCode, tenant.VarExpires, tenant.VarTenant, tenant.VarLink},
+	})
+}
+
+func saveTemplateHandler(c *gin.Context) {
+	var tpl tenant.Template
+	if err := c.ShouldBindJSON(&tpl); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	tpl.Kind = c.Param("kind")
+	if tpl.Locale == "" {
+		tpl.Locale = i18n.Locale(c.Request.Context())
+	}
+	t := currentTenant(c)
+	audit.SetTarget(c.Request.Context(), "template:"+t.Slug+":"+tpl.Kind+":"+tpl.Locale)
+
+	if err := tenant.SaveTemplate(db, dbDialect, t.ID, &tpl); err != nil {
+		tenantError(c, err, "Error al guardar la plantilla")
+		return
+	}
+	c.JSON(http.StatusOK, tpl)
+}
+
+// resetTemplateHandler vuelve a la plantilla predeterminada del idioma de
+// ?locale
+func resetTemplateHandler(c *gin.Context) {
+	locale, ok := localeParam(c)
+	if !ok {
+		return
+	}
+	t := currentTenant(c)
+	kind := c.Param("kind")
+	audit.SetTarget(c.Request.Context(), "template:"+t.Slug+":"+kind+":"+locale)
+
+	tpl, err := tenant.ResetTemplate(db, dbDialect, t.ID, kind, locale)
+	if err != nil {
+		tenantError(c, err, "Error al restablecer la plantilla")
+		return
+	}
+	c.JSON(http.StatusOK, tpl)
+}
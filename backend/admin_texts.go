@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/i18n"
+)
+
+// textError responde los errores del catálogo de textos; msg describe la
+// operación en el log si el error es interno
+func textError(c *gin.Context, err error, msg string) {
+	if errors.Is(err, i18n.ErrUnknownKey) {
+		apierror.Abort(c, apierror.New(apierror.TextKeyNotFound))
+		return
+	}
+	apierror.Abort(c, apierror.From(fmt.Errorf("%s: %w", msg, err)))
+}
+
+// localeParam lee ?locale; sin él se usa el idioma de la petición
+func localeParam(c *gin.Context) (string, bool) {
+	locale := c.Query("locale")
+	if locale == "" {
+		return i18n.Locale(c.Request.Context()), true
+	}
+	if !i18n.Valid(locale) {
+		apierror.Abort(c, apierror.InvalidField("locale", "use "+strings.Join(i18n.Locales(), " o ")))
+		return "", false
+	}
+	return locale, true
+}
+
+// listTextsHandler devuelve los textos del tenant en el idioma de ?locale;
+// ?prefix filtra por clave ("error.", "msg.")
+func listTextsHandler(c *gin.Context) {
+	locale, ok := localeParam(c)
+	if !ok {
+		return
+	}
+	list, err := i18n.Entries(db, dbDialect, currentTenant(c).ID, locale, c.Query("prefix"))
+	if err != nil {
+		textError(c, err, "Error al obtener los textos")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"locale": locale, "locales": i18n.Locales(), "texts": list})
+}
+
+// saveTextHandler personaliza un texto del tenant
+func saveTextHandler(c *gin.Context) {
+	var request struct {
+		Text string `json:"text"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	t := currentTenant(c)
+	locale, key := c.Param("locale"), c.Param("key")
+	audit.SetTarget(c.Request.Context(), "text:"+t.Slug+":"+locale+":"+key)
+
+	entry, err := i18n.Save(db, dbDialect, t.ID, locale, key, request.Text)
+	if err != nil {
+		textError(c, err, "Error al guardar el texto")
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// resetTextHandler vuelve al texto predeterminado
+func resetTextHandler(c *gin.Context) {
+	t := currentTenant(c)
+	locale, key := c.Param("locale"), c.Param("key")
+	audit.SetTarget(c.Request.Context(), "text:"+t.Slug+":"+locale+":"+key)
+
+	entry, err := i18n.Reset(db, dbDialect, t.ID, locale, key)
+	if err != nil {
+		textError(c, err, "Error al restablecer el texto")
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/auth"
+	"password-recovery/database"
+	"password-recovery/i18n"
+	"password-recovery/logging"
+	"password-recovery/rbac"
+	"password-recovery/secrets"
+	"password-recovery/webhook"
+)
+
+// Estados de una cuenta en users.status
+const (
+	userStatusActive        = "active"
+	userStatusDisabled      = "disabled"
+	userStatusResetRequired = "reset_required" // un admin forzó el cambio de contraseña
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+	minPasswordLength    = 8
+)
+
+// User es la vista de administración de una cuenta (sin contraseña)
+type User struct {
+	ID                 int        `json:"id"`
+	Email              string     `json:"email"`
+	Name               string     `json:"name"`
+	Locale             string     `json:"locale"`
+	Status             string     `json:"status"`
+	Role               string     `json:"role"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	LastPasswordChange *time.Time `json:"last_password_change"`
+	LastLogin          *time.Time `json:"last_login"`
+}
+
+const userColumns = "id, email, name, locale, status, role, created_at, updated_at, last_password_change, last_login"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	var lastChange, lastLogin sql.NullTime
+	if err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Locale, &u.Status, &u.Role, &u.CreatedAt, &u.UpdatedAt, &lastChange, &lastLogin); err != nil {
+		return u, err
+	}
+	if lastChange.Valid {
+		u.LastPasswordChange = &lastChange.Time
+	}
+	if lastLogin.Valid {
+		u.LastLogin = &lastLogin.Time
+	}
+	return u, nil
+}
+
+// normalizeEmail valida y pasa a minúsculas un correo
+func normalizeEmail(email string) (string, bool) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", false
+	}
+	return email, true
+}
+
+// revokeResetCodes invalida los códigos pendientes de un usuario
+func revokeResetCodes(exec database.Execer, userId int) (int64, error) {
+	result, err := exec.Exec(q("DELETE FROM reset_codes WHERE user_id = $1"), userId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// userFromParam carga el usuario de :id del tenant de la petición y
+// responde el error si no existe
+func userFromParam(c *gin.Context) (User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		apierror.Abort(c, apierror.InvalidField("id", "se esperaba un entero positivo"))
+		return User{}, false
+	}
+	audit.SetTarget(c.Request.Context(), "user:"+strconv.Itoa(id))
+
+	u, err := scanUser(db.QueryRow(q("SELECT "+userColumns+" FROM users WHERE id = $1 AND tenant_id = $2"), id, currentTenant(c).ID))
+	if err == sql.ErrNoRows {
+		apierror.Abort(c, apierror.New(apierror.UserNotFound))
+		return User{}, false
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return User{}, false
+	}
+	audit.SetTarget(c.Request.Context(), u.Email)
+	return u, true
+}
+
+// requirePrivilegedAccess responde 403 si u tiene acceso administrativo y
+// quien llama no tiene roles:assign. Cambiar el correo, deshabilitar o
+// borrar una cuenta así permite tomarla o dejarla sin acceso, igual que
+// cambiarle el rol.
+func requirePrivilegedAccess(c *gin.Context, u User) bool {
+	if u.Role == rbac.RoleUser || rbac.Can(c.Request.Context(), rbac.PermRolesAssign) {
+		return true
+	}
+	apierror.Abort(c, apierror.New(apierror.PermissionDenied).With("permission", rbac.PermRolesAssign))
+	return false
+}
+
+// listUsersHandler admite search (parte del correo), status, page y page_size
+func listUsersHandler(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		apierror.Abort(c, apierror.InvalidField("page", "debe ser un entero mayor que 0"))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultUsersPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxUsersPageSize {
+		apierror.Abort(c, apierror.InvalidField("page_size", "debe estar entre 1 y "+strconv.Itoa(maxUsersPageSize)))
+		return
+	}
+
+	conds := []string{"tenant_id = $1"}
+	args := []interface{}{currentTenant(c).ID}
+	if search := strings.TrimSpace(strings.ToLower(c.Query("search"))); search != "" {
+		args = append(args, "%"+search+"%")
+		conds = append(conds, "LOWER(email) LIKE $"+strconv.Itoa(len(args)))
+	}
+	if status := c.Query("status"); status != "" {
+		args = append(args, status)
+		conds = append(conds, "status = $"+strconv.Itoa(len(args)))
+	}
+	if role := c.Query("role"); role != "" {
+		args = append(args, role)
+		conds = append(conds, "role = $"+strconv.Itoa(len(args)))
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	var total int
+	if err := db.QueryRow(q("SELECT COUNT(*) FROM users"+where), args...).Scan(&total); err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+
+	query := "SELECT " + userColumns + " FROM users" + where +
+		" ORDER BY id LIMIT " + strconv.Itoa(pageSize) + " OFFSET " + strconv.Itoa((page-1)*pageSize)
+	rows, err := db.Query(q(query), args...)
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+			return
+		}
+		users = append(users, u)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     users,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func getUserHandler(c *gin.Context) {
+	if u, ok := userFromParam(c); ok {
+		c.JSON(http.StatusOK, u)
+	}
+}
+
+func createUserHandler(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role"`
+		// Locale es el idioma de los correos de la cuenta (vacío: el de
+		// cada solicitud)
+		Locale string `json:"locale"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.Invalid(map[string]string{"email": "obligatorio", "password": "obligatorio"}))
+		return
+	}
+
+	email, ok := normalizeEmail(request.Email)
+	if !ok {
+		apierror.Abort(c, apierror.InvalidField("email", "correo inválido"))
+		return
+	}
+	audit.SetTarget(c.Request.Context(), email)
+	if len(request.Password) < minPasswordLength {
+		apierror.Abort(c, apierror.InvalidField("password", "mínimo "+strconv.Itoa(minPasswordLength)+" caracteres"))
+		return
+	}
+	if request.Locale != "" && !i18n.Valid(request.Locale) {
+		apierror.Abort(c, apierror.InvalidField("locale", "use "+strings.Join(i18n.Locales(), " o ")))
+		return
+	}
+	if request.Role == "" {
+		request.Role = rbac.RoleUser
+	}
+	if !rbac.ValidAccountRole(request.Role) {
+		apierror.Abort(c, apierror.New(apierror.RoleInvalid).With("roles", rbac.AccountRoles()))
+		return
+	}
+	// Crear cuentas con acceso administrativo equivale a asignar un rol
+	if request.Role != rbac.RoleUser && !rbac.Can(c.Request.Context(), rbac.PermRolesAssign) {
+		apierror.Abort(c, apierror.New(apierror.PermissionDenied).With("permission", rbac.PermRolesAssign))
+		return
+	}
+
+	var exists int
+	if err := db.QueryRow(q("SELECT COUNT(*) FROM users WHERE tenant_id = $1 AND email = $2"), currentTenant(c).ID, email).Scan(&exists); err != nil || exists > 0 {
+		if err != nil {
+			apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		} else {
+			apierror.Abort(c, apierror.New(apierror.EmailTaken))
+		}
+		return
+	}
+
+	hash, err := secrets.HashPassword(request.Password)
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	now := time.Now().UTC()
+	id, err := database.InsertID(db, dbDialect,
+		"INSERT INTO users (tenant_id, email, password, status, role, locale, last_password_change, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		currentTenant(c).ID, email, hash, userStatusActive, request.Role, request.Locale, now, now, now)
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+
+	u, err := scanUser(db.QueryRow(q("SELECT "+userColumns+" FROM users WHERE id = $1"), id))
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	c.JSON(http.StatusCreated, u)
+}
+
+// updateUserHandler cambia el correo. Los códigos pendientes se enviaron a
+// la dirección anterior, así que se revocan.
+func updateUserHandler(c *gin.Context) {
+	u, ok := userFromParam(c)
+	if !ok || !requirePrivilegedAccess(c, u) {
+		return
+	}
+
+	var request struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.InvalidField("email", "obligatorio"))
+		return
+	}
+	email, valid := normalizeEmail(request.Email)
+	if !valid {
+		apierror.Abort(c, apierror.InvalidField("email", "correo inválido"))
+		return
+	}
+	if email == u.Email {
+		c.JSON(http.StatusOK, u)
+		return
+	}
+
+	var exists int
+	if err := db.QueryRow(q("SELECT COUNT(*) FROM users WHERE tenant_id = $1 AND email = $2"), currentTenant(c).ID, email).Scan(&exists); err == nil && exists > 0 {
+		apierror.Abort(c, apierror.New(apierror.EmailTaken))
+		return
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(q("UPDATE users SET email = $1, updated_at = $2 WHERE id = $3"), email, time.Now().UTC(), u.ID); err != nil {
+			return err
+		}
+		_, err := revokeResetCodes(tx, u.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+
+	u.Email = email
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.user_updated"), "user": u})
+}
+
+// setUserStatusHandler habilita o deshabilita una cuenta; deshabilitar
+// revoca los códigos pendientes
+func setUserStatusHandler(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := userFromParam(c)
+		if !ok || !requirePrivilegedAccess(c, u) {
+			return
+		}
+		if status == userStatusDisabled && wouldRemoveLastAdmin(currentTenant(c).ID, u) {
+			apierror.Abort(c, apierror.New(apierror.LastAdmin))
+			return
+		}
+
+		var revoked int64
+		err := withTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(q("UPDATE users SET status = $1, updated_at = $2 WHERE id = $3"), status, time.Now().UTC(), u.ID); err != nil {
+				return err
+			}
+			if status != userStatusDisabled {
+				return nil
+			}
+			if err := auth.RevokeUserSessions(tx, dbDialect, int64(u.ID)); err != nil {
+				return err
+			}
+			var err error
+			revoked, err = revokeResetCodes(tx, u.ID)
+			return err
+		})
+		if err != nil {
+			apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+			return
+		}
+		if status == userStatusDisabled {
+			p, _ := rbac.PrincipalFrom(c.Request.Context())
+			webhook.Publish(currentTenant(c).ID, webhook.EventAccountLocked, map[string]interface{}{
+				"user_id":       u.ID,
+				"email":         u.Email,
+				"by":            p.Subject,
+				"revoked_codes": revoked,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       i18n.Text(c.Request.Context(), "msg.user_status_updated"),
+			"status":        status,
+			"revoked_codes": revoked,
+		})
+	}
+}
+
+func deleteUserHandler(c *gin.Context) {
+	u, ok := userFromParam(c)
+	if !ok || !requirePrivilegedAccess(c, u) {
+		return
+	}
+	if wouldRemoveLastAdmin(currentTenant(c).ID, u) {
+		apierror.Abort(c, apierror.New(apierror.LastAdmin))
+		return
+	}
+
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := revokeResetCodes(tx, u.ID); err != nil {
+			return err
+		}
+		if err := auth.RevokeUserSessions(tx, dbDialect, int64(u.ID)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(q("DELETE FROM invitations WHERE user_id = $1"), u.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(q("DELETE FROM users WHERE id = $1"), u.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.user_deleted")})
+}
+
+// forceResetHandler marca la cuenta para cambio de contraseña, revoca los
+// códigos anteriores y envía uno nuevo
+func forceResetHandler(c *gin.Context) {
+	u, ok := userFromParam(c)
+	if !ok {
+		return
+	}
+	if u.Status == userStatusDisabled {
+		apierror.Abort(c, apierror.New(apierror.UserDisabled))
+		return
+	}
+
+	var revoked int64
+	err := withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(q("UPDATE users SET status = $1, updated_at = $2 WHERE id = $3"), userStatusResetRequired, time.Now().UTC(), u.ID); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = revokeResetCodes(tx, u.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+
+	if err := issueResetCode(currentTenant(c), u.ID, u.Email, "", i18n.Default); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("No se pudo enviar el código forzado", "email", logging.Email(u.Email))
+		apierror.Abort(c, apierror.Wrap(apierror.ResetCodeNotSent, err).With("revoked_codes", revoked))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       i18n.Text(c.Request.Context(), "msg.reset_code_sent"),
+		"status":        userStatusResetRequired,
+		"revoked_codes": revoked,
+	})
+}
+
+// revokeResetCodesHandler invalida los códigos pendientes sin tocar la
+// cuenta (por ejemplo si se envió un código a un correo equivocado)
+func revokeResetCodesHandler(c *gin.Context) {
+	u, ok := userFromParam(c)
+	if !ok {
+		return
+	}
+	revoked, err := revokeResetCodes(db, u.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       i18n.Text(c.Request.Context(), "msg.reset_codes_revoked"),
+		"revoked_codes": revoked,
+	})
+}
+
+// withTx ejecuta fn en una transacción
+func withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/i18n"
+	"password-recovery/webhook"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+// webhookError responde los errores de validación con sus campos; msg
+// describe la operación en el log si el error es interno
+func webhookError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		apierror.Abort(c, apierror.New(apierror.WebhookNotFound))
+	case errors.Is(err, webhook.ErrDeliveryNotFound):
+		apierror.Abort(c, apierror.New(apierror.DeliveryNotFound))
+	default:
+		apierror.Abort(c, apierror.From(fmt.Errorf("%s: %w", msg, err)))
+	}
+}
+
+// webhookFromParam carga la suscripción :id del tenant de la petición
+func webhookFromParam(c *gin.Context) (*webhook.Webhook, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		apierror.Abort(c, apierror.InvalidField("id", "se esperaba un entero positivo"))
+		return nil, false
+	}
+	audit.SetTarget(c.Request.Context(), "webhook:"+strconv.FormatInt(id, 10))
+	w, err := webhook.Get(db, dbDialect, currentTenant(c).ID, id)
+	if err != nil {
+		webhookError(c, err, "Error al obtener el webhook")
+		return nil, false
+	}
+	audit.SetTarget(c.Request.Context(), "webhook:"+strconv.FormatInt(w.ID, 10)+":"+w.URL)
+	return w, true
+}
+
+func listWebhooksHandler(c *gin.Context) {
+	list, err := webhook.List(db, dbDialect, currentTenant(c).ID)
+	if err != nil {
+		webhookError(c, err, "Error al listar los webhooks")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": list, "events": webhook.EventTypes()})
+}
+
+// createWebhookHandler crea la suscripción; el secreto se muestra solo aquí
+func createWebhookHandler(c *gin.Context) {
+	var request struct {
+		URL         string   `json:"url"`
+		Description string   `json:"description"`
+		Events      []string `json:"events"`
+		IsActive    *bool    `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	audit.SetTarget(c.Request.Context(), "webhook:"+request.URL)
+
+	w := &webhook.Webhook{
+		TenantID:    currentTenant(c).ID,
+		URL:         request.URL,
+		Description: request.Description,
+		Events:      request.Events,
+		IsActive:    request.IsActive == nil || *request.IsActive,
+	}
+	secret, err := webhook.Create(db, dbDialect, w)
+	if err != nil {
+		webhookError(c, err, "Error al crear el webhook")
+		return
+	}
+	audit.SetTarget(c.Request.Context(), "webhook:"+strconv.FormatInt(w.ID, 10)+":"+w.URL)
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.Text(c.Request.Context(), "msg.webhook_secret_created"),
+		"secret":  secret,
+		"webhook": w,
+	})
+}
+
+func getWebhookHandler(c *gin.Context) {
+	if w, ok := webhookFromParam(c); ok {
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// updateWebhookHandler cambia solo los campos presentes en el cuerpo
+func updateWebhookHandler(c *gin.Context) {
+	w, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		URL         *string   `json:"url"`
+		Description *string   `json:"description"`
+		Events      *[]string `json:"events"`
+		IsActive    *bool     `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	if request.URL != nil {
+		w.URL = *request.URL
+	}
+	if request.Description != nil {
+		w.Description = *request.Description
+	}
+	if request.Events != nil {
+		w.Events = *request.Events
+	}
+	if request.IsActive != nil {
+		w.IsActive = *request.IsActive
+	}
+
+	if err := webhook.Update(db, dbDialect, w); err != nil {
+		webhookError(c, err, "Error al actualizar el webhook")
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func deleteWebhookHandler(c *gin.Context) {
+	w, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+	if err := webhook.Delete(db, dbDialect, w); err != nil {
+		webhookError(c, err, "Error al eliminar el webhook")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.webhook_deleted")})
+}
+
+func rotateWebhookSecretHandler(c *gin.Context) {
+	w, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+	secret, err := webhook.RotateSecret(db, dbDialect, w)
+	if err != nil {
+		webhookError(c, err, "Error al rotar el secreto")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Text(c.Request.Context(), "msg.webhook_secret_created"),
+		"secret":  secret,
+		"webhook": w,
+	})
+}
+
+// listWebhookDeliveriesHandler devuelve el historial de entregas, las más
+// recientes primero (?status=pending|delivered|failed&limit=50)
+func listWebhookDeliveriesHandler(c *gin.Context) {
+	w, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDeliveriesLimit)))
+	if err != nil || limit < 1 || limit > maxDeliveriesLimit {
+		apierror.Abort(c, apierror.InvalidField("limit", "debe estar entre 1 y "+strconv.Itoa(maxDeliveriesLimit)))
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", webhook.DeliveryPending, webhook.DeliveryDelivered, webhook.DeliveryFailed:
+	default:
+		apierror.Abort(c, apierror.InvalidField("status", "valor no permitido (use pending, delivered o failed)"))
+		return
+	}
+
+	list, err := webhook.Deliveries(db, dbDialect, w.ID, status, limit)
+	if err != nil {
+		webhookError(c, err, "Error al obtener las entregas")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhook_id": w.ID, "deliveries": list})
+}
+
+// replayWebhookDeliveryHandler vuelve a enviar el evento de una entrega
+func replayWebhookDeliveryHandler(c *gin.Context) {
+	w, ok := webhookFromParam(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		apierror.Abort(c, apierror.InvalidField("delivery_id", "se esperaba un entero positivo"))
+		return
+	}
+	dl, err := webhook.GetDelivery(db, dbDialect, w.ID, deliveryID)
+	if err != nil {
+		webhookError(c, err, "Error al obtener la entrega")
+		return
+	}
+	audit.SetTarget(c.Request.Context(), "webhook:"+strconv.FormatInt(w.ID, 10)+":"+dl.EventID)
+
+	id, err := webhook.Replay(db, dbDialect, dl)
+	if err != nil {
+		webhookError(c, err, "Error al reenviar el evento")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     i18n.Text(c.Request.Context(), "msg.delivery_requeued"),
+		"delivery_id": id,
+		"event_id":    dl.EventID,
+	})
+}
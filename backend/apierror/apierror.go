@@ -0,0 +1,108 @@
+// Package apierror define el formato único de los errores de las dos APIs
+// (gin y gorilla/mux). Cada error tiene un código estable (RESET_CODE_EXPIRED)
+// con su estado HTTP y su mensaje en el catálogo, y opcionalmente los
+// errores por campo y datos adicionales que el cliente puede usar:
+//
+//	{"error": "El código expiró; solicite uno nuevo", "code": "RESET_CODE_EXPIRED",
+//	 "status": 410, "request_id": "…"}
+//
+// "error" sigue siendo el mensaje legible, como antes, en el idioma de la
+// petición (i18n). La causa interna de un error (el error de la base de
+// datos, del servidor SMTP) solo se escribe en el log de la petición; nunca
+// llega a la respuesta.
+package apierror
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"password-recovery/fielderr"
+)
+
+// Error es un error de la API listo para responder
+type Error struct {
+	Code    Code
+	Fields  map[string]string
+	Details map[string]interface{}
+	cause   error
+}
+
+// New crea el error con el estado y el mensaje del catálogo
+func New(code Code) *Error {
+	return &Error{Code: code}
+}
+
+// Wrap crea el error guardando la causa, que solo se escribe en el log
+func Wrap(code Code, cause error) *Error {
+	return &Error{Code: code, cause: cause}
+}
+
+// Invalid es INVALID_INPUT con los errores por campo
+func Invalid(fields map[string]string) *Error {
+	return &Error{Code: InvalidInput, Fields: fields}
+}
+
+// InvalidField es Invalid con un solo campo
+func InvalidField(field, msg string) *Error {
+	return Invalid(map[string]string{field: msg})
+}
+
+// BadJSON es el error de un cuerpo que no se pudo decodificar
+func BadJSON(cause error) *Error {
+	return &Error{Code: InvalidInput, Fields: map[string]string{"body": "JSON mal formado"}, cause: cause}
+}
+
+// With agrega un dato adicional a la respuesta ("permission", "roles")
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Status es el estado HTTP del código
+func (e *Error) Status() int {
+	return Lookup(e.Code).Status
+}
+
+// Message es el mensaje del código
+func (e *Error) Message() string {
+	return Lookup(e.Code).Message
+}
+
+func (e *Error) Error() string {
+	msg := string(e.Code) + ": " + e.Message()
+	if len(e.Fields) > 0 {
+		parts := make([]string, 0, len(e.Fields))
+		for field, m := range e.Fields {
+			parts = append(parts, field+": "+m)
+		}
+		sort.Strings(parts)
+		msg += " (" + strings.Join(parts, "; ") + ")"
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// From convierte cualquier error en un *Error: los *Error se devuelven
+// tal cual, los *fielderr.Error se vuelven INVALID_INPUT y el resto
+// INTERNAL_ERROR con el error original como causa
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var verr *fielderr.Error
+	if errors.As(err, &verr) {
+		return &Error{Code: InvalidInput, Fields: verr.Fields, cause: err}
+	}
+	return Wrap(Internal, err)
+}
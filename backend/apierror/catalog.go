@@ -0,0 +1,225 @@
+package apierror
+
+import (
+	"net/http"
+	"sort"
+
+	"password-recovery/i18n"
+)
+
+// Code identifica un error; es estable y los clientes pueden compararlo
+type Code string
+
+// Entry es la definición de un código en el catálogo. Message está en el
+// idioma por defecto; los demás idiomas (y los textos que personalice cada
+// tenant) están en i18n con la clave "error.<CODE>".
+type Entry struct {
+	Code    Code   `json:"code"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// Generales
+const (
+	InvalidInput         Code = "INVALID_INPUT"
+	PayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	RouteNotFound        Code = "ROUTE_NOT_FOUND"
+	MethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	Internal             Code = "INTERNAL_ERROR"
+	Unavailable          Code = "SERVICE_UNAVAILABLE"
+)
+
+// Sesiones y permisos
+const (
+	AuthRequired       Code = "AUTH_REQUIRED"
+	SessionInvalid     Code = "SESSION_INVALID"
+	InvalidCredentials Code = "INVALID_CREDENTIALS"
+	PermissionDenied   Code = "PERMISSION_DENIED"
+	NotAdminAccount    Code = "NOT_ADMIN_ACCOUNT"
+	TenantAccessDenied Code = "TENANT_ACCESS_DENIED"
+	ScopeNotHeld       Code = "SCOPE_NOT_HELD"
+)
+
+// Tenants
+const (
+	TenantNotFound      Code = "TENANT_NOT_FOUND"
+	TenantDisabled      Code = "TENANT_DISABLED"
+	TenantAPIKeyInvalid Code = "TENANT_API_KEY_INVALID"
+	TenantMismatch      Code = "TENANT_MISMATCH"
+	RedirectNotAllowed  Code = "REDIRECT_NOT_ALLOWED"
+	TemplateNotFound    Code = "TEMPLATE_NOT_FOUND"
+	TextKeyNotFound     Code = "TEXT_KEY_NOT_FOUND"
+	AdminRoleRequired   Code = "ADMIN_ROLE_REQUIRED"
+	TenantMember        Code = "TENANT_MEMBER"
+)
+
+// API keys de servicio
+const (
+	APIKeyInvalid     Code = "API_KEY_INVALID"
+	APIKeyNotFound    Code = "API_KEY_NOT_FOUND"
+	APIKeyInactive    Code = "API_KEY_INACTIVE"
+	SignatureRequired Code = "SIGNATURE_REQUIRED"
+	SignatureInvalid  Code = "SIGNATURE_INVALID"
+	SignatureExpired  Code = "SIGNATURE_EXPIRED"
+	SignatureReplayed Code = "SIGNATURE_REPLAYED"
+)
+
+// Recuperación de contraseña
+const (
+	EmailNotFound     Code = "EMAIL_NOT_FOUND"
+	AccountDisabled   Code = "ACCOUNT_DISABLED"
+	ResetCodeInvalid  Code = "RESET_CODE_INVALID"
+	ResetCodeExpired  Code = "RESET_CODE_EXPIRED"
+	ResetCodeMismatch Code = "RESET_CODE_MISMATCH"
+	ResetCodeNotSent  Code = "RESET_CODE_NOT_SENT"
+	EmailSendFailed   Code = "EMAIL_SEND_FAILED"
+	ChallengeRequired Code = "CHALLENGE_REQUIRED"
+	ChallengeInvalid  Code = "CHALLENGE_INVALID"
+	ChallengeExpired  Code = "CHALLENGE_EXPIRED"
+	ChallengeUsed     Code = "CHALLENGE_USED"
+)
+
+// Administración de usuarios
+const (
+	UserNotFound      Code = "USER_NOT_FOUND"
+	UserDisabled      Code = "USER_DISABLED"
+	EmailTaken        Code = "EMAIL_TAKEN"
+	RoleInvalid       Code = "ROLE_INVALID"
+	LastAdmin         Code = "LAST_ADMIN"
+	ImportInvalidFile Code = "IMPORT_INVALID_FILE"
+	ImportInvalidRows Code = "IMPORT_INVALID_ROWS"
+)
+
+// SMTP y webhooks
+const (
+	SMTPNotConfigured  Code = "SMTP_NOT_CONFIGURED"
+	SMTPConnectFailed  Code = "SMTP_CONNECT_FAILED"
+	SMTPTLSFailed      Code = "SMTP_TLS_FAILED"
+	SMTPAuthFailed     Code = "SMTP_AUTH_FAILED"
+	SMTPSenderRejected Code = "SMTP_SENDER_REJECTED"
+	WebhookNotFound    Code = "WEBHOOK_NOT_FOUND"
+	DeliveryNotFound   Code = "DELIVERY_NOT_FOUND"
+)
+
+// Asistente de setup y destinos externos
+const (
+	SetupLocked        Code = "SETUP_LOCKED"
+	DBNotConfigured    Code = "DB_NOT_CONFIGURED"
+	DBTablesMissing    Code = "DB_TABLES_MISSING"
+	DBConnectionFailed Code = "DB_CONNECTION_FAILED"
+	ConfigSaveFailed   Code = "CONFIG_SAVE_FAILED"
+	ConfigResetFailed  Code = "CONFIG_RESET_FAILED"
+	ConnectorSchema    Code = "CONNECTOR_SCHEMA_MISMATCH"
+	ConnectorAmbiguous Code = "CONNECTOR_AMBIGUOUS_ACCOUNT"
+	LDAPInsecure       Code = "LDAP_INSECURE"
+	LDAPAmbiguous      Code = "LDAP_AMBIGUOUS_ACCOUNT"
+	LDAPCheckFailed    Code = "LDAP_CHECK_FAILED"
+)
+
+var catalog = map[Code]Entry{}
+
+func define(code Code, status int, es, en string) {
+	catalog[code] = Entry{Code: code, Status: status, Message: es}
+	i18n.Define(MessageKey(code), es, en)
+}
+
+// MessageKey es la clave del mensaje del código en i18n
+func MessageKey(code Code) string {
+	return "error." + string(code)
+}
+
+func init() {
+	define(InvalidInput, http.StatusBadRequest, "Datos inválidos", "Invalid input")
+	define(PayloadTooLarge, http.StatusRequestEntityTooLarge, "El cuerpo de la petición es demasiado grande", "The request body is too large")
+	define(UnsupportedMediaType, http.StatusUnsupportedMediaType, "Content-Type no soportado", "Unsupported Content-Type")
+	define(RouteNotFound, http.StatusNotFound, "La ruta no existe", "The route does not exist")
+	define(MethodNotAllowed, http.StatusMethodNotAllowed, "Método no permitido en esta ruta", "Method not allowed on this route")
+	define(Internal, http.StatusInternalServerError, "Error interno del servidor", "Internal server error")
+	define(Unavailable, http.StatusServiceUnavailable, "El servicio no está disponible; intente más tarde", "The service is unavailable; try again later")
+
+	define(AuthRequired, http.StatusUnauthorized, "Falta el token de sesión", "Missing session token")
+	define(SessionInvalid, http.StatusUnauthorized, "Sesión inválida o vencida", "Invalid or expired session")
+	define(InvalidCredentials, http.StatusUnauthorized, "Credenciales inválidas", "Invalid credentials")
+	define(PermissionDenied, http.StatusForbidden, "Permiso insuficiente", "Insufficient permission")
+	define(NotAdminAccount, http.StatusForbidden, "La cuenta no tiene acceso administrativo", "The account has no administrative access")
+	define(TenantAccessDenied, http.StatusForbidden, "Sin acceso a este tenant", "No access to this tenant")
+	define(ScopeNotHeld, http.StatusForbidden, "No puede otorgar un permiso que no tiene", "You cannot grant a permission you do not hold")
+
+	define(TenantNotFound, http.StatusNotFound, "Tenant no encontrado", "Tenant not found")
+	define(TenantDisabled, http.StatusForbidden, "Tenant deshabilitado", "Tenant disabled")
+	define(TenantAPIKeyInvalid, http.StatusUnauthorized, "API key de tenant inválida", "Invalid tenant API key")
+	define(TenantMismatch, http.StatusBadRequest, "X-Tenant no corresponde a la API key", "X-Tenant does not match the API key")
+	define(RedirectNotAllowed, http.StatusBadRequest, "redirect_url no permitida para este tenant", "redirect_url is not allowed for this tenant")
+	define(TemplateNotFound, http.StatusNotFound, "Tipo de plantilla desconocido", "Unknown template type")
+	define(TextKeyNotFound, http.StatusNotFound, "Clave de texto desconocida", "Unknown text key")
+	define(AdminRoleRequired, http.StatusBadRequest, "La cuenta no tiene un rol administrativo", "The account has no administrative role")
+	define(TenantMember, http.StatusConflict, "La cuenta pertenece a este tenant", "The account belongs to this tenant")
+
+	define(APIKeyInvalid, http.StatusUnauthorized, "API key inválida, revocada o vencida", "Invalid, revoked or expired API key")
+	define(APIKeyNotFound, http.StatusNotFound, "API key no encontrada", "API key not found")
+	define(APIKeyInactive, http.StatusConflict, "La llave está revocada o vencida", "The key is revoked or expired")
+	define(SignatureRequired, http.StatusUnauthorized, "La llave exige firma", "The key requires a signature")
+	define(SignatureInvalid, http.StatusUnauthorized, "Firma inválida", "Invalid signature")
+	define(SignatureExpired, http.StatusUnauthorized, "Marca de tiempo fuera de la ventana permitida", "Timestamp outside the allowed window")
+	define(SignatureReplayed, http.StatusUnauthorized, "La firma ya se utilizó", "The signature was already used")
+
+	define(EmailNotFound, http.StatusNotFound, "Correo no encontrado", "Email not found")
+	define(AccountDisabled, http.StatusForbidden, "Cuenta deshabilitada", "Account disabled")
+	define(ResetCodeInvalid, http.StatusNotFound, "Código no válido", "Invalid code")
+	define(ResetCodeExpired, http.StatusGone, "El código expiró; solicite uno nuevo", "The code expired; request a new one")
+	define(ResetCodeMismatch, http.StatusBadRequest, "Correo y código no coinciden", "Email and code do not match")
+	define(ResetCodeNotSent, http.StatusBadGateway, "La cuenta quedó marcada para restablecer, pero no se pudo enviar el código", "The account was flagged for reset, but the code could not be sent")
+	define(EmailSendFailed, http.StatusInternalServerError, "Error al enviar el correo", "Error sending the email")
+	define(ChallengeRequired, http.StatusForbidden, "Resuelva el reto antes de solicitar el código", "Solve the challenge before requesting the code")
+	define(ChallengeInvalid, http.StatusForbidden, "La solución del reto no es válida", "The challenge solution is not valid")
+	define(ChallengeExpired, http.StatusGone, "El reto expiró; solicite uno nuevo", "The challenge expired; request a new one")
+	define(ChallengeUsed, http.StatusConflict, "El reto ya se utilizó; solicite uno nuevo", "The challenge was already used; request a new one")
+
+	define(UserNotFound, http.StatusNotFound, "Usuario no encontrado", "User not found")
+	define(UserDisabled, http.StatusConflict, "La cuenta está deshabilitada", "The account is disabled")
+	define(EmailTaken, http.StatusConflict, "El correo ya está registrado", "The email is already registered")
+	define(RoleInvalid, http.StatusBadRequest, "Rol inválido", "Invalid role")
+	define(LastAdmin, http.StatusConflict, "Debe quedar al menos un administrador activo", "At least one active administrator must remain")
+	define(ImportInvalidFile, http.StatusBadRequest, "El archivo de importación no es válido", "The import file is not valid")
+	define(ImportInvalidRows, http.StatusUnprocessableEntity, "Hay filas inválidas; no se guardó nada", "There are invalid rows; nothing was saved")
+
+	define(SMTPNotConfigured, http.StatusNotFound, "No hay configuración SMTP activa", "There is no active SMTP configuration")
+	define(SMTPConnectFailed, http.StatusBadGateway, "No se pudo conectar al servidor SMTP; verifique el host y el puerto", "Could not connect to the SMTP server; check the host and port")
+	define(SMTPTLSFailed, http.StatusBadGateway, "Falló la negociación TLS con el servidor SMTP", "TLS negotiation with the SMTP server failed")
+	define(SMTPAuthFailed, http.StatusBadGateway, "El servidor SMTP rechazó el usuario o la contraseña", "The SMTP server rejected the username or password")
+	define(SMTPSenderRejected, http.StatusBadGateway, "El servidor SMTP rechazó la dirección del remitente", "The SMTP server rejected the sender address")
+	define(WebhookNotFound, http.StatusNotFound, "Webhook no encontrado", "Webhook not found")
+	define(DeliveryNotFound, http.StatusNotFound, "Entrega no encontrada", "Delivery not found")
+
+	define(SetupLocked, http.StatusForbidden, "El sistema ya tiene configuración de DB", "The system already has a DB configuration")
+	define(DBNotConfigured, http.StatusConflict, "La conexión a la base de datos no está configurada", "The database connection is not configured")
+	define(DBTablesMissing, http.StatusConflict, "Las tablas de la base de datos no se han creado", "The database tables have not been created")
+	define(DBConnectionFailed, http.StatusBadGateway, "No se pudo conectar a la base de datos con esa configuración", "Could not connect to the database with that configuration")
+	define(ConfigSaveFailed, http.StatusInternalServerError, "No se pudo guardar la configuración", "Could not save the configuration")
+	define(ConfigResetFailed, http.StatusInternalServerError, "No se pudo eliminar la configuración", "Could not delete the configuration")
+	define(ConnectorSchema, http.StatusBadRequest, "La tabla o las columnas del conector no existen", "The connector table or columns do not exist")
+	define(ConnectorAmbiguous, http.StatusConflict, "El identificador corresponde a más de una cuenta en la tabla del conector", "The identifier matches more than one account in the connector table")
+	define(LDAPInsecure, http.StatusBadRequest, "Active Directory solo acepta cambios de contraseña sobre LDAPS o StartTLS", "Active Directory only accepts password changes over LDAPS or StartTLS")
+	define(LDAPAmbiguous, http.StatusConflict, "El correo corresponde a más de una cuenta en el directorio", "The email matches more than one account in the directory")
+	define(LDAPCheckFailed, http.StatusBadGateway, "No se pudo conectar, autenticar o leer el directorio LDAP", "Could not connect to, authenticate against or read the LDAP directory")
+}
+
+// Lookup devuelve la definición del código; un código desconocido se
+// trata como INTERNAL_ERROR
+func Lookup(code Code) Entry {
+	if e, ok := catalog[code]; ok {
+		return e
+	}
+	return catalog[Internal]
+}
+
+// Catalog lista todos los códigos ordenados
+func Catalog() []Entry {
+	list := make([]Entry, 0, len(catalog))
+	for _, e := range catalog {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
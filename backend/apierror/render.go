@@ -0,0 +1,77 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/i18n"
+	"password-recovery/logging"
+)
+
+// Body es el cuerpo de todas las respuestas de error
+type Body struct {
+	Error     string                 `json:"error"`
+	Code      Code                   `json:"code"`
+	Status    int                    `json:"status"`
+	Fields    map[string]string      `json:"fields,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// body arma la respuesta y deja la causa de los errores 5xx en el log
+func body(ctx context.Context, header http.Header, err error) Body {
+	e := From(err)
+	entry := Lookup(e.Code)
+	if entry.Status >= http.StatusInternalServerError && e.cause != nil {
+		logging.FromContext(ctx).Error(entry.Message, "error_code", entry.Code, "error", e.cause)
+	}
+	return Body{
+		Error:     i18n.Text(ctx, MessageKey(entry.Code)),
+		Code:      entry.Code,
+		Status:    entry.Status,
+		Fields:    e.Fields,
+		Details:   e.Details,
+		RequestID: header.Get(logging.RequestIDHeader),
+	}
+}
+
+// Abort responde el error en gin y detiene la cadena de handlers
+func Abort(c *gin.Context, err error) {
+	b := body(c.Request.Context(), c.Writer.Header(), err)
+	c.AbortWithStatusJSON(b.Status, b)
+}
+
+// Write responde el error con net/http (servidor de setup)
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	b := body(r.Context(), w.Header(), err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(b.Status)
+	json.NewEncoder(w).Encode(b)
+}
+
+// CatalogHandler sirve la lista de códigos en /errors con los mensajes en
+// el idioma de la petición
+func CatalogHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := Catalog()
+		for i := range list {
+			list[i].Message = i18n.Text(r.Context(), MessageKey(list[i].Code))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"locale": i18n.Locale(r.Context()), "errors": list})
+	}
+}
+
+// NotFound responde ROUTE_NOT_FOUND; sirve para gin.NoRoute y para el
+// NotFoundHandler de gorilla/mux
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(RouteNotFound))
+}
+
+// NotAllowed responde METHOD_NOT_ALLOWED
+func NotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, New(MethodNotAllowed))
+}
@@ -0,0 +1,129 @@
+// Package apikey administra las llaves con las que otros servicios llaman a
+// la API (header X-API-Key) y la firma HMAC opcional de esas peticiones.
+//
+// Cada llave pertenece a un tenant y sus alcances son permisos de rbac. Solo
+// se guarda el hash de la llave; el secreto de firma se guarda en claro,
+// como la contraseña SMTP, porque el servidor lo necesita para verificar.
+package apikey
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"password-recovery/fielderr"
+	"password-recovery/rbac"
+)
+
+const (
+	// Prefix distingue estas llaves de las de tenant (tenant.APIKeyPrefix)
+	Prefix = "rk_"
+	// SecretPrefix identifica los secretos de firma
+	SecretPrefix = "rs_"
+
+	// principalRole es el rol que se muestra para una llave; sus permisos
+	// son los alcances, no los de un rol
+	principalRole = "api_key"
+)
+
+var (
+	ErrNotFound = errors.New("API key no encontrada")
+	ErrInvalid  = errors.New("API key inválida, revocada o vencida")
+)
+
+// Key es una llave de servicio. El valor de la llave y el secreto de firma
+// solo se devuelven al crearla o rotarla.
+type Key struct {
+	ID       int64  `json:"id"`
+	TenantID int64  `json:"tenant_id"`
+	Name     string `json:"name"`
+	// Prefix es la parte pública de la llave (rk_xxxxxxxx) para
+	// reconocerla en listados y logs
+	Prefix           string            `json:"prefix"`
+	Scopes           []rbac.Permission `json:"scopes"`
+	RequireSignature bool              `json:"require_signature"`
+	ExpiresAt        *time.Time        `json:"expires_at"`
+	LastUsedAt       *time.Time        `json:"last_used_at"`
+	CreatedBy        int64             `json:"created_by,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	RevokedAt        *time.Time        `json:"revoked_at,omitempty"`
+
+	signingSecret string
+}
+
+// Grantable son los alcances que se pueden dar a una llave. La
+// administración de llaves, roles, tenants y configuración queda fuera.
+func Grantable() []rbac.Permission {
+	return []rbac.Permission{
+		rbac.PermRecoverySend,
+		rbac.PermUsersRead, rbac.PermUsersWrite, rbac.PermUsersReset,
+		rbac.PermAuditRead,
+	}
+}
+
+func grantable(scope rbac.Permission) bool {
+	for _, s := range Grantable() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize limpia el nombre y ordena los alcances sin repetidos
+func (k *Key) Normalize() {
+	k.Name = strings.TrimSpace(k.Name)
+	seen := map[rbac.Permission]bool{}
+	scopes := []rbac.Permission{}
+	for _, s := range k.Scopes {
+		s = rbac.Permission(strings.TrimSpace(strings.ToLower(string(s))))
+		if s != "" && !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i] < scopes[j] })
+	k.Scopes = scopes
+}
+
+// Validate revisa la llave (después de Normalize)
+func (k *Key) Validate(now time.Time) error {
+	fields := map[string]string{}
+	if k.Name == "" || len(k.Name) > 100 {
+		fields["name"] = "es obligatorio (máximo 100 caracteres)"
+	}
+	if len(k.Scopes) == 0 {
+		fields["scopes"] = "indique al menos un alcance"
+	}
+	for _, s := range k.Scopes {
+		if !grantable(s) {
+			fields["scopes"] = "alcance no permitido: " + string(s)
+			break
+		}
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		fields["expires_at"] = "debe ser una fecha futura"
+	}
+	if len(fields) > 0 {
+		return fielderr.New(fields)
+	}
+	return nil
+}
+
+// Active indica si la llave se puede usar
+func (k *Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Principal es la identidad con la que la llave hace peticiones: solo sus
+// alcances y solo su tenant
+func (k *Key) Principal() rbac.Principal {
+	scopes := append([]rbac.Permission{}, k.Scopes...)
+	return rbac.Principal{
+		Subject: "api-key:" + k.Prefix + " (" + k.Name + ")",
+		Role:    principalRole,
+		Tenants: []int64{k.TenantID},
+		Scopes:  scopes,
+	}
+}
@@ -0,0 +1,135 @@
+package apikey
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/apierror"
+	"password-recovery/database"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// MaxSignedBody es el tamaño máximo del cuerpo de una petición firmada
+const MaxSignedBody = 1 << 20
+
+// Gin autentica las peticiones que traen una API key (rk_...) y exige los
+// alcances indicados. Deja en el contexto el tenant de la llave y su
+// principal, que auth usa en lugar de la sesión. Las peticiones sin llave,
+// o con la llave de tenant (rt_...), pasan sin cambios.
+func Gin(v *Verifier, scopes ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(tenant.HeaderAPIKey))
+		if !strings.HasPrefix(raw, Prefix) {
+			c.Next()
+			return
+		}
+
+		k, t, err := authenticate(v, c.Request, raw)
+		if err != nil {
+			apierror.Abort(c, apiError(err))
+			return
+		}
+		p := k.Principal()
+		for _, scope := range scopes {
+			if !p.Can(scope) {
+				apierror.Abort(c, apierror.New(apierror.PermissionDenied).With("permission", scope))
+				return
+			}
+		}
+
+		ctx := tenant.WithTenant(c.Request.Context(), t)
+		c.Request = c.Request.WithContext(rbac.WithPrincipal(ctx, p))
+		c.Next()
+	}
+}
+
+func authenticate(v *Verifier, r *http.Request, raw string) (*Key, *tenant.Tenant, error) {
+	pool, release, err := database.Default.Acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	now := time.Now()
+	k, err := Authenticate(pool.SQL, pool.Dialect, raw, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timestamp, signature := r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature)
+	if k.RequireSignature || timestamp != "" || signature != "" {
+		body, err := readBody(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := v.Verify(k, r.Method, r.URL.RequestURI(), timestamp, signature, body, now); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	t, err := tenant.Get(pool.SQL, pool.Dialect, k.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !t.IsActive {
+		return nil, nil, tenant.ErrInactive
+	}
+	if slug := strings.TrimSpace(r.Header.Get(tenant.HeaderTenant)); slug != "" && !strings.EqualFold(slug, t.Slug) {
+		return nil, nil, tenant.ErrMismatch
+	}
+
+	if err := Touch(pool.SQL, pool.Dialect, k, now); err != nil {
+		slog.Warn("No se pudo registrar el uso de la API key", "prefix", k.Prefix, "error", err)
+	}
+	return k, t, nil
+}
+
+var errBodyTooLarge = errors.New("el cuerpo de una petición firmada no puede superar 1 MiB")
+
+// readBody lee el cuerpo para firmarlo y lo deja disponible para el handler
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxSignedBody+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MaxSignedBody {
+		return nil, errBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// apiError traduce un error de autenticación con llave
+func apiError(err error) *apierror.Error {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		return apierror.New(apierror.APIKeyInvalid)
+	case errors.Is(err, ErrSignatureRequired):
+		return apierror.New(apierror.SignatureRequired).With("headers", []string{HeaderTimestamp, HeaderSignature})
+	case errors.Is(err, ErrBadSignature):
+		return apierror.New(apierror.SignatureInvalid)
+	case errors.Is(err, ErrStaleTimestamp):
+		return apierror.New(apierror.SignatureExpired)
+	case errors.Is(err, ErrReplayed):
+		return apierror.New(apierror.SignatureReplayed)
+	case errors.Is(err, errBodyTooLarge):
+		return apierror.New(apierror.PayloadTooLarge).With("max_bytes", MaxSignedBody)
+	case errors.Is(err, tenant.ErrInactive):
+		return apierror.New(apierror.TenantDisabled)
+	case errors.Is(err, tenant.ErrMismatch):
+		return apierror.New(apierror.TenantMismatch)
+	}
+	return apierror.Wrap(apierror.Unavailable, err)
+}
@@ -0,0 +1,116 @@
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers de la firma. El cliente envía también X-API-Key.
+const (
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderSignature = "X-Signature"
+)
+
+// DefaultWindow es la tolerancia de la marca de tiempo si
+// API_SIGNATURE_WINDOW no está definido
+const DefaultWindow = 5 * time.Minute
+
+var (
+	ErrSignatureRequired = errors.New("la llave exige firma: envíe " + HeaderTimestamp + " y " + HeaderSignature)
+	ErrBadSignature      = errors.New("firma inválida")
+	ErrStaleTimestamp    = errors.New("marca de tiempo fuera de la ventana permitida")
+	ErrReplayed          = errors.New("la firma ya se utilizó")
+)
+
+// WindowFromEnv lee API_SIGNATURE_WINDOW (por ejemplo "2m")
+func WindowFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("API_SIGNATURE_WINDOW")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultWindow
+}
+
+// Canonical es el texto que se firma: método, ruta con query, marca de
+// tiempo (segundos Unix) y SHA-256 del cuerpo en hex, separados por "\n"
+func Canonical(method, uri, timestamp string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{strings.ToUpper(method), uri, timestamp, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// Sign calcula la firma (HMAC-SHA256 en hex) que envía el cliente en
+// X-Signature
+func Sign(secret, method, uri, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(Canonical(method, uri, timestamp, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifier revisa las firmas y recuerda las ya usadas durante la ventana.
+// La memoria es del proceso: con varias instancias, una firma repetida solo
+// se detecta si llega a la misma.
+type Verifier struct {
+	Window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewVerifier(window time.Duration) *Verifier {
+	return &Verifier{Window: window, seen: map[string]time.Time{}}
+}
+
+// Verify comprueba la firma de una petición hecha con la llave k
+func (v *Verifier) Verify(k *Key, method, uri, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return ErrSignatureRequired
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	at := time.Unix(ts, 0)
+	if at.Before(now.Add(-v.Window)) || at.After(now.Add(v.Window)) {
+		return ErrStaleTimestamp
+	}
+
+	got, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return ErrBadSignature
+	}
+	want, _ := hex.DecodeString(Sign(k.signingSecret, method, uri, timestamp, body))
+	if !hmac.Equal(got, want) {
+		return ErrBadSignature
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.prune(now)
+	id := k.Prefix + ":" + hex.EncodeToString(got)
+	if _, ok := v.seen[id]; ok {
+		return ErrReplayed
+	}
+	// Pasada la ventana la marca de tiempo ya no es válida, así que basta
+	// con recordarla hasta entonces
+	v.seen[id] = at.Add(v.Window)
+	return nil
+}
+
+func (v *Verifier) prune(now time.Time) {
+	if now.Sub(v.lastPrune) < v.Window {
+		return
+	}
+	for id, until := range v.seen {
+		if now.After(until) {
+			delete(v.seen, id)
+		}
+	}
+	v.lastPrune = now
+}
@@ -0,0 +1,101 @@
+package apikey
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	k := &Key{Prefix: "rk_prueba", signingSecret: "secreto"}
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"email":"ana@example.com"}`)
+	valid := Sign(k.signingSecret, "POST", "/send-code?lang=es", ts, body)
+
+	tests := []struct {
+		name      string
+		method    string
+		uri       string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"válida", "POST", "/send-code?lang=es", ts, valid, body, nil},
+		{"método en minúsculas", "post", "/send-code?lang=es", ts, valid, body, nil},
+		{"firma en mayúsculas", "POST", "/send-code?lang=es", ts, strings.ToUpper(valid), body, nil},
+		{"sin firma", "POST", "/send-code?lang=es", ts, "", body, ErrSignatureRequired},
+		{"sin marca de tiempo", "POST", "/send-code?lang=es", "", valid, body, ErrSignatureRequired},
+		{"otro cuerpo", "POST", "/send-code?lang=es", ts, valid, []byte(`{"email":"eva@example.com"}`), ErrBadSignature},
+		{"otra ruta", "POST", "/send-code?lang=en", ts, valid, body, ErrBadSignature},
+		{"otro método", "PUT", "/send-code?lang=es", ts, valid, body, ErrBadSignature},
+		{"firma no hex", "POST", "/send-code?lang=es", ts, "zz", body, ErrBadSignature},
+		{"otro secreto", "POST", "/send-code?lang=es", ts, Sign("otro", "POST", "/send-code?lang=es", ts, body), body, ErrBadSignature},
+		{"marca no numérica", "POST", "/send-code?lang=es", "ayer", valid, body, ErrStaleTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(5 * time.Minute)
+			err := v.Verify(k, tt.method, tt.uri, tt.timestamp, tt.signature, tt.body, now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, se esperaba %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyReplayWindow(t *testing.T) {
+	k := &Key{Prefix: "rk_prueba", signingSecret: "secreto"}
+	signedAt := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+	sig := Sign(k.signingSecret, "POST", "/send-code", ts, nil)
+	window := 5 * time.Minute
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want error
+	}{
+		{"dentro de la ventana", signedAt.Add(window - time.Second), nil},
+		{"justo en el límite", signedAt.Add(window), nil},
+		{"vencida", signedAt.Add(window + time.Second), ErrStaleTimestamp},
+		{"en el futuro dentro de la ventana", signedAt.Add(-window), nil},
+		{"demasiado en el futuro", signedAt.Add(-window - time.Second), ErrStaleTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(window)
+			if err := v.Verify(k, "POST", "/send-code", ts, sig, nil, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, se esperaba %v", err, tt.want)
+			}
+		})
+	}
+
+	v := NewVerifier(window)
+	if err := v.Verify(k, "POST", "/send-code", ts, sig, nil, signedAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(k, "POST", "/send-code", ts, sig, nil, signedAt.Add(time.Second)); !errors.Is(err, ErrReplayed) {
+		t.Errorf("la misma firma se aceptó dos veces: %v", err)
+	}
+	// La misma firma con otra llave no cuenta como repetida
+	other := &Key{Prefix: "rk_otra", signingSecret: "secreto"}
+	if err := v.Verify(other, "POST", "/send-code", ts, sig, nil, signedAt.Add(time.Second)); err != nil {
+		t.Errorf("otra llave con el mismo secreto: %v", err)
+	}
+	// Después de la ventana se olvida, pero la marca de tiempo ya venció
+	if err := v.Verify(k, "POST", "/send-code", ts, sig, nil, signedAt.Add(2*window)); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("firma vencida: %v", err)
+	}
+	later := signedAt.Add(2 * window)
+	lts := strconv.FormatInt(later.Unix(), 10)
+	if err := v.Verify(k, "POST", "/send-code", lts, Sign(k.signingSecret, "POST", "/send-code", lts, nil), nil, later); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.seen) != 1 {
+		t.Errorf("no se olvidaron las firmas vencidas: %d", len(v.seen))
+	}
+}
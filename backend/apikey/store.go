@@ -0,0 +1,189 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+const columns = "id, tenant_id, name, prefix, signing_secret, scopes, require_signature, expires_at, last_used_at, created_by, created_at, revoked_at"
+
+// touchInterval evita escribir last_used_at en cada petición
+const touchInterval = time.Minute
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row rowScanner) (*Key, error) {
+	var k Key
+	var scopes string
+	var expires, lastUsed, revoked sql.NullTime
+	var createdBy sql.NullInt64
+	err := row.Scan(&k.ID, &k.TenantID, &k.Name, &k.Prefix, &k.signingSecret, &scopes, &k.RequireSignature,
+		&expires, &lastUsed, &createdBy, &k.CreatedAt, &revoked)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = []rbac.Permission{}
+	for _, s := range strings.Split(scopes, ",") {
+		if s != "" {
+			k.Scopes = append(k.Scopes, rbac.Permission(s))
+		}
+	}
+	if expires.Valid {
+		k.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	k.CreatedBy = createdBy.Int64
+	return &k, nil
+}
+
+func joinScopes(scopes []rbac.Permission) string {
+	parts := make([]string, len(scopes))
+	for i, s := range scopes {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, ",")
+}
+
+// generate crea el prefijo público, la llave completa y el secreto de firma
+func generate() (prefix, key, secret string, err error) {
+	b := make([]byte, 4+32+32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix = Prefix + hex.EncodeToString(b[:4])
+	key = prefix + "_" + hex.EncodeToString(b[4:36])
+	secret = SecretPrefix + hex.EncodeToString(b[36:])
+	return prefix, key, secret, nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create guarda la llave y devuelve su valor y el secreto de firma; no se
+// vuelven a mostrar
+func Create(db database.Execer, d database.Dialect, k *Key) (key, secret string, err error) {
+	now := time.Now().UTC()
+	k.Normalize()
+	if err := k.Validate(now); err != nil {
+		return "", "", err
+	}
+	prefix, key, secret, err := generate()
+	if err != nil {
+		return "", "", err
+	}
+
+	var createdBy interface{}
+	if k.CreatedBy != 0 {
+		createdBy = k.CreatedBy
+	}
+	id, err := database.InsertID(db, d, `INSERT INTO api_keys
+		(tenant_id, name, prefix, key_hash, signing_secret, scopes, require_signature, expires_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		k.TenantID, k.Name, prefix, hashKey(key), secret, joinScopes(k.Scopes), k.RequireSignature,
+		k.ExpiresAt, createdBy, now)
+	if err != nil {
+		return "", "", err
+	}
+	k.ID, k.Prefix, k.CreatedAt, k.signingSecret = id, prefix, now, secret
+	return key, secret, nil
+}
+
+// Get busca una llave del tenant
+func Get(db database.Execer, d database.Dialect, tenantID, id int64) (*Key, error) {
+	return scan(db.QueryRow(d.Rebind("SELECT "+columns+" FROM api_keys WHERE id = $1 AND tenant_id = $2"), id, tenantID))
+}
+
+// List devuelve las llaves del tenant, las revocadas al final
+func List(db tenant.Querier, d database.Dialect, tenantID int64) ([]*Key, error) {
+	rows, err := db.Query(d.Rebind("SELECT "+columns+" FROM api_keys WHERE tenant_id = $1 ORDER BY revoked_at IS NOT NULL, id"), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Key{}
+	for rows.Next() {
+		k, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, k)
+	}
+	return list, rows.Err()
+}
+
+// Rotate reemplaza la llave y el secreto de firma; los anteriores dejan de
+// valer de inmediato
+func Rotate(db database.Execer, d database.Dialect, k *Key) (key, secret string, err error) {
+	if !k.Active(time.Now()) {
+		return "", "", ErrInvalid
+	}
+	prefix, key, secret, err := generate()
+	if err != nil {
+		return "", "", err
+	}
+	result, err := db.Exec(d.Rebind(`UPDATE api_keys SET prefix = $1, key_hash = $2, signing_secret = $3
+		WHERE id = $4 AND revoked_at IS NULL`), prefix, hashKey(key), secret, k.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return "", "", ErrInvalid
+	}
+	k.Prefix, k.signingSecret = prefix, secret
+	return key, secret, nil
+}
+
+// Revoke desactiva la llave; se conserva para la auditoría
+func Revoke(db database.Execer, d database.Dialect, k *Key) error {
+	if k.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	if _, err := db.Exec(d.Rebind("UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL"), now, k.ID); err != nil {
+		return err
+	}
+	k.RevokedAt = &now
+	return nil
+}
+
+// Authenticate busca la llave por su valor y revisa que esté vigente
+func Authenticate(db database.Execer, d database.Dialect, key string, now time.Time) (*Key, error) {
+	if !strings.HasPrefix(key, Prefix) {
+		return nil, ErrInvalid
+	}
+	k, err := scan(db.QueryRow(d.Rebind("SELECT "+columns+" FROM api_keys WHERE key_hash = $1"), hashKey(key)))
+	if err == ErrNotFound || (err == nil && !k.Active(now)) {
+		return nil, ErrInvalid
+	}
+	return k, err
+}
+
+// Touch registra el uso de la llave, como mucho una vez por minuto
+func Touch(db database.Execer, d database.Dialect, k *Key, now time.Time) error {
+	if k.LastUsedAt != nil && now.Sub(*k.LastUsedAt) < touchInterval {
+		return nil
+	}
+	_, err := db.Exec(d.Rebind("UPDATE api_keys SET last_used_at = $1 WHERE id = $2"), now.UTC(), k.ID)
+	return err
+}
@@ -0,0 +1,271 @@
+// Package audit guarda en audit_events quién hizo qué, sobre qué, desde
+// dónde y con qué resultado. Los handlers se envuelven con Gin o Wrap y
+// solo necesitan indicar el objetivo (y el actor, si lo conocen).
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/database"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// Acciones registradas
+const (
+	ActionCodeRequested = "reset.code_requested"
+	ActionCodeVerified  = "reset.code_verified"
+	ActionPasswordReset = "reset.password_reset"
+
+	ActionSMTPRead    = "smtp.config_read"
+	ActionSMTPCreated = "smtp.config_created"
+	ActionSMTPUpdated = "smtp.config_updated"
+	ActionSMTPDeleted = "smtp.config_deleted"
+	ActionSMTPTested  = "smtp.connection_tested"
+
+	ActionUserCreated      = "user.created"
+	ActionUserUpdated      = "user.updated"
+	ActionUserDisabled     = "user.disabled"
+	ActionUserEnabled      = "user.enabled"
+	ActionUserDeleted      = "user.deleted"
+	ActionUserForcedReset  = "user.reset_forced"
+	ActionUserCodesRevoked = "user.reset_codes_revoked"
+	ActionUserRoleChanged  = "user.role_changed"
+	ActionUsersImported    = "user.imported"
+
+	ActionTenantCreated       = "tenant.created"
+	ActionTenantUpdated       = "tenant.updated"
+	ActionTenantKeyRotated    = "tenant.api_key_rotated"
+	ActionTenantKeyRevoked    = "tenant.api_key_revoked"
+	ActionTenantAdminsChanged = "tenant.admins_changed"
+	ActionTemplateUpdated     = "template.updated"
+	ActionTextUpdated         = "text.updated"
+
+	ActionAPIKeyCreated = "api_key.created"
+	ActionAPIKeyRotated = "api_key.rotated"
+	ActionAPIKeyRevoked = "api_key.revoked"
+
+	ActionWebhookCreated       = "webhook.created"
+	ActionWebhookUpdated       = "webhook.updated"
+	ActionWebhookDeleted       = "webhook.deleted"
+	ActionWebhookSecretRotated = "webhook.secret_rotated"
+	ActionWebhookReplayed      = "webhook.replayed"
+
+	ActionAdminLogin  = "admin.login"
+	ActionAdminLogout = "admin.logout"
+
+	ActionSetupLogin       = "setup.login"
+	ActionSetupDB          = "setup.db_configured"
+	ActionSetupTables      = "setup.tables_created"
+	ActionSetupAdmin       = "setup.admin_created"
+	ActionSetupReset       = "setup.reset"
+	ActionConnectorUpdated = "setup.connector_updated"
+	ActionLDAPUpdated      = "setup.ldap_updated"
+	ActionDBConfigRead     = "db.config_read"
+	ActionDBConfigSaved    = "db.config_updated"
+)
+
+// Resultados
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultDenied  = "denied"
+)
+
+// Anonymous es el actor cuando la petición no está autenticada
+const Anonymous = "anonymous"
+
+// Event es una fila de audit_events
+type Event struct {
+	ID int64 `json:"id"`
+	// TenantID es el tenant de la petición; los eventos que no son de un
+	// tenant (servidor de setup, herramientas) van al tenant por defecto
+	TenantID  int64     `json:"tenant_id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Result    string    `json:"result"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Record inserta un evento. Un fallo se registra en el log pero nunca hace
+// fallar la petición auditada.
+func Record(ctx context.Context, e Event) {
+	pool, release, err := database.Default.Acquire()
+	if err != nil {
+		return // sin base de datos configurada (setup inicial)
+	}
+	defer release()
+	record(ctx, pool, e)
+}
+
+func record(ctx context.Context, pool *database.Pool, e Event) {
+	if e.Actor == "" {
+		e.Actor = Anonymous
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	if e.TenantID == 0 {
+		e.TenantID = tenant.DefaultID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := pool.SQL.ExecContext(ctx, pool.Dialect.Rebind(`
+		INSERT INTO audit_events (tenant_id, actor, action, target, ip, user_agent, result, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`),
+		e.TenantID, truncate(e.Actor, 255), e.Action, truncate(e.Target, 255), truncate(e.IP, 64),
+		truncate(e.UserAgent, 512), e.Result, e.CreatedAt)
+	if err != nil {
+		slog.Warn("No se pudo guardar el evento de auditoría", "action", e.Action, "error", err)
+	}
+}
+
+// entry es lo que el handler va completando durante la petición
+type entry struct {
+	actor  string
+	target string
+	result string
+}
+
+type ctxKey struct{}
+
+func entryFrom(ctx context.Context) *entry {
+	e, _ := ctx.Value(ctxKey{}).(*entry)
+	return e
+}
+
+// SetTarget indica sobre qué actuó la petición (correo, id, archivo...)
+func SetTarget(ctx context.Context, target string) {
+	if e := entryFrom(ctx); e != nil {
+		e.target = target
+	}
+}
+
+// SetActor indica quién hizo la petición cuando no viene de la autenticación
+func SetActor(ctx context.Context, actor string) {
+	if e := entryFrom(ctx); e != nil {
+		e.actor = actor
+	}
+}
+
+// SetResult fuerza el resultado en lugar de deducirlo del status HTTP
+func SetResult(ctx context.Context, result string) {
+	if e := entryFrom(ctx); e != nil {
+		e.result = result
+	}
+}
+
+// resultFor traduce el status HTTP
+func resultFor(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ResultDenied
+	case status >= 400:
+		return ResultFailure
+	default:
+		return ResultSuccess
+	}
+}
+
+func newEvent(r *http.Request, action string, e *entry, status int) Event {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	result := e.result
+	if result == "" {
+		result = resultFor(status)
+	}
+	var tenantID int64
+	if t, ok := tenant.From(r.Context()); ok {
+		tenantID = t.ID
+	}
+	return Event{
+		TenantID:  tenantID,
+		Actor:     e.actor,
+		Action:    action,
+		Target:    e.target,
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+		Result:    result,
+	}
+}
+
+// Gin audita una ruta de gin con la acción indicada
+func Gin(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		e := &entry{}
+		// Una API key se autentica antes que la ruta (apikey.Gin)
+		if p, ok := rbac.PrincipalFrom(c.Request.Context()); ok {
+			e.actor = p.Subject
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ctxKey{}, e))
+		c.Next()
+		Record(c.Request.Context(), newEvent(c.Request, action, e, c.Writer.Status()))
+	}
+}
+
+// Wrap audita un handler de net/http (servidor de setup). El pool se
+// reserva antes de llamar al handler para que el evento quede en la misma
+// base aunque el handler cambie o resetee la configuración.
+func Wrap(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		pool, release, _ := database.Default.Acquire()
+		defer release()
+
+		e := &entry{}
+		r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, e))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		event := newEvent(r, action, e, rec.status)
+		if pool != nil {
+			record(r.Context(), pool, event)
+		} else {
+			Record(r.Context(), event) // la base se configuró en esta petición
+		}
+	}
+}
+
+// WrapWrites es Wrap solo para métodos que modifican (no GET ni HEAD)
+func WrapWrites(action string, next http.HandlerFunc) http.HandlerFunc {
+	audited := Wrap(action, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		audited(w, r)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+	// MaxExportRows limita una exportación para no cargar toda la tabla
+	MaxExportRows = 100000
+)
+
+// Filter son los filtros del endpoint de administración. Los campos vacíos
+// no filtran.
+type Filter struct {
+	// Tenants limita la consulta a los eventos de esos tenants; nil no
+	// filtra y un slice vacío no devuelve nada
+	Tenants []int64
+	Actor   string
+	Action  string // prefijo: "smtp." devuelve todas las acciones SMTP
+	Target  string
+	Result  string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+	Offset  int
+}
+
+// where arma la condición con marcadores $N
+func (f Filter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if f.Tenants != nil {
+		marks := make([]string, len(f.Tenants))
+		for i, id := range f.Tenants {
+			args = append(args, id)
+			marks[i] = "$" + strconv.Itoa(len(args))
+		}
+		if len(marks) == 0 {
+			conds = append(conds, "1 = 0")
+		} else {
+			conds = append(conds, "tenant_id IN ("+strings.Join(marks, ", ")+")")
+		}
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action LIKE ?", f.Action+"%")
+	}
+	if f.Target != "" {
+		add("target = ?", f.Target)
+	}
+	if f.Result != "" {
+		add("result = ?", f.Result)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= ?", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		add("created_at < ?", f.Until.UTC())
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List devuelve una página de eventos (más recientes primero) y el total
+// que cumple el filtro
+func List(ctx context.Context, f Filter) ([]Event, int, error) {
+	pool, release, err := database.Default.Acquire()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	if f.Limit <= 0 {
+		f.Limit = DefaultPageSize
+	}
+	where, args := f.where()
+
+	var total int
+	if err := pool.SQL.QueryRowContext(ctx, pool.Dialect.Rebind("SELECT COUNT(*) FROM audit_events"+where), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT id, tenant_id, actor, action, target, ip, user_agent, result, created_at
+		FROM audit_events%s ORDER BY created_at DESC, id DESC LIMIT %d OFFSET %d`, where, f.Limit, f.Offset)
+	rows, err := pool.SQL.QueryContext(ctx, pool.Dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Actor, &e.Action, &e.Target, &e.IP, &e.UserAgent, &e.Result, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+// Formatos de exportación
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+)
+
+// ContentType devuelve el tipo MIME de un formato de exportación
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Export escribe los eventos del filtro en CSV o JSON Lines
+func Export(ctx context.Context, w io.Writer, format string, f Filter) error {
+	f.Limit, f.Offset = MaxExportRows, 0
+	events, _, err := List(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "tenant_id", "created_at", "actor", "action", "target", "ip", "user_agent", "result"})
+		for _, e := range events {
+			cw.Write([]string{
+				strconv.FormatInt(e.ID, 10), strconv.FormatInt(e.TenantID, 10), e.CreatedAt.UTC().Format(time.RFC3339),
+				csvSafe(e.Actor), e.Action, csvSafe(e.Target), e.IP, csvSafe(e.UserAgent), e.Result,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatJSONLines:
+		enc := json.NewEncoder(w)
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("formato no soportado: %s", format)
+	}
+}
+
+// csvSafe evita que una hoja de cálculo interprete un valor como fórmula
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
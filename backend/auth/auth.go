@@ -0,0 +1,79 @@
+// Package auth emite y valida los tokens de sesión (Authorization: Bearer)
+// y protege las rutas con los permisos de rbac.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"password-recovery/rbac"
+)
+
+// DefaultTTL es la vigencia de una sesión si SESSION_TTL no está definido
+const DefaultTTL = 8 * time.Hour
+
+var (
+	ErrNoToken      = errors.New("falta el token de sesión")
+	ErrInvalidToken = errors.New("sesión inválida o vencida")
+)
+
+// Store guarda las sesiones activas
+type Store interface {
+	// Create abre una sesión para el principal y devuelve el token
+	Create(p rbac.Principal, ttl time.Duration) (token string, expires time.Time, err error)
+	// Lookup devuelve el principal de un token vigente
+	Lookup(token string) (rbac.Principal, error)
+	// Revoke cierra la sesión
+	Revoke(token string) error
+}
+
+// TTLFromEnv lee SESSION_TTL (por ejemplo "30m")
+func TTLFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultTTL
+}
+
+// newToken genera un token aleatorio; solo se guarda su hash
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BearerToken extrae el token del header Authorization
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// Authenticate valida el token de la petición. Una API key ya autenticada
+// (apikey.Gin) ocupa el lugar de la sesión.
+func Authenticate(store Store, r *http.Request) (rbac.Principal, error) {
+	if p, ok := rbac.PrincipalFrom(r.Context()); ok && p.Scopes != nil {
+		return p, nil
+	}
+	token := BearerToken(r)
+	if token == "" {
+		return rbac.Principal{}, ErrNoToken
+	}
+	return store.Lookup(token)
+}
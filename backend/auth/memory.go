@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"password-recovery/rbac"
+)
+
+// MemoryStore guarda las sesiones en memoria. Lo usa el servidor de setup,
+// que puede no tener base de datos todavía; las sesiones se pierden al
+// reiniciar.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	principal rbac.Principal
+	expires   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memorySession)}
+}
+
+func (s *MemoryStore) Create(p rbac.Principal, ttl time.Duration) (string, time.Time, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge()
+	s.sessions[hash] = memorySession{principal: p, expires: expires}
+	return token, expires, nil
+}
+
+func (s *MemoryStore) Lookup(token string) (rbac.Principal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[hashToken(token)]
+	if !ok || time.Now().After(session.expires) {
+		return rbac.Principal{}, ErrInvalidToken
+	}
+	return session.principal, nil
+}
+
+func (s *MemoryStore) Revoke(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, hashToken(token))
+	return nil
+}
+
+// RevokeSubject cierra todas las sesiones de un operador
+func (s *MemoryStore) RevokeSubject(subject string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, session := range s.sessions {
+		if session.principal.Subject == subject {
+			delete(s.sessions, hash)
+		}
+	}
+}
+
+// purge elimina las sesiones vencidas (se llama con el mutex tomado)
+func (s *MemoryStore) purge() {
+	now := time.Now()
+	for hash, session := range s.sessions {
+		if now.After(session.expires) {
+			delete(s.sessions, hash)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/i18n"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// APIError traduce un error de autenticación al error que se responde
+func APIError(err error) *apierror.Error {
+	switch {
+	case errors.Is(err, ErrNoToken):
+		return apierror.New(apierror.AuthRequired)
+	case errors.Is(err, ErrInvalidToken):
+		return apierror.New(apierror.SessionInvalid)
+	}
+	return apierror.Wrap(apierror.Unavailable, err)
+}
+
+// Gin exige una sesión válida con el permiso indicado y deja el principal
+// en el contexto de la petición
+func Gin(store Store, perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := Authenticate(store, c.Request)
+		if err != nil {
+			apierror.Abort(c, APIError(err))
+			return
+		}
+		c.Request = c.Request.WithContext(i18n.WithUser(c.Request.Context(), p.Locale))
+		c.Header("Content-Language", i18n.Locale(c.Request.Context()))
+		audit.SetActor(c.Request.Context(), p.Subject)
+		if !p.Can(perm) {
+			apierror.Abort(c, apierror.New(apierror.PermissionDenied).With("permission", perm))
+			return
+		}
+		if t, ok := tenant.From(c.Request.Context()); ok && !p.InTenant(t.ID) {
+			apierror.Abort(c, apierror.New(apierror.TenantAccessDenied).With("tenant", t.Slug))
+			return
+		}
+		c.Request = c.Request.WithContext(rbac.WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	}
+}
+
+// Require es el equivalente de Gin para handlers de net/http
+func Require(store Store, perm rbac.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := Authenticate(store, r)
+		if err != nil {
+			apierror.Write(w, r, APIError(err))
+			return
+		}
+		r = r.WithContext(i18n.WithUser(r.Context(), p.Locale))
+		w.Header().Set("Content-Language", i18n.Locale(r.Context()))
+		audit.SetActor(r.Context(), p.Subject)
+		if !p.Can(perm) {
+			apierror.Write(w, r, apierror.New(apierror.PermissionDenied).With("permission", perm))
+			return
+		}
+		if t, ok := tenant.From(r.Context()); ok && !p.InTenant(t.ID) {
+			apierror.Write(w, r, apierror.New(apierror.TenantAccessDenied).With("tenant", t.Slug))
+			return
+		}
+		next(w, r.WithContext(rbac.WithPrincipal(r.Context(), p)))
+	}
+}
+
+// Optional deja el principal en el contexto si el token es válido, sin
+// rechazar la petición si no lo es
+func Optional(store Store, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, err := Authenticate(store, r); err == nil {
+			r = r.WithContext(rbac.WithPrincipal(r.Context(), p))
+		}
+		next(w, r)
+	}
+}
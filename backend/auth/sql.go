@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// SQLStore guarda las sesiones en la tabla sessions. Una cuenta
+// deshabilitada o borrada invalida sus sesiones en el siguiente Lookup.
+type SQLStore struct {
+	Manager *database.Manager
+}
+
+func NewSQLStore(m *database.Manager) *SQLStore {
+	return &SQLStore{Manager: m}
+}
+
+func (s *SQLStore) Create(p rbac.Principal, ttl time.Duration) (string, time.Time, error) {
+	pool, release, err := s.Manager.Acquire()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer release()
+
+	token, hash, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+
+	// De paso se limpian las sesiones vencidas
+	pool.SQL.Exec(pool.Dialect.Rebind("DELETE FROM sessions WHERE expires_at < $1"), now)
+
+	_, err = pool.SQL.Exec(pool.Dialect.Rebind(
+		"INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)"),
+		hash, p.ID, now, expires)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+func (s *SQLStore) Lookup(token string) (rbac.Principal, error) {
+	pool, release, err := s.Manager.Acquire()
+	if err != nil {
+		return rbac.Principal{}, err
+	}
+	defer release()
+
+	var p rbac.Principal
+	var status string
+	err = pool.SQL.QueryRow(pool.Dialect.Rebind(`
+		SELECT u.id, u.email, u.role, u.status, u.locale
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = $1 AND s.expires_at > $2`),
+		hashToken(token), time.Now().UTC()).Scan(&p.ID, &p.Subject, &p.Role, &status, &p.Locale)
+	if err == sql.ErrNoRows || (err == nil && status == "disabled") {
+		return rbac.Principal{}, ErrInvalidToken
+	}
+	if err != nil {
+		return rbac.Principal{}, err
+	}
+	if p.Tenants, err = tenant.AdminTenants(pool.SQL, pool.Dialect, p.ID); err != nil {
+		return rbac.Principal{}, err
+	}
+	return p, nil
+}
+
+func (s *SQLStore) Revoke(token string) error {
+	pool, release, err := s.Manager.Acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = pool.SQL.Exec(pool.Dialect.Rebind("DELETE FROM sessions WHERE token_hash = $1"), hashToken(token))
+	return err
+}
+
+// RevokeUserSessions cierra todas las sesiones de una cuenta (por ejemplo
+// dentro de la transacción que la deshabilita o la borra)
+func RevokeUserSessions(exec database.Execer, d database.Dialect, userID int64) error {
+	_, err := exec.Exec(d.Rebind("DELETE FROM sessions WHERE user_id = $1"), userID)
+	return err
+}
@@ -0,0 +1,147 @@
+// Package challenge protege /send-code de solicitudes automatizadas. Antes
+// de pedir el código el cliente obtiene un reto (GET /challenge) y envía la
+// solución junto con el correo. El verificador por defecto es una prueba de
+// trabajo tipo hashcash que no depende de servicios externos; la interfaz
+// Verifier permite agregar uno compatible con hCaptcha o reCAPTCHA.
+//
+// Variables de entorno:
+//
+//	CHALLENGE_PROVIDER    pow (por defecto), stub (pruebas) u off
+//	CHALLENGE_DIFFICULTY  bits en cero que exige la prueba de trabajo (16)
+//	CHALLENGE_TTL         vigencia del reto (5m)
+//	CHALLENGE_SECRET      llave para firmar los retos; con varias instancias
+//	                      debe ser la misma en todas
+//	CHALLENGE_STUB_TOKEN  token que acepta el verificador stub
+package challenge
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"password-recovery/apierror"
+	"password-recovery/secrets"
+)
+
+var (
+	ErrRequired = errors.New("falta la solución del reto")
+	ErrInvalid  = errors.New("la solución del reto no es válida")
+	ErrExpired  = errors.New("el reto expiró")
+	ErrUsed     = errors.New("el reto ya se utilizó")
+)
+
+// Challenge es lo que recibe el cliente para resolver
+type Challenge struct {
+	// Type indica cómo resolverlo: "pow", "stub", "none" o el nombre del
+	// proveedor de CAPTCHA
+	Type       string     `json:"type"`
+	Challenge  string     `json:"challenge,omitempty"`
+	Algorithm  string     `json:"algorithm,omitempty"`
+	Difficulty int        `json:"difficulty,omitempty"`
+	SiteKey    string     `json:"site_key,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// Solution es la respuesta del cliente: el reto con su nonce (prueba de
+// trabajo) o el token del CAPTCHA
+type Solution struct {
+	Challenge string `json:"challenge,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	Token     string `json:"token,omitempty"`
+	// RemoteIP es la IP del cliente, para los proveedores que la validan
+	RemoteIP string `json:"-"`
+}
+
+// Verifier emite y comprueba retos. scope liga el reto a la acción y al
+// tenant ("send-code:acme"): una solución no sirve en otro scope.
+type Verifier interface {
+	Issue(scope string, now time.Time) (*Challenge, error)
+	Verify(ctx context.Context, scope string, s Solution, now time.Time) error
+}
+
+// Disabled no exige reto (CHALLENGE_PROVIDER=off)
+type Disabled struct{}
+
+func (Disabled) Issue(string, time.Time) (*Challenge, error) {
+	return &Challenge{Type: "none"}, nil
+}
+
+func (Disabled) Verify(context.Context, string, Solution, time.Time) error {
+	return nil
+}
+
+// FromEnv arma el verificador configurado
+func FromEnv() (Verifier, error) {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("CHALLENGE_PROVIDER")))
+	switch provider {
+	case "", "pow":
+		return powFromEnv()
+	case "stub":
+		if secrets.IsProduction() {
+			return nil, errors.New("APP_ENV=production no permite CHALLENGE_PROVIDER=stub")
+		}
+		token := strings.TrimSpace(os.Getenv("CHALLENGE_STUB_TOKEN"))
+		if token == "" {
+			token = DefaultStubToken
+		}
+		slog.Warn("Usando el verificador de retos stub (solo pruebas)")
+		return NewStub(token), nil
+	case "off", "none":
+		slog.Warn("/send-code no exige reto (CHALLENGE_PROVIDER=off)")
+		return Disabled{}, nil
+	}
+	return nil, fmt.Errorf("CHALLENGE_PROVIDER desconocido: %q (use pow, stub u off)", provider)
+}
+
+func powFromEnv() (*ProofOfWork, error) {
+	difficulty := DefaultDifficulty
+	if v := strings.TrimSpace(os.Getenv("CHALLENGE_DIFFICULTY")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxDifficulty {
+			return nil, fmt.Errorf("CHALLENGE_DIFFICULTY debe estar entre 1 y %d", MaxDifficulty)
+		}
+		difficulty = n
+	}
+	ttl := DefaultTTL
+	if v := strings.TrimSpace(os.Getenv("CHALLENGE_TTL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("CHALLENGE_TTL inválido: %q", v)
+		}
+		ttl = d
+	}
+	secret := []byte(os.Getenv("CHALLENGE_SECRET"))
+	if len(secret) == 0 {
+		// Los retos dejan de valer al reiniciar y solo los verifica la
+		// instancia que los emitió
+		if secrets.IsProduction() {
+			slog.Warn("CHALLENGE_SECRET no definido: cada instancia firma los retos con su propia llave")
+		}
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return NewProofOfWork(secret, difficulty, ttl), nil
+}
+
+// APIError traduce un error de verificación al error que se responde
+func APIError(err error) *apierror.Error {
+	switch {
+	case errors.Is(err, ErrRequired):
+		return apierror.New(apierror.ChallengeRequired)
+	case errors.Is(err, ErrInvalid):
+		return apierror.New(apierror.ChallengeInvalid)
+	case errors.Is(err, ErrExpired):
+		return apierror.New(apierror.ChallengeExpired)
+	case errors.Is(err, ErrUsed):
+		return apierror.New(apierror.ChallengeUsed)
+	}
+	return apierror.Wrap(apierror.Unavailable, err)
+}
@@ -0,0 +1,121 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testScope = "send-code:acme"
+
+// solve busca el primer nonce que cumple (o no, si enough es false) la
+// dificultad del reto
+func solve(t *testing.T, ch *Challenge, enough bool) string {
+	t.Helper()
+	for i := 0; i < 1<<24; i++ {
+		nonce := strconv.Itoa(i)
+		if (LeadingZeroBits(ch.Challenge, nonce) >= ch.Difficulty) == enough {
+			return nonce
+		}
+	}
+	t.Fatal("no se encontró un nonce")
+	return ""
+}
+
+func issue(t *testing.T, p *ProofOfWork, now time.Time) *Challenge {
+	t.Helper()
+	ch, err := p.Issue(testScope, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Type != "pow" || ch.Difficulty != p.Difficulty || ch.ExpiresAt == nil {
+		t.Fatalf("reto inesperado: %+v", ch)
+	}
+	return ch
+}
+
+func TestProofOfWork(t *testing.T) {
+	p := NewProofOfWork([]byte("secreto-de-prueba"), 8, time.Minute)
+	now := time.Now()
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		scope string
+		valid bool
+		at    time.Duration
+		err   error
+	}{
+		{"solución válida", testScope, true, 0, nil},
+		{"nonce insuficiente", testScope, false, 0, ErrInvalid},
+		{"otro scope", "send-code:otro", true, 0, ErrInvalid},
+		{"reto vencido", testScope, true, 2 * time.Minute, ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := issue(t, p, now)
+			sol := Solution{Challenge: ch.Challenge, Nonce: solve(t, ch, tt.valid)}
+			if err := p.Verify(ctx, tt.scope, sol, now.Add(tt.at)); !errors.Is(err, tt.err) {
+				t.Errorf("Verify: %v, se esperaba %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProofOfWorkSingleUse(t *testing.T) {
+	p := NewProofOfWork([]byte("secreto-de-prueba"), 8, time.Minute)
+	now := time.Now()
+	ch := issue(t, p, now)
+	sol := Solution{Challenge: ch.Challenge, Nonce: solve(t, ch, true)}
+
+	if err := p.Verify(context.Background(), testScope, sol, now); err != nil {
+		t.Fatalf("primer uso: %v", err)
+	}
+	if err := p.Verify(context.Background(), testScope, sol, now.Add(time.Second)); !errors.Is(err, ErrUsed) {
+		t.Errorf("segundo uso: %v, se esperaba ErrUsed", err)
+	}
+}
+
+func TestProofOfWorkRejectsForgery(t *testing.T) {
+	p := NewProofOfWork([]byte("secreto-de-prueba"), 8, time.Minute)
+	other := NewProofOfWork([]byte("otro-secreto"), 8, time.Minute)
+	now := time.Now()
+
+	// Un reto firmado con otra llave
+	ch := issue(t, other, now)
+	sol := Solution{Challenge: ch.Challenge, Nonce: solve(t, ch, true)}
+	if err := p.Verify(context.Background(), testScope, sol, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("reto de otra llave: %v, se esperaba ErrInvalid", err)
+	}
+
+	if err := p.Verify(context.Background(), testScope, Solution{}, now); !errors.Is(err, ErrRequired) {
+		t.Errorf("sin solución: %v, se esperaba ErrRequired", err)
+	}
+}
+
+func TestStub(t *testing.T) {
+	s := NewStub("token-de-prueba")
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{"token-de-prueba", nil},
+		{"", ErrRequired},
+		{"otro", ErrInvalid},
+	}
+	for _, tt := range tests {
+		if err := s.Verify(context.Background(), testScope, Solution{Token: tt.token}, time.Now()); !errors.Is(err, tt.err) {
+			t.Errorf("Verify(%q): %v, se esperaba %v", tt.token, err, tt.err)
+		}
+	}
+}
+
+func TestFromEnvStubNotInProduction(t *testing.T) {
+	t.Setenv("CHALLENGE_PROVIDER", "stub")
+	t.Setenv("APP_ENV", "production")
+	if _, err := FromEnv(); err == nil {
+		t.Error("FromEnv permitió el stub en producción")
+	}
+}
@@ -0,0 +1,155 @@
+package challenge
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDifficulty pide en promedio 2^16 hashes: menos de un segundo
+	// en un navegador y caro para quien envía miles de solicitudes
+	DefaultDifficulty = 16
+	MaxDifficulty     = 32
+	DefaultTTL        = 5 * time.Minute
+	// maxNonce limita lo que se concatena al reto antes de calcular el hash
+	maxNonce = 64
+)
+
+// ProofOfWork es un reto tipo hashcash: el cliente busca un nonce tal que
+// SHA-256(reto + nonce) empiece con Difficulty bits en cero. El reto va
+// firmado con HMAC, así que no se guarda nada al emitirlo; al verificarlo
+// se recuerda hasta que vence para que cada solución sirva una sola vez.
+// Como en apikey.Verifier, esa memoria es del proceso.
+type ProofOfWork struct {
+	Difficulty int
+	TTL        time.Duration
+
+	secret []byte
+
+	mu        sync.Mutex
+	used      map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewProofOfWork(secret []byte, difficulty int, ttl time.Duration) *ProofOfWork {
+	return &ProofOfWork{Difficulty: difficulty, TTL: ttl, secret: secret, used: map[string]time.Time{}}
+}
+
+// claims es el contenido firmado del reto
+type claims struct {
+	ID         string `json:"id"`
+	Scope      string `json:"scope"`
+	Difficulty int    `json:"bits"`
+	Expires    int64  `json:"exp"`
+}
+
+// Issue emite un reto para scope
+func (p *ProofOfWork) Issue(scope string, now time.Time) (*Challenge, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	expires := now.Add(p.TTL).Truncate(time.Second)
+	payload, err := json.Marshal(claims{
+		ID:         hex.EncodeToString(id),
+		Scope:      scope,
+		Difficulty: p.Difficulty,
+		Expires:    expires.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := encoded + "." + base64.RawURLEncoding.EncodeToString(p.sign(encoded))
+	return &Challenge{
+		Type:       "pow",
+		Challenge:  token,
+		Algorithm:  "sha256",
+		Difficulty: p.Difficulty,
+		ExpiresAt:  &expires,
+	}, nil
+}
+
+func (p *ProofOfWork) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// Verify comprueba que el reto sea de este servidor y de scope, que no haya
+// vencido ni se haya usado y que el nonce cumpla la dificultad con la que
+// se emitió
+func (p *ProofOfWork) Verify(_ context.Context, scope string, s Solution, now time.Time) error {
+	if s.Challenge == "" || s.Nonce == "" {
+		return ErrRequired
+	}
+	if len(s.Nonce) > maxNonce {
+		return ErrInvalid
+	}
+	encoded, mac, ok := strings.Cut(s.Challenge, ".")
+	if !ok {
+		return ErrInvalid
+	}
+	got, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(got, p.sign(encoded)) {
+		return ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalid
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Scope != scope {
+		return ErrInvalid
+	}
+	expires := time.Unix(c.Expires, 0)
+	if now.After(expires) {
+		return ErrExpired
+	}
+	if LeadingZeroBits(s.Challenge, s.Nonce) < c.Difficulty {
+		return ErrInvalid
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prune(now)
+	if _, ok := p.used[c.ID]; ok {
+		return ErrUsed
+	}
+	p.used[c.ID] = expires
+	return nil
+}
+
+func (p *ProofOfWork) prune(now time.Time) {
+	if now.Sub(p.lastPrune) < p.TTL {
+		return
+	}
+	for id, until := range p.used {
+		if now.After(until) {
+			delete(p.used, id)
+		}
+	}
+	p.lastPrune = now
+}
+
+// LeadingZeroBits cuenta los bits en cero al inicio de SHA-256(reto+nonce)
+func LeadingZeroBits(challenge, nonce string) int {
+	sum := sha256.Sum256([]byte(challenge + nonce))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
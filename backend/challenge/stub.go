@@ -0,0 +1,37 @@
+package challenge
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+)
+
+// DefaultStubToken es el token que acepta Stub si no se configura
+// CHALLENGE_STUB_TOKEN
+const DefaultStubToken = "stub-pass"
+
+// Stub se comporta como un proveedor de CAPTCHA (el cliente envía un
+// token) sin salir a la red: acepta solo el token configurado. Sirve para
+// probar el flujo de un verificador externo y para las pruebas
+// automatizadas; no se permite en producción.
+type Stub struct {
+	token string
+}
+
+func NewStub(token string) *Stub {
+	return &Stub{token: token}
+}
+
+func (s *Stub) Issue(string, time.Time) (*Challenge, error) {
+	return &Challenge{Type: "stub", SiteKey: "stub"}, nil
+}
+
+func (s *Stub) Verify(_ context.Context, _ string, sol Solution, _ time.Time) error {
+	if sol.Token == "" {
+		return ErrRequired
+	}
+	if subtle.ConstantTimeCompare([]byte(sol.Token), []byte(s.token)) != 1 {
+		return ErrInvalid
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"password-recovery/apierror"
+	"password-recovery/challenge"
+	"password-recovery/logging"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+)
+
+// challenges emite y verifica los retos de /send-code (CHALLENGE_PROVIDER)
+var challenges challenge.Verifier = challenge.Disabled{}
+
+// challengeScope liga el reto a la acción y al tenant
+func challengeScope(t *tenant.Tenant) string {
+	return "send-code:" + t.Slug
+}
+
+// challengeHandler entrega un reto para /send-code
+func challengeHandler(c *gin.Context) {
+	ch, err := challenges.Issue(challengeScope(currentTenant(c)), time.Now())
+	if err != nil {
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, ch)
+}
+
+// checkChallenge verifica la solución que trae la petición; las peticiones
+// con API key de servicio (rk_...) no la necesitan. Responde el error y
+// devuelve false si no es válida.
+func checkChallenge(c *gin.Context, t *tenant.Tenant, proof challenge.Solution) bool {
+	if _, ok := rbac.PrincipalFrom(c.Request.Context()); ok {
+		return true
+	}
+	proof.RemoteIP = c.ClientIP()
+	if err := challenges.Verify(c.Request.Context(), challengeScope(t), proof, time.Now()); err != nil {
+		logging.FromContext(c.Request.Context()).Info("Reto rechazado en send-code", "error", err)
+		apierror.Abort(c, challenge.APIError(err))
+		return false
+	}
+	return true
+}
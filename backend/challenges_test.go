@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/apierror"
+	"password-recovery/challenge"
+	"password-recovery/database"
+	"password-recovery/tenant"
+)
+
+const testStubToken = "token-de-prueba"
+
+// setupRecovery deja db apuntando a una base SQLite nueva y arma las rutas
+// de recuperación con el verificador stub
+func setupRecovery(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dbDialect = database.MustForType(database.SQLite)
+	if err := database.Default.Swap(dbDialect, filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Default.Disconnect)
+	db = database.Default.Current().SQL
+	if _, err := database.Migrate(db, dbDialect); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := challenges
+	challenges = challenge.NewStub(testStubToken)
+	t.Cleanup(func() { challenges = previous })
+
+	router := gin.New()
+	recovery := router.Group("/", tenant.Gin())
+	recovery.GET("/challenge", challengeHandler)
+	recovery.POST("/send-code", sendCode)
+	return router
+}
+
+func postSendCode(t *testing.T, router *gin.Engine, body map[string]interface{}) (int, apierror.Code) {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/send-code", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var resp struct {
+		Code apierror.Code `json:"code"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.Code
+}
+
+func countResetCodes(t *testing.T) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM reset_codes").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestChallengeHandlerStub(t *testing.T) {
+	router := setupRecovery(t)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/challenge", nil))
+
+	var ch challenge.Challenge
+	if err := json.Unmarshal(rec.Body.Bytes(), &ch); err != nil || rec.Code != http.StatusOK || ch.Type != "stub" {
+		t.Fatalf("GET /challenge = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSendCodeChallenge(t *testing.T) {
+	router := setupRecovery(t)
+	if _, err := db.Exec(q("INSERT INTO users (tenant_id, email, password, status) VALUES ($1, $2, '!', 'active')"),
+		tenant.DefaultID, "ana@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		proof  map[string]interface{}
+		email  string
+		status int
+		code   apierror.Code
+	}{
+		{"sin reto", nil, "nadie@example.com", http.StatusForbidden, apierror.ChallengeRequired},
+		{"token incorrecto", map[string]interface{}{"token": "otro"}, "nadie@example.com", http.StatusForbidden, apierror.ChallengeInvalid},
+		// Con el reto resuelto la petición sigue hasta buscar el correo
+		{"token válido", map[string]interface{}{"token": testStubToken}, "nadie@example.com", http.StatusNotFound, apierror.EmailNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]interface{}{"email": tt.email}
+			if tt.proof != nil {
+				body["proof"] = tt.proof
+			}
+			status, code := postSendCode(t, router, body)
+			if status != tt.status || code != tt.code {
+				t.Errorf("POST /send-code = %d %s, se esperaba %d %s", status, code, tt.status, tt.code)
+			}
+		})
+	}
+
+	// Un reto rechazado no genera código para una cuenta que existe
+	postSendCode(t, router, map[string]interface{}{"email": "ana@example.com", "proof": map[string]interface{}{"token": "otro"}})
+	if n := countResetCodes(t); n != 0 {
+		t.Fatalf("se generaron %d códigos con un reto inválido", n)
+	}
+	// Con el token válido se genera (el envío falla: el tenant no tiene SMTP)
+	postSendCode(t, router, map[string]interface{}{"email": "ana@example.com", "proof": map[string]interface{}{"token": testStubToken}})
+	if n := countResetCodes(t); n != 1 {
+		t.Errorf("se generaron %d códigos con el reto resuelto, se esperaba 1", n)
+	}
+}
@@ -1,51 +1,84 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
+	"context"
+	"log/slog"
 	"os"
 	"password-recovery/config"
+	"password-recovery/cors"
+	"password-recovery/httpserver"
+	"password-recovery/ldapdir"
+	"password-recovery/logging"
 	"password-recovery/routes"
+	"password-recovery/secrets"
+	"password-recovery/webapp"
 )
 
 func main() {
-	// Configuración de credenciales
-	key := []byte("12345678901234567890123456789012") // Tu key de prueba
+	// Logs estructurados (LOG_FORMAT, LOG_LEVEL)
+	logging.Setup("setup")
 
-	// Cargar credenciales encriptadas
-	user, pass, err := config.LoadEncryptedCredentials(key)
+	// Cargar llave maestra (RESET_MASTER_KEY, RESET_MASTER_KEY_FILE o RESET_MASTER_PASSPHRASE)
+	masterKey, err := secrets.LoadMasterKey()
 	if err != nil {
-		fmt.Println("⚠️ Usando credenciales por defecto (para desarrollo):")
+		slog.Error("Error cargando llave maestra", "error", err)
+		os.Exit(1)
+	}
+	if masterKey.IsDevelopment() {
+		if secrets.IsProduction() {
+			slog.Error("APP_ENV=production no permite la llave de desarrollo; configure RESET_MASTER_KEY")
+			os.Exit(1)
+		}
+		slog.Warn("Usando la llave maestra de desarrollo")
+	}
+	slog.Info("Llave maestra cargada", "key_id", masterKey.ID, "source", masterKey.Source)
+	ldapdir.SetKeys(secrets.Keyring{masterKey})
 
+	// Cargar operadores de setup desde el archivo encriptado
+	store, err := config.LoadCredentialStore(secrets.Keyring{masterKey})
+	if err != nil {
+		slog.Warn("No se pudieron cargar los operadores de setup", "error", err)
 	} else {
-		fmt.Println("🔑 Credenciales cargadas desde archivo encriptado")
-		config.SetupUser = user
-		config.SetupPassword = pass
+		slog.Info("Operadores de setup cargados", "count", len(store.Operators))
+		if store.Legacy {
+			slog.Warn("config.json.enc usa el formato anterior; ejecute 'go run ./key passwd' para migrarlo")
+		}
+		config.SetSetupStore(store)
 	}
-
-	// Mostrar credenciales (solo para desarrollo)
-	//fmt.Printf("\n🔐 Credenciales de acceso:\nUsuario: %s\nContraseña: %s\n\n",
-	//	config.SetupUser, config.SetupPassword)
+	// Los cambios hechos con 'key operator' aplican sin reiniciar y cierran
+	// las sesiones de los operadores deshabilitados o borrados
+	go config.WatchCredentialStore(context.Background(), secrets.Keyring{masterKey}, httpserver.DefaultReloadInterval)
 
 	// Configurar servidor
-	router := routes.SetupRouter()
+	// CORS_ORIGINS o CORS_SETUP_ORIGINS (ver cors)
+	corsRules, err := cors.Load(cors.Group{Name: "setup", Credentials: true})
+	if err != nil {
+		slog.Error("Error en la configuración de CORS", "error", err)
+		os.Exit(1)
+	}
+	// Frontend embebido (APP_API_BASE_URL, APP_NAME, ... ver webapp)
+	web, err := webapp.FromEnv()
+	if err != nil {
+		slog.Error("Error en la configuración del frontend", "error", err)
+		os.Exit(1)
+	}
+	router := routes.SetupRouter(corsRules, web)
 
-	port := ":8080"
+	port := "8080"
 	if portEnv := os.Getenv("PORT"); portEnv != "" {
-		port = ":" + portEnv
+		port = portEnv
 	}
 
-	fmt.Printf("🚀 Servidor iniciado en http://localhost%s\n", port)
-	fmt.Println("📡 Endpoints disponibles:")
-	fmt.Println("- POST /api/login-setup")
-	fmt.Println("- POST /api/setup-db")
-	fmt.Println("- GET  /api/status")
-	fmt.Println("- POST   /api/db/test")
-	fmt.Println("- GET    /api/db/config")
-	fmt.Println("- PUT    /api/db/config")
+	// HTTPS con TLS_CERT_FILE/TLS_KEY_FILE o TLS_SELF_SIGNED (ver httpserver)
+	server := httpserver.ConfigFromEnv(port)
+	scheme := "http"
+	if server.TLS() {
+		scheme = "https"
+	}
+	slog.Info("Servidor iniciado", "addr", scheme+"://localhost:"+port)
 
-	if err := http.ListenAndServe(port, router); err != nil {
-		fmt.Printf("❌ Error iniciando servidor: %v\n", err)
+	if err := httpserver.ListenAndServe(server, router); err != nil {
+		slog.Error("Error iniciando servidor", "error", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"password-recovery/database"
+	"password-recovery/rbac"
+	"password-recovery/secrets"
+)
+
+func TestCreateAdminUser(t *testing.T) {
+	pool, err := database.Open(database.MustForType(database.SQLite), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.SQL.Close() })
+	if _, err := database.Migrate(pool.SQL, pool.Dialect); err != nil {
+		t.Fatal(err)
+	}
+
+	// El mismo correo en otro tenant no debe volverse administrador
+	if _, err := pool.SQL.Exec(`INSERT INTO tenants (slug, name) VALUES ('acme', 'Acme')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.SQL.Exec(`INSERT INTO users (tenant_id, email, password, role) VALUES (2, 'admin@example.com', '!', 'user')`); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := CreateAdminUser(pool.Gorm, "admin@example.com", "Admin#2024")
+	if err != nil || !created {
+		t.Fatalf("CreateAdminUser = %v, %v", created, err)
+	}
+
+	var hash, role string
+	var changed interface{}
+	if err := pool.SQL.QueryRow(`SELECT password, role, last_password_change FROM users WHERE tenant_id = 1 AND email = 'admin@example.com'`).
+		Scan(&hash, &role, &changed); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := secrets.VerifyPassword(hash, "Admin#2024"); !ok || err != nil {
+		t.Errorf("la contraseña del administrador no quedó como hash Argon2id: %q", hash)
+	}
+	if role != rbac.RoleAdmin || changed == nil {
+		t.Errorf("role = %q, last_password_change = %v", role, changed)
+	}
+
+	var other string
+	pool.SQL.QueryRow(`SELECT role FROM users WHERE tenant_id = 2`).Scan(&other)
+	if other != rbac.RoleUser {
+		t.Errorf("se promovió la cuenta de otro tenant: %q", other)
+	}
+
+	// Una segunda llamada encuentra al administrador del tenant por defecto
+	if created, err := CreateAdminUser(pool.Gorm, "admin@example.com", "otra"); err != nil || created {
+		t.Errorf("segunda llamada = %v, %v", created, err)
+	}
+}
@@ -1,41 +1,64 @@
 package config
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"context"
+	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"password-recovery/database"
+	"password-recovery/rbac"
+	"password-recovery/secrets"
+	"password-recovery/tenant"
 )
 
 const (
     ConfigFile    = "dbconfig.json" // Cambiado a mayúscula para exportar
-    encConfigFile = "config.json.enc"
+    EncConfigFile = "config.json.enc" // Exportado para la herramienta de llaves
 )
 
+func init() {
+	// config.json.enc se vuelve a cifrar al rotar la llave maestra
+	secrets.RegisterProtected(EncConfigFile)
+}
+
 // User struct definition for GORM
 type User struct {
-    ID        uint      `gorm:"primaryKey"`
-    Email     string    `gorm:"unique;not null"`
-    Password  string    `gorm:"not null"`
-    CreatedAt time.Time // Cambiado a time.Time
-    UpdatedAt time.Time // Cambiado a time.Time
+    ID                 uint       `gorm:"primaryKey"`
+    TenantID           int64      `gorm:"not null;default:1"` // el correo es único por tenant
+    Email              string     `gorm:"not null"`
+    Password           string     `gorm:"not null"` // hash Argon2id (secrets.HashPassword)
+    Role               string     `gorm:"default:user"`
+    LastPasswordChange *time.Time
+    CreatedAt          time.Time // Cambiado a time.Time
+    UpdatedAt          time.Time // Cambiado a time.Time
 }
 
 type DBConfig struct {
+	DBType   string `json:"db_type,omitempty"` // postgres (por defecto), mysql o sqlite
+	Path     string `json:"path,omitempty"`    // archivo de la base SQLite
 	Host     string `json:"host"`
 	Port     string `json:"port"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	DBName   string `json:"dbname"`
+
+	// Opciones de conexión (opcionales, ver dsn.go)
+	URL             string `json:"url,omitempty"` // postgres://... o mysql://... reemplaza host/port/user/password/dbname
+	SSLMode         string `json:"sslmode,omitempty"`
+	SSLRootCert     string `json:"sslrootcert,omitempty"`
+	SSLCert         string `json:"sslcert,omitempty"`
+	SSLKey          string `json:"sslkey,omitempty"`
+	ConnectTimeout  int    `json:"connect_timeout,omitempty"` // segundos
+	SearchPath      string `json:"search_path,omitempty"`
+	ApplicationName string `json:"application_name,omitempty"`
 }
 type SetupStatus struct {
 	DBConfigured    bool `json:"db_configured"`
@@ -44,99 +67,108 @@ type SetupStatus struct {
 }
 
 var (
-	SetupUser          string
-	SetupPassword      string
+	SetupUser          string // primer operador, solo informativo
 	currentConfig      DBConfig
-	CurrentSetupStatus SetupStatus
 	configMutex        sync.RWMutex
+
+	// setupStatus lo escriben los handlers y la verificación de salud en
+	// segundo plano; siempre se usa con statusMutex (después de configMutex
+	// si hacen falta los dos)
+	setupStatus SetupStatus
+	statusMutex sync.RWMutex
 )
 
-// GetDSN returns the connection string for PostgreSQL
-func (c DBConfig) GetDSN() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		c.Host,
-		c.User,
-		c.Password,
-		c.DBName,
-		c.Port)
+// Status devuelve una copia del estado del setup
+func Status() SetupStatus {
+	statusMutex.RLock()
+	defer statusMutex.RUnlock()
+	return setupStatus
+}
+
+// SetStatus reemplaza el estado del setup
+func SetStatus(s SetupStatus) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	setupStatus = s
+}
+
+// SetDBConfigured marca si hay una base configurada y accesible
+func SetDBConfigured(v bool) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	setupStatus.DBConfigured = v
+}
+
+// SetTablesCreated marca si el esquema está al día
+func SetTablesCreated(v bool) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	setupStatus.DBTablesCreated = v
+}
+
+// SetAdminCreated marca si ya existe el administrador
+func SetAdminCreated(v bool) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	setupStatus.AdminCreated = v
 }
 
 // Agrega esta función en config/config.go
 func IsSetupComplete() bool {
-	return CurrentSetupStatus.DBConfigured &&
-		CurrentSetupStatus.DBTablesCreated &&
-		CurrentSetupStatus.AdminCreated
+	status := Status()
+	return status.DBConfigured && status.DBTablesCreated && status.AdminCreated
 }
 
 // inizializacion de las tablas de la base de datos
 func InitializeDB(db *gorm.DB) error {
-	// Crear tablas según el SQL proporcionado
-	err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS smtp_config (
-			id SERIAL PRIMARY KEY,
-			host VARCHAR(100) NOT NULL,
-			port INTEGER NOT NULL,
-			username VARCHAR(100) NOT NULL,
-			password VARCHAR(100) NOT NULL,
-			from_email VARCHAR(100) NOT NULL,
-			is_active BOOLEAN DEFAULT TRUE,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_smtp_config_active ON smtp_config(is_active);
-		
-		CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			email VARCHAR(100) UNIQUE NOT NULL,
-			password VARCHAR(100) NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		);
-		
-		CREATE TABLE IF NOT EXISTS reset_codes (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER NOT NULL,
-			code VARCHAR(10) NOT NULL,
-			expiration_time TIMESTAMP WITH TIME ZONE NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		);
-		
-		CREATE INDEX IF NOT EXISTS idx_reset_codes_expiration ON reset_codes(expiration_time);
-	`).Error
-
-	if err != nil {
+	// Aplicar las migraciones del dialecto correspondiente
+	if _, err := database.MigrateGorm(db); err != nil {
 		return fmt.Errorf("error creating tables: %v", err)
 	}
 
-	CurrentSetupStatus.DBTablesCreated = true
+	SetTablesCreated(true)
 	return nil
 }
 
-// creacion de un usuario administrador
+// creacion de un usuario administrador del tenant por defecto
 func CreateAdminUser(db *gorm.DB, email, password string) (bool, error) {
-    // Verificar si el usuario ya existe
+    // Verificar si el usuario ya existe (el mismo correo puede existir en
+    // otros tenants; solo cuenta el del tenant por defecto)
     var existingUser User
-    result := db.Where("email = ?", email).First(&existingUser)
+    result := db.Where("tenant_id = ? AND email = ?", tenant.DefaultID, email).First(&existingUser)
     
     if result.Error == nil {
-        // Usuario ya existe - no es un error, solo marcamos como completado
-        CurrentSetupStatus.AdminCreated = true
+        // Usuario ya existe - no es un error, solo nos aseguramos de que
+        // tenga el rol de administrador y marcamos como completado
+        if existingUser.Role != rbac.RoleAdmin {
+            if err := db.Model(&existingUser).Update("role", rbac.RoleAdmin).Error; err != nil {
+                return false, fmt.Errorf("error updating admin role: %v", err)
+            }
+        }
+        SetAdminCreated(true)
         return false, nil
     }
 
-    // Si no existe, crear nuevo usuario
+    // Si no existe, crear nuevo usuario con la contraseña en Argon2id, que
+    // es lo único que acepta /admin/login
+    hash, err := secrets.HashPassword(password)
+    if err != nil {
+        return false, fmt.Errorf("error hashing admin password: %v", err)
+    }
+    now := time.Now().UTC()
     admin := User{
-        Email:    email,
-        Password: password,
+        TenantID:           tenant.DefaultID,
+        Email:              email,
+        Password:           hash,
+        Role:               rbac.RoleAdmin,
+        LastPasswordChange: &now,
     }
 
     if err := db.Create(&admin).Error; err != nil {
         return false, fmt.Errorf("error creating admin user: %v", err)
     }
 
-    CurrentSetupStatus.AdminCreated = true
+    SetAdminCreated(true)
     return true, nil
 }
 
@@ -171,7 +203,7 @@ func LoadDBConfig() (DBConfig, error) {
 	}
 
 	currentConfig = cfg
-	CurrentSetupStatus.DBConfigured = true
+	SetDBConfigured(true)
 	return cfg, nil
 }
 
@@ -214,77 +246,40 @@ func (c *DBConfig) TestConnection() (map[string]interface{}, error) {
 }
 */
 // Actualizar configuración de DB
-// UpdateDBConfig actualiza la configuración
+// UpdateDBConfig abre un pool con la nueva configuración, la guarda y
+// reemplaza el pool compartido. El pool anterior se cierra cuando terminan
+// las peticiones que lo usan; si la conexión falla no se cambia nada.
+// La conexión (y su ping, que puede tardar segundos) se hace antes de tomar
+// configMutex, que solo protege el archivo y el cambio de pool.
 func UpdateDBConfig(newConfig DBConfig) error {
-	configMutex.Lock()
-	defer configMutex.Unlock()
-
 	data, err := json.MarshalIndent(newConfig, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error serializando configuración: %v", err)
 	}
 
+	pool, err := newConfig.OpenPool()
+	if err != nil {
+		return fmt.Errorf("error al conectar: %v", err)
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
 	if err := ioutil.WriteFile(ConfigFile, data, 0644); err != nil {
+		pool.SQL.Close()
 		return fmt.Errorf("error guardando configuración: %v", err)
 	}
 
+	database.Default.Replace(pool)
 	currentConfig = newConfig
-	CurrentSetupStatus.DBConfigured = true
+	SetDBConfigured(true)
 	return nil
 }
 
 // Obtener configuración actual (sin contraseña)
 func GetDBConfig() map[string]interface{} {
-	return map[string]interface{}{
-		"host":   currentConfig.Host,
-		"port":   currentConfig.Port,
-		"user":   currentConfig.User,
-		"dbname": currentConfig.DBName,
-	}
-}
-func LoadEncryptedCredentials(key []byte) (string, string, error) {
-	if _, err := os.Stat(encConfigFile); os.IsNotExist(err) {
-		return "", "", errors.New("archivo encriptado no encontrado")
-	}
-
-	encryptedData, err := ioutil.ReadFile(encConfigFile)
-	if err != nil {
-		return "", "", err
-	}
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", "", err
-	}
-
-	if len(encryptedData) < 12 {
-		return "", "", errors.New("datos cifrados incompletos")
-	}
-
-	nonce := encryptedData[:12]
-	ciphertext := encryptedData[12:]
-
-	aesgcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", "", err
-	}
-
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", "", err
-	}
-
-	var creds struct {
-		User string `json:"user"`
-		Pass string `json:"pass"`
-	}
-	if err := json.Unmarshal(plaintext, &creds); err != nil {
-		return "", "", err
-	}
-
-	return creds.User, creds.Pass, nil
+	return GetCurrentConfig().Public()
 }
-
 func ConfigExists() bool {
 	_, err := os.Stat(ConfigFile)
 	return !os.IsNotExist(err)
@@ -352,18 +347,15 @@ func ResetConfig() error {
 		if err := os.Remove(ConfigFile); err != nil {
 			return fmt.Errorf("no se pudo eliminar el archivo: %v", err)
 		}
-		fmt.Println("🗑️ Archivo dbconfig.json eliminado físicamente")
+		slog.Info("Archivo de configuración eliminado", "file", ConfigFile)
 	}
 
-	// 2. Resetear configuración en memoria
+	// 2. Resetear configuración en memoria y cerrar el pool
 	currentConfig = DBConfig{}
+	database.Default.Disconnect()
 
 	// 3. Resetear estado
-	CurrentSetupStatus = SetupStatus{
-		DBConfigured:    false,
-		DBTablesCreated: false,
-		AdminCreated:    false,
-	}
+	SetStatus(SetupStatus{})
 
 	return nil
 }
@@ -384,33 +376,39 @@ func RefreshConfigState() {
 
 	// Verificar si el archivo existe físicamente
 	if _, err := os.Stat(ConfigFile); os.IsNotExist(err) {
-		CurrentSetupStatus.DBConfigured = false
+		SetDBConfigured(false)
 		currentConfig = DBConfig{}
-		fmt.Println("🔄 Estado actualizado: archivo no existe")
+		slog.Info("Estado de configuración actualizado", "result", "archivo no existe")
 		return
 	}
 
 	// Si el archivo existe, cargarlo y verificar conexión
 	cfg, err := loadConfigFromFile()
 	if err != nil {
-		CurrentSetupStatus.DBConfigured = false
+		SetDBConfigured(false)
 		currentConfig = DBConfig{}
-		fmt.Printf("🔄 Estado actualizado: archivo inválido (%v)\n", err)
+		slog.Warn("Estado de configuración actualizado", "result", "archivo inválido", "error", err)
 		return
 	}
 
-	// Verificar conexión real
+	// Verificar conexión real (abre el pool compartido si hace falta)
+	if err := Connect(cfg); err != nil {
+		SetDBConfigured(false)
+		currentConfig = DBConfig{}
+		slog.Warn("Estado de configuración actualizado", "result", "conexión fallida", "error", err)
+		return
+	}
 	if _, err := cfg.TestConnection(); err != nil {
-		CurrentSetupStatus.DBConfigured = false
+		SetDBConfigured(false)
 		currentConfig = DBConfig{}
-		fmt.Printf("🔄 Estado actualizado: conexión fallida (%v)\n", err)
+		slog.Warn("Estado de configuración actualizado", "result", "conexión fallida", "error", err)
 		return
 	}
 
 	// Todo está correcto
 	currentConfig = cfg
-	CurrentSetupStatus.DBConfigured = true
-	fmt.Println("🔄 Estado actualizado: configuración válida y conexión exitosa")
+	SetDBConfigured(true)
+	slog.Info("Estado de configuración actualizado", "result", "configuración válida y conexión exitosa")
 }
 
 // GetCurrentConfig devuelve la configuración actual
@@ -450,43 +448,71 @@ func loadConfigFromFile() (DBConfig, error) {
 	}
 
 	// Verificar si la configuración está vacía
-	if cfg.Host == "" && cfg.User == "" && cfg.DBName == "" {
+	if cfg.Host == "" && cfg.User == "" && cfg.DBName == "" && cfg.URL == "" && cfg.Path == "" {
 		return cfg, fmt.Errorf("configuración vacía")
 	}
 
 	return cfg, nil
 }
 
+// TestConnection verifica la configuración. Si es la del pool compartido se
+// reutiliza; si no, abre una sola conexión temporal con database/sql.
 func (c *DBConfig) TestConnection() (map[string]interface{}, error) {
 	// Verificar si la configuración es válida
-	if c.Host == "" || c.User == "" || c.DBName == "" {
-		return nil, fmt.Errorf("configuración incompleta: campos requeridos vacíos")
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	dialect := c.Dialect()
+	dsn := c.GetDSN()
+
+	var db *sql.DB
+	if database.Default.Matches(dialect, dsn) {
+		pool, release, err := database.Default.Acquire()
+		if err == nil {
+			defer release()
+			db = pool.SQL
+		}
+	}
+	if db == nil {
+		tmp, err := sql.Open(dialect.DriverName(), dsn)
+		if err != nil {
+			return nil, fmt.Errorf("error al conectar: %v", err)
+		}
+		defer tmp.Close()
+		tmp.SetMaxOpenConns(1)
+		db = tmp
 	}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		c.Host, c.User, c.Password, c.DBName, c.Port)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
 		return nil, fmt.Errorf("error al conectar: %v", err)
 	}
 
 	// Obtener información de la base de datos
 	var version string
-	db.Raw("SELECT version()").Scan(&version)
+	db.QueryRowContext(ctx, dialect.VersionQuery()).Scan(&version)
 
 	var tableCount int64
-	db.Raw("SELECT count(*) FROM information_schema.tables WHERE table_schema = 'public'").Scan(&tableCount)
-
-	sqlDB, err := db.DB()
-	if err == nil {
-		sqlDB.Close()
-	}
+	db.QueryRowContext(ctx, dialect.TableCountQuery()).Scan(&tableCount)
 
-	return map[string]interface{}{
-		"db_type":    "postgres",
+	result := map[string]interface{}{
+		"db_type":    dialect.Name(),
 		"status":     "success",
 		"version":    version,
 		"tableCount": tableCount,
-	}, nil
+	}
+
+	if dialect.Name() == database.Postgres {
+		var sslInUse bool
+		db.QueryRowContext(ctx, "SELECT COALESCE((SELECT ssl FROM pg_stat_ssl WHERE pid = pg_backend_pid()), false)").Scan(&sslInUse)
+		result["ssl"] = sslInUse
+	}
+
+	return result, nil
+}
+
+// Connect deja el pool compartido apuntando a cfg (no hace nada si ya lo está)
+func Connect(cfg DBConfig) error {
+	return database.Default.Ensure(cfg.Dialect(), cfg.GetDSN())
 }
@@ -0,0 +1,357 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"password-recovery/database"
+	"password-recovery/fielderr"
+	"password-recovery/secrets"
+)
+
+// Valores aceptados por libpq/pgx para sslmode
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Lista de esquemas separados por coma: public, "Mi Esquema", $user
+var searchPathPattern = regexp.MustCompile(`^\s*("[^"]+"|[A-Za-z_$][A-Za-z0-9_$]*)(\s*,\s*("[^"]+"|[A-Za-z_$][A-Za-z0-9_$]*))*\s*$`)
+
+// Dialect devuelve el dialecto de db_type (postgres si está vacío o es inválido;
+// Validate reporta los tipos inválidos)
+func (c DBConfig) Dialect() database.Dialect {
+	d, err := database.ForType(c.DBType)
+	if err != nil {
+		return database.MustForType(database.Postgres)
+	}
+	return d
+}
+
+// OpenPool abre un pool de conexiones sin publicarlo en database.Default
+func (c DBConfig) OpenPool() (*database.Pool, error) {
+	return database.Open(c.Dialect(), c.GetDSN())
+}
+
+// GetDSN returns the connection string for the configured database type.
+func (c DBConfig) GetDSN() string {
+	switch c.Dialect().Name() {
+	case database.MySQL:
+		return c.mysqlDSN()
+	case database.SQLite:
+		return c.sqliteDSN()
+	default:
+		return c.postgresDSN()
+	}
+}
+
+// postgresDSN: when a full postgres:// URL is configured the explicit options
+// are merged into its query string; otherwise a quoted key/value DSN is built.
+func (c DBConfig) postgresDSN() string {
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return c.URL
+		}
+		q := u.Query()
+		for _, opt := range c.options() {
+			if q.Get(opt[0]) == "" {
+				q.Set(opt[0], opt[1])
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	parts := []string{
+		"host=" + quoteDSNValue(c.Host),
+		"user=" + quoteDSNValue(c.User),
+		"password=" + quoteDSNValue(c.Password),
+		"dbname=" + quoteDSNValue(c.DBName),
+		"port=" + quoteDSNValue(c.Port),
+	}
+	for _, opt := range c.options() {
+		parts = append(parts, opt[0]+"="+quoteDSNValue(opt[1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// mysqlDSN construye el DSN de go-sql-driver/mysql. Las fechas se leen como
+// time.Time en UTC y sslmode se traduce al parámetro tls del driver.
+func (c DBConfig) mysqlDSN() string {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.User, cfg.Passwd, cfg.DBName = c.User, c.Password, c.DBName
+	cfg.Addr = net.JoinHostPort(c.Host, c.Port)
+
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err == nil {
+			cfg.User = u.User.Username()
+			cfg.Passwd, _ = u.User.Password()
+			cfg.Addr = u.Host
+			if u.Port() == "" {
+				cfg.Addr = net.JoinHostPort(u.Hostname(), "3306")
+			}
+			cfg.DBName = strings.TrimPrefix(u.Path, "/")
+		}
+	}
+
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	if c.ConnectTimeout > 0 {
+		cfg.Timeout = time.Duration(c.ConnectTimeout) * time.Second
+	}
+	if c.ApplicationName != "" {
+		cfg.ConnectionAttributes = "program_name:" + c.ApplicationName
+	}
+
+	switch c.SSLMode {
+	case "", "disable":
+		cfg.TLSConfig = "false"
+	case "allow", "prefer":
+		cfg.TLSConfig = "preferred"
+	case "require":
+		cfg.TLSConfig = "skip-verify"
+	default:
+		cfg.TLSConfig = "true"
+		if name, err := c.registerMySQLTLS(); err == nil && name != "" {
+			cfg.TLSConfig = name
+		}
+	}
+	return cfg.FormatDSN()
+}
+
+// registerMySQLTLS registra un tls.Config con el CA y certificado de cliente
+// configurados, ya que el driver de MySQL no acepta rutas en el DSN.
+func (c DBConfig) registerMySQLTLS() (string, error) {
+	if c.SSLRootCert == "" && c.SSLCert == "" {
+		return "", nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: c.Host}
+	if c.SSLMode == "verify-ca" {
+		// verify-ca valida la cadena pero no el nombre del host
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if c.SSLRootCert != "" {
+		pem, err := os.ReadFile(c.SSLRootCert)
+		if err != nil {
+			return "", err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("sslrootcert sin certificados válidos")
+		}
+		tlsConfig.RootCAs = pool
+		if c.SSLMode == "verify-ca" {
+			tlsConfig.VerifyPeerCertificate = verifyChainOnly(pool)
+		}
+	}
+	if c.SSLCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.SSLCert, c.SSLKey)
+		if err != nil {
+			return "", err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	name := "reset-" + strings.ToLower(c.SSLMode) + "-" + secrets.KeyID([]byte(c.SSLRootCert+"|"+c.SSLCert))
+	if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func verifyChainOnly(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("el servidor no envió certificado")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
+
+// sqliteDSN abre el archivo con llaves foráneas activas y WAL
+func (c DBConfig) sqliteDSN() string {
+	busyTimeout := 5000
+	if c.ConnectTimeout > 0 {
+		busyTimeout = c.ConnectTimeout * 1000
+	}
+	params := fmt.Sprintf("_foreign_keys=1&_busy_timeout=%d", busyTimeout)
+	if c.Path == ":memory:" {
+		return "file::memory:?cache=shared&" + params
+	}
+	return "file:" + c.Path + "?" + params + "&_journal_mode=WAL"
+}
+
+// options devuelve los parámetros opcionales en orden estable
+func (c DBConfig) options() [][2]string {
+	sslMode := c.SSLMode
+	if sslMode == "" && c.URL == "" && c.Dialect().Name() == database.Postgres {
+		// Compatibilidad con configuraciones guardadas antes de sslmode
+		sslMode = "disable"
+	}
+
+	var opts [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			opts = append(opts, [2]string{key, value})
+		}
+	}
+	add("sslmode", sslMode)
+	add("sslrootcert", c.SSLRootCert)
+	add("sslcert", c.SSLCert)
+	add("sslkey", c.SSLKey)
+	if c.ConnectTimeout > 0 {
+		add("connect_timeout", strconv.Itoa(c.ConnectTimeout))
+	}
+	add("search_path", c.SearchPath)
+	add("application_name", c.ApplicationName)
+	return opts
+}
+
+// quoteDSNValue encierra el valor entre comillas simples escapando \ y '
+// según las reglas de libpq, para soportar espacios y comillas.
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// Validate revisa los campos obligatorios y las opciones de conexión
+func (c DBConfig) Validate() error {
+	fields := map[string]string{}
+
+	dialect, err := database.ForType(c.DBType)
+	if err != nil {
+		fields["db_type"] = "valor no soportado (" + strings.Join(database.SupportedTypes(), ", ") + ")"
+		return fielderr.In("configuración inválida", fields)
+	}
+
+	switch {
+	case dialect.Name() == database.SQLite:
+		if strings.TrimSpace(c.Path) == "" {
+			fields["path"] = "obligatorio para sqlite"
+		} else if c.Path != ":memory:" {
+			if info, err := os.Stat(filepath.Dir(c.Path)); err != nil || !info.IsDir() {
+				fields["path"] = "el directorio no existe"
+			}
+		}
+	case c.URL != "":
+		u, err := url.Parse(c.URL)
+		schemes := map[string]bool{"postgres": true, "postgresql": true}
+		if dialect.Name() == database.MySQL {
+			schemes = map[string]bool{"mysql": true}
+		}
+		if err != nil || !schemes[u.Scheme] {
+			fields["url"] = "esquema de URL no válido para " + dialect.Name()
+		} else if u.Host == "" {
+			fields["url"] = "la URL no incluye host"
+		}
+	default:
+		if strings.TrimSpace(c.Host) == "" {
+			fields["host"] = "obligatorio"
+		}
+		if strings.TrimSpace(c.User) == "" {
+			fields["user"] = "obligatorio"
+		}
+		if strings.TrimSpace(c.DBName) == "" {
+			fields["dbname"] = "obligatorio"
+		}
+		if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+			fields["port"] = "debe ser un número entre 1 y 65535"
+		}
+	}
+
+	if c.SearchPath != "" && dialect.Name() != database.Postgres {
+		fields["search_path"] = "solo disponible para postgres"
+	}
+	if c.SSLMode != "" && !validSSLModes[c.SSLMode] {
+		fields["sslmode"] = "valor no soportado (disable, allow, prefer, require, verify-ca, verify-full)"
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		fields["sslkey"] = "sslcert y sslkey deben indicarse juntos"
+	}
+	for name, path := range map[string]string{"sslrootcert": c.SSLRootCert, "sslcert": c.SSLCert, "sslkey": c.SSLKey} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			fields[name] = "archivo no encontrado"
+		}
+	}
+	if c.ConnectTimeout < 0 || c.ConnectTimeout > 300 {
+		fields["connect_timeout"] = "debe estar entre 0 y 300 segundos"
+	}
+	if _, ok := fields["search_path"]; !ok && c.SearchPath != "" && !searchPathPattern.MatchString(c.SearchPath) {
+		fields["search_path"] = "lista de esquemas inválida"
+	}
+	if len(c.ApplicationName) > 63 {
+		fields["application_name"] = "máximo 63 caracteres"
+	} else {
+		for _, r := range c.ApplicationName {
+			if r < 0x20 || r > 0x7e {
+				fields["application_name"] = "solo caracteres ASCII imprimibles"
+				break
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		return fielderr.In("configuración inválida", fields)
+	}
+	return nil
+}
+
+// Public devuelve la configuración sin contraseña, apta para respuestas
+func (c DBConfig) Public() map[string]interface{} {
+	info := map[string]interface{}{
+		"db_type": c.Dialect().Name(),
+		"host":    c.Host,
+		"port":    c.Port,
+		"user":    c.User,
+		"dbname":  c.DBName,
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err == nil {
+			info["url"] = u.Redacted()
+		}
+	}
+	if c.Path != "" {
+		info["path"] = c.Path
+	}
+	for _, opt := range c.options() {
+		info[opt[0]] = opt[1]
+	}
+	if c.ConnectTimeout > 0 {
+		info["connect_timeout"] = c.ConnectTimeout
+	}
+	return info
+}
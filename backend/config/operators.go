@@ -0,0 +1,333 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"password-recovery/secrets"
+)
+
+// Roles de los operadores de setup
+const (
+	OperatorRoleAdmin    = "admin"    // puede administrar otros operadores
+	OperatorRoleOperator = "operator" // solo puede ejecutar el setup
+)
+
+const credentialStoreVersion = 2
+
+var (
+	ErrInvalidCredentials = errors.New("credenciales inválidas")
+	ErrOperatorNotFound   = errors.New("operador no encontrado")
+	ErrOperatorExists     = errors.New("el operador ya existe")
+	ErrLastAdmin          = errors.New("debe quedar al menos un administrador activo")
+)
+
+// Operator es una persona autorizada a usar el modo setup
+type Operator struct {
+	User         string    `json:"user"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
+	Disabled     bool      `json:"disabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CredentialStore es el contenido descifrado de config.json.enc
+type CredentialStore struct {
+	Version   int        `json:"version"`
+	Operators []Operator `json:"operators"`
+
+	// Legacy indica que el archivo estaba en el formato {user, pass}
+	Legacy bool `json:"-"`
+}
+
+var (
+	setupStore *CredentialStore
+	storeMutex sync.RWMutex
+)
+
+// hash de relleno para que un usuario inexistente tarde lo mismo en fallar
+var dummyHash, _ = secrets.HashPassword("dummy-password")
+
+// ValidOperatorRole indica si el rol existe
+func ValidOperatorRole(role string) bool {
+	return role == OperatorRoleAdmin || role == OperatorRoleOperator
+}
+
+// LoadCredentialStore descifra config.json.enc. El formato anterior con un
+// solo par {user, pass} se convierte a un operador administrador.
+func LoadCredentialStore(keys secrets.Keyring) (*CredentialStore, error) {
+	if _, err := os.Stat(EncConfigFile); os.IsNotExist(err) {
+		return nil, errors.New("archivo encriptado no encontrado")
+	}
+
+	plaintext, err := secrets.DecryptFile(EncConfigFile, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		CredentialStore
+		User string `json:"user"`
+		Pass string `json:"pass"`
+	}
+	if err := json.Unmarshal(plaintext, &raw); err != nil {
+		return nil, fmt.Errorf("credenciales inválidas en %s: %v", EncConfigFile, err)
+	}
+
+	store := raw.CredentialStore
+	if len(store.Operators) == 0 && raw.User != "" {
+		hash, err := secrets.HashPassword(raw.Pass)
+		if err != nil {
+			return nil, err
+		}
+		store = CredentialStore{
+			Version: credentialStoreVersion,
+			Operators: []Operator{{
+				User:         raw.User,
+				PasswordHash: hash,
+				Role:         OperatorRoleAdmin,
+			}},
+			Legacy: true,
+		}
+	}
+
+	return &store, nil
+}
+
+// SaveCredentialStore cifra y guarda la lista de operadores
+func SaveCredentialStore(key *secrets.MasterKey, store *CredentialStore) error {
+	store.Version = credentialStoreVersion
+	plaintext, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+	if err := secrets.EncryptFile(EncConfigFile, plaintext, key); err != nil {
+		return err
+	}
+	store.Legacy = false
+	return nil
+}
+
+// Find busca un operador por nombre (sin distinguir mayúsculas)
+func (s *CredentialStore) Find(user string) *Operator {
+	for i := range s.Operators {
+		if strings.EqualFold(s.Operators[i].User, user) {
+			return &s.Operators[i]
+		}
+	}
+	return nil
+}
+
+// Authenticate valida usuario y contraseña. Un operador deshabilitado o
+// inexistente devuelve el mismo error que una contraseña incorrecta.
+func (s *CredentialStore) Authenticate(user, pass string) (*Operator, error) {
+	op := s.Find(user)
+	if op == nil {
+		secrets.VerifyPassword(dummyHash, pass)
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, err := secrets.VerifyPassword(op.PasswordHash, pass)
+	if err != nil || !ok || op.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	return op, nil
+}
+
+// Add agrega un operador con la contraseña hasheada
+func (s *CredentialStore) Add(user, pass, role string) error {
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return errors.New("el usuario no puede estar vacío")
+	}
+	if !ValidOperatorRole(role) {
+		return fmt.Errorf("rol inválido: %s", role)
+	}
+	if s.Find(user) != nil {
+		return ErrOperatorExists
+	}
+
+	hash, err := secrets.HashPassword(pass)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	s.Operators = append(s.Operators, Operator{
+		User:         user,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	return nil
+}
+
+// Remove elimina un operador
+func (s *CredentialStore) Remove(user string) error {
+	for i := range s.Operators {
+		if strings.EqualFold(s.Operators[i].User, user) {
+			rest := append(append([]Operator(nil), s.Operators[:i]...), s.Operators[i+1:]...)
+			if !hasActiveAdmin(rest) {
+				return ErrLastAdmin
+			}
+			s.Operators = rest
+			return nil
+		}
+	}
+	return ErrOperatorNotFound
+}
+
+// SetPassword cambia la contraseña de un operador
+func (s *CredentialStore) SetPassword(user, pass string) error {
+	op := s.Find(user)
+	if op == nil {
+		return ErrOperatorNotFound
+	}
+	hash, err := secrets.HashPassword(pass)
+	if err != nil {
+		return err
+	}
+	op.PasswordHash = hash
+	op.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// SetDisabled habilita o deshabilita un operador
+func (s *CredentialStore) SetDisabled(user string, disabled bool) error {
+	op := s.Find(user)
+	if op == nil {
+		return ErrOperatorNotFound
+	}
+	previous := op.Disabled
+	op.Disabled = disabled
+	if !hasActiveAdmin(s.Operators) {
+		op.Disabled = previous
+		return ErrLastAdmin
+	}
+	op.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// SetRole cambia el rol de un operador
+func (s *CredentialStore) SetRole(user, role string) error {
+	if !ValidOperatorRole(role) {
+		return fmt.Errorf("rol inválido: %s", role)
+	}
+	op := s.Find(user)
+	if op == nil {
+		return ErrOperatorNotFound
+	}
+	previous := op.Role
+	op.Role = role
+	if !hasActiveAdmin(s.Operators) {
+		op.Role = previous
+		return ErrLastAdmin
+	}
+	op.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func hasActiveAdmin(operators []Operator) bool {
+	for _, op := range operators {
+		if op.Role == OperatorRoleAdmin && !op.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSetupStore publica la lista de operadores usada por /api/login-setup
+func SetSetupStore(store *CredentialStore) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	setupStore = store
+
+	SetupUser = ""
+	if store != nil && len(store.Operators) > 0 {
+		SetupUser = store.Operators[0].User
+	}
+}
+
+// AuthenticateSetup valida un login de setup contra la lista de operadores
+func AuthenticateSetup(user, pass string) (*Operator, error) {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+
+	if setupStore == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return setupStore.Authenticate(user, pass)
+}
+
+// SetupOperator devuelve el operador habilitado con ese nombre; false si se
+// borró o se deshabilitó
+func SetupOperator(user string) (Operator, bool) {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+
+	if setupStore == nil {
+		return Operator{}, false
+	}
+	op := setupStore.Find(user)
+	if op == nil || op.Disabled {
+		return Operator{}, false
+	}
+	return *op, true
+}
+
+// WatchCredentialStore vuelve a leer config.json.enc con SIGHUP o cuando
+// cambia (por ejemplo después de 'key operator disable'), revisándolo cada
+// interval, hasta que ctx termina. Si la lectura falla se mantiene la lista
+// anterior.
+func WatchCredentialStore(ctx context.Context, keys secrets.Keyring, interval time.Duration) {
+	seen := encConfigModTime()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP: recargando los operadores de setup")
+			seen = encConfigModTime()
+		case <-ticker.C:
+			modTime := encConfigModTime()
+			if modTime.Equal(seen) {
+				continue
+			}
+			seen = modTime
+		}
+
+		store, err := LoadCredentialStore(keys)
+		if err != nil {
+			slog.Error("No se pudieron recargar los operadores de setup; se mantienen los anteriores", "error", err)
+			continue
+		}
+		SetSetupStore(store)
+		slog.Info("Operadores de setup recargados", "count", len(store.Operators))
+	}
+}
+
+// encConfigModTime es la fecha de modificación de config.json.enc (cero si
+// no existe)
+func encConfigModTime() time.Time {
+	info, err := os.Stat(EncConfigFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"password-recovery/secrets"
+)
+
+func TestWatchCredentialStore(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	t.Cleanup(func() { SetSetupStore(nil) })
+
+	raw, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := secrets.NewMasterKey(raw, secrets.SourceEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &CredentialStore{}
+	if err := store.Add("root", "RootPass123!", OperatorRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("ana", "AnaPass123!", OperatorRoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveCredentialStore(key, store); err != nil {
+		t.Fatal(err)
+	}
+	SetSetupStore(store)
+	if _, ok := SetupOperator("ANA"); !ok {
+		t.Fatal("SetupOperator no encontró a un operador habilitado")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchCredentialStore(ctx, secrets.Keyring{key}, 10*time.Millisecond)
+
+	// Lo mismo que hace 'key operator disable' desde otro proceso
+	changed, err := LoadCredentialStore(secrets.Keyring{key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changed.SetDisabled("ana", true); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := SaveCredentialStore(key, changed); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Second)
+	os.Chtimes(EncConfigFile, future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := SetupOperator("ana"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no se recargó config.json.enc")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := SetupOperator("root"); !ok {
+		t.Error("la recarga perdió al operador habilitado")
+	}
+	if _, ok := SetupOperator("nadie"); ok {
+		t.Error("SetupOperator encontró un operador inexistente")
+	}
+}
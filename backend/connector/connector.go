@@ -0,0 +1,202 @@
+// Package connector implementa el modo conector: en lugar de la tabla users
+// propia, las contraseñas se escriben en la tabla de usuarios de otra
+// aplicación que vive en la misma base de datos, con el formato de hash que
+// esa aplicación entiende.
+package connector
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/fielderr"
+	"password-recovery/passhash"
+)
+
+var (
+	ErrNotFound  = errors.New("la cuenta no existe en la tabla del conector")
+	ErrAmbiguous = errors.New("el identificador corresponde a más de una cuenta en la tabla del conector")
+	ErrSchema    = errors.New("la tabla o las columnas no existen")
+
+	identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+)
+
+// Config es el mapeo a la tabla externa
+type Config struct {
+	Schema           string    `json:"schema"`
+	Table            string    `json:"table"`
+	IdentifierColumn string    `json:"identifier_column"`
+	PasswordColumn   string    `json:"password_column"`
+	HashFormat       string    `json:"hash_format"`
+	UpdatedAtColumns []string  `json:"updated_at_columns"`
+	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+}
+
+// Validate revisa los nombres (solo letras, números y guion bajo) y el
+// formato de hash
+func (c *Config) Validate() error {
+	fields := map[string]string{}
+	if c.Schema != "" && !identPattern.MatchString(c.Schema) {
+		fields["schema"] = "nombre inválido"
+	}
+	for name, value := range map[string]string{
+		"table":             c.Table,
+		"identifier_column": c.IdentifierColumn,
+		"password_column":   c.PasswordColumn,
+	} {
+		if value == "" {
+			fields[name] = "es obligatorio"
+		} else if !identPattern.MatchString(value) {
+			fields[name] = "nombre inválido"
+		}
+	}
+	if !passhash.Valid(c.HashFormat) {
+		fields["hash_format"] = "use " + strings.Join(passhash.Formats(), ", ")
+	}
+	for _, col := range c.UpdatedAtColumns {
+		if !identPattern.MatchString(col) {
+			fields["updated_at_columns"] = "nombre inválido: " + col
+			break
+		}
+	}
+	if len(fields) > 0 {
+		return fielderr.In("configuración del conector inválida", fields)
+	}
+	return nil
+}
+
+func (c *Config) table(d database.Dialect) string {
+	if c.Schema != "" {
+		return d.QuoteIdent(c.Schema) + "." + d.QuoteIdent(c.Table)
+	}
+	return d.QuoteIdent(c.Table)
+}
+
+// Active devuelve el mapeo configurado o nil si el conector está apagado
+func Active(db database.Execer, d database.Dialect) (*Config, error) {
+	var c Config
+	var updatedCols string
+	err := db.QueryRow(`SELECT schema_name, table_name, identifier_column, password_column, hash_format, updated_at_columns, updated_at
+		FROM connector_config WHERE is_active = TRUE LIMIT 1`).
+		Scan(&c.Schema, &c.Table, &c.IdentifierColumn, &c.PasswordColumn, &c.HashFormat, &updatedCols, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.UpdatedAtColumns = splitColumns(updatedCols)
+	return &c, nil
+}
+
+// Save reemplaza el mapeo activo
+func Save(db *sql.DB, d database.Dialect, c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM connector_config"); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(d.Rebind(`INSERT INTO connector_config
+		(schema_name, table_name, identifier_column, password_column, hash_format, updated_at_columns, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`),
+		c.Schema, c.Table, c.IdentifierColumn, c.PasswordColumn, c.HashFormat,
+		strings.Join(c.UpdatedAtColumns, ","), true, now, now); err != nil {
+		return err
+	}
+	c.UpdatedAt = now
+	return tx.Commit()
+}
+
+// Disable apaga el modo conector; el flujo vuelve a la tabla users propia
+func Disable(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM connector_config")
+	return err
+}
+
+// Check verifica que la tabla y las columnas existan sin leer filas
+func Check(db *sql.DB, d database.Dialect, c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	cols := []string{d.QuoteIdent(c.IdentifierColumn), d.QuoteIdent(c.PasswordColumn)}
+	for _, col := range c.UpdatedAtColumns {
+		cols = append(cols, d.QuoteIdent(col))
+	}
+	rows, err := db.Query("SELECT " + strings.Join(cols, ", ") + " FROM " + c.table(d) + " WHERE 1 = 0")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSchema, err)
+	}
+	return rows.Close()
+}
+
+// Exists indica si hay exactamente una cuenta con ese correo (la
+// comparación ignora mayúsculas)
+func Exists(db database.Execer, d database.Dialect, c *Config, email string) (bool, error) {
+	var n int
+	err := db.QueryRow(d.Rebind("SELECT COUNT(*) FROM "+c.table(d)+
+		" WHERE LOWER("+d.QuoteIdent(c.IdentifierColumn)+") = $1"), strings.ToLower(email)).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	if n > 1 {
+		return false, ErrAmbiguous
+	}
+	return n == 1, nil
+}
+
+// SetPassword escribe el hash de la contraseña y las columnas de fecha de
+// la cuenta. exec puede ser una transacción.
+func SetPassword(exec database.Execer, d database.Dialect, c *Config, email, password string, now time.Time) error {
+	hash, err := passhash.Hash(c.HashFormat, password)
+	if err != nil {
+		return err
+	}
+
+	sets := []string{d.QuoteIdent(c.PasswordColumn) + " = $1"}
+	args := []interface{}{hash}
+	for _, col := range c.UpdatedAtColumns {
+		args = append(args, now)
+		sets = append(sets, fmt.Sprintf("%s = $%d", d.QuoteIdent(col), len(args)))
+	}
+	args = append(args, strings.ToLower(email))
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE LOWER(%s) = $%d",
+		c.table(d), strings.Join(sets, ", "), d.QuoteIdent(c.IdentifierColumn), len(args))
+
+	result, err := exec.Exec(d.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	switch {
+	case affected == 0:
+		return ErrNotFound
+	case affected > 1:
+		return ErrAmbiguous
+	}
+	return nil
+}
+
+func splitColumns(value string) []string {
+	cols := []string{}
+	for _, col := range strings.Split(value, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
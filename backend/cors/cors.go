@@ -0,0 +1,317 @@
+// Package cors aplica la misma política de CORS a los dos servidores (gin y
+// gorilla/mux). Cada grupo de rutas (admin, recuperación pública, setup)
+// tiene su política: orígenes permitidos, credenciales y cuánto tiempo el
+// navegador guarda la respuesta del preflight.
+//
+// La configuración sale del entorno; las variables de un grupo reemplazan a
+// las generales:
+//
+//	CORS_ORIGINS, CORS_<GRUPO>_ORIGINS          orígenes separados por coma
+//	                                            ("https://app.example.com",
+//	                                            "https://*.example.com", "*")
+//	CORS_CREDENTIALS, CORS_<GRUPO>_CREDENTIALS  true/false
+//	CORS_MAX_AGE, CORS_<GRUPO>_MAX_AGE          caché del preflight (12h)
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultOrigin = "http://localhost:3000"
+	DefaultMaxAge = 12 * time.Hour
+)
+
+var (
+	// DefaultMethods son los métodos que usan las dos APIs
+	DefaultMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	// DefaultHeaders son los headers que el frontend y otros servicios
+	// pueden enviar (sesión, API key, tenant, firma e idioma)
+	DefaultHeaders = []string{
+		"Accept", "Accept-Language", "Authorization", "Content-Type", "X-Requested-With",
+		"X-API-Key", "X-Tenant", "X-Signature", "X-Signature-Timestamp", "X-Request-ID",
+	}
+	// DefaultExposeHeaders son los headers de la respuesta que el
+	// navegador deja leer
+	DefaultExposeHeaders = []string{"Content-Disposition", "Content-Language", "Content-Length", "X-Request-ID"}
+)
+
+// Policy es la política de un grupo de rutas
+type Policy struct {
+	Origins       []string
+	Methods       []string
+	Headers       []string
+	ExposeHeaders []string
+	Credentials   bool
+	MaxAge        time.Duration
+}
+
+// Group es un grupo de rutas con su política. Name se usa en las variables
+// de entorno; un grupo sin Prefixes aplica a todas las demás rutas.
+type Group struct {
+	Name     string
+	Prefixes []string
+	// Credentials es el valor si no se configura CORS_<GRUPO>_CREDENTIALS
+	// ni CORS_CREDENTIALS
+	Credentials bool
+}
+
+// Rules elige la política de cada petición según su ruta
+type Rules struct {
+	groups   []compiled
+	fallback *compiled
+}
+
+type compiled struct {
+	name     string
+	prefixes []string
+	policy   Policy
+	exact    map[string]bool
+	wildcard []wildcard
+	any      bool
+	methods  string
+	allowed  string
+	expose   string
+	maxAge   string
+}
+
+// wildcard es un origen "https://*.example.com": cualquier subdominio con
+// el mismo esquema y puerto
+type wildcard struct {
+	prefix string // "https://"
+	suffix string // ".example.com"
+}
+
+// Load arma las reglas con la política de cada grupo leída del entorno
+func Load(groups ...Group) (*Rules, error) {
+	rules := &Rules{}
+	for _, g := range groups {
+		policy, err := PolicyFromEnv(g)
+		if err != nil {
+			return nil, err
+		}
+		c, err := compile(g, policy)
+		if err != nil {
+			return nil, err
+		}
+		if len(g.Prefixes) == 0 {
+			rules.fallback = c
+			continue
+		}
+		rules.groups = append(rules.groups, *c)
+	}
+	return rules, nil
+}
+
+// PolicyFromEnv lee la política de un grupo
+func PolicyFromEnv(g Group) (Policy, error) {
+	name := strings.ToUpper(g.Name)
+	policy := Policy{
+		Origins:       splitList(env(name, "ORIGINS", DefaultOrigin)),
+		Methods:       DefaultMethods,
+		Headers:       DefaultHeaders,
+		ExposeHeaders: DefaultExposeHeaders,
+		Credentials:   g.Credentials,
+		MaxAge:        DefaultMaxAge,
+	}
+	if v := env(name, "CREDENTIALS", ""); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return policy, fmt.Errorf("CORS_%s_CREDENTIALS inválido: %q", name, v)
+		}
+		policy.Credentials = b
+	}
+	if v := env(name, "MAX_AGE", ""); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return policy, fmt.Errorf("CORS_%s_MAX_AGE inválido: %q", name, v)
+		}
+		policy.MaxAge = d
+	}
+	return policy, nil
+}
+
+// env lee CORS_<GRUPO>_<KEY> y si no existe CORS_<KEY>
+func env(group, key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv("CORS_" + group + "_" + key)); v != "" {
+		return v
+	}
+	if v := strings.TrimSpace(os.Getenv("CORS_" + key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func compile(g Group, p Policy) (*compiled, error) {
+	c := &compiled{
+		name:     g.Name,
+		prefixes: g.Prefixes,
+		policy:   p,
+		exact:    map[string]bool{},
+		methods:  strings.Join(p.Methods, ", "),
+		allowed:  strings.Join(p.Headers, ", "),
+		expose:   strings.Join(p.ExposeHeaders, ", "),
+		maxAge:   strconv.Itoa(int(p.MaxAge.Seconds())),
+	}
+	for _, origin := range p.Origins {
+		if origin == "*" {
+			if p.Credentials {
+				return nil, fmt.Errorf("CORS %s: el origen \"*\" no se puede usar con credenciales", g.Name)
+			}
+			c.any = true
+			continue
+		}
+		origin = strings.ToLower(strings.TrimRight(origin, "/"))
+		if err := validOrigin(strings.Replace(origin, "*.", "", 1)); err != nil {
+			return nil, fmt.Errorf("CORS %s: origen %q inválido: %w", g.Name, origin, err)
+		}
+		if i := strings.Index(origin, "://*."); i >= 0 && !strings.Contains(origin[i+4:], "*") {
+			c.wildcard = append(c.wildcard, wildcard{prefix: origin[:i+3], suffix: origin[i+4:]})
+			continue
+		}
+		if strings.Contains(origin, "*") {
+			return nil, fmt.Errorf("CORS %s: origen %q inválido: el comodín solo puede ir al inicio del host (https://*.example.com)", g.Name, origin)
+		}
+		c.exact[origin] = true
+	}
+	return c, nil
+}
+
+// validOrigin exige esquema://host[:puerto] sin ruta
+func validOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("use http:// o https://")
+	}
+	if u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return errors.New("use solo esquema, host y puerto")
+	}
+	return nil
+}
+
+// allows indica si el origen está permitido
+func (c *compiled) allows(origin string) bool {
+	if c.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.exact[origin] {
+		return true
+	}
+	for _, w := range c.wildcard {
+		if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+			continue
+		}
+		sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+		if sub != "" && !strings.ContainsAny(sub, ":/@") && !strings.HasSuffix(sub, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// policyFor devuelve el grupo de la ruta (nil: sin CORS)
+func (r *Rules) policyFor(path string) *compiled {
+	for i := range r.groups {
+		for _, prefix := range r.groups[i].prefixes {
+			if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+				return &r.groups[i]
+			}
+		}
+	}
+	return r.fallback
+}
+
+// handle escribe los headers de CORS; devuelve true si era un preflight y
+// ya se respondió
+func (r *Rules) handle(w http.ResponseWriter, req *http.Request) bool {
+	c := r.policyFor(req.URL.Path)
+	if c == nil {
+		return false
+	}
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if !c.allows(origin) {
+		if preflight {
+			// Sin headers de CORS el navegador bloquea la petición
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return preflight
+	}
+	if c.any && !c.policy.Credentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.policy.Credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if c.expose != "" {
+			header.Set("Access-Control-Expose-Headers", c.expose)
+		}
+		return false
+	}
+
+	header.Set("Access-Control-Allow-Methods", c.methods)
+	header.Set("Access-Control-Allow-Headers", c.allowed)
+	if c.policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// Gin aplica las reglas en el servidor principal; los preflight se
+// responden aquí, antes de la autenticación y la validación
+func (r *Rules) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.handle(c.Writer, c.Request) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Mux es el equivalente de Gin para el servidor de setup
+func (r *Rules) Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.handle(w, req) {
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
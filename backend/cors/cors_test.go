@@ -0,0 +1,75 @@
+package cors
+
+import "testing"
+
+func TestWildcardOrigins(t *testing.T) {
+	c, err := compile(Group{Name: "api"}, Policy{Origins: []string{
+		"https://*.example.com",
+		"http://*.dev.example.org:8080/",
+		"https://app.example.net",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://a.b.example.com", true},
+		{"HTTPS://App.Example.COM", true},
+		{"https://example.com", false},
+		{"https://.example.com", false},
+		{"https://a..example.com", false},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"https://app.example.com.evil.com", false},
+		{"https://evilexample.com", false},
+		{"https://user@app.example.com", false},
+		{"https://evil.com/.example.com", false},
+		{"https://evil.com:443.example.com", false},
+		{"http://web.dev.example.org:8080", true},
+		{"http://web.dev.example.org", false},
+		{"http://web.dev.example.org:9090", false},
+		{"https://app.example.net", true},
+		{"https://x.app.example.net", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := c.allows(tt.origin); got != tt.want {
+				t.Errorf("allows(%q) = %v, se esperaba %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsBadWildcards(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+	}{
+		{"comodín en medio del host", Policy{Origins: []string{"https://app.*.example.com"}}},
+		{"comodín sin punto", Policy{Origins: []string{"https://*example.com"}}},
+		{"comodín con ruta", Policy{Origins: []string{"https://*.example.com/app"}}},
+		{"comodín sin esquema", Policy{Origins: []string{"*.example.com"}}},
+		{"dos comodines", Policy{Origins: []string{"https://*.*.example.com"}}},
+		{"cualquiera con credenciales", Policy{Origins: []string{"*"}, Credentials: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := compile(Group{Name: "api"}, tt.policy); err == nil {
+				t.Errorf("se aceptó %v", tt.policy.Origins)
+			}
+		})
+	}
+
+	c, err := compile(Group{Name: "api"}, Policy{Origins: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.allows("https://cualquiera.example") {
+		t.Error(`"*" sin credenciales debe aceptar cualquier origen`)
+	}
+}
@@ -0,0 +1,208 @@
+// Package database aísla las diferencias entre los motores soportados
+// (PostgreSQL, MySQL y SQLite): drivers, DDL de las migraciones y sintaxis
+// de las consultas.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Tipos de base de datos aceptados en DBConfig.DBType
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// Dialect describe lo que cambia entre motores
+type Dialect interface {
+	// Name es el valor de db_type
+	Name() string
+	// DriverName es el nombre registrado en database/sql
+	DriverName() string
+	// Gorm devuelve el dialector de gorm para el DSN
+	Gorm(dsn string) gorm.Dialector
+	// Rebind convierte los marcadores $1, $2... al estilo del motor; deben
+	// ir en orden y sin repetirse
+	Rebind(query string) string
+	// SupportsReturning indica si INSERT ... RETURNING está disponible
+	SupportsReturning() bool
+	// QuoteIdent escapa un nombre de tabla o columna
+	QuoteIdent(name string) string
+	// VersionQuery y TableCountQuery se usan al probar la conexión
+	VersionQuery() string
+	TableCountQuery() string
+	// Tipos de columna usados por las migraciones
+	types() columnTypes
+}
+
+type columnTypes struct {
+	Name      string // motor, para el DDL que no es portable
+	ID        string // llave primaria autoincremental
+	Timestamp string // fecha con zona horaria
+	Now       string // valor por defecto "ahora"
+	Text      string // texto sin límite práctico
+	// CreateIndex es el prefijo para crear índices (MySQL no soporta
+	// IF NOT EXISTS; como cada migración corre una sola vez no hace falta)
+	CreateIndex string
+}
+
+// ForType devuelve el dialecto de un db_type ("" equivale a postgres)
+func ForType(dbType string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(dbType)) {
+	case "", Postgres, "postgresql":
+		return postgresDialect{}, nil
+	case MySQL, "mariadb":
+		return mysqlDialect{}, nil
+	case SQLite, "sqlite3":
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("tipo de base de datos no soportado: %s", dbType)
+	}
+}
+
+// MustForType es ForType para tipos ya validados
+func MustForType(dbType string) Dialect {
+	d, err := ForType(dbType)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// ForGorm devuelve el dialecto de una conexión gorm abierta
+func ForGorm(db *gorm.DB) Dialect {
+	return MustForType(db.Dialector.Name())
+}
+
+// SupportedTypes lista los valores válidos de db_type
+func SupportedTypes() []string {
+	return []string{Postgres, MySQL, SQLite}
+}
+
+var placeholderPattern = regexp.MustCompile(`\$\d+`)
+
+// rebindQuestion reemplaza $N por ?. Con ? los argumentos se asignan por
+// posición, así que los marcadores tienen que ir en orden ($1, $2...) y sin
+// repetirse; si no, $2 recibiría el valor de $1 sin ningún error. Una
+// consulta así es un error de programación y provoca panic (como
+// MustForType).
+func rebindQuestion(query string) string {
+	next := 1
+	return placeholderPattern.ReplaceAllStringFunc(query, func(m string) string {
+		if n, err := strconv.Atoi(m[1:]); err != nil || n != next {
+			panic(fmt.Sprintf("database: marcador %s fuera de orden o repetido (se esperaba $%d) en %q", m, next, query))
+		}
+		next++
+		return "?"
+	})
+}
+
+// quoteWith encierra un identificador duplicando las comillas internas
+func quoteWith(name string, q rune) string {
+	quote := string(q)
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string                   { return Postgres }
+func (postgresDialect) DriverName() string             { return "postgres" }
+func (postgresDialect) Gorm(dsn string) gorm.Dialector { return postgres.Open(dsn) }
+func (postgresDialect) Rebind(query string) string     { return query }
+func (postgresDialect) SupportsReturning() bool        { return true }
+func (postgresDialect) QuoteIdent(name string) string  { return quoteWith(name, '"') }
+func (postgresDialect) VersionQuery() string           { return "SELECT version()" }
+func (postgresDialect) TableCountQuery() string {
+	return "SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema()"
+}
+func (postgresDialect) types() columnTypes {
+	return columnTypes{
+		Name:        Postgres,
+		ID:          "SERIAL PRIMARY KEY",
+		Timestamp:   "TIMESTAMP WITH TIME ZONE",
+		Now:         "NOW()",
+		Text:        "TEXT",
+		CreateIndex: "CREATE INDEX IF NOT EXISTS",
+	}
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string                   { return MySQL }
+func (mysqlDialect) DriverName() string             { return "mysql" }
+func (mysqlDialect) Gorm(dsn string) gorm.Dialector { return mysql.Open(dsn) }
+func (mysqlDialect) Rebind(query string) string     { return rebindQuestion(query) }
+func (mysqlDialect) SupportsReturning() bool        { return false }
+func (mysqlDialect) QuoteIdent(name string) string  { return quoteWith(name, '`') }
+func (mysqlDialect) VersionQuery() string           { return "SELECT VERSION()" }
+func (mysqlDialect) TableCountQuery() string {
+	return "SELECT count(*) FROM information_schema.tables WHERE table_schema = DATABASE()"
+}
+func (mysqlDialect) types() columnTypes {
+	return columnTypes{
+		Name:        MySQL,
+		ID:          "INT AUTO_INCREMENT PRIMARY KEY",
+		Timestamp:   "DATETIME(6)",
+		Now:         "CURRENT_TIMESTAMP(6)",
+		Text:        "TEXT",
+		CreateIndex: "CREATE INDEX",
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string                   { return SQLite }
+func (sqliteDialect) DriverName() string             { return "sqlite3" }
+func (sqliteDialect) Gorm(dsn string) gorm.Dialector { return sqlite.Open(dsn) }
+func (sqliteDialect) Rebind(query string) string     { return rebindQuestion(query) }
+func (sqliteDialect) SupportsReturning() bool        { return true }
+func (sqliteDialect) QuoteIdent(name string) string  { return quoteWith(name, '"') }
+func (sqliteDialect) VersionQuery() string           { return "SELECT sqlite_version()" }
+func (sqliteDialect) TableCountQuery() string {
+	return "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+}
+func (sqliteDialect) types() columnTypes {
+	return columnTypes{
+		Name:        SQLite,
+		ID:          "INTEGER PRIMARY KEY AUTOINCREMENT",
+		Timestamp:   "DATETIME",
+		Now:         "CURRENT_TIMESTAMP",
+		Text:        "TEXT",
+		CreateIndex: "CREATE INDEX IF NOT EXISTS",
+	}
+}
+
+// Execer es lo común entre *sql.DB y *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// InsertID ejecuta un INSERT (con marcadores $N) y devuelve el id generado,
+// usando RETURNING cuando el motor lo soporta y LastInsertId si no.
+func InsertID(db Execer, d Dialect, query string, args ...interface{}) (int64, error) {
+	if d.SupportsReturning() {
+		var id int64
+		err := db.QueryRow(d.Rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.Exec(d.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
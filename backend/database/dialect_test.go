@@ -0,0 +1,53 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		postgres string
+		question string
+	}{
+		{"sin marcadores", "SELECT 1", "SELECT 1", "SELECT 1"},
+		{"en orden", "SELECT id FROM users WHERE tenant_id = $1 AND email = $2",
+			"SELECT id FROM users WHERE tenant_id = $1 AND email = $2",
+			"SELECT id FROM users WHERE tenant_id = ? AND email = ?"},
+		{"más de nueve", "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"},
+		{"$ sin número", "SELECT 1 WHERE password LIKE '$argon2id$%' AND id = $1",
+			"SELECT 1 WHERE password LIKE '$argon2id$%' AND id = $1",
+			"SELECT 1 WHERE password LIKE '$argon2id$%' AND id = ?"},
+	}
+	for _, tt := range tests {
+		if got := MustForType(Postgres).Rebind(tt.query); got != tt.postgres {
+			t.Errorf("%s: postgres = %q, se esperaba %q", tt.name, got, tt.postgres)
+		}
+		for _, dbType := range []string{MySQL, SQLite} {
+			if got := MustForType(dbType).Rebind(tt.query); got != tt.question {
+				t.Errorf("%s: %s = %q, se esperaba %q", tt.name, dbType, got, tt.question)
+			}
+		}
+	}
+}
+
+func TestRebindRejectsOutOfOrder(t *testing.T) {
+	for _, query := range []string{
+		"UPDATE users SET email = $2 WHERE id = $1",
+		"SELECT 1 WHERE a = $1 OR b = $1",
+		"SELECT 1 WHERE a = $2",
+		"SELECT 1 WHERE a = $0",
+	} {
+		for _, dbType := range []string{MySQL, SQLite} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: Rebind(%q) no rechazó los marcadores", dbType, query)
+					}
+				}()
+				MustForType(dbType).Rebind(query)
+			}()
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Parámetros del pool compartido
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = 5 * time.Minute
+	// Tiempo máximo que se espera a las peticiones en curso antes de cerrar
+	// el pool anterior tras un cambio de configuración
+	drainTimeout = 30 * time.Second
+)
+
+var ErrNotConnected = errors.New("la base de datos no está configurada")
+
+// Pool es una conexión abierta con su dialecto. Las peticiones deben
+// obtenerla con Manager.Acquire y liberarla al terminar.
+type Pool struct {
+	SQL     *sql.DB
+	Gorm    *gorm.DB
+	Dialect Dialect
+
+	key      string
+	openedAt time.Time
+	inFlight sync.WaitGroup
+	active   int64
+}
+
+// Manager es dueño del pool de conexiones y lo reemplaza de forma atómica
+// cuando cambia la configuración.
+type Manager struct {
+	mu      sync.RWMutex
+	current *Pool
+	swaps   int64
+}
+
+// Default es el pool compartido por los handlers
+var Default = &Manager{}
+
+// poolKey identifica una configuración sin guardar el DSN (tiene contraseña)
+func poolKey(d Dialect, dsn string) string {
+	sum := sha256.Sum256([]byte(d.Name() + "|" + dsn))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Open abre y verifica un pool nuevo sin publicarlo
+func Open(d Dialect, dsn string) (*Pool, error) {
+	sqlDB, err := sql.Open(d.DriverName(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(defaultMaxOpenConns)
+	sqlDB.SetMaxIdleConns(defaultMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(defaultConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// gorm reutiliza el *sql.DB, así que comparten el mismo pool
+	gormDB, err := gorm.Open(d.Gorm(dsn), &gorm.Config{
+		ConnPool: sqlDB,
+		Logger:   logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return &Pool{
+		SQL:      sqlDB,
+		Gorm:     gormDB,
+		Dialect:  d,
+		key:      poolKey(d, dsn),
+		openedAt: time.Now(),
+	}, nil
+}
+
+// Acquire devuelve el pool actual y una función para liberarlo. Mientras no
+// se libere, un Swap no cerrará ese pool.
+func (m *Manager) Acquire() (*Pool, func(), error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pool := m.current
+	if pool == nil {
+		return nil, func() {}, ErrNotConnected
+	}
+	pool.inFlight.Add(1)
+	atomic.AddInt64(&pool.active, 1)
+
+	var once sync.Once
+	return pool, func() {
+		once.Do(func() {
+			atomic.AddInt64(&pool.active, -1)
+			pool.inFlight.Done()
+		})
+	}, nil
+}
+
+// Current devuelve el pool sin reservarlo (para lecturas de estado)
+func (m *Manager) Current() *Pool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// Matches indica si el pool actual corresponde a esta configuración
+func (m *Manager) Matches(d Dialect, dsn string) bool {
+	pool := m.Current()
+	return pool != nil && pool.key == poolKey(d, dsn)
+}
+
+// Ensure conecta solo si la configuración cambió
+func (m *Manager) Ensure(d Dialect, dsn string) error {
+	if m.Matches(d, dsn) {
+		return nil
+	}
+	return m.Swap(d, dsn)
+}
+
+// Swap abre un pool nuevo, lo publica y cierra el anterior cuando terminen
+// las peticiones que lo estaban usando. Si el pool nuevo no se puede abrir
+// se conserva el actual.
+func (m *Manager) Swap(d Dialect, dsn string) error {
+	pool, err := Open(d, dsn)
+	if err != nil {
+		return fmt.Errorf("error al conectar: %v", err)
+	}
+	m.Replace(pool)
+	return nil
+}
+
+// Replace publica un pool ya abierto con Open y drena el anterior
+func (m *Manager) Replace(pool *Pool) {
+	m.mu.Lock()
+	old := m.current
+	m.current = pool
+	m.swaps++
+	m.mu.Unlock()
+
+	if old != nil {
+		go drain(old)
+	}
+}
+
+// Disconnect cierra el pool actual (por ejemplo al resetear la configuración)
+func (m *Manager) Disconnect() {
+	m.mu.Lock()
+	old := m.current
+	m.current = nil
+	m.mu.Unlock()
+
+	if old != nil {
+		go drain(old)
+	}
+}
+
+// drain espera a las peticiones en curso y cierra el pool. sql.DB.Close
+// además espera a que terminen las consultas ya iniciadas.
+func drain(pool *Pool) {
+	done := make(chan struct{})
+	go func() {
+		pool.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(drainTimeout):
+		slog.Warn("Cerrando pool anterior con peticiones en curso", "in_flight", atomic.LoadInt64(&pool.active))
+	}
+	pool.SQL.Close()
+}
+
+// PoolStats resume el estado del pool para /api/db/pool y métricas
+type PoolStats struct {
+	Connected         bool      `json:"connected"`
+	DBType            string    `json:"db_type,omitempty"`
+	OpenedAt          time.Time `json:"opened_at,omitempty"`
+	Swaps             int64     `json:"swaps"`
+	InFlight          int64     `json:"in_flight"`
+	MaxOpen           int       `json:"max_open_connections"`
+	Open              int       `json:"open_connections"`
+	InUse             int       `json:"in_use"`
+	Idle              int       `json:"idle"`
+	WaitCount         int64     `json:"wait_count"`
+	WaitDurationMs    int64     `json:"wait_duration_ms"`
+	MaxIdleClosed     int64     `json:"max_idle_closed"`
+	MaxLifetimeClosed int64     `json:"max_lifetime_closed"`
+}
+
+// Stats devuelve las estadísticas del pool actual
+func (m *Manager) Stats() PoolStats {
+	m.mu.RLock()
+	pool, swaps := m.current, m.swaps
+	m.mu.RUnlock()
+
+	stats := PoolStats{Swaps: swaps}
+	if pool == nil {
+		return stats
+	}
+
+	s := pool.SQL.Stats()
+	stats.Connected = true
+	stats.DBType = pool.Dialect.Name()
+	stats.OpenedAt = pool.openedAt
+	stats.InFlight = atomic.LoadInt64(&pool.active)
+	stats.MaxOpen = s.MaxOpenConnections
+	stats.Open = s.OpenConnections
+	stats.InUse = s.InUse
+	stats.Idle = s.Idle
+	stats.WaitCount = s.WaitCount
+	stats.WaitDurationMs = s.WaitDuration.Milliseconds()
+	stats.MaxIdleClosed = s.MaxIdleClosed
+	stats.MaxLifetimeClosed = s.MaxLifetimeClosed
+	return stats
+}
@@ -0,0 +1,490 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration es un cambio de esquema versionado. Up recibe los tipos del
+// motor para generar el DDL correspondiente.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(t columnTypes) []string
+}
+
+// migrations debe mantenerse en orden ascendente de versión
+var migrations = []Migration{
+	{1, "esquema inicial", initialSchema},
+	{2, "eventos de auditoría", auditEvents},
+	{3, "estado y fechas de usuarios", userStatus},
+	{4, "roles y sesiones", rolesAndSessions},
+	{5, "importación de usuarios e invitaciones", userImport},
+	{6, "modo conector", connectorConfig},
+	{7, "directorio LDAP", ldapConfig},
+	{8, "multi-tenant", tenants},
+	{9, "API keys", apiKeys},
+	{10, "webhooks", webhooks},
+	{11, "idiomas", translations},
+	{12, "contraseñas con hash", hashedPasswords},
+	{13, "correo único por tenant", tenantEmails},
+	{14, "tenant de los eventos de auditoría", auditTenants},
+	{15, "bind_password cifrada", sealedBindPassword},
+}
+
+func initialSchema(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS smtp_config (
+			id ` + t.ID + `,
+			host VARCHAR(100) NOT NULL,
+			port INTEGER NOT NULL,
+			username VARCHAR(100) NOT NULL,
+			password VARCHAR(100) NOT NULL,
+			from_email VARCHAR(100) NOT NULL,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+		)`,
+		t.CreateIndex + ` idx_smtp_config_active ON smtp_config(is_active)`,
+
+		`CREATE TABLE IF NOT EXISTS users (
+			id ` + t.ID + `,
+			email VARCHAR(100) UNIQUE NOT NULL,
+			password VARCHAR(100) NOT NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS reset_codes (
+			id ` + t.ID + `,
+			user_id INTEGER NOT NULL,
+			code VARCHAR(10) NOT NULL,
+			expiration_time ` + t.Timestamp + ` NOT NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`,
+		t.CreateIndex + ` idx_reset_codes_expiration ON reset_codes(expiration_time)`,
+	}
+}
+
+func auditEvents(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id ` + t.ID + `,
+			actor VARCHAR(255) NOT NULL,
+			action VARCHAR(100) NOT NULL,
+			target VARCHAR(255) NOT NULL DEFAULT '',
+			ip VARCHAR(64) NOT NULL DEFAULT '',
+			user_agent VARCHAR(512) NOT NULL DEFAULT '',
+			result VARCHAR(20) NOT NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+		)`,
+		t.CreateIndex + ` idx_audit_events_created ON audit_events(created_at)`,
+		t.CreateIndex + ` idx_audit_events_action ON audit_events(action)`,
+		t.CreateIndex + ` idx_audit_events_actor ON audit_events(actor)`,
+	}
+}
+
+func userStatus(t columnTypes) []string {
+	return []string{
+		`ALTER TABLE users ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'`,
+		`ALTER TABLE users ADD COLUMN last_password_change ` + t.Timestamp + ` NULL`,
+		`ALTER TABLE users ADD COLUMN last_login ` + t.Timestamp + ` NULL`,
+		t.CreateIndex + ` idx_users_status ON users(status)`,
+		t.CreateIndex + ` idx_reset_codes_user ON reset_codes(user_id)`,
+	}
+}
+
+func rolesAndSessions(t columnTypes) []string {
+	return []string{
+		`ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user'`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id ` + t.ID + `,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			expires_at ` + t.Timestamp + ` NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`,
+		t.CreateIndex + ` idx_sessions_expires ON sessions(expires_at)`,
+	}
+}
+
+func userImport(t columnTypes) []string {
+	return []string{
+		`ALTER TABLE users ADD COLUMN name VARCHAR(100) NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN locale VARCHAR(10) NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS invitations (
+			id ` + t.ID + `,
+			user_id INTEGER NOT NULL,
+			email VARCHAR(100) NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error VARCHAR(500) NOT NULL DEFAULT '',
+			next_attempt_at ` + t.Timestamp + ` NOT NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			sent_at ` + t.Timestamp + ` NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`,
+		t.CreateIndex + ` idx_invitations_due ON invitations(status, next_attempt_at)`,
+	}
+}
+
+func connectorConfig(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS connector_config (
+			id ` + t.ID + `,
+			schema_name VARCHAR(63) NOT NULL DEFAULT '',
+			table_name VARCHAR(63) NOT NULL,
+			identifier_column VARCHAR(63) NOT NULL,
+			password_column VARCHAR(63) NOT NULL,
+			hash_format VARCHAR(20) NOT NULL,
+			updated_at_columns VARCHAR(255) NOT NULL DEFAULT '',
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+		)`,
+	}
+}
+
+func ldapConfig(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ldap_config (
+			id ` + t.ID + `,
+			url VARCHAR(255) NOT NULL,
+			start_tls BOOLEAN NOT NULL DEFAULT FALSE,
+			insecure_skip_verify BOOLEAN NOT NULL DEFAULT FALSE,
+			ca_cert_file VARCHAR(255) NOT NULL DEFAULT '',
+			bind_dn VARCHAR(255) NOT NULL,
+			bind_password VARCHAR(255) NOT NULL DEFAULT '',
+			base_dn VARCHAR(255) NOT NULL,
+			search_filter VARCHAR(255) NOT NULL,
+			mode VARCHAR(20) NOT NULL,
+			timeout_seconds INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+		)`,
+	}
+}
+
+// tenants crea el tenant por defecto (id 1) y asigna a él las filas
+// existentes
+func tenants(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS tenants (
+			id ` + t.ID + `,
+			slug VARCHAR(50) UNIQUE NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			hosts VARCHAR(500) NOT NULL DEFAULT '',
+			api_key_hash VARCHAR(64) NULL,
+			code_length INTEGER NOT NULL DEFAULT 8,
+			code_ttl_seconds INTEGER NOT NULL DEFAULT 300,
+			redirect_allowlist VARCHAR(1000) NOT NULL DEFAULT '',
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+		)`,
+		t.CreateIndex + ` idx_tenants_api_key ON tenants(api_key_hash)`,
+		`INSERT INTO tenants (slug, name) VALUES ('default', 'Predeterminado')`,
+
+		`ALTER TABLE users ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE reset_codes ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE smtp_config ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1`,
+		t.CreateIndex + ` idx_users_tenant ON users(tenant_id)`,
+		t.CreateIndex + ` idx_reset_codes_tenant_code ON reset_codes(tenant_id, code)`,
+		t.CreateIndex + ` idx_smtp_config_tenant ON smtp_config(tenant_id, is_active)`,
+
+		`CREATE TABLE IF NOT EXISTS email_templates (
+			id ` + t.ID + `,
+			tenant_id INTEGER NOT NULL,
+			kind VARCHAR(30) NOT NULL,
+			subject VARCHAR(200) NOT NULL,
+			body ` + t.Text + ` NOT NULL,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			UNIQUE (tenant_id, kind),
+			FOREIGN KEY (tenant_id) REFERENCES tenants(id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS admin_tenants (
+			tenant_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			PRIMARY KEY (tenant_id, user_id),
+			FOREIGN KEY (tenant_id) REFERENCES tenants(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`,
+	}
+}
+
+func apiKeys(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id ` + t.ID + `,
+			tenant_id INTEGER NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			prefix VARCHAR(20) NOT NULL,
+			key_hash VARCHAR(64) UNIQUE NOT NULL,
+			signing_secret VARCHAR(100) NOT NULL,
+			scopes VARCHAR(500) NOT NULL,
+			require_signature BOOLEAN NOT NULL DEFAULT FALSE,
+			expires_at ` + t.Timestamp + ` NULL,
+			last_used_at ` + t.Timestamp + ` NULL,
+			created_by INTEGER NULL,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			revoked_at ` + t.Timestamp + ` NULL,
+			FOREIGN KEY (tenant_id) REFERENCES tenants(id)
+		)`,
+		t.CreateIndex + ` idx_api_keys_tenant ON api_keys(tenant_id)`,
+	}
+}
+
+func webhooks(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id ` + t.ID + `,
+			tenant_id INTEGER NOT NULL,
+			url VARCHAR(500) NOT NULL,
+			description VARCHAR(200) NOT NULL DEFAULT '',
+			secret VARCHAR(100) NOT NULL,
+			events VARCHAR(500) NOT NULL,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			FOREIGN KEY (tenant_id) REFERENCES tenants(id)
+		)`,
+		t.CreateIndex + ` idx_webhooks_tenant ON webhooks(tenant_id)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id ` + t.ID + `,
+			webhook_id INTEGER NOT NULL,
+			event_id VARCHAR(40) NOT NULL,
+			event_type VARCHAR(50) NOT NULL,
+			payload ` + t.Text + ` NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at ` + t.Timestamp + ` NOT NULL,
+			response_status INTEGER NOT NULL DEFAULT 0,
+			last_error VARCHAR(500) NOT NULL DEFAULT '',
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			delivered_at ` + t.Timestamp + ` NULL,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+		)`,
+		t.CreateIndex + ` idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`,
+		t.CreateIndex + ` idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id)`,
+	}
+}
+
+// translations agrega los textos personalizados por tenant e idioma y el
+// idioma de las plantillas de correo. La tabla de plantillas se recrea para
+// cambiar su llave única; las personalizadas hasta ahora están en español.
+func translations(t columnTypes) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS translations (
+			id ` + t.ID + `,
+			tenant_id INTEGER NOT NULL,
+			locale VARCHAR(10) NOT NULL,
+			msg_key VARCHAR(100) NOT NULL,
+			message ` + t.Text + ` NOT NULL,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			UNIQUE (tenant_id, locale, msg_key),
+			FOREIGN KEY (tenant_id) REFERENCES tenants(id)
+		)`,
+
+		`CREATE TABLE email_templates_i18n (
+			id ` + t.ID + `,
+			tenant_id INTEGER NOT NULL,
+			kind VARCHAR(30) NOT NULL,
+			locale VARCHAR(10) NOT NULL,
+			subject VARCHAR(200) NOT NULL,
+			body ` + t.Text + ` NOT NULL,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			UNIQUE (tenant_id, kind, locale),
+			FOREIGN KEY (tenant_id) REFERENCES tenants(id)
+		)`,
+		`INSERT INTO email_templates_i18n (tenant_id, kind, locale, subject, body, updated_at)
+			SELECT tenant_id, kind, 'es-MX', subject, body, updated_at FROM email_templates`,
+		`DROP TABLE email_templates`,
+		`ALTER TABLE email_templates_i18n RENAME TO email_templates`,
+	}
+}
+
+// hashedPasswords descarta las contraseñas guardadas en claro. No se
+// pueden convertir en SQL, así que esas cuentas quedan en reset_required y
+// definen una nueva con un código; las que empiezan con "!" ya eran
+// inutilizables (importadas o de un destino externo).
+func hashedPasswords(t columnTypes) []string {
+	plaintext := `password NOT LIKE '$argon2id$%' AND password NOT LIKE '!%'`
+	return []string{
+		`UPDATE users SET status = 'reset_required' WHERE status <> 'disabled' AND ` + plaintext,
+		`UPDATE users SET password = '!' WHERE ` + plaintext,
+	}
+}
+
+// tenantEmails cambia UNIQUE(email) por UNIQUE(tenant_id, email): el mismo
+// correo puede tener cuenta en varios tenants. SQLite no permite quitar la
+// restricción, así que la tabla se copia y se vuelve a crear con los mismos
+// id; las llaves foráneas se revisan al confirmar y la secuencia de
+// AUTOINCREMENT se conserva para no reutilizar id.
+func tenantEmails(t columnTypes) []string {
+	unique := `CREATE UNIQUE INDEX idx_users_tenant_email ON users(tenant_id, email)`
+	switch t.Name {
+	case Postgres:
+		return []string{`ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key`, unique}
+	case MySQL:
+		return []string{`ALTER TABLE users DROP INDEX email`, unique}
+	}
+	columns := "id, tenant_id, email, password, name, locale, role, status, created_at, updated_at, last_password_change, last_login"
+	return []string{
+		`PRAGMA defer_foreign_keys = ON`,
+		`CREATE TEMP TABLE users_old AS SELECT * FROM users`,
+		`CREATE TEMP TABLE users_seq AS SELECT seq FROM sqlite_sequence WHERE name = 'users'`,
+		`DROP TABLE users`,
+		`CREATE TABLE users (
+			id ` + t.ID + `,
+			tenant_id INTEGER NOT NULL DEFAULT 1,
+			email VARCHAR(100) NOT NULL,
+			password VARCHAR(100) NOT NULL,
+			name VARCHAR(100) NOT NULL DEFAULT '',
+			locale VARCHAR(10) NOT NULL DEFAULT '',
+			role VARCHAR(20) NOT NULL DEFAULT 'user',
+			status VARCHAR(20) NOT NULL DEFAULT 'active',
+			created_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			updated_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `,
+			last_password_change ` + t.Timestamp + ` NULL,
+			last_login ` + t.Timestamp + ` NULL
+		)`,
+		`INSERT INTO users (` + columns + `) SELECT ` + columns + ` FROM users_old`,
+		`UPDATE sqlite_sequence SET seq = (SELECT MAX(seq) FROM users_seq)
+			WHERE name = 'users' AND seq < (SELECT MAX(seq) FROM users_seq)`,
+		`DROP TABLE users_old`,
+		`DROP TABLE users_seq`,
+		t.CreateIndex + ` idx_users_status ON users(status)`,
+		t.CreateIndex + ` idx_users_tenant ON users(tenant_id)`,
+		unique,
+	}
+}
+
+// auditTenants guarda el tenant de cada evento; los anteriores quedan en el
+// tenant por defecto, como las filas de la migración multi-tenant
+func auditTenants(t columnTypes) []string {
+	return []string{
+		`ALTER TABLE audit_events ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1`,
+		t.CreateIndex + ` idx_audit_events_tenant ON audit_events(tenant_id, created_at)`,
+	}
+}
+
+// sealedBindPassword amplía ldap_config.bind_password para el valor cifrado
+// (ldapdir la cifra al arrancar). SQLite no limita el largo de VARCHAR.
+func sealedBindPassword(t columnTypes) []string {
+	switch t.Name {
+	case Postgres:
+		return []string{`ALTER TABLE ldap_config ALTER COLUMN bind_password TYPE VARCHAR(1024)`}
+	case MySQL:
+		return []string{`ALTER TABLE ldap_config MODIFY bind_password VARCHAR(1024) NOT NULL DEFAULT ''`}
+	}
+	return nil
+}
+
+// LatestVersion es la versión de esquema que espera el código
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate aplica las migraciones pendientes y devuelve cuántas aplicó
+func Migrate(db *sql.DB, d Dialect) (int, error) {
+	if err := ensureMigrationsTable(db, d); err != nil {
+		return 0, err
+	}
+
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(db, d, m); err != nil {
+			return applied, fmt.Errorf("migración %d (%s): %v", m.Version, m.Name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// MigrateGorm aplica las migraciones sobre una conexión gorm
+func MigrateGorm(db *gorm.DB) (int, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, err
+	}
+	return Migrate(sqlDB, ForGorm(db))
+}
+
+// CurrentVersion devuelve la última migración aplicada (0 si ninguna)
+func CurrentVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// PendingMigrations cuenta las migraciones sin aplicar
+func PendingMigrations(db *sql.DB) (int, error) {
+	current, err := CurrentVersion(db)
+	if err != nil {
+		// Sin tabla de migraciones: todas pendientes
+		return len(migrations), nil
+	}
+	pending := 0
+	for _, m := range migrations {
+		if m.Version > current {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func ensureMigrationsTable(db *sql.DB, d Dialect) error {
+	t := d.types()
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		applied_at ` + t.Timestamp + ` DEFAULT ` + t.Now + `
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creando schema_migrations: %v", err)
+	}
+	return nil
+}
+
+func apply(db *sql.DB, d Dialect, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.Up(d.types()) {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(d.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"),
+		m.Version, m.Name, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
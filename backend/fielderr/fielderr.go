@@ -0,0 +1,50 @@
+// Package fielderr define el error de validación por campo que devuelven
+// los paquetes al revisar lo que llega del cliente (tenants, llaves de API,
+// webhooks, configuración...). apierror lo responde como INVALID_INPUT con
+// sus campos. Es un paquete hoja para que lo use cualquiera, incluido i18n,
+// del que depende apierror.
+package fielderr
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultContext encabeza el mensaje si el error no indica otro
+const defaultContext = "datos inválidos"
+
+// Error lista los campos inválidos con su mensaje
+type Error struct {
+	// Context encabeza el mensaje ("configuración LDAP inválida"); vacío
+	// usa "datos inválidos"
+	Context string            `json:"-"`
+	Fields  map[string]string `json:"fields"`
+}
+
+// New crea el error con los campos inválidos
+func New(fields map[string]string) *Error {
+	return &Error{Fields: fields}
+}
+
+// Field es New con un solo campo
+func Field(field, msg string) *Error {
+	return New(map[string]string{field: msg})
+}
+
+// In crea el error con un encabezado propio para el mensaje
+func In(context string, fields map[string]string) *Error {
+	return &Error{Context: context, Fields: fields}
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for field, msg := range e.Fields {
+		parts = append(parts, field+": "+msg)
+	}
+	sort.Strings(parts)
+	context := e.Context
+	if context == "" {
+		context = defaultContext
+	}
+	return context + ": " + strings.Join(parts, "; ")
+}
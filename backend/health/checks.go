@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"password-recovery/database"
+)
+
+// Nombres de las verificaciones estándar
+const (
+	CheckDatabase   = "database"
+	CheckMigrations = "migrations"
+	CheckSMTP       = "smtp"
+)
+
+var ErrSMTPNotConfigured = errors.New("no hay configuración SMTP activa")
+
+// Database verifica que el pool compartido responda
+func Database(m *database.Manager) Check {
+	return func(ctx context.Context) error {
+		pool, release, err := m.Acquire()
+		if err != nil {
+			return err
+		}
+		defer release()
+		return pool.SQL.PingContext(ctx)
+	}
+}
+
+// Migrations verifica que no haya migraciones pendientes
+func Migrations(m *database.Manager) Check {
+	return func(ctx context.Context) error {
+		pool, release, err := m.Acquire()
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		pending, err := database.PendingMigrations(pool.SQL)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d migraciones pendientes", pending)
+		}
+		return nil
+	}
+}
+
+// SMTP verifica que el servidor de la configuración SMTP activa acepte
+// conexiones TCP (no se autentica para no generar ruido en el servidor)
+func SMTP(m *database.Manager) Check {
+	return func(ctx context.Context) error {
+		pool, release, err := m.Acquire()
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		var host string
+		var port int
+		err = pool.SQL.QueryRowContext(ctx,
+			"SELECT host, port FROM smtp_config WHERE is_active = TRUE LIMIT 1").Scan(&host, &port)
+		if err == sql.ErrNoRows {
+			return ErrSMTPNotConfigured
+		}
+		if err != nil {
+			return err
+		}
+
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return fmt.Errorf("SMTP %s:%d inaccesible: %v", host, port, err)
+		}
+		return conn.Close()
+	}
+}
+
+// RegisterDefaults registra base de datos, migraciones y SMTP
+func (c *Checker) RegisterDefaults(m *database.Manager) {
+	c.Register(CheckDatabase, true, Database(m))
+	c.Register(CheckMigrations, true, Migrations(m))
+	c.Register(CheckSMTP, true, SMTP(m))
+}
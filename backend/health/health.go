@@ -0,0 +1,243 @@
+// Package health ejecuta en segundo plano las verificaciones de la base de
+// datos, migraciones y SMTP, y guarda el último resultado para que /readyz
+// y /api/status respondan sin tocar la base en cada petición.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInterval = 15 * time.Second
+	checkTimeout    = 5 * time.Second
+)
+
+// Estado público de una verificación (ver Snapshot.Statuses)
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Check devuelve nil si el componente está disponible
+type Check func(ctx context.Context) error
+
+// Result es el último resultado de una verificación
+type Result struct {
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	DurationMs int64     `json:"duration_ms"`
+	// Readiness indica si el resultado cuenta para /readyz
+	Readiness bool `json:"readiness"`
+}
+
+// Snapshot es el estado cacheado de todas las verificaciones
+type Snapshot struct {
+	Ready     bool              `json:"ready"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name      string
+	readiness bool
+	fn        Check
+}
+
+// Checker corre las verificaciones registradas cada cierto intervalo
+type Checker struct {
+	interval time.Duration
+	started  time.Time
+
+	mu        sync.RWMutex
+	checks    []namedCheck
+	results   map[string]Result
+	checkedAt time.Time
+
+	runMu sync.Mutex
+}
+
+// New crea un Checker; un intervalo <= 0 usa DefaultInterval
+func New(interval time.Duration) *Checker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Checker{
+		interval: interval,
+		started:  time.Now(),
+		results:  make(map[string]Result),
+	}
+}
+
+// IntervalFromEnv lee HEALTH_CHECK_INTERVAL (por ejemplo "30s")
+func IntervalFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_INTERVAL")); err == nil {
+		return d
+	}
+	return DefaultInterval
+}
+
+// Register agrega una verificación. Las que tienen readiness en false solo
+// se informan (no hacen fallar /readyz).
+func (c *Checker) Register(name string, readiness bool, fn Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, readiness: readiness, fn: fn})
+}
+
+// Start corre las verificaciones de inmediato y luego en cada intervalo
+// hasta que se cancele ctx
+func (c *Checker) Start(ctx context.Context) {
+	c.RunNow()
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.RunNow()
+			}
+		}
+	}()
+}
+
+// RunNow ejecuta todas las verificaciones y actualiza el cache. Se usa
+// también después de cambiar la configuración para no esperar al intervalo.
+func (c *Checker) RunNow() {
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make(map[string]Result, len(checks))
+	for _, check := range checks {
+		results[check.name] = run(check)
+	}
+
+	c.mu.Lock()
+	previous := c.results
+	c.results = results
+	c.checkedAt = time.Now().UTC()
+	c.mu.Unlock()
+
+	logChanges(previous, results)
+}
+
+// logChanges deja en el log el detalle de las verificaciones que empiezan a
+// fallar (o cambian de error) y de las que se recuperan; /readyz solo
+// publica ok o failed
+func logChanges(previous, results map[string]Result) {
+	for name, result := range results {
+		before, seen := previous[name]
+		switch {
+		case !result.OK && (!seen || before.OK || before.Error != result.Error):
+			slog.Warn("Verificación de salud fallida", "check", name, "readiness", result.Readiness, "error", result.Error)
+		case result.OK && seen && !before.OK:
+			slog.Info("Verificación de salud recuperada", "check", name)
+		}
+	}
+}
+
+func run(check namedCheck) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.fn(ctx)
+	result := Result{
+		OK:         err == nil,
+		CheckedAt:  start.UTC(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Readiness:  check.readiness,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Snapshot devuelve una copia del último resultado
+func (c *Checker) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snap := Snapshot{
+		Ready:     !c.checkedAt.IsZero(),
+		CheckedAt: c.checkedAt,
+		Checks:    make(map[string]Result, len(c.results)),
+	}
+	for name, result := range c.results {
+		snap.Checks[name] = result
+		if result.Readiness && !result.OK {
+			snap.Ready = false
+		}
+	}
+	return snap
+}
+
+// Statuses resume cada verificación como "ok" o "failed", sin el detalle
+// del error (que puede incluir hosts, usuarios o rutas internas)
+func (s Snapshot) Statuses() map[string]string {
+	statuses := make(map[string]string, len(s.Checks))
+	for name, result := range s.Checks {
+		statuses[name] = StatusFailed
+		if result.OK {
+			statuses[name] = StatusOK
+		}
+	}
+	return statuses
+}
+
+// Result devuelve el último resultado de una verificación
+func (c *Checker) Result(name string) (Result, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result, ok := c.results[name]
+	return result, ok
+}
+
+// LivenessHandler responde /healthz: el proceso está vivo, sin depender de
+// la base de datos
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":         "ok",
+			"uptime_seconds": int64(time.Since(c.started).Seconds()),
+		})
+	}
+}
+
+// ReadinessHandler responde /readyz con el estado cacheado: 200 si todas
+// las verificaciones de readiness pasaron y 503 si no. Es público, así que
+// solo dice ok o failed por verificación.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap := c.Snapshot()
+		status, code := "ok", http.StatusOK
+		if !snap.Ready {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]interface{}{
+			"status":     status,
+			"checked_at": snap.CheckedAt,
+			"checks":     snap.Statuses(),
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,115 @@
+package httpserver
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCSP es la política para la API: solo recursos propios, sin
+// plugins ni formularios hacia otros sitios. frame-ancestors se agrega
+// aparte (SECURITY_FRAME_ANCESTORS).
+const DefaultCSP = "default-src 'self'; base-uri 'self'; form-action 'self'; object-src 'none'"
+
+const defaultHSTSMaxAge = 365 * 24 * 60 * 60
+
+// Headers son los headers de seguridad de todas las respuestas. Se leen
+// una vez del entorno:
+//
+//	SECURITY_CSP              Content-Security-Policy sin frame-ancestors
+//	SECURITY_FRAME_ANCESTORS  quién puede mostrar las páginas en un frame
+//	                          ('none' por defecto, o 'self' u orígenes)
+//	SECURITY_REFERRER_POLICY  no-referrer por defecto
+//	SECURITY_HSTS_MAX_AGE     segundos de HSTS (un año; 0 lo desactiva)
+type Headers struct {
+	CSP            string
+	FrameAncestors string
+	ReferrerPolicy string
+	HSTSMaxAge     int
+	HSTSSubdomains bool
+}
+
+var (
+	headersOnce sync.Once
+	headers     Headers
+)
+
+// HeadersFromEnv devuelve la configuración de los headers de seguridad
+func HeadersFromEnv() Headers {
+	headersOnce.Do(func() {
+		headers = Headers{
+			CSP:            envOr("SECURITY_CSP", DefaultCSP),
+			FrameAncestors: envOr("SECURITY_FRAME_ANCESTORS", "'none'"),
+			ReferrerPolicy: envOr("SECURITY_REFERRER_POLICY", "no-referrer"),
+			HSTSMaxAge:     defaultHSTSMaxAge,
+			HSTSSubdomains: strings.EqualFold(os.Getenv("SECURITY_HSTS_SUBDOMAINS"), "true"),
+		}
+		if v, err := strconv.Atoi(os.Getenv("SECURITY_HSTS_MAX_AGE")); err == nil && v >= 0 {
+			headers.HSTSMaxAge = v
+		}
+	})
+	return headers
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Policy arma la Content-Security-Policy con las directivas indicadas y el
+// frame-ancestors configurado
+func (h Headers) Policy(directives string) string {
+	return strings.TrimRight(strings.TrimSpace(directives), ";") + "; frame-ancestors " + h.FrameAncestors
+}
+
+// SetCSP reemplaza la política de una respuesta que necesita otras
+// directivas (por ejemplo /docs, que carga Redoc desde su CDN)
+func SetCSP(w http.ResponseWriter, directives string) {
+	w.Header().Set("Content-Security-Policy", HeadersFromEnv().Policy(directives))
+}
+
+// apply escribe los headers; HSTS solo se envía por HTTPS (directo o
+// detrás de un proxy que lo indique con X-Forwarded-Proto)
+func (h Headers) apply(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Content-Security-Policy", h.Policy(h.CSP))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", h.ReferrerPolicy)
+	switch h.FrameAncestors {
+	case "'none'":
+		header.Set("X-Frame-Options", "DENY")
+	case "'self'":
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+	}
+	if h.HSTSMaxAge > 0 && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+		value := "max-age=" + strconv.Itoa(h.HSTSMaxAge)
+		if h.HSTSSubdomains {
+			value += "; includeSubDomains"
+		}
+		header.Set("Strict-Transport-Security", value)
+	}
+}
+
+// Gin agrega los headers de seguridad a las respuestas del servidor principal
+func Gin() gin.HandlerFunc {
+	h := HeadersFromEnv()
+	return func(c *gin.Context) {
+		h.apply(c.Writer, c.Request)
+		c.Next()
+	}
+}
+
+// Mux es el equivalente de Gin para el servidor de setup
+func Mux(next http.Handler) http.Handler {
+	h := HeadersFromEnv()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apply(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,140 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CertReloader entrega el certificado vigente a cada conexión TLS y lo
+// vuelve a leer de los archivos con SIGHUP o cuando cambian. Si la lectura
+// falla se sigue usando el anterior.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	// seen es la fecha de modificación (la más reciente de los dos
+	// archivos) del último intento, aunque haya fallado, para no repetir
+	// el error en cada revisión
+	seen time.Time
+}
+
+// NewCertReloader lee el certificado por primera vez
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// StaticCertificate entrega siempre el mismo certificado (el autofirmado
+// temporal); Reload y Watch no hacen nada
+func StaticCertificate(cert *tls.Certificate) *CertReloader {
+	return &CertReloader{cert: cert}
+}
+
+// Reload lee los archivos y reemplaza el certificado
+func (r *CertReloader) Reload() error {
+	if r.certFile == "" {
+		return nil
+	}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.seen = modTime
+	r.mu.Unlock()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("error al leer el certificado TLS: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("error al leer el certificado TLS: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	slog.Info("Certificado TLS cargado", "subject", cert.Leaf.Subject.String(), "not_after", cert.Leaf.NotAfter)
+	if time.Until(cert.Leaf.NotAfter) < 14*24*time.Hour {
+		slog.Warn("El certificado TLS vence pronto", "not_after", cert.Leaf.NotAfter)
+	}
+	return nil
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error al leer el certificado TLS: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// changed indica si alguno de los archivos cambió desde la última carga
+func (r *CertReloader) changed() bool {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false // mientras se reemplazan puede faltar uno
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !modTime.Equal(r.seen)
+}
+
+// GetCertificate es tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch recarga con SIGHUP y cuando los archivos cambian (revisándolos cada
+// interval) hasta que ctx termina
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	if r.certFile == "" {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("SIGHUP: recargando el certificado TLS")
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("No se pudo recargar el certificado TLS; se mantiene el anterior", "error", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// selfSignedValidity es la vigencia del certificado de desarrollo
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// generate crea un certificado autofirmado para localhost, 127.0.0.1, ::1
+// y el nombre del equipo; devuelve certificado y llave en PEM
+func generate(now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	names := []string{"localhost"}
+	if host, err := os.Hostname(); err == nil && host != "" && host != "localhost" {
+		names = append(names, host)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"Password Recovery (desarrollo)"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              names,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// SelfSigned genera un certificado autofirmado en memoria
+func SelfSigned(now time.Time) (*tls.Certificate, error) {
+	certPEM, keyPEM, err := generate(now)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// EnsureSelfSigned escribe un certificado autofirmado en los archivos si
+// todavía no existen, para que el navegador vea el mismo entre reinicios.
+// created indica si se generó.
+func EnsureSelfSigned(certFile, keyFile string, now time.Time) (created bool, err error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return false, nil
+	}
+	certPEM, keyPEM, err := generate(now)
+	if err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
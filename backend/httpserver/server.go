@@ -0,0 +1,180 @@
+// Package httpserver arranca los dos servidores (gin y gorilla/mux) con
+// HTTPS propio: certificado y llave en archivos que se recargan con SIGHUP
+// o cuando cambian, un certificado autofirmado para desarrollo, un
+// listener HTTP opcional que redirige a HTTPS y los headers de seguridad.
+//
+// Variables de entorno:
+//
+//	TLS_CERT_FILE, TLS_KEY_FILE  certificado (con la cadena) y llave en PEM
+//	TLS_SELF_SIGNED=true         genera un certificado autofirmado si faltan
+//	                             los archivos (no se permite en producción)
+//	TLS_RELOAD_INTERVAL          cada cuánto se revisan los archivos (30s)
+//	HTTP_REDIRECT_PORT           puerto HTTP que redirige a HTTPS (80)
+//
+// Sin certificado el servidor sigue en HTTP como antes.
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"password-recovery/secrets"
+)
+
+const (
+	DefaultReloadInterval = 30 * time.Second
+	readHeaderTimeout     = 10 * time.Second
+	idleTimeout           = 2 * time.Minute
+)
+
+// Config describe cómo escuchar
+type Config struct {
+	Addr           string // ":8080"
+	CertFile       string
+	KeyFile        string
+	SelfSigned     bool
+	ReloadInterval time.Duration
+	// RedirectAddr es la dirección del listener HTTP que redirige a HTTPS
+	// (vacía: sin redirección)
+	RedirectAddr string
+}
+
+// ConfigFromEnv lee la configuración TLS del entorno para escuchar en port
+func ConfigFromEnv(port string) Config {
+	cfg := Config{
+		Addr:           ":" + port,
+		CertFile:       strings.TrimSpace(os.Getenv("TLS_CERT_FILE")),
+		KeyFile:        strings.TrimSpace(os.Getenv("TLS_KEY_FILE")),
+		SelfSigned:     strings.EqualFold(os.Getenv("TLS_SELF_SIGNED"), "true"),
+		ReloadInterval: DefaultReloadInterval,
+	}
+	if d, err := time.ParseDuration(os.Getenv("TLS_RELOAD_INTERVAL")); err == nil && d > 0 {
+		cfg.ReloadInterval = d
+	}
+	if p := strings.TrimSpace(os.Getenv("HTTP_REDIRECT_PORT")); p != "" {
+		cfg.RedirectAddr = ":" + p
+	}
+	return cfg
+}
+
+// TLS indica si el servidor escucha con HTTPS
+func (c Config) TLS() bool {
+	return c.SelfSigned || c.CertFile != "" || c.KeyFile != ""
+}
+
+// Validate revisa la combinación de opciones
+func (c Config) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") && !c.SelfSigned {
+		return errors.New("TLS_CERT_FILE y TLS_KEY_FILE se configuran juntos")
+	}
+	if c.SelfSigned && secrets.IsProduction() {
+		return errors.New("APP_ENV=production no permite TLS_SELF_SIGNED; configure TLS_CERT_FILE y TLS_KEY_FILE")
+	}
+	if c.RedirectAddr != "" && !c.TLS() {
+		return errors.New("HTTP_REDIRECT_PORT requiere TLS")
+	}
+	return nil
+}
+
+// ListenAndServe sirve handler con la configuración; con TLS el certificado
+// se recarga hasta que termina el proceso
+func ListenAndServe(cfg Config, handler http.Handler) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	if !cfg.TLS() {
+		if secrets.IsProduction() {
+			slog.Warn("Servidor sin TLS en producción; configure TLS_CERT_FILE y TLS_KEY_FILE o un proxy con HTTPS")
+		}
+		slog.Info("Escuchando", "addr", cfg.Addr, "scheme", "http")
+		return srv.ListenAndServe()
+	}
+
+	certs, err := loadCertificates(cfg)
+	if err != nil {
+		return err
+	}
+	go certs.Watch(context.Background(), cfg.ReloadInterval)
+
+	srv.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if cfg.RedirectAddr != "" {
+		go serveRedirect(cfg.RedirectAddr, cfg.Addr)
+	}
+	slog.Info("Escuchando", "addr", cfg.Addr, "scheme", "https")
+	// Los archivos ya están en TLSConfig (GetCertificate)
+	return srv.ListenAndServeTLS("", "")
+}
+
+func loadCertificates(cfg Config) (*CertReloader, error) {
+	if cfg.SelfSigned {
+		if cfg.CertFile == "" {
+			slog.Warn("Usando un certificado autofirmado temporal (solo desarrollo)")
+			cert, err := SelfSigned(time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return StaticCertificate(cert), nil
+		}
+		created, err := EnsureSelfSigned(cfg.CertFile, cfg.KeyFile, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			slog.Warn("Certificado autofirmado generado (solo desarrollo)", "cert_file", cfg.CertFile)
+		}
+	}
+	return NewCertReloader(cfg.CertFile, cfg.KeyFile)
+}
+
+// serveRedirect responde 308 hacia la misma ruta en HTTPS
+func serveRedirect(addr, tlsAddr string) {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           RedirectHandler(tlsPort),
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	slog.Info("Redirigiendo HTTP a HTTPS", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil {
+		slog.Error("Error en el listener de redirección", "addr", addr, "error", err)
+	}
+}
+
+// RedirectHandler redirige al mismo host y ruta con https y el puerto
+// indicado (se omite si es 443)
+func RedirectHandler(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "falta el header Host", http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host += ":" + tlsPort
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
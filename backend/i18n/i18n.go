@@ -0,0 +1,171 @@
+// Package i18n elige el idioma de cada petición y traduce los mensajes de
+// la API, los correos y las descripciones de la política de códigos.
+//
+// El idioma se negocia con Accept-Language; si la petición no lo indica se
+// usa users.locale de la cuenta autenticada, y los correos van en el idioma
+// de la cuenta que los recibe. Los textos predeterminados están en el
+// código (Register) y cada tenant puede reemplazarlos en tiempo de
+// ejecución (tabla translations, ver store.go).
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Idiomas soportados
+const (
+	Spanish = "es-MX"
+	English = "en"
+
+	// Default es el idioma cuando ni la petición ni la cuenta indican uno
+	Default = Spanish
+)
+
+// Locales lista los idiomas soportados
+func Locales() []string {
+	return []string{Spanish, English}
+}
+
+// Match devuelve el idioma soportado que corresponde a tag ("es", "es-419",
+// "en-US"), o "" si no hay ninguno
+func Match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	lang := tag
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		lang = tag[:i]
+	}
+	switch lang {
+	case "es":
+		return Spanish
+	case "en":
+		return English
+	}
+	return ""
+}
+
+// Valid indica si locale es exactamente uno de los idiomas soportados
+func Valid(locale string) bool {
+	for _, l := range Locales() {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate elige el idioma de un header Accept-Language respetando los
+// pesos (q); ok es false si ninguno de los idiomas pedidos está soportado
+func Negotiate(header string) (locale string, ok bool) {
+	type option struct {
+		tag string
+		q   float64
+	}
+	var options []option
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag != "" && q > 0 {
+			options = append(options, option{tag, q})
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].q > options[j].q })
+	for _, o := range options {
+		if l := Match(o.tag); l != "" {
+			return l, true
+		}
+	}
+	return Default, false
+}
+
+type ctxKey struct{}
+
+// state es el idioma de la petición; explicit indica que lo pidió el
+// cliente con Accept-Language
+type state struct {
+	locale   string
+	explicit bool
+	tenantID int64
+}
+
+func stateFrom(ctx context.Context) state {
+	if s, ok := ctx.Value(ctxKey{}).(state); ok {
+		return s
+	}
+	return state{locale: Default}
+}
+
+// WithRequest guarda en el contexto el idioma negociado de la petición
+func WithRequest(ctx context.Context, r *http.Request) context.Context {
+	s := stateFrom(ctx)
+	s.locale, s.explicit = Negotiate(r.Header.Get("Accept-Language"))
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+// WithUser usa el idioma de la cuenta autenticada si la petición no pidió
+// uno con Accept-Language
+func WithUser(ctx context.Context, locale string) context.Context {
+	s := stateFrom(ctx)
+	if l := Match(locale); l != "" && !s.explicit {
+		s.locale = l
+		return context.WithValue(ctx, ctxKey{}, s)
+	}
+	return ctx
+}
+
+// WithTenant indica de qué tenant son los textos personalizados que se usan
+func WithTenant(ctx context.Context, tenantID int64) context.Context {
+	s := stateFrom(ctx)
+	s.tenantID = tenantID
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+// Locale es el idioma de la petición
+func Locale(ctx context.Context) string {
+	return stateFrom(ctx).locale
+}
+
+// ForRecipient es el idioma de un correo: el de la cuenta que lo recibe o,
+// si no tiene, fallback
+func ForRecipient(userLocale, fallback string) string {
+	if l := Match(userLocale); l != "" {
+		return l
+	}
+	if l := Match(fallback); l != "" {
+		return l
+	}
+	return Default
+}
+
+// Gin negocia el idioma de la petición e indica en Content-Language el
+// idioma de la respuesta
+func Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(WithRequest(c.Request.Context(), c.Request))
+		c.Header("Content-Language", Locale(c.Request.Context()))
+		c.Header("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+// Mux es el equivalente de Gin para el servidor de setup
+func Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(WithRequest(r.Context(), r))
+		w.Header().Set("Content-Language", Locale(r.Context()))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,109 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// builtin son los textos predeterminados por clave e idioma
+var builtin = map[string]map[string]string{}
+
+// Register agrega el texto predeterminado de una clave en un idioma. Se
+// llama desde init(); los paquetes usan un prefijo propio ("error.",
+// "msg.", "policy.").
+func Register(locale, key, text string) {
+	if builtin[key] == nil {
+		builtin[key] = map[string]string{}
+	}
+	builtin[key][locale] = text
+}
+
+// Define registra la clave en español y en inglés
+func Define(key, es, en string) {
+	Register(Spanish, key, es)
+	Register(English, key, en)
+}
+
+// Keys lista las claves registradas ordenadas
+func Keys() []string {
+	keys := make([]string, 0, len(builtin))
+	for key := range builtin {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Known indica si la clave está registrada
+func Known(key string) bool {
+	_, ok := builtin[key]
+	return ok
+}
+
+// Builtin es el texto predeterminado de la clave; si no existe en el idioma
+// se usa el del idioma por defecto
+func Builtin(locale, key string) string {
+	texts := builtin[key]
+	if text, ok := texts[locale]; ok {
+		return text
+	}
+	if text, ok := texts[Default]; ok {
+		return text
+	}
+	return key
+}
+
+// overrides son los textos personalizados por tenant (cargados de la
+// tabla translations)
+var (
+	overridesMu sync.RWMutex
+	overrides   = map[int64]map[string]map[string]string{} // tenant → idioma → clave
+)
+
+// Lookup es el texto de la clave para el tenant y el idioma: el
+// personalizado si lo hay y si no el predeterminado
+func Lookup(tenantID int64, locale, key string) string {
+	overridesMu.RLock()
+	text, ok := overrides[tenantID][locale][key]
+	overridesMu.RUnlock()
+	if ok {
+		return text
+	}
+	return Builtin(locale, key)
+}
+
+// Text es el texto de la clave en el idioma y el tenant de la petición
+func Text(ctx context.Context, key string) string {
+	s := stateFrom(ctx)
+	return Lookup(s.tenantID, s.locale, key)
+}
+
+// Textf es Text con los valores de los verbos de formato (%d, %s)
+func Textf(ctx context.Context, key string, args ...interface{}) string {
+	return fmt.Sprintf(Text(ctx, key), args...)
+}
+
+// Sprintf es Textf fuera de una petición (correos)
+func Sprintf(tenantID int64, locale, key string, args ...interface{}) string {
+	return fmt.Sprintf(Lookup(tenantID, locale, key), args...)
+}
+
+var verbPattern = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// sameVerbs indica si el texto personalizado usa los mismos verbos de
+// formato, en el mismo orden, que el predeterminado
+func sameVerbs(text, def string) bool {
+	a, b := verbPattern.FindAllString(text, -1), verbPattern.FindAllString(def, -1)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package i18n
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/fielderr"
+)
+
+// maxTextLength es el largo máximo de un texto personalizado
+const maxTextLength = 1000
+
+var ErrUnknownKey = errors.New("clave de texto desconocida")
+
+// Entry es un texto del catálogo de un tenant. Custom indica si el tenant
+// lo personalizó; Default es el texto predeterminado.
+type Entry struct {
+	Key       string     `json:"key"`
+	Locale    string     `json:"locale"`
+	Text      string     `json:"text"`
+	Default   string     `json:"default"`
+	Custom    bool       `json:"custom"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Load lee todos los textos personalizados y reemplaza los que están en
+// memoria; se llama al iniciar y periódicamente para ver los cambios
+// hechos desde otras instancias
+func Load(db *sql.DB) error {
+	rows, err := db.Query("SELECT tenant_id, locale, msg_key, message FROM translations")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	loaded := map[int64]map[string]map[string]string{}
+	for rows.Next() {
+		var tenantID int64
+		var locale, key, text string
+		if err := rows.Scan(&tenantID, &locale, &key, &text); err != nil {
+			return err
+		}
+		setOverride(loaded, tenantID, locale, key, text)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	overridesMu.Lock()
+	overrides = loaded
+	overridesMu.Unlock()
+	return nil
+}
+
+func setOverride(m map[int64]map[string]map[string]string, tenantID int64, locale, key, text string) {
+	if m[tenantID] == nil {
+		m[tenantID] = map[string]map[string]string{}
+	}
+	if m[tenantID][locale] == nil {
+		m[tenantID][locale] = map[string]string{}
+	}
+	m[tenantID][locale][key] = text
+}
+
+// Entries lista el catálogo del tenant en un idioma; prefix filtra por
+// clave ("error.")
+func Entries(db *sql.DB, d database.Dialect, tenantID int64, locale, prefix string) ([]Entry, error) {
+	if !Valid(locale) {
+		return nil, fielderr.Field("locale", "use "+strings.Join(Locales(), " o "))
+	}
+	rows, err := db.Query(d.Rebind("SELECT msg_key, message, updated_at FROM translations WHERE tenant_id = $1 AND locale = $2"), tenantID, locale)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	custom := map[string]Entry{}
+	for rows.Next() {
+		var e Entry
+		var updated time.Time
+		if err := rows.Scan(&e.Key, &e.Text, &updated); err != nil {
+			return nil, err
+		}
+		e.Custom, e.UpdatedAt = true, &updated
+		custom[e.Key] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := []Entry{}
+	for _, key := range Keys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		e, ok := custom[key]
+		if !ok {
+			e.Key, e.Text = key, Builtin(locale, key)
+		}
+		e.Locale, e.Default = locale, Builtin(locale, key)
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+// validate revisa un texto personalizado: la clave debe existir y el texto
+// debe conservar los verbos de formato del predeterminado
+func validate(locale, key, text string) error {
+	if !Known(key) {
+		return ErrUnknownKey
+	}
+	fields := map[string]string{}
+	if !Valid(locale) {
+		fields["locale"] = "use " + strings.Join(Locales(), " o ")
+	}
+	if strings.TrimSpace(text) == "" || len(text) > maxTextLength {
+		fields["text"] = "es obligatorio (máximo 1000 caracteres)"
+	} else if def := Builtin(locale, key); !sameVerbs(text, def) {
+		fields["text"] = "debe conservar los valores del texto predeterminado (" + strings.Join(verbPattern.FindAllString(def, -1), " ") + ")"
+	}
+	if len(fields) > 0 {
+		return fielderr.New(fields)
+	}
+	return nil
+}
+
+// Save personaliza un texto del tenant; el cambio se aplica de inmediato en
+// esta instancia
+func Save(db *sql.DB, d database.Dialect, tenantID int64, locale, key, text string) (Entry, error) {
+	if err := validate(locale, key, text); err != nil {
+		return Entry{}, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.Rebind("DELETE FROM translations WHERE tenant_id = $1 AND locale = $2 AND msg_key = $3"), tenantID, locale, key); err != nil {
+		return Entry{}, err
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(d.Rebind("INSERT INTO translations (tenant_id, locale, msg_key, message, updated_at) VALUES ($1, $2, $3, $4, $5)"),
+		tenantID, locale, key, text, now); err != nil {
+		return Entry{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Entry{}, err
+	}
+
+	overridesMu.Lock()
+	setOverride(overrides, tenantID, locale, key, text)
+	overridesMu.Unlock()
+	return Entry{Key: key, Locale: locale, Text: text, Default: Builtin(locale, key), Custom: true, UpdatedAt: &now}, nil
+}
+
+// Reset vuelve al texto predeterminado
+func Reset(db database.Execer, d database.Dialect, tenantID int64, locale, key string) (Entry, error) {
+	if !Known(key) {
+		return Entry{}, ErrUnknownKey
+	}
+	if !Valid(locale) {
+		return Entry{}, fielderr.Field("locale", "use "+strings.Join(Locales(), " o "))
+	}
+	if _, err := db.Exec(d.Rebind("DELETE FROM translations WHERE tenant_id = $1 AND locale = $2 AND msg_key = $3"), tenantID, locale, key); err != nil {
+		return Entry{}, err
+	}
+
+	overridesMu.Lock()
+	delete(overrides[tenantID][locale], key)
+	overridesMu.Unlock()
+	def := Builtin(locale, key)
+	return Entry{Key: key, Locale: locale, Text: def, Default: def}, nil
+}
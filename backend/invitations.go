@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"password-recovery/i18n"
+	"password-recovery/logging"
+	"password-recovery/retry"
+	"password-recovery/tenant"
+	"password-recovery/userimport"
+)
+
+const (
+	defaultInvitationInterval = 30 * time.Second
+	invitationBatchSize       = 20
+)
+
+// runInvitations envía en segundo plano las invitaciones encoladas por la
+// importación de usuarios. Cada invitación lleva un código de 72 horas con
+// el que la persona define su contraseña en /reset-password.
+func runInvitations(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultInvitationInterval
+	}
+	retry.Run(ctx, interval, nil, sendDueInvitations)
+}
+
+func sendDueInvitations() {
+	now := time.Now().UTC()
+	due, err := userimport.DueInvitations(db, dbDialect, now, invitationBatchSize)
+	if err != nil {
+		slog.Warn("No se pudieron leer las invitaciones pendientes", "error", err)
+		return
+	}
+
+	tenants := map[int64]*tenant.Tenant{}
+	for _, inv := range due {
+		t, ok := tenants[inv.TenantID]
+		if !ok {
+			if t, err = tenant.Get(db, dbDialect, inv.TenantID); err != nil {
+				slog.Error("No se pudo leer el tenant de la invitación", "id", inv.ID, "tenant_id", inv.TenantID, "error", err)
+				continue
+			}
+			tenants[inv.TenantID] = t
+		}
+
+		err := issueCode(t, int(inv.UserID), inv.Email, tenant.TemplateInvitation, invitationCodeTTL, "", i18n.Default)
+		if err != nil {
+			slog.Warn("Error al enviar invitación", "email", logging.Email(inv.Email), "attempt", inv.Attempts+1, "error", err)
+			err = userimport.MarkFailed(db, dbDialect, inv, err, time.Now().UTC())
+		} else {
+			slog.Info("Invitación enviada", "email", logging.Email(inv.Email), "user_id", inv.UserID)
+			err = userimport.MarkSent(db, dbDialect, inv.ID, time.Now().UTC())
+		}
+		if err != nil {
+			slog.Error("No se pudo actualizar la invitación", "id", inv.ID, "error", err)
+		}
+	}
+}
@@ -1,66 +1,352 @@
+// Herramienta de administración de las credenciales de setup (config.json.enc).
+//
+// Uso:
+//
+//	go run ./key <comando>
+//
+// Comandos:
+//
+//	init        crea config.json.enc con un operador administrador
+//	verify      comprueba si un usuario y contraseña son válidos
+//	passwd      cambia la contraseña de un operador
+//	show        muestra metadatos del archivo (nunca secretos)
+//	operator    administra los operadores de setup
+//	rotate-key  vuelve a cifrar los artefactos y la base de datos con RESET_NEW_MASTER_*
+//	import-users importa usuarios desde CSV o JSON (ver key/import.go)
+//
+// La llave maestra se toma de RESET_MASTER_KEY, RESET_MASTER_KEY_FILE o
+// RESET_MASTER_PASSPHRASE.
 package main
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	
+	"os"
+
+	"password-recovery/config"
+	"password-recovery/database"
+	"password-recovery/ldapdir"
+	"password-recovery/secrets"
 )
 
-type Credentials struct {
-	User string `json:"user"`
-	Pass string `json:"pass"`
+type command struct {
+	name string
+	help string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"init", "crea config.json.enc con un operador administrador", runInit},
+	{"verify", "comprueba un usuario y contraseña contra config.json.enc", runVerify},
+	{"passwd", "cambia la contraseña de un operador", runPasswd},
+	{"show", "muestra metadatos de config.json.enc (sin secretos)", runShow},
+	{"operator", "administra operadores: list | add | remove | enable | disable | role", runOperator},
+	{"rotate-key", "vuelve a cifrar los artefactos con la llave RESET_NEW_MASTER_*", runRotateKey},
+	{"import-users", "importa usuarios desde CSV o JSON a la base de datos", runImportUsers},
 }
 
-func encrypt(plaintext, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// errUsage indica un error en los argumentos (código de salida 2)
+var errUsage = errors.New("uso incorrecto")
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage()
+		if len(os.Args) < 2 {
+			os.Exit(2)
+		}
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", cmd.name, err)
+			if errors.Is(err, errUsage) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "❌ comando desconocido: %s\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Uso: key <comando>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Comandos:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", cmd.name, cmd.help)
+	}
+}
+
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: argumentos inesperados %v", errUsage, args)
+	}
+	return nil
+}
+
+// loadKey carga la llave maestra y rechaza la de desarrollo en producción
+func loadKey() (*secrets.MasterKey, error) {
+	key, err := secrets.LoadMasterKey()
 	if err != nil {
 		return nil, err
 	}
+	if key.IsDevelopment() {
+		if secrets.IsProduction() {
+			return nil, errors.New("APP_ENV=production no permite la llave de desarrollo")
+		}
+		fmt.Fprintln(os.Stderr, "⚠️ Usando la llave maestra de desarrollo")
+	}
+	return key, nil
+}
 
-	aesgcm, err := cipher.NewGCM(block)
+// loadStore carga la llave maestra y la lista de operadores
+func loadStore() (*secrets.MasterKey, *config.CredentialStore, error) {
+	key, err := loadKey()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	store, err := config.LoadCredentialStore(secrets.Keyring{key})
+	if err != nil {
+		return nil, nil, fmt.Errorf("no se pudo leer %s: %v", config.EncConfigFile, err)
 	}
+	return key, store, nil
+}
 
-	nonce := make([]byte, 12)
-	if _, err := rand.Read(nonce); err != nil {
-		return nil, err
+func runInit(args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(config.EncConfigFile); err == nil {
+		return fmt.Errorf("%s ya existe; use passwd u operator para modificarlo", config.EncConfigFile)
 	}
 
-	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
-	return append(nonce, ciphertext...), nil
+	key, err := loadKey()
+	if err != nil {
+		return err
+	}
+
+	user, err := promptLine("Usuario: ")
+	if err != nil {
+		return err
+	}
+	pass, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+
+	store := &config.CredentialStore{}
+	if err := store.Add(user, pass, config.OperatorRoleAdmin); err != nil {
+		return err
+	}
+	if err := config.SaveCredentialStore(key, store); err != nil {
+		return fmt.Errorf("error guardando credenciales: %v", err)
+	}
+	fmt.Printf("✅ %s creado (llave %s)\n", config.EncConfigFile, key.ID)
+	return nil
 }
 
-func main() {
-	// Usando tu key de prueba
-	key := []byte("12345678901234567890123456789012")
+func runVerify(args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	_, store, err := loadStore()
+	if err != nil {
+		return err
+	}
 
-	creds := Credentials{
-		User: "admin",
-		Pass: "shinon56",
+	user, err := promptLine("Usuario: ")
+	if err != nil {
+		return err
+	}
+	pass, err := promptPassword("Contraseña: ")
+	if err != nil {
+		return err
 	}
 
-	jsonData, err := json.Marshal(creds)
+	op, err := store.Authenticate(user, pass)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	fmt.Printf("✅ Credenciales válidas (rol %s)\n", op.Role)
+	return nil
+}
 
-	encryptedData, err := encrypt(jsonData, key)
+func runPasswd(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%w: passwd [usuario]", errUsage)
+	}
+	key, store, err := loadStore()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	err = ioutil.WriteFile("config.json.enc", encryptedData, 0600)
+	var user string
+	if len(args) == 1 {
+		user = args[0]
+	} else if user, err = promptLine("Usuario: "); err != nil {
+		return err
+	}
+
+	current, err := promptPassword("Contraseña actual: ")
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if _, err := store.Authenticate(user, current); err != nil {
+		return err
 	}
 
-	fmt.Println("✅ Archivo config.json.enc generado correctamente")
-	fmt.Println("🔑 Key usada:", string(key))
-	fmt.Println("👤 Usuario:", creds.User)
-	fmt.Println("🔒 Contraseña:", creds.Pass)
-}
\ No newline at end of file
+	pass, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+	if err := store.SetPassword(user, pass); err != nil {
+		return err
+	}
+	if err := config.SaveCredentialStore(key, store); err != nil {
+		return fmt.Errorf("error guardando credenciales: %v", err)
+	}
+	fmt.Println("✅ Contraseña actualizada")
+	return nil
+}
+
+func runShow(args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(config.EncConfigFile)
+	if err != nil {
+		return fmt.Errorf("no se pudo leer %s: %v", config.EncConfigFile, err)
+	}
+	data, err := os.ReadFile(config.EncConfigFile)
+	if err != nil {
+		return err
+	}
+	keyID, err := secrets.KeyIDOf(data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Archivo:      %s\n", config.EncConfigFile)
+	fmt.Printf("Tamaño:       %d bytes\n", info.Size())
+	fmt.Printf("Permisos:     %s\n", info.Mode().Perm())
+	fmt.Printf("Modificado:   %s\n", info.ModTime().Format("2006-01-02 15:04:05"))
+	if keyID == "" {
+		fmt.Println("Formato:      anterior (sin cabecera)")
+	} else {
+		fmt.Println("Formato:      RPE1")
+		fmt.Printf("ID de llave:  %s\n", keyID)
+	}
+
+	key, err := loadKey()
+	if err != nil {
+		fmt.Printf("Llave actual: no disponible (%v)\n", err)
+		return nil
+	}
+	fmt.Printf("Llave actual: %s (%s)\n", key.ID, key.Source)
+	store, err := config.LoadCredentialStore(secrets.Keyring{key})
+	if err != nil {
+		fmt.Println("Descifrable:  no")
+		return nil
+	}
+	fmt.Println("Descifrable:  sí")
+	if store.Legacy {
+		fmt.Println("Contenido:    par usuario/contraseña (formato anterior)")
+	}
+	printOperators(store)
+	return nil
+}
+
+func runRotateKey(args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	oldKey, err := secrets.LoadMasterKey()
+	if err != nil {
+		return fmt.Errorf("llave actual: %v", err)
+	}
+
+	newKey, err := secrets.LoadMasterKeyFrom(secrets.NewEnvPrefix)
+	if err != nil {
+		return fmt.Errorf("llave nueva: %v", err)
+	}
+	if newKey.IsDevelopment() {
+		return errors.New("la llave nueva no puede ser la llave de desarrollo")
+	}
+
+	// Se abre antes de tocar los archivos para no dejar la rotación a medias
+	// si la base de datos no está disponible
+	pool, err := openRotationDB()
+	if err != nil {
+		return err
+	}
+	if pool != nil {
+		defer pool.SQL.Close()
+	}
+
+	// La llave nueva también descifra, para poder repetir la rotación si se
+	// interrumpe entre los archivos y la base de datos
+	oldKeys := secrets.Keyring{oldKey, newKey}
+	results, err := secrets.Rotate(secrets.ProtectedFiles(), oldKeys, newKey)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Skipped {
+			fmt.Printf("- %s: no existe, omitido\n", r.Path)
+			continue
+		}
+		fmt.Printf("- %s: %s -> %s\n", r.Path, displayKeyID(r.OldKey), newKey.ID)
+	}
+
+	if pool == nil {
+		fmt.Println("- base de datos: sin configurar, omitida")
+	} else {
+		resealed, err := ldapdir.Reseal(pool.SQL, pool.Dialect, oldKeys, newKey)
+		if err != nil {
+			return fmt.Errorf("ldap_config.bind_password: %v", err)
+		}
+		if resealed {
+			fmt.Printf("- ldap_config.bind_password: -> %s\n", newKey.ID)
+		} else {
+			fmt.Println("- ldap_config.bind_password: sin contraseña guardada, omitida")
+		}
+	}
+	fmt.Println("✅ Rotación completada; actualice RESET_MASTER_* con la llave nueva")
+	return nil
+}
+
+// openRotationDB abre la base de datos cuyos secretos (bind_password de
+// LDAP) también se rotan; devuelve nil sin DB_TYPE ni dbconfig.json
+func openRotationDB() (*database.Pool, error) {
+	if _, ok := os.LookupEnv("DB_TYPE"); !ok {
+		if _, err := os.Stat(config.ConfigFile); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	pool, err := openDB()
+	if err != nil {
+		return nil, fmt.Errorf("base de datos: %v", err)
+	}
+	if pending, err := database.PendingMigrations(pool.SQL); err != nil || pending > 0 {
+		pool.SQL.Close()
+		return nil, fmt.Errorf("el esquema no está actualizado (%d migraciones pendientes); inicie el servidor para aplicarlas", pending)
+	}
+	return pool, nil
+}
+
+func displayKeyID(id string) string {
+	if id == "" {
+		return "(formato anterior)"
+	}
+	return id
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"text/tabwriter"
+
+	"password-recovery/audit"
+	"password-recovery/config"
+	"password-recovery/database"
+	"password-recovery/tenant"
+	"password-recovery/userimport"
+)
+
+// runImportUsers importa usuarios desde un archivo CSV o JSON. La base de
+// datos se toma de las variables DB_* del servidor principal si DB_TYPE
+// está definida, o de dbconfig.json.
+func runImportUsers(args []string) error {
+	flags := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "", "csv o json (por defecto según la extensión)")
+	dryRun := flags.Bool("dry-run", false, "valida y muestra el resultado sin guardar")
+	invite := flags.Bool("invite", false, "encola invitaciones para las cuentas nuevas")
+	asJSON := flags.Bool("json", false, "imprime el reporte en JSON")
+	tenantSlug := flags.String("tenant", tenant.DefaultSlug, "tenant al que pertenecen las cuentas")
+	path, err := parseUserArg(flags, args)
+	if err != nil {
+		return fmt.Errorf("%w: import-users <archivo> [-format csv|json] [-dry-run] [-invite] [-json] [-tenant slug]", errUsage)
+	}
+
+	if *format == "" {
+		*format = userimport.FormatFromName(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := userimport.Parse(file, *format)
+	if err != nil {
+		return err
+	}
+
+	pool, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer pool.SQL.Close()
+	if pending, err := database.PendingMigrations(pool.SQL); err != nil || pending > 0 {
+		return fmt.Errorf("el esquema no está actualizado (%d migraciones pendientes); inicie el servidor para aplicarlas", pending)
+	}
+
+	t, err := tenant.BySlug(pool.SQL, pool.Dialect, *tenantSlug)
+	if err != nil {
+		return fmt.Errorf("tenant %q: %w", *tenantSlug, err)
+	}
+
+	// Quien tiene acceso al servidor puede asignar cualquier rol
+	report, err := userimport.Import(pool.SQL, pool.Dialect, rows, userimport.Options{
+		DryRun:          *dryRun,
+		Invite:          *invite,
+		AllowPrivileged: true,
+		TenantID:        t.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printImportReport(report)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d filas con errores; no se importó nada", len(report.Errors))
+	}
+
+	if !report.DryRun {
+		database.Default.Replace(pool)
+		audit.Record(context.Background(), audit.Event{
+			Actor:  cliActor(),
+			Action: audit.ActionUsersImported,
+			Target: fmt.Sprintf("%d filas (tenant %s)", report.Total, t.Slug),
+			Result: audit.ResultSuccess,
+		})
+	}
+	return nil
+}
+
+// openDB abre la base de datos con la misma configuración que el servidor
+// principal
+func openDB() (*database.Pool, error) {
+	var cfg config.DBConfig
+	if dbType, ok := os.LookupEnv("DB_TYPE"); ok {
+		cfg = config.DBConfig{
+			DBType:   dbType,
+			Path:     envOr("DB_PATH", "reset.db"),
+			Host:     envOr("DB_HOST", "localhost"),
+			Port:     envOr("DB_PORT", "5432"),
+			User:     envOr("DB_USER", "postgres"),
+			Password: envOr("DB_PASSWORD", "root"),
+			DBName:   envOr("DB_NAME", "db_reset"),
+		}
+	} else {
+		var err error
+		if cfg, err = config.LoadDBConfig(); err != nil {
+			return nil, errors.New("defina DB_TYPE y DB_* o ejecute desde el directorio con dbconfig.json: " + err.Error())
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg.OpenPool()
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+func printImportReport(r *userimport.Report) {
+	if len(r.Errors) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LÍNEA\tCORREO\tCAMPO\tERROR")
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.Line, e.Email, e.Field, e.Message)
+		}
+		w.Flush()
+		return
+	}
+
+	mode := "✅ Importación aplicada"
+	if r.DryRun {
+		mode = "🔎 Simulación (no se guardó nada)"
+	}
+	fmt.Printf("%s: %d filas, %d nuevas, %d actualizadas, %d sin cambios, %d invitaciones\n",
+		mode, r.Total, r.Created, r.Updated, r.Unchanged, r.Invited)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"password-recovery/config"
+)
+
+// runOperator administra la lista de operadores de setup
+func runOperator(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: operator list | add <usuario> [-role admin|operator] | remove <usuario> | enable <usuario> | disable <usuario> | role <usuario> <rol>", errUsage)
+	}
+
+	sub, rest := args[0], args[1:]
+	if sub == "list" {
+		if err := noArgs(rest); err != nil {
+			return err
+		}
+		_, store, err := loadStore()
+		if err != nil {
+			return err
+		}
+		printOperators(store)
+		return nil
+	}
+
+	key, store, err := loadStore()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "add":
+		flags := flag.NewFlagSet("operator add", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		role := flags.String("role", config.OperatorRoleOperator, "rol del operador")
+		user, err := parseUserArg(flags, rest)
+		if err != nil {
+			return err
+		}
+		pass, err := promptNewPassword()
+		if err != nil {
+			return err
+		}
+		if err := store.Add(user, pass, *role); err != nil {
+			return err
+		}
+
+	case "remove":
+		user, err := parseUserArg(nil, rest)
+		if err != nil {
+			return err
+		}
+		if err := store.Remove(user); err != nil {
+			return err
+		}
+
+	case "enable", "disable":
+		user, err := parseUserArg(nil, rest)
+		if err != nil {
+			return err
+		}
+		if err := store.SetDisabled(user, sub == "disable"); err != nil {
+			return err
+		}
+
+	case "role":
+		if len(rest) != 2 {
+			return fmt.Errorf("%w: operator role <usuario> <rol>", errUsage)
+		}
+		if err := store.SetRole(rest[0], rest[1]); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("%w: subcomando desconocido %q", errUsage, sub)
+	}
+
+	if err := config.SaveCredentialStore(key, store); err != nil {
+		return fmt.Errorf("error guardando credenciales: %v", err)
+	}
+	fmt.Printf("✅ operator %s aplicado\n", sub)
+	return nil
+}
+
+// parseUserArg acepta exactamente un usuario y, opcionalmente, flags
+func parseUserArg(flags *flag.FlagSet, args []string) (string, error) {
+	if flags != nil {
+		// Permite el usuario antes o después de las flags
+		if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+			args = append(args[1:], args[0])
+		}
+		if err := flags.Parse(args); err != nil {
+			return "", fmt.Errorf("%w: %v", errUsage, err)
+		}
+		args = flags.Args()
+	}
+	if len(args) != 1 {
+		return "", fmt.Errorf("%w: se esperaba un usuario", errUsage)
+	}
+	return args[0], nil
+}
+
+func printOperators(store *config.CredentialStore) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USUARIO\tROL\tESTADO\tACTUALIZADO")
+	for _, op := range store.Operators {
+		status := "activo"
+		if op.Disabled {
+			status = "deshabilitado"
+		}
+		updated := "-"
+		if !op.UpdatedAt.IsZero() {
+			updated = op.UpdatedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", op.User, op.Role, status, updated)
+	}
+	w.Flush()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const minPasswordLength = 8
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptLine lee una línea visible desde la terminal o stdin
+func promptLine(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("error leyendo entrada: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptPassword lee una contraseña sin eco cuando stdin es una terminal.
+// Si no lo es (por ejemplo en scripts) se lee una línea normal.
+func promptPassword(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return promptLine(label)
+	}
+
+	fmt.Fprint(os.Stderr, label)
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error leyendo contraseña: %v", err)
+	}
+	return string(pass), nil
+}
+
+// promptNewPassword pide la contraseña dos veces y valida su longitud
+func promptNewPassword() (string, error) {
+	pass, err := promptPassword("Contraseña nueva: ")
+	if err != nil {
+		return "", err
+	}
+	if len(pass) < minPasswordLength {
+		return "", fmt.Errorf("la contraseña debe tener al menos %d caracteres", minPasswordLength)
+	}
+
+	confirm, err := promptPassword("Confirmar contraseña: ")
+	if err != nil {
+		return "", err
+	}
+	if pass != confirm {
+		return "", errors.New("las contraseñas no coinciden")
+	}
+	return pass, nil
+}
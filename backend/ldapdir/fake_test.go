@@ -0,0 +1,257 @@
+package ldapdir
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+
+	"password-recovery/httpserver"
+)
+
+const (
+	startTLSOID       = "1.3.6.1.4.1.1466.20037"
+	passwordModifyOID = "1.3.6.1.4.1.4203.1.11.1"
+	fakeBindDN        = "cn=svc,dc=example,dc=com"
+	fakeBindPassword  = "svc-secret"
+	fakeBaseDN        = "dc=example,dc=com"
+)
+
+// modification es un cambio que recibió el directorio falso
+type modification struct {
+	DN        string
+	Operation int64
+	Attribute string
+	Values    []string
+	TLS       bool
+}
+
+// passwordChange es una operación Password Modify (RFC 3062)
+type passwordChange struct {
+	UserIdentity string
+	OldPassword  string
+	NewPassword  string
+	TLS          bool
+}
+
+// fakeDirectory es un servidor LDAP mínimo en proceso: Bind simple,
+// búsqueda por igualdad de mail, Modify, StartTLS y Password Modify. Solo
+// entiende lo que usa el paquete.
+type fakeDirectory struct {
+	t       *testing.T
+	ln      net.Listener
+	tlsConf *tls.Config
+	// entries relaciona DN con correo
+	entries map[string]string
+
+	mu          sync.Mutex
+	connections int
+	modified    []modification
+	changed     []passwordChange
+}
+
+func newFakeDirectory(t *testing.T, entries map[string]string) *fakeDirectory {
+	t.Helper()
+	cert, err := httpserver.SelfSigned(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeDirectory{
+		t:       t,
+		ln:      ln,
+		tlsConf: &tls.Config{Certificates: []tls.Certificate{*cert}},
+		entries: entries,
+	}
+	go f.serve()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+// config es una configuración que apunta al directorio falso
+func (f *fakeDirectory) config(mode string) *Config {
+	c := &Config{
+		URL:                "ldap://" + f.ln.Addr().String(),
+		BindDN:             fakeBindDN,
+		BindPassword:       fakeBindPassword,
+		BaseDN:             fakeBaseDN,
+		Mode:               mode,
+		InsecureSkipVerify: true,
+		TimeoutSeconds:     5,
+	}
+	c.Normalize()
+	return c
+}
+
+func (f *fakeDirectory) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.connections++
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeDirectory) handle(conn net.Conn) {
+	// conn cambia a la conexión TLS después de StartTLS
+	defer func() { conn.Close() }()
+	secure := false
+	for {
+		// Un error de lectura es el cliente que cerró la conexión
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := ber.DecodeString(op.Children[1].Data.Bytes())
+			password := ber.DecodeString(op.Children[2].Data.Bytes())
+			code := ldap.LDAPResultSuccess
+			if dn != fakeBindDN || password != fakeBindPassword {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			f.reply(conn, id, result(ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			for _, dn := range f.search(op) {
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+				entry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, ""))
+				f.reply(conn, id, entry)
+			}
+			f.reply(conn, id, result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationModifyRequest:
+			dn := ber.DecodeString(op.Children[0].Data.Bytes())
+			for _, change := range op.Children[1].Children {
+				m := modification{DN: dn, Operation: change.Children[0].Value.(int64), TLS: secure}
+				attr := change.Children[1]
+				m.Attribute = ber.DecodeString(attr.Children[0].Data.Bytes())
+				for _, v := range attr.Children[1].Children {
+					m.Values = append(m.Values, string(v.Data.Bytes()))
+				}
+				f.mu.Lock()
+				f.modified = append(f.modified, m)
+				f.mu.Unlock()
+			}
+			f.reply(conn, id, result(ldap.ApplicationModifyResponse, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationExtendedRequest:
+			switch ber.DecodeString(op.Children[0].Data.Bytes()) {
+			case startTLSOID:
+				f.reply(conn, id, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+				tlsConn := tls.Server(conn, f.tlsConf)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				conn, secure = tlsConn, true
+			case passwordModifyOID:
+				f.mu.Lock()
+				f.changed = append(f.changed, parsePasswordModify(op, secure))
+				f.mu.Unlock()
+				f.reply(conn, id, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			default:
+				f.reply(conn, id, result(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError))
+			}
+
+		case ldap.ApplicationUnbindRequest:
+			return
+
+		default:
+			f.t.Errorf("directorio falso: operación no soportada %d", op.Tag)
+			return
+		}
+	}
+}
+
+// search resuelve un filtro de igualdad sobre mail
+func (f *fakeDirectory) search(op *ber.Packet) []string {
+	filter := op.Children[6]
+	if filter.Tag != ldap.FilterEqualityMatch {
+		f.t.Errorf("directorio falso: filtro no soportado %d", filter.Tag)
+		return nil
+	}
+	attr := ber.DecodeString(filter.Children[0].Data.Bytes())
+	value := ber.DecodeString(filter.Children[1].Data.Bytes())
+	var dns []string
+	for dn, mail := range f.entries {
+		if strings.EqualFold(attr, "mail") && strings.EqualFold(mail, value) {
+			dns = append(dns, dn)
+		}
+	}
+	return dns
+}
+
+func parsePasswordModify(op *ber.Packet, secure bool) passwordChange {
+	change := passwordChange{TLS: secure}
+	value := op.Children[1]
+	var request *ber.Packet
+	if len(value.Children) > 0 {
+		request = value.Children[0]
+	} else {
+		request = ber.DecodePacket(value.Data.Bytes())
+	}
+	for _, field := range request.Children {
+		v := string(field.Data.Bytes())
+		switch field.Tag {
+		case 0:
+			change.UserIdentity = v
+		case 1:
+			change.OldPassword = v
+		case 2:
+			change.NewPassword = v
+		}
+	}
+	return change
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return p
+}
+
+func (f *fakeDirectory) reply(conn net.Conn, id int64, op *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	envelope.AppendChild(op)
+	conn.Write(envelope.Bytes())
+}
+
+func (f *fakeDirectory) modifications() []modification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]modification(nil), f.modified...)
+}
+
+func (f *fakeDirectory) passwordChanges() []passwordChange {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]passwordChange(nil), f.changed...)
+}
+
+func (f *fakeDirectory) connectionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connections
+}
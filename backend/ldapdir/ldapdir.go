@@ -0,0 +1,285 @@
+// Package ldapdir usa un directorio LDAP (OpenLDAP o Active Directory) como
+// destino de las contraseñas: busca la cuenta por correo y cambia la
+// contraseña en el directorio en lugar de la tabla users.
+//
+// En Active Directory la contraseña se reemplaza en el atributo unicodePwd,
+// lo que el servidor solo acepta sobre una conexión cifrada (LDAPS o
+// StartTLS). En OpenLDAP se usa la operación extendida Password Modify
+// (RFC 3062) para que el servidor aplique su propio hash.
+package ldapdir
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"password-recovery/fielderr"
+)
+
+// Tipos de servidor
+const (
+	ModeOpenLDAP        = "openldap"
+	ModeActiveDirectory = "ad"
+)
+
+// DefaultFilter busca la cuenta por el atributo mail
+const DefaultFilter = "(mail={email})"
+
+const defaultTimeout = 10 * time.Second
+
+var (
+	ErrNotFound  = errors.New("la cuenta no existe en el directorio")
+	ErrAmbiguous = errors.New("el correo corresponde a más de una cuenta en el directorio")
+	ErrInsecure  = errors.New("Active Directory solo acepta cambios de contraseña sobre LDAPS o StartTLS")
+)
+
+// Config es la conexión al directorio
+type Config struct {
+	// URL es ldap://host:389 o ldaps://host:636
+	URL      string `json:"url"`
+	StartTLS bool   `json:"start_tls"`
+	// InsecureSkipVerify desactiva la verificación del certificado (solo
+	// para pruebas)
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// CACertFile es un PEM con la CA del servidor (opcional)
+	CACertFile   string `json:"ca_cert_file,omitempty"`
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password,omitempty"`
+	BaseDN       string `json:"base_dn"`
+	// Filter lleva {email} donde va el correo (escapado)
+	Filter         string `json:"filter"`
+	Mode           string `json:"mode"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// Normalize completa los valores por defecto
+func (c *Config) Normalize() {
+	c.URL = strings.TrimSpace(c.URL)
+	c.Mode = strings.ToLower(strings.TrimSpace(c.Mode))
+	if c.Mode == "" {
+		c.Mode = ModeOpenLDAP
+	}
+	if strings.TrimSpace(c.Filter) == "" {
+		c.Filter = DefaultFilter
+	}
+}
+
+// Validate revisa la configuración (después de Normalize)
+func (c *Config) Validate() error {
+	fields := map[string]string{}
+
+	u, err := url.Parse(c.URL)
+	switch {
+	case c.URL == "":
+		fields["url"] = "es obligatorio"
+	case err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "":
+		fields["url"] = "use ldap://host:389 o ldaps://host:636"
+	case u.Scheme == "ldaps" && c.StartTLS:
+		fields["start_tls"] = "StartTLS no aplica a ldaps://"
+	case c.Mode == ModeActiveDirectory && u.Scheme == "ldap" && !c.StartTLS:
+		fields["url"] = ErrInsecure.Error()
+	}
+
+	if c.BindDN == "" {
+		fields["bind_dn"] = "es obligatorio"
+	}
+	if c.BaseDN == "" {
+		fields["base_dn"] = "es obligatorio"
+	}
+	if !strings.Contains(c.Filter, "{email}") {
+		fields["filter"] = "debe contener {email}"
+	} else if _, err := ldap.CompileFilter(c.filterFor("user@example.com")); err != nil {
+		fields["filter"] = "filtro inválido: " + err.Error()
+	}
+	if c.Mode != ModeOpenLDAP && c.Mode != ModeActiveDirectory {
+		fields["mode"] = "use openldap o ad"
+	}
+	if c.CACertFile != "" {
+		if _, err := os.Stat(c.CACertFile); err != nil {
+			fields["ca_cert_file"] = "no se puede leer el archivo"
+		}
+	}
+	if c.TimeoutSeconds < 0 {
+		fields["timeout_seconds"] = "no puede ser negativo"
+	}
+
+	if len(fields) > 0 {
+		return fielderr.In("configuración LDAP inválida", fields)
+	}
+	return nil
+}
+
+func (c *Config) filterFor(email string) string {
+	return strings.ReplaceAll(c.Filter, "{email}", ldap.EscapeFilter(email))
+}
+
+func (c *Config) timeout() time.Duration {
+	if c.TimeoutSeconds > 0 {
+		return time.Duration(c.TimeoutSeconds) * time.Second
+	}
+	return defaultTimeout
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+	}
+	cfg := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CACertFile != "" {
+		pem, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s no contiene certificados PEM", c.CACertFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// connect abre la conexión, aplica StartTLS si corresponde y se autentica
+// con la cuenta de servicio
+func (c *Config) connect() (*ldap.Conn, error) {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ldap.DialURL(c.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: c.timeout()}),
+		ldap.DialWithTLSConfig(tlsCfg))
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar al directorio: %v", err)
+	}
+	conn.SetTimeout(c.timeout())
+
+	if c.StartTLS {
+		if err := conn.StartTLS(tlsCfg); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error en StartTLS: %v", err)
+		}
+	}
+	if err := conn.Bind(c.BindDN, c.BindPassword); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error de autenticación con %s: %v", c.BindDN, err)
+	}
+	return conn, nil
+}
+
+// secure indica si la conexión va cifrada
+func (c *Config) secure() bool {
+	return strings.HasPrefix(strings.ToLower(c.URL), "ldaps://") || c.StartTLS
+}
+
+// Check se conecta, se autentica y lee la base de búsqueda
+func (c *Config) Check() error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Search(ldap.NewSearchRequest(c.BaseDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases,
+		1, int(c.timeout().Seconds()), false, "(objectClass=*)", []string{"1.1"}, nil))
+	if err != nil {
+		return fmt.Errorf("no se pudo leer la base %s: %v", c.BaseDN, err)
+	}
+	return nil
+}
+
+// Lookup devuelve el DN de la cuenta con ese correo
+func (c *Config) Lookup(email string) (string, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return c.lookup(conn, email)
+}
+
+func (c *Config) lookup(conn *ldap.Conn, email string) (string, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(c.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(c.timeout().Seconds()), false, c.filterFor(email), []string{"1.1"}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", fmt.Errorf("error al buscar en el directorio: %v", err)
+	}
+	switch {
+	case result == nil || len(result.Entries) == 0:
+		return "", ErrNotFound
+	case len(result.Entries) > 1:
+		return "", ErrAmbiguous
+	}
+	return result.Entries[0].DN, nil
+}
+
+// SetPassword busca la cuenta y le asigna la contraseña nueva
+func (c *Config) SetPassword(email, password string) error {
+	if c.Mode == ModeActiveDirectory && !c.secure() {
+		return ErrInsecure
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dn, err := c.lookup(conn, email)
+	if err != nil {
+		return err
+	}
+
+	if c.Mode == ModeActiveDirectory {
+		modify := ldap.NewModifyRequest(dn, nil)
+		modify.Replace("unicodePwd", []string{EncodeUnicodePwd(password)})
+		if err := conn.Modify(modify); err != nil {
+			return fmt.Errorf("el directorio rechazó la contraseña: %v", err)
+		}
+		return nil
+	}
+
+	if _, err := conn.PasswordModify(ldap.NewPasswordModifyRequest(dn, "", password)); err != nil {
+		return fmt.Errorf("el directorio rechazó la contraseña: %v", err)
+	}
+	return nil
+}
+
+// EncodeUnicodePwd codifica la contraseña como la espera unicodePwd: entre
+// comillas dobles y en UTF-16LE
+func EncodeUnicodePwd(password string) string {
+	units := utf16.Encode([]rune(`"` + password + `"`))
+	b := make([]byte, len(units)*2)
+	for i, u := range units {
+		b[2*i] = byte(u)
+		b[2*i+1] = byte(u >> 8)
+	}
+	return string(b)
+}
+
+// Public devuelve la configuración sin la contraseña de la cuenta de
+// servicio
+func (c Config) Public() Config {
+	if c.BindPassword != "" {
+		c.BindPassword = maskedPassword
+	}
+	return c
+}
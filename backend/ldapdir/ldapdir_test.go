@@ -0,0 +1,290 @@
+package ldapdir
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"password-recovery/database"
+	"password-recovery/secrets"
+)
+
+var directoryEntries = map[string]string{
+	"uid=ana,ou=people,dc=example,dc=com":   "ana@example.com",
+	"uid=luis,ou=people,dc=example,dc=com":  "luis@example.com",
+	"uid=luis2,ou=people,dc=example,dc=com": "LUIS@example.com",
+}
+
+func TestLookup(t *testing.T) {
+	dir := newFakeDirectory(t, directoryEntries)
+	cfg := dir.config(ModeOpenLDAP)
+
+	tests := []struct {
+		email  string
+		wantDN string
+		err    error
+	}{
+		{"ana@example.com", "uid=ana,ou=people,dc=example,dc=com", nil},
+		{"nadie@example.com", "", ErrNotFound},
+		{"luis@example.com", "", ErrAmbiguous},
+	}
+	for _, tt := range tests {
+		dn, err := cfg.Lookup(tt.email)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Lookup(%q): error %v, se esperaba %v", tt.email, err, tt.err)
+		}
+		if dn != tt.wantDN {
+			t.Errorf("Lookup(%q) = %q, se esperaba %q", tt.email, dn, tt.wantDN)
+		}
+	}
+}
+
+func TestLookupRejectsBadBind(t *testing.T) {
+	dir := newFakeDirectory(t, directoryEntries)
+	cfg := dir.config(ModeOpenLDAP)
+	cfg.BindPassword = "incorrecta"
+
+	if _, err := cfg.Lookup("ana@example.com"); err == nil || !strings.Contains(err.Error(), "autenticación") {
+		t.Fatalf("Lookup con bind inválido: %v", err)
+	}
+}
+
+func TestEncodeUnicodePwd(t *testing.T) {
+	tests := []struct {
+		password string
+		want     []byte
+	}{
+		{"", []byte{'"', 0, '"', 0}},
+		{"Ab1", []byte{'"', 0, 'A', 0, 'b', 0, '1', 0, '"', 0}},
+		// ñ es U+00F1; 😀 (U+1F600) va como par sustituto D83D DE00
+		{"ñ😀", []byte{'"', 0, 0xF1, 0x00, 0x3D, 0xD8, 0x00, 0xDE, '"', 0}},
+	}
+	for _, tt := range tests {
+		if got := []byte(EncodeUnicodePwd(tt.password)); !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeUnicodePwd(%q) = % x, se esperaba % x", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestSetPasswordActiveDirectory(t *testing.T) {
+	dir := newFakeDirectory(t, directoryEntries)
+	cfg := dir.config(ModeActiveDirectory)
+	cfg.StartTLS = true
+
+	if err := cfg.SetPassword("ana@example.com", "Nueva#2024"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+
+	mods := dir.modifications()
+	if len(mods) != 1 {
+		t.Fatalf("se esperaba un Modify, hubo %d", len(mods))
+	}
+	m := mods[0]
+	if m.DN != "uid=ana,ou=people,dc=example,dc=com" || m.Attribute != "unicodePwd" || m.Operation != ldap.ReplaceAttribute {
+		t.Errorf("Modify inesperado: %+v", m)
+	}
+	if len(m.Values) != 1 || m.Values[0] != EncodeUnicodePwd("Nueva#2024") {
+		t.Errorf("unicodePwd = %q, se esperaba %q", m.Values, EncodeUnicodePwd("Nueva#2024"))
+	}
+	if !m.TLS {
+		t.Error("el cambio de contraseña no viajó sobre TLS")
+	}
+	if len(dir.passwordChanges()) != 0 {
+		t.Error("Active Directory no debe usar Password Modify")
+	}
+}
+
+func TestSetPasswordActiveDirectoryRequiresTLS(t *testing.T) {
+	dir := newFakeDirectory(t, directoryEntries)
+	cfg := dir.config(ModeActiveDirectory)
+
+	if err := cfg.SetPassword("ana@example.com", "Nueva#2024"); !errors.Is(err, ErrInsecure) {
+		t.Fatalf("SetPassword sin TLS: %v, se esperaba ErrInsecure", err)
+	}
+	if n := dir.connectionCount(); n != 0 {
+		t.Errorf("se abrieron %d conexiones; la contraseña no debe salir sin cifrar", n)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate debe rechazar AD sobre ldap:// sin StartTLS")
+	}
+}
+
+func TestSetPasswordOpenLDAP(t *testing.T) {
+	dir := newFakeDirectory(t, directoryEntries)
+	cfg := dir.config(ModeOpenLDAP)
+
+	if err := cfg.SetPassword("ana@example.com", "Nueva#2024"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+
+	changes := dir.passwordChanges()
+	if len(changes) != 1 {
+		t.Fatalf("se esperaba un Password Modify, hubo %d", len(changes))
+	}
+	want := passwordChange{UserIdentity: "uid=ana,ou=people,dc=example,dc=com", NewPassword: "Nueva#2024"}
+	if changes[0] != want {
+		t.Errorf("Password Modify = %+v, se esperaba %+v", changes[0], want)
+	}
+	if len(dir.modifications()) != 0 {
+		t.Error("OpenLDAP no debe escribir el atributo directamente")
+	}
+}
+
+func TestSetPasswordNotFound(t *testing.T) {
+	dir := newFakeDirectory(t, directoryEntries)
+	cfg := dir.config(ModeOpenLDAP)
+
+	if err := cfg.SetPassword("nadie@example.com", "Nueva#2024"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SetPassword: %v, se esperaba ErrNotFound", err)
+	}
+	if len(dir.passwordChanges()) != 0 {
+		t.Error("no se debe cambiar nada si la cuenta no existe")
+	}
+}
+
+func testKeys(t *testing.T) secrets.Keyring {
+	t.Helper()
+	raw, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := secrets.NewMasterKey(raw, secrets.SourceEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secrets.Keyring{key}
+}
+
+func openTestDB(t *testing.T) (*sql.DB, database.Dialect) {
+	t.Helper()
+	d := database.MustForType(database.SQLite)
+	db, err := sql.Open(d.DriverName(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := database.Migrate(db, d); err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+func TestBindPasswordEncrypted(t *testing.T) {
+	SetKeys(testKeys(t))
+	t.Cleanup(func() { SetKeys(nil) })
+	db, d := openTestDB(t)
+
+	cfg := &Config{URL: "ldaps://ldap.example.com", BindDN: fakeBindDN, BindPassword: fakeBindPassword, BaseDN: fakeBaseDN}
+	if err := Save(db, d, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	if err := db.QueryRow("SELECT bind_password FROM ldap_config").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, sealedPrefix) || strings.Contains(stored, fakeBindPassword) {
+		t.Fatalf("bind_password quedó sin cifrar: %q", stored)
+	}
+
+	active, err := Active(db, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.BindPassword != fakeBindPassword {
+		t.Errorf("Active devolvió %q", active.BindPassword)
+	}
+
+	// Otra llave no descifra
+	SetKeys(testKeys(t))
+	if _, err := Active(db, d); err == nil {
+		t.Error("Active descifró con una llave distinta")
+	}
+}
+
+func TestSealStored(t *testing.T) {
+	keys := testKeys(t)
+	SetKeys(keys)
+	t.Cleanup(func() { SetKeys(nil) })
+	db, d := openTestDB(t)
+
+	// Configuración guardada en claro antes del cifrado
+	if _, err := db.Exec(`INSERT INTO ldap_config (url, bind_dn, bind_password, base_dn, search_filter, mode, is_active)
+		VALUES ('ldaps://ldap.example.com', 'cn=svc', 'en-claro', 'dc=example', '(mail={email})', 'openldap', TRUE)`); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := Active(db, d); err != nil || cfg.BindPassword != "en-claro" {
+		t.Fatalf("Active con valor en claro: %v, %+v", err, cfg)
+	}
+
+	if err := SealStored(db, d); err != nil {
+		t.Fatal(err)
+	}
+	var stored string
+	if err := db.QueryRow("SELECT bind_password FROM ldap_config").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		t.Fatalf("SealStored no cifró: %q", stored)
+	}
+	if cfg, err := Active(db, d); err != nil || cfg.BindPassword != "en-claro" {
+		t.Fatalf("Active después de SealStored: %v, %+v", err, cfg)
+	}
+
+	// Una segunda pasada no vuelve a cifrar
+	if err := SealStored(db, d); err != nil {
+		t.Fatal(err)
+	}
+	var again string
+	db.QueryRow("SELECT bind_password FROM ldap_config").Scan(&again)
+	if again != stored {
+		t.Error("SealStored cifró un valor ya cifrado")
+	}
+}
+
+func TestReseal(t *testing.T) {
+	oldKeys, newKeys := testKeys(t), testKeys(t)
+	SetKeys(oldKeys)
+	t.Cleanup(func() { SetKeys(nil) })
+	db, d := openTestDB(t)
+
+	if resealed, err := Reseal(db, d, oldKeys, newKeys.Primary()); err != nil || resealed {
+		t.Fatalf("Reseal sin configuración: %v, %v", resealed, err)
+	}
+
+	cfg := &Config{URL: "ldaps://ldap.example.com", BindDN: fakeBindDN, BindPassword: fakeBindPassword, BaseDN: fakeBaseDN}
+	if err := Save(db, d, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if resealed, err := Reseal(db, d, oldKeys, newKeys.Primary()); err != nil || !resealed {
+		t.Fatalf("Reseal: %v, %v", resealed, err)
+	}
+
+	if _, err := Active(db, d); err == nil {
+		t.Error("la llave anterior todavía descifra bind_password")
+	}
+	SetKeys(newKeys)
+	active, err := Active(db, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.BindPassword != fakeBindPassword {
+		t.Errorf("Active con la llave nueva devolvió %q", active.BindPassword)
+	}
+
+	// Con una llave que no corresponde no se modifica nada
+	var before, after string
+	db.QueryRow("SELECT bind_password FROM ldap_config").Scan(&before)
+	if _, err := Reseal(db, d, testKeys(t), testKeys(t).Primary()); err == nil {
+		t.Error("Reseal aceptó una llave que no descifra")
+	}
+	db.QueryRow("SELECT bind_password FROM ldap_config").Scan(&after)
+	if before != after {
+		t.Error("Reseal modificó bind_password tras fallar")
+	}
+}
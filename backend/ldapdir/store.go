@@ -0,0 +1,199 @@
+package ldapdir
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/secrets"
+)
+
+// maskedPassword es lo que devuelve Public; al guardar significa "conservar
+// la contraseña actual"
+const maskedPassword = "********"
+
+// sealedPrefix marca bind_password cifrada con la llave maestra; sin él es
+// una contraseña guardada en claro antes del cifrado
+const sealedPrefix = "enc:"
+
+// keys cifran bind_password en ldap_config. Cada servidor las fija al
+// arrancar con su llave maestra (RESET_MASTER_KEY).
+var (
+	keys   secrets.Keyring
+	keysMu sync.RWMutex
+)
+
+// SetKeys fija las llaves de bind_password; la primera es la que cifra
+func SetKeys(k secrets.Keyring) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	keys = k
+}
+
+func currentKeys() secrets.Keyring {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keys
+}
+
+// seal cifra la contraseña de la cuenta de servicio para guardarla
+func seal(password string) (string, error) {
+	return sealWith(password, currentKeys().Primary())
+}
+
+func sealWith(password string, key *secrets.MasterKey) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	data, err := secrets.Encrypt([]byte(password), key)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo cifrar bind_password: %w", err)
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// unseal descifra bind_password; un valor en claro se devuelve igual
+func unseal(stored string) (string, error) {
+	return unsealWith(stored, currentKeys())
+}
+
+func unsealWith(stored string, keys secrets.Keyring) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("bind_password cifrada inválida: %v", err)
+	}
+	plaintext, err := secrets.Decrypt(data, keys)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo descifrar bind_password: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Active devuelve la configuración guardada o nil si el destino LDAP está
+// apagado
+func Active(db database.Execer, d database.Dialect) (*Config, error) {
+	var c Config
+	err := db.QueryRow(`SELECT url, start_tls, insecure_skip_verify, ca_cert_file, bind_dn, bind_password,
+		base_dn, search_filter, mode, timeout_seconds
+		FROM ldap_config WHERE is_active = TRUE LIMIT 1`).
+		Scan(&c.URL, &c.StartTLS, &c.InsecureSkipVerify, &c.CACertFile, &c.BindDN, &c.BindPassword,
+			&c.BaseDN, &c.Filter, &c.Mode, &c.TimeoutSeconds)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.BindPassword, err = unseal(c.BindPassword); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SealStored cifra bind_password si quedó en claro (configuraciones
+// guardadas antes del cifrado)
+func SealStored(db *sql.DB, d database.Dialect) error {
+	var id int64
+	var stored string
+	err := db.QueryRow("SELECT id, bind_password FROM ldap_config WHERE is_active = TRUE LIMIT 1").Scan(&id, &stored)
+	if err == sql.ErrNoRows || stored == "" || strings.HasPrefix(stored, sealedPrefix) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(d.Rebind("UPDATE ldap_config SET bind_password = $1 WHERE id = $2"), sealed, id)
+	return err
+}
+
+// Reseal vuelve a cifrar bind_password con newKey al rotar la llave
+// maestra; la guardada se descifra con oldKeys. Devuelve false si no hay
+// contraseña guardada.
+func Reseal(db *sql.DB, d database.Dialect, oldKeys secrets.Keyring, newKey *secrets.MasterKey) (bool, error) {
+	var id int64
+	var stored string
+	err := db.QueryRow("SELECT id, bind_password FROM ldap_config WHERE is_active = TRUE LIMIT 1").Scan(&id, &stored)
+	if err == sql.ErrNoRows || (err == nil && stored == "") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	password, err := unsealWith(stored, oldKeys)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := sealWith(password, newKey)
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(d.Rebind("UPDATE ldap_config SET bind_password = $1 WHERE id = $2"), sealed, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResolvePassword reemplaza la contraseña enmascarada (o vacía) por la que
+// ya estaba guardada, para que el asistente no tenga que volver a pedirla
+func ResolvePassword(db database.Execer, d database.Dialect, c *Config) error {
+	if c.BindPassword != "" && c.BindPassword != maskedPassword {
+		return nil
+	}
+	current, err := Active(db, d)
+	if err != nil {
+		return err
+	}
+	c.BindPassword = ""
+	if current != nil && current.BindDN == c.BindDN {
+		c.BindPassword = current.BindPassword
+	}
+	return nil
+}
+
+// Save reemplaza la configuración activa
+func Save(db *sql.DB, d database.Dialect, c *Config) error {
+	c.Normalize()
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	sealed, err := seal(c.BindPassword)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM ldap_config"); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(d.Rebind(`INSERT INTO ldap_config
+		(url, start_tls, insecure_skip_verify, ca_cert_file, bind_dn, bind_password, base_dn, search_filter, mode, timeout_seconds, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`),
+		c.URL, c.StartTLS, c.InsecureSkipVerify, c.CACertFile, c.BindDN, sealed,
+		c.BaseDN, c.Filter, c.Mode, c.TimeoutSeconds, true, now, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Disable apaga el destino LDAP
+func Disable(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM ldap_config")
+	return err
+}
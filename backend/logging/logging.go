@@ -0,0 +1,69 @@
+// Package logging configura log/slog para ambos servidores: formato JSON o
+// texto, nivel, identificador de petición y redacción de datos sensibles
+// (correos, códigos y contraseñas).
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// Setup crea el logger según LOG_FORMAT (json o text, por defecto text) y
+// LOG_LEVEL (debug, info, warn o error) y lo deja como slog.Default. Los
+// log.Printf que queden también pasan por él.
+func Setup(service string) *slog.Logger {
+	return SetupWriter(os.Stderr, service, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+}
+
+// SetupWriter es Setup con destino, formato y nivel explícitos
+func SetupWriter(w io.Writer, service, format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	logger := slog.New(handler).With("service", service)
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	return logger
+}
+
+// ParseLevel convierte LOG_LEVEL; un valor desconocido equivale a info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithLogger guarda un logger (normalmente con request_id) en el contexto
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext devuelve el logger de la petición o slog.Default
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader se acepta del cliente (o del proxy) y se devuelve siempre
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// RequestID reutiliza el id recibido si es válido o genera uno nuevo
+func RequestID(incoming string) string {
+	if requestIDPattern.MatchString(incoming) {
+		return incoming
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Gin asigna el request id, deja el logger en el contexto de la petición y
+// escribe una línea de acceso al terminar
+func Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := RequestID(c.GetHeader(RequestIDHeader))
+		c.Header(RequestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), logger))
+		c.Next()
+
+		accessLog(logger, c.Request, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
+
+// Mux es el equivalente de Gin para gorilla/mux
+func Mux(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := RequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(WithLogger(r.Context(), logger)))
+
+		accessLog(logger, r, "", rec.status, time.Since(start))
+	})
+}
+
+func accessLog(logger *slog.Logger, r *http.Request, route string, status int, elapsed time.Duration) {
+	level := slog.LevelInfo
+	if status >= 500 {
+		level = slog.LevelError
+	}
+	attrs := []any{
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", status,
+		"duration_ms", elapsed.Milliseconds(),
+		"remote_addr", r.RemoteAddr,
+	}
+	if route != "" {
+		attrs = append(attrs, "route", route)
+	}
+	logger.Log(r.Context(), level, "petición HTTP", attrs...)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Atributos que nunca se escriben tal cual
+var secretKeys = map[string]bool{
+	"password":     true,
+	"pass":         true,
+	"new_password": true,
+	"newpassword":  true,
+	"code":         true,
+	"token":        true,
+	"secret":       true,
+	"api_key":      true,
+	"dsn":          true,
+}
+
+// Email oculta un correo dejando la primera letra, el dominio y un hash
+// corto para poder correlacionar líneas del mismo usuario:
+// "j***@example.com#3f2a9c1d"
+func Email(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(email))
+	hash := hex.EncodeToString(sum[:4])
+
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return "#" + hash
+	}
+	return local[:1] + "***@" + domain + "#" + hash
+}
+
+// redactAttr es el ReplaceAttr de los handlers: tapa secretos y correos
+// aunque alguien los pase directamente al logger
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(a.Key, redacted)
+	case key == "email" || strings.HasSuffix(key, "_email"):
+		if a.Value.Kind() == slog.KindString && !strings.Contains(a.Value.String(), "***") {
+			return slog.String(a.Key, Email(a.Value.String()))
+		}
+	}
+	return a
+}
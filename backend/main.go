@@ -1,27 +1,50 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/big"
 
 	"net"
 	"net/http"
 	"net/smtp"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
+	"password-recovery/apierror"
+	"password-recovery/apikey"
+	"password-recovery/audit"
+	"password-recovery/auth"
+	"password-recovery/challenge"
+	"password-recovery/config"
+	"password-recovery/cors"
+	"password-recovery/database"
+	"password-recovery/health"
+	"password-recovery/httpserver"
+	"password-recovery/i18n"
+	"password-recovery/ldapdir"
+	"password-recovery/logging"
+	"password-recovery/metrics"
+	"password-recovery/openapi"
+	"password-recovery/rbac"
+	"password-recovery/secrets"
+	"password-recovery/tenant"
+	"password-recovery/webapp"
+	"password-recovery/webhook"
 )
 
 // Configuración de la aplicación
 type AppConfig struct {
+	DBType     string `json:"db_type"`
+	DBPath     string `json:"db_path"`
 	DBHost     string `json:"db_host"`
 	DBPort     string `json:"db_port"`
 	DBUser     string `json:"db_user"`
@@ -46,11 +69,46 @@ type SMTPConfig struct {
 // Estructura para solicitud de código
 type RequestCode struct {
 	Email string `json:"email"`
+	// RedirectURL es a dónde volverá el cliente; debe estar en la lista del
+	// tenant y se puede incluir en el correo con {link}
+	RedirectURL string `json:"redirect_url"`
+	// Proof es la solución del reto de GET /challenge
+	Proof challenge.Solution `json:"proof"`
 }
 
-var db *sql.DB
+var (
+	db        *sql.DB
+	dbDialect database.Dialect
+	sessions  *auth.SQLStore
+)
+
+// q adapta los marcadores $N de una consulta al motor configurado
+func q(query string) string {
+	return dbDialect.Rebind(query)
+}
 
 func main() {
+	// Logs estructurados (LOG_FORMAT, LOG_LEVEL)
+	logging.Setup("main")
+
+	// Llave maestra (RESET_MASTER_KEY, RESET_MASTER_KEY_FILE o
+	// RESET_MASTER_PASSPHRASE): cifra la contraseña de la cuenta de servicio
+	// LDAP guardada en la base
+	if masterKey, err := secrets.LoadMasterKey(); err == nil {
+		if masterKey.IsDevelopment() {
+			if secrets.IsProduction() {
+				slog.Error("APP_ENV=production no permite la llave de desarrollo; configure RESET_MASTER_KEY")
+				os.Exit(1)
+			}
+			slog.Warn("Usando la llave maestra de desarrollo")
+		}
+		ldapdir.SetKeys(secrets.Keyring{masterKey})
+	} else if errors.Is(err, secrets.ErrNoMasterKey) {
+		slog.Warn("Sin llave maestra: el destino LDAP no estará disponible")
+	} else {
+		fatal("Error cargando llave maestra", err)
+	}
+
 	// Cargar configuración
 	cfg := loadConfig()
 
@@ -58,50 +116,197 @@ func main() {
 	var err error
 	db, err = connectDB(cfg)
 	if err != nil {
-		log.Fatalf("Error al conectar a la base de datos: %v", err)
+		fatal("Error al conectar a la base de datos", err)
 	}
 	defer db.Close()
 
-	// Crear tablas si no existen
-	if err := createTables(); err != nil {
-		log.Fatalf("Error al crear tablas: %v", err)
+	// Aplicar migraciones pendientes
+	if applied, err := database.Migrate(db, dbDialect); err != nil {
+		fatal("Error al crear tablas", err)
+	} else if applied > 0 {
+		slog.Info("Migraciones aplicadas", "count", applied, "db_type", dbDialect.Name())
+	}
+	if err := ldapdir.SealStored(db, dbDialect); err != nil {
+		slog.Warn("No se pudo cifrar la contraseña LDAP guardada en claro", "error", err)
+	}
+
+	// Verificaciones de salud en segundo plano (BD, migraciones y SMTP)
+	checker := health.New(health.IntervalFromEnv())
+	checker.RegisterDefaults(database.Default)
+	checker.Start(context.Background())
+
+	// Envío de invitaciones encoladas por la importación de usuarios
+	invitationInterval, _ := time.ParseDuration(getEnv("INVITATION_INTERVAL", "30s"))
+	go runInvitations(context.Background(), invitationInterval)
+
+	// Textos personalizados por los tenants (se recargan para ver los
+	// cambios de otras instancias)
+	if err := i18n.Load(db); err != nil {
+		fatal("Error al cargar los textos personalizados", err)
 	}
+	textsInterval, _ := time.ParseDuration(getEnv("TEXTS_RELOAD_INTERVAL", "1m"))
+	go runTexts(context.Background(), textsInterval)
+
+	// Entrega de webhooks con reintentos
+	webhookInterval, _ := time.ParseDuration(getEnv("WEBHOOK_INTERVAL", "15s"))
+	go runWebhooks(context.Background(), webhookInterval)
 
 	// Configurar router
-	router := gin.Default()
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"}, // URL de tu frontend
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	// Rutas para la configuración SMTP
-	admin := router.Group("/admin")
+	router := gin.New()
+	router.Use(gin.Recovery(), logging.Gin(), httpserver.Gin(), metrics.Gin(), i18n.Gin())
+	// CORS por grupo de rutas: administración con credenciales y
+	// recuperación pública (CORS_ORIGINS, CORS_ADMIN_*, CORS_PUBLIC_*)
+	corsRules, err := cors.Load(
+		cors.Group{Name: "admin", Prefixes: []string{"/admin"}, Credentials: true},
+		cors.Group{Name: "public"},
+	)
+	if err != nil {
+		fatal("Error en la configuración de CORS", err)
+	}
+	router.Use(corsRules.Gin())
+
+	// Liveness y readiness para el orquestador
+	router.GET("/healthz", gin.WrapF(checker.LivenessHandler()))
+	router.GET("/readyz", gin.WrapF(checker.ReadinessHandler()))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Especificación OpenAPI y validación de las peticiones contra ella
+	// (campos desconocidos y cuerpos de más de MAX_BODY_BYTES se rechazan)
+	spec := openapi.Default()
+	router.Use(openapi.Gin(spec, openapi.MaxBodyBytesFromEnv()))
+	router.GET("/openapi.json", gin.WrapF(spec.JSONHandler()))
+	router.GET("/docs", gin.WrapF(openapi.DocsHandler()))
+
+	// Catálogo de códigos de error y errores de ruta con el mismo formato
+	router.GET("/errors", gin.WrapF(apierror.CatalogHandler()))
+	router.HandleMethodNotAllowed = true
+	// Frontend embebido (go generate ./webapp): archivos del build,
+	// index.html para las rutas del SPA y /config.js (APP_API_BASE_URL,
+	// APP_NAME, ...); lo demás responde ROUTE_NOT_FOUND
+	web, err := webapp.FromEnv()
+	if err != nil {
+		fatal("Error en la configuración del frontend", err)
+	}
+	if !web.Built() {
+		slog.Info("El binario no incluye el frontend (go generate ./webapp)")
+	}
+	router.GET("/config.js", gin.WrapF(web.ConfigHandler()))
+	router.NoRoute(gin.WrapH(web.Handler(http.HandlerFunc(apierror.NotFound))))
+	router.NoMethod(gin.WrapF(apierror.NotAllowed))
+
+	// Sesiones de administración (Authorization: Bearer) y permisos por ruta
+	sessions = auth.NewSQLStore(database.Default)
+	can := func(perm rbac.Permission) gin.HandlerFunc { return auth.Gin(sessions, perm) }
+
+	// Las rutas de administración y de recuperación trabajan sobre el
+	// tenant de la petición (API key, X-Tenant o host). Otros servicios
+	// pueden llamarlas con una API key (rk_...), opcionalmente firmada.
+	signatures := apikey.NewVerifier(apikey.WindowFromEnv())
+	admin := router.Group("/admin", apikey.Gin(signatures), tenant.Gin())
 	{
-		admin.GET("/smtp-config", getSMTPConfigHandler)
-		admin.POST("/smtp-config", createSMTPConfigHandler)
-		admin.PUT("/smtp-config", updateSMTPConfigHandler)
-		admin.DELETE("/smtp-config", deleteSMTPConfigHandler)
-		admin.POST("/test-smtp", testSMTPConnectionHandler)
+		admin.POST("/login", audit.Gin(audit.ActionAdminLogin), adminLoginHandler)
+		admin.POST("/logout", audit.Gin(audit.ActionAdminLogout), adminLogoutHandler)
+		admin.GET("/me", adminMeHandler)
+
+		// Rutas para la configuración SMTP
+		admin.GET("/smtp-config", audit.Gin(audit.ActionSMTPRead), can(rbac.PermSMTPRead), getSMTPConfigHandler)
+		admin.POST("/smtp-config", audit.Gin(audit.ActionSMTPCreated), can(rbac.PermSMTPWrite), createSMTPConfigHandler)
+		admin.PUT("/smtp-config", audit.Gin(audit.ActionSMTPUpdated), can(rbac.PermSMTPWrite), updateSMTPConfigHandler)
+		admin.DELETE("/smtp-config", audit.Gin(audit.ActionSMTPDeleted), can(rbac.PermSMTPWrite), deleteSMTPConfigHandler)
+		admin.POST("/test-smtp", audit.Gin(audit.ActionSMTPTested), can(rbac.PermSMTPWrite), testSMTPConnectionHandler)
+
+		// Usuarios
+		admin.GET("/users", can(rbac.PermUsersRead), listUsersHandler)
+		admin.POST("/users", audit.Gin(audit.ActionUserCreated), can(rbac.PermUsersWrite), createUserHandler)
+		admin.GET("/users/:id", can(rbac.PermUsersRead), getUserHandler)
+		admin.PUT("/users/:id", audit.Gin(audit.ActionUserUpdated), can(rbac.PermUsersWrite), updateUserHandler)
+		admin.DELETE("/users/:id", audit.Gin(audit.ActionUserDeleted), can(rbac.PermUsersWrite), deleteUserHandler)
+		admin.POST("/users/:id/disable", audit.Gin(audit.ActionUserDisabled), can(rbac.PermUsersWrite), setUserStatusHandler(userStatusDisabled))
+		admin.POST("/users/:id/enable", audit.Gin(audit.ActionUserEnabled), can(rbac.PermUsersWrite), setUserStatusHandler(userStatusActive))
+		admin.POST("/users/:id/force-reset", audit.Gin(audit.ActionUserForcedReset), can(rbac.PermUsersReset), forceResetHandler)
+		admin.DELETE("/users/:id/reset-codes", audit.Gin(audit.ActionUserCodesRevoked), can(rbac.PermUsersReset), revokeResetCodesHandler)
+		admin.POST("/users/import", audit.Gin(audit.ActionUsersImported), can(rbac.PermUsersWrite), importUsersHandler)
+		admin.PUT("/users/:id/role", audit.Gin(audit.ActionUserRoleChanged), can(rbac.PermRolesAssign), setUserRoleHandler)
+		admin.GET("/roles", can(rbac.PermUsersRead), listRolesHandler)
+
+		// Tenants y plantillas de correo (las plantillas son parte de la
+		// configuración de correo del tenant)
+		admin.GET("/tenants", can(rbac.PermTenantsRead), listTenantsHandler)
+		admin.POST("/tenants", audit.Gin(audit.ActionTenantCreated), can(rbac.PermTenantsWrite), createTenantHandler)
+		admin.GET("/tenants/:id", can(rbac.PermTenantsRead), getTenantHandler)
+		admin.PUT("/tenants/:id", audit.Gin(audit.ActionTenantUpdated), can(rbac.PermTenantsWrite), updateTenantHandler)
+		admin.POST("/tenants/:id/api-key", audit.Gin(audit.ActionTenantKeyRotated), can(rbac.PermTenantsWrite), rotateTenantKeyHandler)
+		admin.DELETE("/tenants/:id/api-key", audit.Gin(audit.ActionTenantKeyRevoked), can(rbac.PermTenantsWrite), revokeTenantKeyHandler)
+		admin.GET("/tenants/:id/admins", can(rbac.PermTenantsRead), listTenantAdminsHandler)
+		admin.PUT("/tenants/:id/admins/:user_id", audit.Gin(audit.ActionTenantAdminsChanged), can(rbac.PermRolesAssign), grantTenantAdminHandler)
+		admin.DELETE("/tenants/:id/admins/:user_id", audit.Gin(audit.ActionTenantAdminsChanged), can(rbac.PermRolesAssign), revokeTenantAdminHandler)
+		admin.GET("/templates", can(rbac.PermSMTPRead), listTemplatesHandler)
+		admin.PUT("/templates/:kind", audit.Gin(audit.ActionTemplateUpdated), can(rbac.PermSMTPWrite), saveTemplateHandler)
+		admin.DELETE("/templates/:kind", audit.Gin(audit.ActionTemplateUpdated), can(rbac.PermSMTPWrite), resetTemplateHandler)
+
+		// Textos de la API (mensajes, errores, política) por idioma
+		admin.GET("/texts", can(rbac.PermTextsRead), listTextsHandler)
+		admin.PUT("/texts/:locale/:key", audit.Gin(audit.ActionTextUpdated), can(rbac.PermTextsWrite), saveTextHandler)
+		admin.DELETE("/texts/:locale/:key", audit.Gin(audit.ActionTextUpdated), can(rbac.PermTextsWrite), resetTextHandler)
+
+		// API keys de servicio del tenant
+		admin.GET("/api-keys", can(rbac.PermAPIKeysRead), listAPIKeysHandler)
+		admin.POST("/api-keys", audit.Gin(audit.ActionAPIKeyCreated), can(rbac.PermAPIKeysWrite), createAPIKeyHandler)
+		admin.GET("/api-keys/:id", can(rbac.PermAPIKeysRead), getAPIKeyHandler)
+		admin.POST("/api-keys/:id/rotate", audit.Gin(audit.ActionAPIKeyRotated), can(rbac.PermAPIKeysWrite), rotateAPIKeyHandler)
+		admin.DELETE("/api-keys/:id", audit.Gin(audit.ActionAPIKeyRevoked), can(rbac.PermAPIKeysWrite), revokeAPIKeyHandler)
+
+		// Webhooks del tenant y su historial de entregas
+		admin.GET("/webhooks", can(rbac.PermWebhooksRead), listWebhooksHandler)
+		admin.POST("/webhooks", audit.Gin(audit.ActionWebhookCreated), can(rbac.PermWebhooksWrite), createWebhookHandler)
+		admin.GET("/webhooks/:id", can(rbac.PermWebhooksRead), getWebhookHandler)
+		admin.PUT("/webhooks/:id", audit.Gin(audit.ActionWebhookUpdated), can(rbac.PermWebhooksWrite), updateWebhookHandler)
+		admin.DELETE("/webhooks/:id", audit.Gin(audit.ActionWebhookDeleted), can(rbac.PermWebhooksWrite), deleteWebhookHandler)
+		admin.POST("/webhooks/:id/rotate-secret", audit.Gin(audit.ActionWebhookSecretRotated), can(rbac.PermWebhooksWrite), rotateWebhookSecretHandler)
+		admin.GET("/webhooks/:id/deliveries", can(rbac.PermWebhooksRead), listWebhookDeliveriesHandler)
+		admin.POST("/webhooks/:id/deliveries/:delivery_id/replay", audit.Gin(audit.ActionWebhookReplayed), can(rbac.PermWebhooksWrite), replayWebhookDeliveryHandler)
+
+		// Bitácora de auditoría
+		admin.GET("/audit-events", can(rbac.PermAuditRead), listAuditEventsHandler)
+		admin.GET("/audit-events/export", can(rbac.PermAuditRead), exportAuditEventsHandler)
+	}
+
+	// Rutas para recuperación de contraseña; /send-code exige resolver el
+	// reto de /challenge (prueba de trabajo, ver challenge)
+	verifier, err := challenge.FromEnv()
+	if err != nil {
+		fatal("Error en la configuración de los retos", err)
 	}
+	challenges = verifier
+	recovery := router.Group("/", apikey.Gin(signatures, rbac.PermRecoverySend), tenant.Gin())
+	recovery.GET("/policy", policyHandler)
+	recovery.GET("/challenge", challengeHandler)
+	recovery.POST("/send-code", audit.Gin(audit.ActionCodeRequested), sendCode)
+	recovery.POST("/verify-code", audit.Gin(audit.ActionCodeVerified), verifyCode)
+	recovery.POST("/reset-password", audit.Gin(audit.ActionPasswordReset), resetPassword)
 
-	// Rutas para recuperación de contraseña
-	router.POST("/send-code", sendCode)
-	router.POST("/verify-code", verifyCode)
-	router.POST("/reset-password", resetPassword)
+	if missing := spec.Undocumented(openapi.GinRoutes(router.Routes())); len(missing) > 0 {
+		slog.Warn("Rutas sin documentar en openapi/spec.json", "routes", missing)
+	}
 
 	// Iniciar servidor
-	log.Printf("Servidor iniciado en el puerto %s", cfg.ServerPort)
-	if err := router.Run(":" + cfg.ServerPort); err != nil {
-		log.Fatalf("Error al iniciar el servidor: %v", err)
+	// HTTPS con TLS_CERT_FILE/TLS_KEY_FILE o TLS_SELF_SIGNED (ver httpserver)
+	slog.Info("Servidor iniciado", "port", cfg.ServerPort)
+	if err := httpserver.ListenAndServe(httpserver.ConfigFromEnv(cfg.ServerPort), router); err != nil {
+		fatal("Error al iniciar el servidor", err)
 	}
 }
 
+// fatal registra el error y termina el proceso
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func loadConfig() AppConfig {
 	return AppConfig{
+		DBType:     getEnv("DB_TYPE", database.Postgres),
+		DBPath:     getEnv("DB_PATH", "reset.db"),
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "postgres"),
@@ -118,88 +323,44 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func connectDB(cfg AppConfig) (*sql.DB, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("error al conectar a la base de datos: %v", err)
+// dbConfig traduce las variables de entorno a un config.DBConfig
+func (c AppConfig) dbConfig() config.DBConfig {
+	return config.DBConfig{
+		DBType:   c.DBType,
+		Path:     c.DBPath,
+		Host:     c.DBHost,
+		Port:     c.DBPort,
+		User:     c.DBUser,
+		Password: c.DBPassword,
+		DBName:   c.DBName,
 	}
+}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("error al verificar conexión a la BD: %v", err)
+func connectDB(cfg AppConfig) (*sql.DB, error) {
+	dbCfg := cfg.dbConfig()
+	if err := dbCfg.Validate(); err != nil {
+		return nil, err
 	}
+	dbDialect = dbCfg.Dialect()
 
-	return db, nil
-}
-
-func createTables() error {
-	// Crear tabla de configuración SMTP
-	smtpTable := `
-	CREATE TABLE IF NOT EXISTS smtp_config (
-		id SERIAL PRIMARY KEY,
-		host VARCHAR(100) NOT NULL,
-		port INTEGER NOT NULL,
-		username VARCHAR(100) NOT NULL,
-		password VARCHAR(100) NOT NULL,
-		from_email VARCHAR(100) NOT NULL,
-		is_active BOOLEAN DEFAULT TRUE,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-	CREATE INDEX IF NOT EXISTS idx_smtp_config_active ON smtp_config(is_active);
-	`
-
-	// Crear tabla de códigos de recuperación
-	resetCodesTable := `
-	CREATE TABLE IF NOT EXISTS reset_codes (
-		id SERIAL PRIMARY KEY,
-		user_id INTEGER NOT NULL,
-		code VARCHAR(10) NOT NULL,
-		expiration_time TIMESTAMP WITH TIME ZONE NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		FOREIGN KEY (user_id) REFERENCES users(id)
-	);
-	CREATE INDEX IF NOT EXISTS idx_reset_codes_expiration ON reset_codes(expiration_time);
-	`
-
-	// Crear tabla de usuarios si no existe
-	usersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		email VARCHAR(100) UNIQUE NOT NULL,
-		password VARCHAR(100) NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-	);
-	`
-
-	// Ejecutar todas las consultas
-	if _, err := db.Exec(smtpTable); err != nil {
-		return fmt.Errorf("error al crear tabla smtp_config: %v", err)
-	}
-	if _, err := db.Exec(resetCodesTable); err != nil {
-		return fmt.Errorf("error al crear tabla reset_codes: %v", err)
-	}
-	if _, err := db.Exec(usersTable); err != nil {
-		return fmt.Errorf("error al crear tabla users: %v", err)
+	// El pool lo administra database.Default (mismos límites y estadísticas
+	// que el servidor de setup)
+	if err := database.Default.Swap(dbDialect, dbCfg.GetDSN()); err != nil {
+		return nil, fmt.Errorf("error al verificar conexión a la BD: %v", err)
 	}
 
-	return nil
+	return database.Default.Current().SQL, nil
 }
 
-func sendEmail(to, subject, body string) error {
+// sendEmail envía con la configuración SMTP activa del tenant
+func sendEmail(tenantID int64, to, subject, body string) (err error) {
 	// Obtener la configuración SMTP activa
 	var config SMTPConfig
-	err := db.QueryRow(`
-        SELECT host, port, username, password, from_email 
+	err = db.QueryRow(q(`
+        SELECT id, host, port, username, password, from_email 
         FROM smtp_config 
-        WHERE is_active = TRUE LIMIT 1`).Scan(
+        WHERE is_active = TRUE AND tenant_id = $1 LIMIT 1`), tenantID).Scan(
+		&config.ID,
 		&config.Host,
 		&config.Port,
 		&config.Username,
@@ -208,9 +369,16 @@ func sendEmail(to, subject, body string) error {
 	)
 
 	if err != nil {
+		metrics.ObserveEmail("none", err, 0)
 		return fmt.Errorf("no se pudo obtener la configuración SMTP: %v", err)
 	}
 
+	// Duración y resultado por perfil SMTP
+	start := time.Now()
+	defer func() {
+		metrics.ObserveEmail(strconv.Itoa(config.ID), err, time.Since(start))
+	}()
+
 	// Construir el mensaje
 	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
 		config.FromEmail, to, subject, body)
@@ -311,73 +479,142 @@ func sendEmail(to, subject, body string) error {
 
 func sendCode(c *gin.Context) {
 	var request RequestCode
+	logger := logging.FromContext(c.Request.Context())
 
 	// Parsear el JSON de entrada
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-		log.Println("Error al parsear el JSON:", err)
+		apierror.Abort(c, apierror.BadJSON(err))
+		logger.Warn("JSON inválido en send-code", "error", err)
 		return
 	}
 
 	// Normalizar el email (minúsculas y sin espacios)
 	request.Email = strings.TrimSpace(strings.ToLower(request.Email))
-	log.Println("Buscando email:", request.Email)
+	audit.SetTarget(c.Request.Context(), request.Email)
+	logger.Debug("Buscando usuario", "email", logging.Email(request.Email))
 
-	var userId int
-	// Buscar el usuario en la base de datos
-	err := db.QueryRow("SELECT id FROM users WHERE email = $1", request.Email).Scan(&userId)
+	t := currentTenant(c)
+	if !checkChallenge(c, t, request.Proof) {
+		return
+	}
+	if request.RedirectURL != "" && !t.AllowsRedirect(request.RedirectURL) {
+		apierror.Abort(c, apierror.New(apierror.RedirectNotAllowed))
+		return
+	}
+
+	// Buscar el usuario en la base de datos (o en la tabla del conector)
+	userId, status, err := lookupUser(t.ID, request.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// El correo no existe en la BD
-			log.Println("Correo no encontrado en la BD:", request.Email)
-			c.JSON(http.StatusNotFound, gin.H{
-				"error":   "Correo no encontrado",
-				"details": "El correo proporcionado no está registrado",
-			})
+			logger.Info("Correo no encontrado", "email", logging.Email(request.Email))
+			apierror.Abort(c, apierror.New(apierror.EmailNotFound))
 		} else {
-			// Error de base de datos
-			log.Println("Error al verificar correo:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Error al verificar el correo",
-				"details": err.Error(),
-			})
+			// Error de base de datos (la causa queda en el log)
+			apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		}
 		return
 	}
 
-	// Generar código aleatorio de 8 dígitos usando crypto/rand (más seguro)
-	n, err := rand.Int(rand.Reader, big.NewInt(100000000))
-	if err != nil {
-		log.Println("Error al generar código aleatorio:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar código"})
+	if status == userStatusDisabled {
+		logger.Info("Código solicitado para cuenta deshabilitada", "email", logging.Email(request.Email))
+		apierror.Abort(c, apierror.New(apierror.AccountDisabled))
 		return
 	}
-	code := fmt.Sprintf("%08d", n)
 
-	expirationTime := time.Now().Add(5 * time.Minute) // Código válido por 5 minutos
+	if err := issueResetCode(t, userId, request.Email, request.RedirectURL, i18n.Locale(c.Request.Context())); err != nil {
+		logger.Warn("No se pudo emitir el código", "email", logging.Email(request.Email))
+		code := apierror.Internal
+		if errors.Is(err, errCodeSend) {
+			code = apierror.EmailSendFailed
+		}
+		apierror.Abort(c, apierror.Wrap(code, err))
+		return
+	}
+	logger.Info("Código de recuperación enviado", "email", logging.Email(request.Email), "user_id", userId)
+
+	// Respuesta exitosa
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.Text(c.Request.Context(), "msg.code_sent"),
+		"email":   request.Email,
+	})
+}
+
+// Errores de issueResetCode según la etapa que falló
+var (
+	errCodeGenerate = errors.New("error al generar código")
+	errCodeSave     = errors.New("error al guardar el código")
+	errCodeSend     = errors.New("error al enviar el correo")
+)
+
+// invitationCodeTTL es la vigencia del código de las invitaciones; la de
+// los restablecimientos la define el tenant
+const invitationCodeTTL = 72 * time.Hour
+
+// issueResetCode genera un código con la política del tenant y lo envía con
+// su plantilla de restablecimiento; link es el redirect_url ya validado y
+// locale el idioma del correo si la cuenta no tiene uno
+func issueResetCode(t *tenant.Tenant, userId int, email, link, locale string) error {
+	ttl := t.CodeTTL()
+	if err := issueCode(t, userId, email, tenant.TemplateResetCode, ttl, link, locale); err != nil {
+		return err
+	}
+	webhook.Publish(t.ID, webhook.EventCodeRequested, map[string]interface{}{
+		"user_id":    userId,
+		"email":      email,
+		"expires_at": time.Now().UTC().Add(ttl),
+	})
+	return nil
+}
+
+// issueCode guarda un código nuevo con la vigencia indicada y lo envía con
+// la plantilla kind del tenant, en el idioma de la cuenta (users.locale) o
+// en fallback si no tiene
+func issueCode(t *tenant.Tenant, userId int, email, kind string, ttl time.Duration, link, fallback string) error {
+	// Generar el código aleatorio con crypto/rand (más seguro)
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.CodeLength)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errCodeGenerate, err)
+	}
+	code := fmt.Sprintf("%0*d", t.CodeLength, n)
+
+	expirationTime := time.Now().UTC().Add(ttl)
 
 	// Guardar el código en la base de datos
-	_, err = db.Exec("INSERT INTO reset_codes (user_id, code, expiration_time) VALUES ($1, $2, $3)",
-		userId, code, expirationTime)
+	_, err = db.Exec(q("INSERT INTO reset_codes (tenant_id, user_id, code, expiration_time) VALUES ($1, $2, $3, $4)"),
+		t.ID, userId, code, expirationTime)
 	if err != nil {
-		log.Println("Error al insertar el código en la base de datos:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar el código"})
-		return
+		return fmt.Errorf("%w: %v", errCodeSave, err)
 	}
+	metrics.CodeIssued()
 
 	// Preparar y enviar el correo
-	emailBody := "Tu código de restablecimiento de contraseña es: " + code
-	if err := sendEmail(request.Email, "Restablecimiento de contraseña", emailBody); err != nil {
-		log.Println("Error al enviar el correo:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al enviar el correo"})
-		return
+	var userLocale string
+	if err := db.QueryRow(q("SELECT locale FROM users WHERE id = $1"), userId).Scan(&userLocale); err != nil {
+		return fmt.Errorf("%w: %v", errCodeSend, err)
 	}
-
-	// Respuesta exitosa
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Código enviado correctamente",
-		"email":   request.Email,
+	locale := i18n.ForRecipient(userLocale, fallback)
+	tpl, err := tenant.TemplateFor(db, dbDialect, t.ID, kind, locale)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errCodeSend, err)
+	}
+	subject, body := tpl.Render(map[string]string{
+		tenant.VarCode:    code,
+		tenant.VarExpires: humanDuration(t.ID, locale, ttl),
+		tenant.VarTenant:  t.Name,
+		tenant.VarLink:    link,
 	})
+	if err := sendEmail(t.ID, email, subject, body); err != nil {
+		return fmt.Errorf("%w: %v", errCodeSend, err)
+	}
+	return nil
+}
+
+// currentTenant devuelve el tenant que resolvió tenant.Gin
+func currentTenant(c *gin.Context) *tenant.Tenant {
+	t, _ := tenant.From(c.Request.Context())
+	return t
 }
 
 func verifyCode(c *gin.Context) {
@@ -388,47 +625,68 @@ func verifyCode(c *gin.Context) {
 
 	// Parsear el JSON de entrada
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Abort(c, apierror.BadJSON(err))
 		return
 	}
+	audit.SetTarget(c.Request.Context(), strings.TrimSpace(strings.ToLower(request.Email)))
+	t := currentTenant(c)
 
 	var expirationTime time.Time
 	var validCode string
 	var userId int
-	// Buscar el código en la base de datos (solo códigos no expirados)
-	err := db.QueryRow(`
+	// Buscar el código en la base de datos (solo códigos no expirados del
+	// tenant)
+	err := db.QueryRow(q(`
 		SELECT user_id, code, expiration_time 
 		FROM reset_codes 
-		WHERE code = $1 AND expiration_time > NOW()`,
-		request.Code).Scan(&userId, &validCode, &expirationTime)
+		WHERE tenant_id = $1 AND code = $2 AND expiration_time > $3`),
+		t.ID, request.Code, time.Now().UTC()).Scan(&userId, &validCode, &expirationTime)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Código no encontrado o expirado
-			c.JSON(http.StatusNotFound, gin.H{"error": "Código no válido o expirado"})
+			apierror.Abort(c, codeFailed(t.ID, request.Code))
 		} else {
 			// Error de base de datos
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el código"})
+			apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		}
 		return
 	}
 
 	// Verificar que el código corresponde al email
-	var dbEmail string
-	err = db.QueryRow("SELECT email FROM users WHERE id = $1", userId).Scan(&dbEmail)
+	var dbEmail, status string
+	err = db.QueryRow(q("SELECT email, status FROM users WHERE id = $1"), userId).Scan(&dbEmail, &status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el correo del usuario"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	if status == userStatusDisabled {
+		apierror.Abort(c, apierror.New(apierror.AccountDisabled))
 		return
 	}
 
 	// Comparación case-insensitive de emails
 	if strings.ToLower(dbEmail) != strings.ToLower(request.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Correo y código no coinciden"})
+		metrics.CodeFailed(metrics.ReasonMismatch)
+		apierror.Abort(c, apierror.New(apierror.ResetCodeMismatch))
 		return
 	}
 
 	// Código verificado correctamente
-	c.JSON(http.StatusOK, gin.H{"message": "Código verificado correctamente"})
+	metrics.CodeVerified()
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.code_verified")})
+}
+
+// codeFailed distingue un código vencido de uno inexistente, lo cuenta en
+// las métricas y devuelve el error que se responde
+func codeFailed(tenantID int64, code string) *apierror.Error {
+	var count int
+	if err := db.QueryRow(q("SELECT COUNT(*) FROM reset_codes WHERE tenant_id = $1 AND code = $2"), tenantID, code).Scan(&count); err == nil && count > 0 {
+		metrics.CodeFailed(metrics.ReasonExpired)
+		return apierror.New(apierror.ResetCodeExpired)
+	}
+	metrics.CodeFailed(metrics.ReasonInvalid)
+	return apierror.New(apierror.ResetCodeInvalid)
 }
 
 func resetPassword(c *gin.Context) {
@@ -436,65 +694,87 @@ func resetPassword(c *gin.Context) {
 		Email       string `json:"email"`
 		NewPassword string `json:"newPassword"`
 		Code        string `json:"code"`
+		RedirectURL string `json:"redirect_url"`
 	}
 
 	// Parsear el JSON de entrada
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Abort(c, apierror.BadJSON(err))
+		return
+	}
+	audit.SetTarget(c.Request.Context(), strings.TrimSpace(strings.ToLower(request.Email)))
+
+	t := currentTenant(c)
+	if request.RedirectURL != "" && !t.AllowsRedirect(request.RedirectURL) {
+		apierror.Abort(c, apierror.New(apierror.RedirectNotAllowed))
 		return
 	}
 
 	var userId int
 	// Verificar que el código es válido y no ha expirado
-	err := db.QueryRow(`
+	err := db.QueryRow(q(`
 		SELECT user_id 
 		FROM reset_codes 
-		WHERE code = $1 AND expiration_time > NOW()`,
-		request.Code).Scan(&userId)
+		WHERE tenant_id = $1 AND code = $2 AND expiration_time > $3`),
+		t.ID, request.Code, time.Now().UTC()).Scan(&userId)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Código no válido o expirado"})
+			apierror.Abort(c, codeFailed(t.ID, request.Code))
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el código"})
+			apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		}
 		return
 	}
 
 	// Verificar que el código corresponde al email
-	var dbEmail string
-	err = db.QueryRow("SELECT email FROM users WHERE id = $1", userId).Scan(&dbEmail)
+	var dbEmail, status string
+	err = db.QueryRow(q("SELECT email, status FROM users WHERE id = $1"), userId).Scan(&dbEmail, &status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el correo del usuario"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
+		return
+	}
+	if status == userStatusDisabled {
+		apierror.Abort(c, apierror.New(apierror.AccountDisabled))
 		return
 	}
 
 	// Comparación case-insensitive de emails
 	if strings.ToLower(dbEmail) != strings.ToLower(request.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Correo y código no coinciden"})
+		metrics.CodeFailed(metrics.ReasonMismatch)
+		apierror.Abort(c, apierror.New(apierror.ResetCodeMismatch))
 		return
 	}
 
 	// Actualizar la contraseña en la base de datos
-	_, err = db.Exec("UPDATE users SET password = $1 WHERE id = $2", request.NewPassword, userId)
+	err = withTx(func(tx *sql.Tx) error {
+		return storePassword(tx, t.ID, userId, dbEmail, request.NewPassword)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar la contraseña"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, fmt.Errorf("actualizar la contraseña del usuario %d: %w", userId, err)))
 		return
 	}
 
 	// Eliminar el código usado (opcional)
-	_, _ = db.Exec("DELETE FROM reset_codes WHERE code = $1", request.Code)
+	_, _ = db.Exec(q("DELETE FROM reset_codes WHERE tenant_id = $1 AND code = $2"), t.ID, request.Code)
 
 	// Contraseña actualizada correctamente
-	c.JSON(http.StatusOK, gin.H{"message": "Contraseña actualizada correctamente"})
+	metrics.ResetCompleted()
+	logging.FromContext(c.Request.Context()).Info("Contraseña restablecida", "user_id", userId)
+	webhook.Publish(t.ID, webhook.EventPasswordReset, map[string]interface{}{"user_id": userId, "email": dbEmail})
+	response := gin.H{"message": i18n.Text(c.Request.Context(), "msg.password_updated")}
+	if request.RedirectURL != "" {
+		response["redirect_url"] = request.RedirectURL
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func getSMTPConfigHandler(c *gin.Context) {
 	var config SMTPConfig
 	query := `SELECT id, host, port, username, password, from_email, is_active, created_at, updated_at 
-	          FROM smtp_config WHERE is_active = TRUE LIMIT 1`
+	          FROM smtp_config WHERE is_active = TRUE AND tenant_id = $1 LIMIT 1`
 
-	err := db.QueryRow(query).Scan(
+	err := db.QueryRow(q(query), currentTenant(c).ID).Scan(
 		&config.ID,
 		&config.Host,
 		&config.Port,
@@ -508,124 +788,151 @@ func getSMTPConfigHandler(c *gin.Context) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No hay configuración SMTP activa"})
+			apierror.Abort(c, apierror.New(apierror.SMTPNotConfigured))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener configuración SMTP"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
+	audit.SetTarget(c.Request.Context(), "smtp_config:"+strconv.Itoa(config.ID))
 	c.JSON(http.StatusOK, config)
 }
 
 func createSMTPConfigHandler(c *gin.Context) {
 	var config SMTPConfig
 	if err := c.ShouldBindJSON(&config); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos: " + err.Error()})
+		apierror.Abort(c, apierror.BadJSON(err))
 		return
 	}
+	audit.SetTarget(c.Request.Context(), config.Host)
 
 	tx, err := db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al iniciar transacción"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec("UPDATE smtp_config SET is_active = FALSE")
+	tenantID := currentTenant(c).ID
+	_, err = tx.Exec(q("UPDATE smtp_config SET is_active = FALSE WHERE tenant_id = $1"), tenantID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al desactivar configuraciones existentes"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
 	query := `INSERT INTO smtp_config 
-	          (host, port, username, password, from_email, is_active) 
-	          VALUES ($1, $2, $3, $4, $5, TRUE)
-	          RETURNING id, created_at, updated_at`
+	          (tenant_id, host, port, username, password, from_email, is_active) 
+	          VALUES ($1, $2, $3, $4, $5, $6, TRUE)`
 
-	err = tx.QueryRow(query,
+	id, err := database.InsertID(tx, dbDialect, query,
+		tenantID,
 		config.Host,
 		config.Port,
 		config.Username,
 		config.Password,
 		config.FromEmail,
-	).Scan(&config.ID, &config.CreatedAt, &config.UpdatedAt)
+	)
+	if err == nil {
+		config.ID = int(id)
+		err = tx.QueryRow(q("SELECT created_at, updated_at FROM smtp_config WHERE id = $1"), id).
+			Scan(&config.CreatedAt, &config.UpdatedAt)
+	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al crear configuración SMTP: " + err.Error()})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al confirmar transacción"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
 	config.IsActive = true
+	publishSMTPChanged(tenantID, "created", config.ID, config.Host)
 	c.JSON(http.StatusCreated, config)
 }
 
 func updateSMTPConfigHandler(c *gin.Context) {
 	var config SMTPConfig
 	if err := c.ShouldBindJSON(&config); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Datos inválidos"})
+		apierror.Abort(c, apierror.BadJSON(err))
 		return
 	}
+	audit.SetTarget(c.Request.Context(), config.Host)
 
 	var currentID int
-	err := db.QueryRow("SELECT id FROM smtp_config WHERE is_active = TRUE LIMIT 1").Scan(&currentID)
+	err := db.QueryRow(q("SELECT id FROM smtp_config WHERE is_active = TRUE AND tenant_id = $1 LIMIT 1"), currentTenant(c).ID).Scan(&currentID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No hay configuración SMTP activa para actualizar"})
+			apierror.Abort(c, apierror.New(apierror.SMTPNotConfigured))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar configuración existente"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
 	query := `UPDATE smtp_config SET 
-	          host = $1, port = $2, username = $3, password = $4, from_email = $5, updated_at = NOW()
-	          WHERE id = $6
-	          RETURNING created_at, updated_at`
+	          host = $1, port = $2, username = $3, password = $4, from_email = $5, updated_at = $6
+	          WHERE id = $7`
 
-	err = db.QueryRow(query,
+	_, err = db.Exec(q(query),
 		config.Host,
 		config.Port,
 		config.Username,
 		config.Password,
 		config.FromEmail,
+		time.Now().UTC(),
 		currentID,
-	).Scan(&config.CreatedAt, &config.UpdatedAt)
+	)
+	if err == nil {
+		err = db.QueryRow(q("SELECT created_at, updated_at FROM smtp_config WHERE id = $1"), currentID).
+			Scan(&config.CreatedAt, &config.UpdatedAt)
+	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar configuración SMTP"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
 	config.ID = currentID
 	config.IsActive = true
+	publishSMTPChanged(currentTenant(c).ID, "updated", config.ID, config.Host)
 	c.JSON(http.StatusOK, config)
 }
 
 func deleteSMTPConfigHandler(c *gin.Context) {
 	var currentID int
-	err := db.QueryRow("SELECT id FROM smtp_config WHERE is_active = TRUE LIMIT 1").Scan(&currentID)
+	err := db.QueryRow(q("SELECT id FROM smtp_config WHERE is_active = TRUE AND tenant_id = $1 LIMIT 1"), currentTenant(c).ID).Scan(&currentID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No hay configuración SMTP activa para eliminar"})
+			apierror.Abort(c, apierror.New(apierror.SMTPNotConfigured))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar configuración existente"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM smtp_config WHERE id = $1", currentID)
+	audit.SetTarget(c.Request.Context(), "smtp_config:"+strconv.Itoa(currentID))
+	_, err = db.Exec(q("DELETE FROM smtp_config WHERE id = $1"), currentID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al eliminar configuración SMTP"})
+		apierror.Abort(c, apierror.Wrap(apierror.Internal, err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Configuración SMTP eliminada correctamente"})
+	publishSMTPChanged(currentTenant(c).ID, "deleted", currentID, "")
+	c.JSON(http.StatusOK, gin.H{"message": i18n.Text(c.Request.Context(), "msg.smtp_deleted")})
+}
+
+// publishSMTPChanged avisa a los webhooks del tenant; nunca incluye la
+// contraseña
+func publishSMTPChanged(tenantID int64, action string, id int, host string) {
+	data := map[string]interface{}{"action": action, "smtp_config_id": id}
+	if host != "" {
+		data["host"] = host
+	}
+	webhook.Publish(tenantID, webhook.EventSMTPChanged, data)
 }
 
 func testSMTPConnectionHandler(c *gin.Context) {
@@ -638,34 +945,26 @@ func testSMTPConnectionHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&config); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request data",
-			"details": err.Error(),
-		})
+		apierror.Abort(c, apierror.BadJSON(err))
 		return
 	}
 
+	audit.SetTarget(c.Request.Context(), config.Host)
+
 	// Validación adicional
 	if config.Port <= 0 || config.Port > 65535 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid port number",
-			"field": "port",
-		})
+		apierror.Abort(c, apierror.InvalidField("port", "debe estar entre 1 y 65535"))
 		return
 	}
 
 	// Configurar timeout
 	timeout := 15 * time.Second
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
 
 	// Paso 1: Conexión básica TCP
 	conn, err := net.DialTimeout("tcp", addr, timeout)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Could not connect to SMTP server",
-			"details":    err.Error(),
-			"suggestion": "Verify server address and port availability",
-		})
+		apierror.Abort(c, apierror.Wrap(apierror.SMTPConnectFailed, err))
 		return
 	}
 	defer conn.Close()
@@ -676,10 +975,7 @@ func testSMTPConnectionHandler(c *gin.Context) {
 	// Paso 2: Crear cliente SMTP
 	client, err := smtp.NewClient(conn, config.Host)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "SMTP client creation failed",
-			"details": err.Error(),
-		})
+		apierror.Abort(c, apierror.Wrap(apierror.SMTPConnectFailed, err))
 		return
 	}
 	defer client.Close()
@@ -692,11 +988,7 @@ func testSMTPConnectionHandler(c *gin.Context) {
 		}
 
 		if err := client.StartTLS(tlsConfig); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":      "TLS negotiation failed",
-				"details":    err.Error(),
-				"suggestion": "The server may require different TLS settings",
-			})
+			apierror.Abort(c, apierror.Wrap(apierror.SMTPTLSFailed, err))
 			return
 		}
 	}
@@ -704,29 +996,20 @@ func testSMTPConnectionHandler(c *gin.Context) {
 	// Paso 4: Autenticación
 	auth := smtp.PlainAuth("", config.Username, config.Password, config.Host)
 	if err := client.Auth(auth); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":      "Authentication failed",
-			"details":    err.Error(),
-			"suggestion": "Verify username and password",
-			"field":      "auth", // Indica que el problema está en las credenciales
-		})
+		apierror.Abort(c, apierror.Wrap(apierror.SMTPAuthFailed, err))
 		return
 	}
 
 	// Paso 5: Verificar dirección del remitente
 	if err := client.Mail(config.FromEmail); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Sender address not accepted",
-			"details": err.Error(),
-			"field":   "from_email",
-		})
+		apierror.Abort(c, apierror.Wrap(apierror.SMTPSenderRejected, err))
 		return
 	}
 
 	// Éxito - conexión SMTP verificada
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "SMTP connection successfully verified",
-		"details": fmt.Sprintf("Connected to %s:%d with TLS support", config.Host, config.Port),
+		"message": i18n.Text(c.Request.Context(), "msg.smtp_verified"),
+		"details": i18n.Textf(c.Request.Context(), "msg.smtp_verified_details", config.Host, config.Port),
 	})
 }
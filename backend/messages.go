@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/i18n"
+	"password-recovery/tenant"
+)
+
+// Textos de las respuestas del servidor principal. Los de error están en
+// el catálogo de apierror y las plantillas de correo en tenant.
+func init() {
+	i18n.Define("msg.code_sent", "Código enviado correctamente", "Code sent successfully")
+	i18n.Define("msg.code_verified", "Código verificado correctamente", "Code verified successfully")
+	i18n.Define("msg.password_updated", "Contraseña actualizada correctamente", "Password updated successfully")
+	i18n.Define("msg.smtp_deleted", "Configuración SMTP eliminada correctamente", "SMTP configuration deleted successfully")
+	i18n.Define("msg.smtp_verified", "Conexión SMTP verificada correctamente", "SMTP connection successfully verified")
+	i18n.Define("msg.smtp_verified_details", "Conectado a %s:%d con soporte TLS", "Connected to %s:%d with TLS support")
+	i18n.Define("msg.session_closed", "Sesión cerrada", "Session closed")
+	i18n.Define("msg.role_updated", "Rol actualizado", "Role updated")
+	i18n.Define("msg.user_updated", "Usuario actualizado", "User updated")
+	i18n.Define("msg.user_status_updated", "Estado actualizado", "Status updated")
+	i18n.Define("msg.user_deleted", "Usuario eliminado", "User deleted")
+	i18n.Define("msg.reset_code_sent", "Código de restablecimiento enviado", "Reset code sent")
+	i18n.Define("msg.reset_codes_revoked", "Códigos de restablecimiento revocados", "Reset codes revoked")
+	i18n.Define("msg.tenant_key_created", "Guarde la llave: no se vuelve a mostrar", "Store the key now: it will not be shown again")
+	i18n.Define("msg.tenant_key_revoked", "API key revocada", "API key revoked")
+	i18n.Define("msg.tenant_access_granted", "Acceso asignado", "Access granted")
+	i18n.Define("msg.tenant_access_revoked", "Acceso retirado", "Access removed")
+	i18n.Define("msg.api_key_created", "Guarde la llave y el secreto de firma: no se vuelven a mostrar", "Store the key and the signing secret now: they will not be shown again")
+	i18n.Define("msg.api_key_revoked", "Llave revocada", "Key revoked")
+	i18n.Define("msg.webhook_secret_created", "Guarde el secreto: no se vuelve a mostrar", "Store the secret now: it will not be shown again")
+	i18n.Define("msg.webhook_deleted", "Webhook eliminado", "Webhook deleted")
+	i18n.Define("msg.delivery_requeued", "Evento encolado para reenvío", "Event queued for redelivery")
+
+	i18n.Define("duration.hour", "%d hora", "%d hour")
+	i18n.Define("duration.hours", "%d horas", "%d hours")
+	i18n.Define("duration.minute", "%d minuto", "%d minute")
+	i18n.Define("duration.minutes", "%d minutos", "%d minutes")
+	i18n.Define("duration.second", "%d segundo", "%d second")
+	i18n.Define("duration.seconds", "%d segundos", "%d seconds")
+
+	i18n.Define("policy.code", "El código tiene %d dígitos y vence en %s.", "The code has %d digits and expires in %s.")
+}
+
+// humanDuration escribe la vigencia para el correo ("5 minutos", "72
+// hours") en el idioma indicado, con los textos del tenant
+func humanDuration(tenantID int64, locale string, d time.Duration) string {
+	unit := func(n int64, one, many string) string {
+		key := many
+		if n == 1 {
+			key = one
+		}
+		return i18n.Sprintf(tenantID, locale, key, n)
+	}
+	switch {
+	case d%time.Hour == 0:
+		return unit(int64(d/time.Hour), "duration.hour", "duration.hours")
+	case d%time.Minute == 0:
+		return unit(int64(d/time.Minute), "duration.minute", "duration.minutes")
+	}
+	return unit(int64(d/time.Second), "duration.second", "duration.seconds")
+}
+
+// policyDescription describe la política de códigos del tenant
+func policyDescription(t *tenant.Tenant, locale string) string {
+	return i18n.Sprintf(t.ID, locale, "policy.code", t.CodeLength, humanDuration(t.ID, locale, t.CodeTTL()))
+}
+
+// policyHandler muestra a los usuarios la política de códigos del tenant
+// en el idioma de la petición
+func policyHandler(c *gin.Context) {
+	t := currentTenant(c)
+	c.JSON(http.StatusOK, gin.H{
+		"tenant":           t.Slug,
+		"locale":           i18n.Locale(c.Request.Context()),
+		"code_length":      t.CodeLength,
+		"code_ttl_seconds": t.CodeTTLSeconds,
+		"description":      policyDescription(t, i18n.Locale(c.Request.Context())),
+	})
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"password-recovery/database"
+)
+
+// poolCollector lee database.Manager.Stats en cada scrape
+type poolCollector struct {
+	manager *database.Manager
+
+	connected, maxOpen, open, inUse, idle, inFlight *prometheus.Desc
+	waitCount, waitDuration, swaps                  *prometheus.Desc
+}
+
+func newPoolCollector(m *database.Manager) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, []string{"db_type"}, nil)
+	}
+	return &poolCollector{
+		manager:      m,
+		connected:    desc("connected", "1 si hay un pool de base de datos abierto."),
+		maxOpen:      desc("max_open_connections", "Límite de conexiones abiertas."),
+		open:         desc("open_connections", "Conexiones abiertas."),
+		inUse:        desc("in_use_connections", "Conexiones en uso."),
+		idle:         desc("idle_connections", "Conexiones inactivas."),
+		inFlight:     desc("in_flight_requests", "Peticiones que tienen reservado el pool."),
+		waitCount:    desc("wait_count_total", "Veces que se esperó una conexión libre."),
+		waitDuration: desc("wait_duration_seconds_total", "Tiempo total esperando conexiones."),
+		swaps:        desc("swaps_total", "Reemplazos del pool por cambios de configuración."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.connected, c.maxOpen, c.open, c.inUse, c.idle, c.inFlight, c.waitCount, c.waitDuration, c.swaps} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.manager.Stats()
+	connected := 0.0
+	if s.Connected {
+		connected = 1
+	}
+
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, s.DBType)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, s.DBType)
+	}
+
+	gauge(c.connected, connected)
+	gauge(c.maxOpen, float64(s.MaxOpen))
+	gauge(c.open, float64(s.Open))
+	gauge(c.inUse, float64(s.InUse))
+	gauge(c.idle, float64(s.Idle))
+	gauge(c.inFlight, float64(s.InFlight))
+	counter(c.waitCount, float64(s.WaitCount))
+	counter(c.waitDuration, float64(s.WaitDurationMs)/1000)
+	counter(c.swaps, float64(s.Swaps))
+}
+
+// setupStages se registra una sola vez; volver a llamar a
+// RegisterSetupStages (por ejemplo al armar otra vez el router de setup)
+// solo reemplaza la función que lee las etapas
+var (
+	setupStages = &setupCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "setup_stage"),
+			"Etapas del setup completadas (1) o pendientes (0).", []string{"stage"}, nil),
+	}
+	setupStagesOnce sync.Once
+)
+
+// RegisterSetupStages publica una serie setup_stage{stage} (0 o 1) por cada
+// etapa que devuelva stages. Solo lo usa el servidor de setup.
+func RegisterSetupStages(stages func() map[string]bool) {
+	setupStages.mu.Lock()
+	setupStages.stages = stages
+	setupStages.mu.Unlock()
+	setupStagesOnce.Do(func() { Registry.MustRegister(setupStages) })
+}
+
+type setupCollector struct {
+	mu     sync.Mutex
+	stages func() map[string]bool
+	desc   *prometheus.Desc
+}
+
+func (c *setupCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *setupCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	stages := c.stages
+	c.mu.Unlock()
+	for stage, done := range stages() {
+		v := 0.0
+		if done {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, v, stage)
+	}
+}
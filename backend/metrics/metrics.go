@@ -0,0 +1,137 @@
+// Package metrics expone las métricas Prometheus de ambos servidores en
+// /metrics: códigos de recuperación, envío de correos, pool de la base de
+// datos, duración de las peticiones HTTP y etapas del setup.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"password-recovery/database"
+)
+
+const namespace = "password_recovery"
+
+// Motivos de fallo al validar un código
+const (
+	ReasonInvalid  = "invalid"  // el código no existe
+	ReasonExpired  = "expired"  // el código existe pero venció
+	ReasonMismatch = "mismatch" // el código no corresponde al correo
+)
+
+// Registry contiene solo las métricas de la aplicación y las del runtime
+var Registry = prometheus.NewRegistry()
+
+var (
+	codesIssued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reset_codes_issued_total",
+		Help:      "Códigos de recuperación generados y guardados.",
+	})
+	codesVerified = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reset_codes_verified_total",
+		Help:      "Códigos verificados correctamente.",
+	})
+	codesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reset_codes_failed_total",
+		Help:      "Intentos de verificación o restablecimiento con un código rechazado.",
+	}, []string{"reason"})
+	codesExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reset_codes_expired_total",
+		Help:      "Intentos con un código que ya había vencido.",
+	})
+	resetsCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "password_resets_completed_total",
+		Help:      "Contraseñas restablecidas.",
+	})
+	emailDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "smtp_send_duration_seconds",
+		Help:      "Duración de sendEmail por perfil SMTP y resultado.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"profile", "outcome"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duración de las peticiones HTTP por servidor y ruta.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"server", "method", "route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		codesIssued, codesVerified, codesFailed, codesExpired, resetsCompleted,
+		emailDuration, httpDuration,
+		newPoolCollector(database.Default),
+	)
+}
+
+// CodeIssued cuenta un código generado
+func CodeIssued() { codesIssued.Inc() }
+
+// CodeVerified cuenta un código verificado
+func CodeVerified() { codesVerified.Inc() }
+
+// CodeFailed cuenta un código rechazado; los vencidos también suman a
+// reset_codes_expired_total
+func CodeFailed(reason string) {
+	codesFailed.WithLabelValues(reason).Inc()
+	if reason == ReasonExpired {
+		codesExpired.Inc()
+	}
+}
+
+// ResetCompleted cuenta una contraseña restablecida
+func ResetCompleted() { resetsCompleted.Inc() }
+
+// ObserveEmail registra la duración y el resultado de un envío
+func ObserveEmail(profile string, err error, elapsed time.Duration) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	emailDuration.WithLabelValues(profile, outcome).Observe(elapsed.Seconds())
+}
+
+// ObserveRequest registra la duración de una petición HTTP
+func ObserveRequest(server, method, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched" // evita una serie por cada URL desconocida
+	}
+	httpDuration.WithLabelValues(server, method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// Handler sirve /metrics. Si METRICS_TOKEN está definido se exige
+// "Authorization: Bearer <token>".
+func Handler() http.Handler {
+	handler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	token := os.Getenv("METRICS_TOKEN")
+	if token == "" {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Un token sin el esquema Bearer no se acepta
+		scheme, given, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "no autorizado", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerToken(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "secreto")
+	handler := Handler()
+
+	tests := []struct {
+		authorization string
+		status        int
+	}{
+		{"Bearer secreto", http.StatusOK},
+		{"bearer secreto", http.StatusOK},
+		{"secreto", http.StatusUnauthorized},
+		{"Basic secreto", http.StatusUnauthorized},
+		{"Bearer otro", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("Authorization %q: %d, se esperaba %d", tt.authorization, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestRegisterSetupStagesTwice(t *testing.T) {
+	RegisterSetupStages(func() map[string]bool { return map[string]bool{"db_configured": false} })
+	// El router de setup se puede armar más de una vez
+	RegisterSetupStages(func() map[string]bool { return map[string]bool{"db_configured": true} })
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == namespace+"_setup_stage" {
+			if v := f.GetMetric()[0].GetGauge().GetValue(); v != 1 {
+				t.Errorf("setup_stage = %v, se esperaba la función registrada al final", v)
+			}
+			return
+		}
+	}
+	t.Error("no se publicó setup_stage")
+}
@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+
+	"password-recovery/apierror"
+	"password-recovery/fielderr"
+)
+
+var (
+	ErrBodyTooLarge       = errors.New("el cuerpo de la petición es demasiado grande")
+	ErrUnsupportedContent = errors.New("Content-Type no soportado")
+)
+
+// Check aplica el límite de tamaño y valida parámetros y cuerpo de la
+// petición contra la operación de la ruta (en formato OpenAPI). Deja el
+// cuerpo listo para que el handler lo lea otra vez. Las rutas que no están
+// en la especificación solo tienen el límite de tamaño.
+func (s *Spec) Check(r *http.Request, route string, path func(string) string, defaultLimit int64) error {
+	op := s.Operation(r.Method, route)
+	limit := defaultLimit
+	if op != nil && op.MaxBodyBytes > 0 {
+		limit = op.MaxBodyBytes
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > limit {
+			return ErrBodyTooLarge
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if op == nil {
+		return nil
+	}
+
+	if err := s.ValidateParams(op, path, r.URL.Query()); err != nil {
+		return err
+	}
+	if op.RequestBody == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return fielderr.Field("body", "obligatorio")
+		}
+		return nil
+	}
+
+	// Los handlers leen JSON sin mirar el Content-Type, así que cualquier
+	// tipo que la operación no declare se valida como JSON
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	content, ok := op.RequestBody.Content[contentType]
+	if !ok {
+		contentType = "application/json"
+		if content, ok = op.RequestBody.Content[contentType]; !ok {
+			return ErrUnsupportedContent
+		}
+	}
+	if contentType != "application/json" || content.Schema == nil {
+		return nil // CSV y multipart los revisa el handler
+	}
+	return s.ValidateJSON(content.Schema, body)
+}
+
+// apiError traduce el error de Check; los errores de validación los
+// responde apierror con sus campos
+func apiError(err error) error {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return apierror.New(apierror.PayloadTooLarge)
+	case errors.Is(err, ErrUnsupportedContent):
+		return apierror.New(apierror.UnsupportedMediaType)
+	}
+	return err
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Gin valida las peticiones del servidor principal usando la ruta
+// registrada (c.FullPath)
+func Gin(s *Spec, limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := ginParam.ReplaceAllString(c.FullPath(), "{$1}")
+		if err := s.Check(c.Request, route, c.Param, limit); err != nil {
+			apierror.Abort(c, apiError(err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// Mux es el equivalente de Gin para gorilla/mux; usa la plantilla de la
+// ruta, que ya tiene el formato de OpenAPI
+func Mux(s *Spec, limit int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+			vars := mux.Vars(r)
+			path := func(name string) string { return vars[name] }
+			if err := s.Check(r, route, path, limit); err != nil {
+				apierror.Write(w, r, apiError(err))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GinRoutes lista las rutas de gin como "GET /admin/users/{id}"
+func GinRoutes(routes gin.RoutesInfo) []string {
+	list := make([]string, 0, len(routes))
+	for _, route := range routes {
+		list = append(list, route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+	return list
+}
+
+// MuxRoutes lista las rutas de gorilla/mux como "GET /api/status"; OPTIONS
+// se omite porque lo responde CORS
+func MuxRoutes(r *mux.Router) []string {
+	var list []string
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if method != http.MethodOptions {
+				list = append(list, method+" "+path)
+			}
+		}
+		return nil
+	})
+	return list
+}
@@ -0,0 +1,248 @@
+// Package openapi publica la especificación OpenAPI 3 de las dos APIs (la
+// de recuperación y administración en gin y la de setup en gorilla/mux) y
+// valida contra ella las peticiones antes de que lleguen a los handlers.
+//
+// spec.json es la fuente de verdad: al agregar o cambiar una ruta se
+// actualiza ahí. Los cuerpos JSON se validan con el esquema de la
+// operación (campos desconocidos incluidos) y todos los cuerpos tienen un
+// tamaño máximo.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"password-recovery/httpserver"
+)
+
+//go:embed spec.json
+var specJSON []byte
+
+// DefaultMaxBodyBytes es el tamaño máximo de un cuerpo cuando la operación
+// no define x-max-body-bytes
+const DefaultMaxBodyBytes = 64 << 10
+
+// MaxBodyBytesFromEnv lee MAX_BODY_BYTES (en bytes)
+func MaxBodyBytesFromEnv() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return DefaultMaxBodyBytes
+}
+
+// Schema es el subconjunto de JSON Schema que usa spec.json
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Additional es additionalProperties: false, true o el esquema de los
+// valores (un mapa)
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// Parameter es un parámetro de ruta o de query
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describe el cuerpo por tipo de contenido
+type RequestBody struct {
+	Required bool `json:"required"`
+	Content  map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// Operation es un método de una ruta
+type Operation struct {
+	OperationID  string       `json:"operationId"`
+	Parameters   []Parameter  `json:"parameters"`
+	RequestBody  *RequestBody `json:"requestBody"`
+	MaxBodyBytes int64        `json:"x-max-body-bytes"`
+}
+
+// Spec es la especificación ya interpretada
+type Spec struct {
+	raw        []byte
+	operations map[string]*Operation // "POST /admin/users/{id}"
+	schemas    map[string]*Schema
+}
+
+var methods = []string{"get", "post", "put", "patch", "delete"}
+
+// Parse interpreta una especificación OpenAPI 3 en JSON
+func Parse(data []byte) (*Spec, error) {
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("especificación OpenAPI inválida: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("especificación OpenAPI inválida: versión %q", doc.OpenAPI)
+	}
+
+	s := &Spec{raw: data, operations: map[string]*Operation{}, schemas: doc.Components.Schemas}
+	for path, item := range doc.Paths {
+		var shared []Parameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op Operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			op.Parameters = append(append([]Parameter{}, shared...), op.Parameters...)
+			s.operations[strings.ToUpper(method)+" "+path] = &op
+		}
+	}
+	return s, nil
+}
+
+var defaultSpec *Spec
+
+// Default devuelve la especificación embebida (spec.json)
+func Default() *Spec {
+	return defaultSpec
+}
+
+func init() {
+	s, err := Parse(specJSON)
+	if err != nil {
+		panic(err)
+	}
+	defaultSpec = s
+}
+
+// Operation busca la operación por método y ruta en formato OpenAPI
+// (/admin/users/{id}); nil si no está documentada
+func (s *Spec) Operation(method, path string) *Operation {
+	return s.operations[strings.ToUpper(method)+" "+path]
+}
+
+// Undocumented devuelve las rutas ("GET /x") que no están en la
+// especificación, para avisar al arrancar
+func (s *Spec) Undocumented(routes []string) []string {
+	var missing []string
+	for _, route := range routes {
+		if _, ok := s.operations[route]; !ok {
+			missing = append(missing, route)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// resolve sigue $ref y aplana allOf
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	if schema == nil || len(schema.AllOf) == 0 {
+		return schema
+	}
+	merged := *schema
+	merged.AllOf = nil
+	merged.Properties = map[string]*Schema{}
+	for name, prop := range schema.Properties {
+		merged.Properties[name] = prop
+	}
+	for _, part := range schema.AllOf {
+		part = s.resolve(part)
+		if part == nil {
+			continue
+		}
+		if merged.Type == "" {
+			merged.Type = part.Type
+		}
+		for name, prop := range part.Properties {
+			merged.Properties[name] = prop
+		}
+		merged.Required = append(merged.Required, part.Required...)
+	}
+	return &merged
+}
+
+// JSONHandler sirve la especificación en /openapi.json
+func (s *Spec) JSONHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(s.raw)
+	}
+}
+
+// docsPage usa Redoc desde su CDN para mostrar /openapi.json
+const docsPage = `<!DOCTYPE html>
+<html lang="es">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Password Recovery API</title>
+  <style>body { margin: 0; padding: 0; }</style>
+</head>
+<body>
+  <redoc spec-url="/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// docsCSP permite el script de Redoc, sus estilos en línea y su worker
+const docsCSP = "default-src 'self'; script-src 'self' https://cdn.redoc.ly; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: https://cdn.redoc.ly; font-src 'self' data:; worker-src 'self' blob:; " +
+	"base-uri 'self'; object-src 'none'"
+
+// DocsHandler sirve la documentación navegable en /docs
+func DocsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpserver.SetCSP(w, docsCSP)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(docsPage))
+	}
+}
@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"password-recovery/fielderr"
+)
+
+// validator acumula los errores de un cuerpo o de los parámetros
+type validator struct {
+	spec   *Spec
+	fields map[string]string
+}
+
+func (v *validator) fail(path, msg string) {
+	if path == "" {
+		path = "body"
+	}
+	if _, ok := v.fields[path]; !ok {
+		v.fields[path] = msg
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.fields) > 0 {
+		return fielderr.New(v.fields)
+	}
+	return nil
+}
+
+// ValidateJSON revisa un cuerpo JSON con el esquema indicado
+func (s *Spec) ValidateJSON(schema *Schema, body []byte) error {
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return fielderr.Field("body", "JSON mal formado")
+	}
+	if dec.More() {
+		return fielderr.Field("body", "se esperaba un solo documento JSON")
+	}
+	v := &validator{spec: s, fields: map[string]string{}}
+	v.value("", schema, value)
+	return v.err()
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func (v *validator) value(path string, schema *Schema, value interface{}) {
+	schema = v.spec.resolve(schema)
+	if schema == nil {
+		return
+	}
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			v.fail(path, "no puede ser null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "se esperaba un objeto")
+			return
+		}
+		v.object(path, schema, obj)
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "se esperaba una lista")
+			return
+		}
+		if schema.MinItems != nil && len(list) < *schema.MinItems {
+			v.fail(path, fmt.Sprintf("mínimo %d elementos", *schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(list) > *schema.MaxItems {
+			v.fail(path, fmt.Sprintf("máximo %d elementos", *schema.MaxItems))
+		}
+		for i, item := range list {
+			v.value(fmt.Sprintf("%s[%d]", path, i), schema.Items, item)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(path, "se esperaba texto")
+			return
+		}
+		v.str(path, schema, str)
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			v.fail(path, "se esperaba un número")
+			return
+		}
+		v.number(path, schema, n)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "se esperaba true o false")
+		}
+	}
+}
+
+func (v *validator) object(path string, schema *Schema, obj map[string]interface{}) {
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			v.fail(join(path, name), "obligatorio")
+		}
+	}
+	for name, value := range obj {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			extra := schema.AdditionalProperties
+			switch {
+			case extra == nil:
+			case !extra.Allowed:
+				v.fail(join(path, name), "campo no permitido")
+			case extra.Schema != nil:
+				v.value(join(path, name), extra.Schema, value)
+			}
+			continue
+		}
+		v.value(join(path, name), prop, value)
+	}
+}
+
+func (v *validator) str(path string, schema *Schema, str string) {
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, str) {
+		v.fail(path, "valor no permitido (use "+enumList(schema.Enum)+")")
+		return
+	}
+	n := utf8.RuneCountInString(str)
+	if schema.MinLength != nil && n < *schema.MinLength {
+		if *schema.MinLength == 1 {
+			v.fail(path, "no puede estar vacío")
+		} else {
+			v.fail(path, fmt.Sprintf("mínimo %d caracteres", *schema.MinLength))
+		}
+		return
+	}
+	if schema.MaxLength != nil && n > *schema.MaxLength {
+		v.fail(path, fmt.Sprintf("máximo %d caracteres", *schema.MaxLength))
+		return
+	}
+	if str == "" {
+		return // el formato solo se revisa si hay valor
+	}
+	switch schema.Format {
+	case "email":
+		if _, err := mail.ParseAddress(str); err != nil {
+			v.fail(path, "correo inválido")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			v.fail(path, "fecha inválida (use RFC 3339)")
+		}
+	case "uri":
+		if u, err := url.Parse(str); err != nil || u.Scheme == "" {
+			v.fail(path, "URL inválida")
+		}
+	}
+}
+
+func (v *validator) number(path string, schema *Schema, n json.Number) {
+	f, err := n.Float64()
+	if err != nil {
+		v.fail(path, "se esperaba un número")
+		return
+	}
+	if schema.Type == "integer" && f != math.Trunc(f) {
+		v.fail(path, "se esperaba un entero")
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, f) {
+		v.fail(path, "valor no permitido (use "+enumList(schema.Enum)+")")
+		return
+	}
+	if schema.Minimum != nil && f < *schema.Minimum {
+		v.fail(path, "mínimo "+strconv.FormatFloat(*schema.Minimum, 'f', -1, 64))
+	}
+	if schema.Maximum != nil && f > *schema.Maximum {
+		v.fail(path, "máximo "+strconv.FormatFloat(*schema.Maximum, 'f', -1, 64))
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []interface{}) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		parts[i] = fmt.Sprint(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ValidateParams revisa los parámetros de ruta y de query declarados;
+// path devuelve el valor de un parámetro de ruta. Los parámetros de query
+// no declarados se ignoran.
+func (s *Spec) ValidateParams(op *Operation, path func(string) string, query url.Values) error {
+	v := &validator{spec: s, fields: map[string]string{}}
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw = path(p.Name)
+			present = raw != ""
+		case "query":
+			_, present = query[p.Name]
+			raw = query.Get(p.Name)
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				v.fail(p.Name, "obligatorio")
+			}
+			continue
+		}
+		v.param(p.Name, p.Schema, raw)
+	}
+	return v.err()
+}
+
+// param convierte el texto del parámetro al tipo del esquema antes de
+// validarlo
+func (v *validator) param(name string, schema *Schema, raw string) {
+	schema = v.spec.resolve(schema)
+	if schema == nil {
+		return
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			v.fail(name, "se esperaba un número")
+			return
+		}
+		v.number(name, schema, json.Number(raw))
+	case "boolean":
+		if _, err := strconv.ParseBool(raw); err != nil {
+			v.fail(name, "se esperaba true o false")
+		}
+	default:
+		v.str(name, schema, raw)
+	}
+}
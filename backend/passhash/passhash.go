@@ -0,0 +1,188 @@
+// Package passhash genera y verifica contraseñas en los formatos que usan
+// otras aplicaciones, para que el modo conector escriba en su tabla de
+// usuarios un hash que ellas puedan validar.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+
+	"password-recovery/secrets"
+)
+
+// Formatos soportados
+const (
+	// Bcrypt escribe $2a$<costo>$...
+	Bcrypt = "bcrypt"
+	// PBKDF2SHA256 usa el formato de passlib: $pbkdf2-sha256$<iter>$<sal>$<hash>
+	PBKDF2SHA256 = "pbkdf2-sha256"
+	// Argon2id usa el formato PHC: $argon2id$v=19$m=...,t=...,p=...$<sal>$<hash>
+	Argon2id = "argon2id"
+	// Django es el hasher por defecto de Django: pbkdf2_sha256$<iter>$<sal>$<hash>
+	Django = "django"
+	// Laravel es bcrypt con el prefijo $2y$ de PHP
+	Laravel = "laravel"
+)
+
+// Parámetros por defecto (recomendaciones actuales de OWASP y de cada
+// framework)
+const (
+	bcryptCost       = 12
+	pbkdf2Iterations = 600000
+	djangoIterations = 870000
+	saltLen          = 16
+)
+
+var (
+	ErrUnknownFormat = errors.New("formato de hash desconocido")
+	ErrMalformed     = errors.New("hash con formato inválido")
+)
+
+// Formats lista los formatos aceptados
+func Formats() []string {
+	return []string{Bcrypt, PBKDF2SHA256, Argon2id, Django, Laravel}
+}
+
+// Valid indica si el formato existe
+func Valid(format string) bool {
+	for _, f := range Formats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash codifica la contraseña en el formato indicado
+func Hash(format, password string) (string, error) {
+	switch format {
+	case Bcrypt, Laravel:
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		if format == Laravel {
+			// PHP genera $2y$; el algoritmo es el mismo que $2a$
+			return "$2y$" + string(h[4:]), nil
+		}
+		return string(h), nil
+
+	case PBKDF2SHA256:
+		salt, err := newSalt()
+		if err != nil {
+			return "", err
+		}
+		key := pbkdf2.Key([]byte(password), salt, pbkdf2Iterations, sha256.Size, sha256.New)
+		return fmt.Sprintf("$pbkdf2-sha256$%d$%s$%s", pbkdf2Iterations, ab64(salt), ab64(key)), nil
+
+	case Django:
+		salt, err := newDjangoSalt()
+		if err != nil {
+			return "", err
+		}
+		key := pbkdf2.Key([]byte(password), []byte(salt), djangoIterations, sha256.Size, sha256.New)
+		return fmt.Sprintf("pbkdf2_sha256$%d$%s$%s", djangoIterations, salt, base64.StdEncoding.EncodeToString(key)), nil
+
+	case Argon2id:
+		// Mismo formato PHC y parámetros que las cuentas propias
+		return secrets.HashPassword(password)
+	}
+	return "", ErrUnknownFormat
+}
+
+// Verify compara una contraseña con un hash de cualquiera de los formatos
+// (se detecta por el prefijo)
+func Verify(encoded, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		// x/crypto/bcrypt no reconoce $2y$ pero es equivalente a $2a$
+		h := []byte(encoded)
+		if strings.HasPrefix(encoded, "$2y$") {
+			h = append([]byte("$2a$"), h[4:]...)
+		}
+		err := bcrypt.CompareHashAndPassword(h, []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+
+	case strings.HasPrefix(encoded, "$pbkdf2-sha256$"):
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 5 {
+			return false, ErrMalformed
+		}
+		iter, err := strconv.Atoi(parts[2])
+		salt, err2 := unab64(parts[3])
+		want, err3 := unab64(parts[4])
+		if err != nil || err2 != nil || err3 != nil || iter <= 0 {
+			return false, ErrMalformed
+		}
+		got := pbkdf2.Key([]byte(password), salt, iter, len(want), sha256.New)
+		return subtle.ConstantTimeCompare(got, want) == 1, nil
+
+	case strings.HasPrefix(encoded, "pbkdf2_sha256$"):
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 4 {
+			return false, ErrMalformed
+		}
+		iter, err := strconv.Atoi(parts[1])
+		want, err2 := base64.StdEncoding.DecodeString(parts[3])
+		if err != nil || err2 != nil || iter <= 0 {
+			return false, ErrMalformed
+		}
+		got := pbkdf2.Key([]byte(password), []byte(parts[2]), iter, len(want), sha256.New)
+		return subtle.ConstantTimeCompare(got, want) == 1, nil
+
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		ok, err := secrets.VerifyPassword(encoded, password)
+		if errors.Is(err, secrets.ErrInvalidHash) {
+			return false, ErrMalformed
+		}
+		return ok, err
+	}
+	return false, ErrUnknownFormat
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltLen)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// newDjangoSalt genera la sal alfanumérica de 22 caracteres de Django
+func newDjangoSalt() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	salt := make([]byte, 0, 22)
+	buf := make([]byte, 32)
+	for len(salt) < cap(salt) {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, c := range buf {
+			// Se descartan los bytes >= 248 para no sesgar el alfabeto
+			if c < 248 && len(salt) < cap(salt) {
+				salt = append(salt, alphabet[int(c)%len(alphabet)])
+			}
+		}
+	}
+	return string(salt), nil
+}
+
+// ab64 es la variante de base64 de passlib: sin relleno y con "." en lugar
+// de "+"
+func ab64(b []byte) string {
+	return strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(b), "+", ".")
+}
+
+func unab64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
@@ -0,0 +1,86 @@
+package passhash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Hashes generados por otras implementaciones
+var knownVectors = []struct {
+	name     string
+	encoded  string
+	password string
+}{
+	// Ejemplo de password_verify en la documentación de PHP
+	{"laravel $2y$", "$2y$10$.vGA1O9wmRjrwAVXD98HNOgsNpDczlqm3Jq7KnEd1rVAGv3Fykk1a", "rasmuslerdorf"},
+	// Vector de prueba de crypt_blowfish (Openwall)
+	{"bcrypt $2a$", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U"},
+	// Formato de passlib; generado con hashlib.pbkdf2_hmac de Python
+	{"passlib", "$pbkdf2-sha256$29000$obLD1OX2BxgpOktcbX6PkA$/.TyrY7P2qWluKvzrEhHs3lu/GzhIghZw6HLp4cqGp8", "contraseña"},
+	// Formato de Django; generado con hashlib.pbkdf2_hmac de Python
+	{"django", "pbkdf2_sha256$870000$c3JxHAXr0oVzVaUDYPE1m3$zTGv8LdFEQGqLUs7eJEICzNHbp2vaipfcjAFbo/bztY=", "contraseña"},
+	// Ejemplo de la documentación de argon2-cffi
+	{"argon2id", "$argon2id$v=19$m=65536,t=3,p=4$MIIRqgvgQbgj220jfp0MPA$YfwJSVjtjSU0zzV/P3S9nnQ/USre2wvJMjfCIjrTQbg", "correct horse battery staple"},
+}
+
+func TestVerifyKnownVectors(t *testing.T) {
+	for _, v := range knownVectors {
+		t.Run(v.name, func(t *testing.T) {
+			if ok, err := Verify(v.encoded, v.password); !ok || err != nil {
+				t.Errorf("Verify con la contraseña correcta = %v, %v", ok, err)
+			}
+			if ok, err := Verify(v.encoded, v.password+"x"); ok || err != nil {
+				t.Errorf("Verify con otra contraseña = %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	prefixes := map[string]string{
+		Bcrypt:       "$2a$",
+		Laravel:      "$2y$",
+		PBKDF2SHA256: "$pbkdf2-sha256$",
+		Django:       "pbkdf2_sha256$",
+		Argon2id:     "$argon2id$v=19$",
+	}
+	for _, format := range Formats() {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Hash(format, "Nueva#2024")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(encoded, prefixes[format]) {
+				t.Errorf("Hash = %q, se esperaba el prefijo %q", encoded, prefixes[format])
+			}
+			if ok, err := Verify(encoded, "Nueva#2024"); !ok || err != nil {
+				t.Errorf("Verify = %v, %v", ok, err)
+			}
+			if ok, _ := Verify(encoded, "otra"); ok {
+				t.Error("Verify aceptó otra contraseña")
+			}
+		})
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	tests := []struct {
+		encoded string
+		err     error
+	}{
+		{"md5$abc", ErrUnknownFormat},
+		{"", ErrUnknownFormat},
+		{"$pbkdf2-sha256$x$abc$def", ErrMalformed},
+		{"pbkdf2_sha256$1000$sal", ErrMalformed},
+		{"$argon2id$v=19$m=65536,t=3$sal$hash", ErrMalformed},
+	}
+	for _, tt := range tests {
+		if ok, err := Verify(tt.encoded, "x"); ok || !errors.Is(err, tt.err) {
+			t.Errorf("Verify(%q) = %v, %v; se esperaba %v", tt.encoded, ok, err, tt.err)
+		}
+	}
+	if _, err := Hash("md5", "x"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Hash con formato desconocido: %v", err)
+	}
+}
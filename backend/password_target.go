@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"password-recovery/connector"
+	"password-recovery/database"
+	"password-recovery/ldapdir"
+	"password-recovery/logging"
+	"password-recovery/secrets"
+	"password-recovery/tenant"
+)
+
+// Las contraseñas se pueden guardar en tres destinos, en este orden de
+// prioridad: un directorio LDAP, la tabla de otra aplicación (modo
+// conector) o la tabla users propia. En los dos primeros la tabla users
+// guarda una fila sombra por correo (con una contraseña aleatoria que nunca
+// se usa) para que reset_codes, el estado de la cuenta y la auditoría
+// funcionen igual en todos los modos.
+//
+// El directorio LDAP y el conector se configuran una sola vez para toda la
+// instalación, así que solo los usa el tenant por defecto; los demás tenants
+// guardan siempre en la tabla users.
+
+// usesExternalTarget indica si el tenant guarda las contraseñas en el
+// directorio o el conector configurados
+func usesExternalTarget(tenantID int64) bool {
+	return tenantID == tenant.DefaultID
+}
+
+// lookupUser busca la cuenta local del tenant por correo. Si solo existe en
+// el directorio o en la tabla del conector se crea su fila sombra en el
+// tenant.
+func lookupUser(tenantID int64, email string) (userId int, status string, err error) {
+	err = db.QueryRow(q("SELECT id, status FROM users WHERE tenant_id = $1 AND email = $2"), tenantID, email).Scan(&userId, &status)
+	if err != sql.ErrNoRows {
+		return userId, status, err
+	}
+
+	found, ferr := existsInExternalTarget(tenantID, email)
+	if ferr != nil {
+		return 0, "", ferr
+	}
+	if !found {
+		return 0, "", err
+	}
+
+	id, err := createShadowUser(db, dbDialect, tenantID, email)
+	if err != nil {
+		return 0, "", err
+	}
+	return int(id), userStatusActive, nil
+}
+
+func existsInExternalTarget(tenantID int64, email string) (bool, error) {
+	if !usesExternalTarget(tenantID) {
+		return false, nil
+	}
+	dir, err := ldapdir.Active(db, dbDialect)
+	if err != nil {
+		return false, err
+	}
+	if dir != nil {
+		_, err := dir.Lookup(email)
+		if errors.Is(err, ldapdir.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	cfg, err := connector.Active(db, dbDialect)
+	if err != nil || cfg == nil {
+		return false, err
+	}
+	return connector.Exists(db, dbDialect, cfg, email)
+}
+
+// storePassword guarda la contraseña nueva en el destino configurado; la
+// fila local solo registra la fecha. Si la cuenta no está en el destino
+// externo (por ejemplo un administrador local) se usa la tabla users, con
+// hash Argon2id.
+func storePassword(tx *sql.Tx, tenantID int64, userId int, email, password string) error {
+	now := time.Now().UTC()
+
+	written, err := storeExternalPassword(tx, tenantID, email, password, now)
+	if err != nil {
+		return err
+	}
+	if written {
+		_, err = tx.Exec(q("UPDATE users SET status = $1, last_password_change = $2, updated_at = $3 WHERE id = $4"),
+			userStatusActive, now, now, userId)
+		if err != nil {
+			// La contraseña ya cambió en el destino externo
+			slog.Error("Contraseña cambiada pero no se actualizó la fila local",
+				"email", logging.Email(email), "error", err)
+		}
+		return err
+	}
+
+	hash, err := secrets.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(q("UPDATE users SET password = $1, status = $2, last_password_change = $3, updated_at = $4 WHERE id = $5"),
+		hash, userStatusActive, now, now, userId)
+	return err
+}
+
+func storeExternalPassword(tx *sql.Tx, tenantID int64, email, password string, now time.Time) (bool, error) {
+	if !usesExternalTarget(tenantID) {
+		return false, nil
+	}
+	dir, err := ldapdir.Active(tx, dbDialect)
+	if err != nil {
+		return false, err
+	}
+	if dir != nil {
+		err := dir.SetPassword(email, password)
+		if errors.Is(err, ldapdir.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	cfg, err := connector.Active(tx, dbDialect)
+	if err != nil || cfg == nil {
+		return false, err
+	}
+	err = connector.SetPassword(tx, dbDialect, cfg, email, password, now)
+	if errors.Is(err, connector.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// createShadowUser crea la fila local de una cuenta externa para poder
+// emitirle códigos
+func createShadowUser(exec database.Execer, d database.Dialect, tenantID int64, email string) (int64, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	return database.InsertID(exec, d,
+		"INSERT INTO users (tenant_id, email, password, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)",
+		tenantID, email, "!"+hex.EncodeToString(b), now, now)
+}
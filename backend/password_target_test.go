@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"password-recovery/secrets"
+	"password-recovery/tenant"
+)
+
+// El destino LDAP configurado es de la instalación: otro tenant no puede
+// crear filas sombra ni escribir contraseñas en él
+func TestExternalTargetOnlyDefaultTenant(t *testing.T) {
+	setupRecovery(t)
+	if _, err := db.Exec(`INSERT INTO tenants (slug, name) VALUES ('acme', 'Acme')`); err != nil {
+		t.Fatal(err)
+	}
+	// Un servidor que no responde: si se consulta, la llamada falla
+	if _, err := db.Exec(`INSERT INTO ldap_config (url, bind_dn, bind_password, base_dn, search_filter, mode, is_active)
+		VALUES ('ldap://127.0.0.1:1', 'cn=svc', 'svc', 'dc=example', '(mail={email})', 'openldap', TRUE)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := existsInExternalTarget(tenant.DefaultID, "ana@example.com"); err == nil {
+		t.Error("el tenant por defecto no consultó el directorio")
+	}
+
+	const acme int64 = 2
+	if _, _, err := lookupUser(acme, "ana@example.com"); err != sql.ErrNoRows {
+		t.Fatalf("lookupUser en otro tenant: %v", err)
+	}
+	var shadows int
+	db.QueryRow("SELECT COUNT(*) FROM users WHERE tenant_id = 2").Scan(&shadows)
+	if shadows != 0 {
+		t.Fatalf("se creó una fila sombra en otro tenant")
+	}
+
+	if _, err := db.Exec(`INSERT INTO users (tenant_id, email, password) VALUES (2, 'ana@example.com', '!')`); err != nil {
+		t.Fatal(err)
+	}
+	userId, _, err := lookupUser(acme, "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storePassword(tx, acme, userId, "ana@example.com", "NuevaClave123!"); err != nil {
+		tx.Rollback()
+		t.Fatalf("storePassword usó el directorio: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var hash string
+	db.QueryRow(q("SELECT password FROM users WHERE id = $1"), userId).Scan(&hash)
+	if ok, err := secrets.VerifyPassword(hash, "NuevaClave123!"); err != nil || !ok {
+		t.Errorf("la contraseña no quedó en la tabla users: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package rbac define los roles, los permisos de cada uno y la identidad
+// (Principal) de la petición autenticada.
+package rbac
+
+import (
+	"context"
+	"sort"
+)
+
+// Roles de las cuentas de users.role
+const (
+	RoleAdmin    = "admin"    // todo
+	RoleHelpdesk = "helpdesk" // ve usuarios y dispara restablecimientos
+	RoleAuditor  = "auditor"  // solo lectura de usuarios y auditoría
+	RoleUser     = "user"     // sin acceso administrativo
+
+	// RoleOperator es el rol "operator" de los operadores de setup
+	// (config.json.enc): puede ejecutar el setup pero no administrar
+	RoleOperator = "operator"
+)
+
+// Permission es una acción protegida
+type Permission string
+
+const (
+	PermUsersRead     Permission = "users:read"
+	PermUsersWrite    Permission = "users:write"
+	PermUsersReset    Permission = "users:reset"
+	PermRolesAssign   Permission = "roles:assign"
+	PermSMTPRead      Permission = "smtp:read"
+	PermSMTPWrite     Permission = "smtp:write"
+	PermAuditRead     Permission = "audit:read"
+	PermSetupRead     Permission = "setup:read"
+	PermSetupWrite    Permission = "setup:write"
+	PermTenantsRead   Permission = "tenants:read"
+	PermTenantsWrite  Permission = "tenants:write"
+	PermAPIKeysRead   Permission = "api_keys:read"
+	PermAPIKeysWrite  Permission = "api_keys:write"
+	PermWebhooksRead  Permission = "webhooks:read"
+	PermWebhooksWrite Permission = "webhooks:write"
+	PermTextsRead     Permission = "texts:read"
+	PermTextsWrite    Permission = "texts:write"
+
+	// PermRecoverySend solo se asigna a API keys: permite llamar a
+	// /send-code, /verify-code y /reset-password con la llave
+	PermRecoverySend Permission = "recovery:send"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermUsersRead, PermUsersWrite, PermUsersReset, PermRolesAssign,
+		PermSMTPRead, PermSMTPWrite, PermAuditRead, PermSetupRead, PermSetupWrite,
+		PermTenantsRead, PermTenantsWrite, PermAPIKeysRead, PermAPIKeysWrite,
+		PermWebhooksRead, PermWebhooksWrite, PermTextsRead, PermTextsWrite,
+	},
+	RoleHelpdesk: {PermUsersRead, PermUsersReset, PermTenantsRead},
+	RoleAuditor: {
+		PermUsersRead, PermAuditRead, PermSetupRead, PermTenantsRead, PermAPIKeysRead,
+		PermWebhooksRead, PermTextsRead,
+	},
+	RoleUser:     {},
+	RoleOperator: {PermSetupRead, PermSetupWrite},
+}
+
+// AccountRoles son los roles asignables a users.role
+func AccountRoles() []string {
+	return []string{RoleAdmin, RoleHelpdesk, RoleAuditor, RoleUser}
+}
+
+// ValidAccountRole indica si role se puede asignar a una cuenta
+func ValidAccountRole(role string) bool {
+	for _, r := range AccountRoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Has indica si el rol tiene el permiso
+func Has(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions devuelve los permisos del rol ordenados
+func Permissions(role string) []Permission {
+	perms := append([]Permission{}, rolePermissions[role]...)
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// Principal es quién hace la petición
+type Principal struct {
+	ID      int64  `json:"id,omitempty"` // users.id (0 para operadores de setup)
+	Subject string `json:"subject"`      // correo u operador
+	Role    string `json:"role"`
+	// Locale es users.locale de la cuenta; se usa si la petición no trae
+	// Accept-Language
+	Locale string `json:"locale,omitempty"`
+	// Tenants son los tenants que la cuenta puede administrar (vacío para
+	// operadores de setup, que no trabajan por tenant)
+	Tenants []int64 `json:"tenants,omitempty"`
+	// Scopes no es nil cuando el principal es una API key: solo tiene esos
+	// permisos, no los de un rol
+	Scopes []Permission `json:"scopes,omitempty"`
+}
+
+// Can indica si el principal tiene el permiso
+func (p Principal) Can(perm Permission) bool {
+	if p.Scopes != nil {
+		for _, s := range p.Scopes {
+			if s == perm {
+				return true
+			}
+		}
+		return false
+	}
+	return Has(p.Role, perm)
+}
+
+// InTenant indica si el principal puede administrar el tenant
+func (p Principal) InTenant(id int64) bool {
+	for _, t := range p.Tenants {
+		if t == id {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+// WithPrincipal guarda el principal en el contexto
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// PrincipalFrom devuelve el principal autenticado, si hay
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// Can indica si la petición autenticada tiene el permiso
+func Can(ctx context.Context, perm Permission) bool {
+	p, ok := PrincipalFrom(ctx)
+	return ok && p.Can(perm)
+}
@@ -0,0 +1,82 @@
+// Package retry reúne lo que comparten las colas que se procesan en
+// segundo plano (invitaciones y entregas de webhooks): el ciclo que las
+// revisa, la espera exponencial entre intentos y el recorte del último
+// error que se guarda en la base.
+package retry
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxErrorLength es el largo máximo, en bytes, del error guardado
+const MaxErrorLength = 500
+
+// maxShift limita la espera para que el desplazamiento no desborde
+const maxShift = 20
+
+// Policy define cuántas veces se intenta y la espera base, que se duplica
+// en cada intento fallido (Base, 2×Base, 4×Base...)
+type Policy struct {
+	MaxAttempts int
+	Base        time.Duration
+}
+
+// Attempt es el resultado de registrar un intento fallido
+type Attempt struct {
+	// Number es el total de intentos hechos, contando el que falló
+	Number int
+	// Next es cuándo toca el siguiente intento
+	Next time.Time
+	// Exhausted indica que no quedan intentos
+	Exhausted bool
+}
+
+// Failed calcula el siguiente intento después de uno fallido; previous es
+// el número de intentos que ya había antes de este
+func (p Policy) Failed(previous int, now time.Time) Attempt {
+	n := previous + 1
+	shift := n - 1
+	if shift > maxShift {
+		shift = maxShift
+	}
+	return Attempt{
+		Number:    n,
+		Next:      now.Add(p.Base << uint(shift)),
+		Exhausted: n >= p.MaxAttempts,
+	}
+}
+
+// ErrorMessage devuelve el texto de err recortado a MaxErrorLength
+func ErrorMessage(err error) string {
+	return Truncate(err.Error(), MaxErrorLength)
+}
+
+// Truncate recorta s a max bytes como mucho sin partir un carácter UTF-8
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	i := max
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i]
+}
+
+// Run llama a work al empezar, cada interval y cada vez que llega algo por
+// wake (puede ser nil), hasta que se cancela ctx
+func Run(ctx context.Context, interval time.Duration, wake <-chan struct{}, work func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		work()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package retry
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hola", 10, "hola"},
+		{"hola", 4, "hola"},
+		{"hola", 2, "ho"},
+		// ñ ocupa dos bytes: no se corta a la mitad
+		{"añb", 2, "a"},
+		{"añb", 3, "añ"},
+		// 😀 ocupa cuatro bytes
+		{"x😀", 4, "x"},
+		{"😀", 0, ""},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.s, tt.max)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, se esperaba %q", tt.s, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d) produjo UTF-8 inválido", tt.s, tt.max)
+		}
+	}
+
+	long := strings.Repeat("é", MaxErrorLength)
+	if got := Truncate(long, MaxErrorLength); len(got) != MaxErrorLength || !utf8.ValidString(got) {
+		t.Errorf("Truncate de %d bytes dejó %d bytes", len(long), len(got))
+	}
+}
+
+func TestPolicyFailed(t *testing.T) {
+	p := Policy{MaxAttempts: 3, Base: time.Minute}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		previous  int
+		wait      time.Duration
+		exhausted bool
+	}{
+		{0, time.Minute, false},
+		{1, 2 * time.Minute, false},
+		{2, 4 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		a := p.Failed(tt.previous, now)
+		if a.Number != tt.previous+1 || a.Next.Sub(now) != tt.wait || a.Exhausted != tt.exhausted {
+			t.Errorf("Failed(%d) = %+v, se esperaba espera %v y agotado %v", tt.previous, a, tt.wait, tt.exhausted)
+		}
+	}
+
+	if a := p.Failed(100, now); a.Next.Before(now) {
+		t.Errorf("la espera desbordó: %v", a.Next)
+	}
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/auth"
+	"password-recovery/connector"
+	"password-recovery/database"
+	"password-recovery/i18n"
+	"password-recovery/passhash"
+	"password-recovery/rbac"
+)
+
+// registerConnectorRoutes agrega al asistente la configuración del modo
+// conector (tabla de usuarios de otra aplicación)
+func registerConnectorRoutes(r *mux.Router, sessions auth.Store) {
+	r.HandleFunc("/api/connector", audit.WrapWrites(audit.ActionConnectorUpdated, auth.Require(sessions, rbac.PermSetupRead, func(w http.ResponseWriter, r *http.Request) {
+		pool, release, err := database.Default.Acquire()
+		if err != nil {
+			apierror.Write(w, r, apierror.Wrap(apierror.Unavailable, err))
+			return
+		}
+		defer release()
+
+		switch r.Method {
+		case "GET":
+			cfg, err := connector.Active(pool.SQL, pool.Dialect)
+			if err != nil {
+				apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
+				return
+			}
+			jsonResponse(w, map[string]interface{}{
+				"success":      true,
+				"enabled":      cfg != nil,
+				"connector":    cfg,
+				"hash_formats": passhash.Formats(),
+			}, http.StatusOK)
+
+		case "PUT", "DELETE":
+			if !rbac.Can(r.Context(), rbac.PermSetupWrite) {
+				apierror.Write(w, r, apierror.New(apierror.PermissionDenied).With("permission", rbac.PermSetupWrite))
+				return
+			}
+
+			if r.Method == "DELETE" {
+				audit.SetTarget(r.Context(), "connector:disabled")
+				if err := connector.Disable(pool.SQL); err != nil {
+					apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
+					return
+				}
+				jsonResponse(w, map[string]interface{}{
+					"success": true,
+					"message": i18n.Text(r.Context(), "msg.connector_disabled"),
+				}, http.StatusOK)
+				return
+			}
+
+			var cfg connector.Config
+			if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+				apierror.Write(w, r, apierror.BadJSON(err))
+				return
+			}
+			audit.SetTarget(r.Context(), "connector:"+cfg.Table)
+
+			// No se guarda un mapeo que apunte a columnas inexistentes
+			if err := connector.Check(pool.SQL, pool.Dialect, &cfg); err != nil {
+				connectorError(w, r, err)
+				return
+			}
+			if err := connector.Save(pool.SQL, pool.Dialect, &cfg); err != nil {
+				connectorError(w, r, err)
+				return
+			}
+			jsonResponse(w, map[string]interface{}{
+				"success":   true,
+				"message":   i18n.Text(r.Context(), "msg.connector_saved"),
+				"connector": cfg,
+			}, http.StatusOK)
+		}
+	}))).Methods("GET", "PUT", "DELETE")
+
+	// Prueba un mapeo sin guardarlo; con "email" también busca la cuenta
+	r.HandleFunc("/api/connector/test", auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			connector.Config
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			apierror.Write(w, r, apierror.BadJSON(err))
+			return
+		}
+
+		pool, release, err := database.Default.Acquire()
+		if err != nil {
+			apierror.Write(w, r, apierror.Wrap(apierror.Unavailable, err))
+			return
+		}
+		defer release()
+
+		if err := connector.Check(pool.SQL, pool.Dialect, &request.Config); err != nil {
+			connectorError(w, r, err)
+			return
+		}
+		response := map[string]interface{}{
+			"success": true,
+			"message": i18n.Text(r.Context(), "msg.connector_checked"),
+		}
+		if request.Email != "" {
+			found, err := connector.Exists(pool.SQL, pool.Dialect, &request.Config, request.Email)
+			if err != nil {
+				connectorError(w, r, err)
+				return
+			}
+			response["account_found"] = found
+		}
+		jsonResponse(w, response, http.StatusOK)
+	})).Methods("POST")
+}
+
+// connectorError responde los errores de la revisión del mapeo; los
+// errores de la base quedan solo en el log
+func connectorError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, connector.ErrSchema):
+		apierror.Write(w, r, apierror.Wrap(apierror.ConnectorSchema, err))
+	case errors.Is(err, connector.ErrAmbiguous):
+		apierror.Write(w, r, apierror.New(apierror.ConnectorAmbiguous))
+	default:
+		apierror.Write(w, r, err)
+	}
+}
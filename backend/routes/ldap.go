@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/auth"
+	"password-recovery/database"
+	"password-recovery/fielderr"
+	"password-recovery/i18n"
+	"password-recovery/ldapdir"
+	"password-recovery/rbac"
+)
+
+// registerLDAPRoutes agrega al asistente la configuración del directorio
+// LDAP / Active Directory como destino de las contraseñas
+func registerLDAPRoutes(r *mux.Router, sessions auth.Store) {
+	r.HandleFunc("/api/ldap", audit.WrapWrites(audit.ActionLDAPUpdated, auth.Require(sessions, rbac.PermSetupRead, func(w http.ResponseWriter, r *http.Request) {
+		pool, release, err := database.Default.Acquire()
+		if err != nil {
+			apierror.Write(w, r, apierror.Wrap(apierror.Unavailable, err))
+			return
+		}
+		defer release()
+
+		switch r.Method {
+		case "GET":
+			cfg, err := ldapdir.Active(pool.SQL, pool.Dialect)
+			if err != nil {
+				apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
+				return
+			}
+			response := map[string]interface{}{
+				"success":        true,
+				"enabled":        cfg != nil,
+				"ldap":           nil,
+				"modes":          []string{ldapdir.ModeOpenLDAP, ldapdir.ModeActiveDirectory},
+				"default_filter": ldapdir.DefaultFilter,
+			}
+			if cfg != nil {
+				response["ldap"] = cfg.Public()
+			}
+			jsonResponse(w, response, http.StatusOK)
+
+		case "PUT", "DELETE":
+			if !rbac.Can(r.Context(), rbac.PermSetupWrite) {
+				apierror.Write(w, r, apierror.New(apierror.PermissionDenied).With("permission", rbac.PermSetupWrite))
+				return
+			}
+
+			if r.Method == "DELETE" {
+				audit.SetTarget(r.Context(), "ldap:disabled")
+				if err := ldapdir.Disable(pool.SQL); err != nil {
+					apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
+					return
+				}
+				jsonResponse(w, map[string]interface{}{
+					"success": true,
+					"message": i18n.Text(r.Context(), "msg.ldap_disabled"),
+				}, http.StatusOK)
+				return
+			}
+
+			var cfg ldapdir.Config
+			if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+				apierror.Write(w, r, apierror.BadJSON(err))
+				return
+			}
+			audit.SetTarget(r.Context(), "ldap:"+cfg.URL)
+
+			// No se guarda una configuración con la que no se pueda conectar
+			if err := checkLDAP(pool, &cfg); err != nil {
+				ldapError(w, r, err)
+				return
+			}
+			if err := ldapdir.Save(pool.SQL, pool.Dialect, &cfg); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+			jsonResponse(w, map[string]interface{}{
+				"success": true,
+				"message": i18n.Text(r.Context(), "msg.ldap_saved"),
+				"ldap":    cfg.Public(),
+			}, http.StatusOK)
+		}
+	}))).Methods("GET", "PUT", "DELETE")
+
+	// Prueba la conexión sin guardarla; con "email" también busca la cuenta
+	r.HandleFunc("/api/ldap/test", auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ldapdir.Config
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			apierror.Write(w, r, apierror.BadJSON(err))
+			return
+		}
+
+		pool, release, err := database.Default.Acquire()
+		if err != nil {
+			apierror.Write(w, r, apierror.Wrap(apierror.Unavailable, err))
+			return
+		}
+		defer release()
+
+		if err := checkLDAP(pool, &request.Config); err != nil {
+			ldapError(w, r, err)
+			return
+		}
+		response := map[string]interface{}{
+			"success": true,
+			"message": i18n.Text(r.Context(), "msg.ldap_checked"),
+		}
+		if request.Email != "" {
+			dn, err := request.Config.Lookup(request.Email)
+			switch {
+			case errors.Is(err, ldapdir.ErrNotFound):
+				response["account_found"] = false
+			case err != nil:
+				ldapError(w, r, err)
+				return
+			default:
+				response["account_found"] = true
+				response["dn"] = dn
+			}
+		}
+		jsonResponse(w, response, http.StatusOK)
+	})).Methods("POST")
+}
+
+// checkLDAP completa y valida la configuración recibida y prueba la
+// conexión. Una contraseña vacía o enmascarada conserva la guardada.
+func checkLDAP(pool *database.Pool, cfg *ldapdir.Config) error {
+	cfg.Normalize()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := ldapdir.ResolvePassword(pool.SQL, pool.Dialect, cfg); err != nil {
+		return err
+	}
+	return cfg.Check()
+}
+
+// ldapError responde los errores de la revisión del directorio; el
+// detalle de un fallo de conexión o de bind queda solo en el log
+func ldapError(w http.ResponseWriter, r *http.Request, err error) {
+	var fields *fielderr.Error
+	switch {
+	case errors.As(err, &fields):
+		apierror.Write(w, r, err)
+	case errors.Is(err, ldapdir.ErrInsecure):
+		apierror.Write(w, r, apierror.New(apierror.LDAPInsecure))
+	case errors.Is(err, ldapdir.ErrAmbiguous):
+		apierror.Write(w, r, apierror.New(apierror.LDAPAmbiguous))
+	default:
+		apierror.Write(w, r, apierror.Wrap(apierror.LDAPCheckFailed, err))
+	}
+}
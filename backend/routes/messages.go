@@ -0,0 +1,20 @@
+package routes
+
+import "password-recovery/i18n"
+
+// Textos de las respuestas del servidor de setup. Los de error están en el
+// catálogo de apierror.
+func init() {
+	i18n.Define("msg.setup_db_saved", "Configuración guardada y conexión verificada", "Configuration saved and connection verified")
+	i18n.Define("msg.setup_tables_created", "Tablas creadas correctamente", "Tables created successfully")
+	i18n.Define("msg.setup_admin_created", "Usuario administrador creado correctamente", "Admin user created successfully")
+	i18n.Define("msg.setup_admin_exists", "El usuario administrador ya existe; el setup está completo", "Admin user already exists, setup completed")
+	i18n.Define("msg.setup_reset", "Configuración completamente reseteada", "Configuration fully reset")
+	i18n.Define("msg.setup_db_updated", "Configuración actualizada", "Configuration updated")
+	i18n.Define("msg.connector_disabled", "Modo conector desactivado", "Connector mode disabled")
+	i18n.Define("msg.connector_saved", "Modo conector configurado", "Connector mode configured")
+	i18n.Define("msg.connector_checked", "La tabla y las columnas existen", "The table and columns exist")
+	i18n.Define("msg.ldap_disabled", "Destino LDAP desactivado", "LDAP target disabled")
+	i18n.Define("msg.ldap_saved", "Destino LDAP configurado", "LDAP target configured")
+	i18n.Define("msg.ldap_checked", "Conexión y autenticación correctas", "Connection and authentication succeeded")
+}
@@ -1,94 +1,144 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 	"os"
+	"password-recovery/apierror"
+	"password-recovery/audit"
+	"password-recovery/auth"
 	"password-recovery/config"
+	"password-recovery/cors"
+	"password-recovery/database"
+	"password-recovery/health"
+	"password-recovery/httpserver"
+	"password-recovery/i18n"
+	"password-recovery/logging"
+	"password-recovery/metrics"
+	"password-recovery/openapi"
+	"password-recovery/rbac"
+	"password-recovery/tenant"
+	"password-recovery/webapp"
+	"password-recovery/webhook"
 )
 
-func enableCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Type, Authorization, X-Requested-With")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 func jsonResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
 
-func SetupRouter() http.Handler {
+// SetupRouter arma el router del servidor de setup con las reglas de CORS
+// del grupo "setup"; web es el frontend embebido
+func SetupRouter(corsRules *cors.Rules, web *webapp.App) http.Handler {
 	r := mux.NewRouter()
-	r.Use(enableCORS)
+	r.Use(logging.Mux)
+	r.Use(httpserver.Mux)
+	r.Use(i18n.Mux)
+	r.Use(corsRules.Mux)
+	r.Use(metrics.Mux)
+
+	// Validación contra la especificación OpenAPI (ver openapi/spec.json)
+	spec := openapi.Default()
+	r.Use(openapi.Mux(spec, openapi.MaxBodyBytesFromEnv()))
+	r.HandleFunc("/openapi.json", spec.JSONHandler()).Methods("GET")
+	r.HandleFunc("/docs", openapi.DocsHandler()).Methods("GET")
+
+	// Frontend embebido: /config.js y, en NotFoundHandler, los archivos
+	// del build e index.html para las rutas del SPA
+	r.HandleFunc("/config.js", web.ConfigHandler()).Methods("GET")
+
+	// Catálogo de códigos de error y errores de ruta con el mismo formato
+	// que el servidor principal
+	r.HandleFunc("/errors", apierror.CatalogHandler()).Methods("GET")
+	r.NotFoundHandler = httpserver.Mux(corsRules.Mux(i18n.Mux(web.Handler(http.HandlerFunc(apierror.NotFound)))))
+	r.MethodNotAllowedHandler = httpserver.Mux(corsRules.Mux(i18n.Mux(http.HandlerFunc(apierror.NotAllowed))))
+
+	// Las sesiones de setup viven en memoria: los operadores no están en la
+	// base de datos y un reinicio obliga a volver a iniciar sesión
+	sessions := operatorSessions{auth.NewMemoryStore()}
 
 	// Actualizar estado inicial
 	config.RefreshConfigState()
 
-	// Cargar configuración inicial
-	// Intenta cargar la configuración
-	// Cargar configuración con verificación de conexión
-	if err := loadAndVerifyConfig(); err != nil {
-		fmt.Printf("⚠️ Advertencia: %v\n", err)
-	} else {
-		fmt.Println("✅ Configuración de DB cargada y verificada exitosamente")
-	}
-
 	// Inicializar estado de configuración
-	config.CurrentSetupStatus = config.SetupStatus{
+	config.SetStatus(config.SetupStatus{
 		DBConfigured:    config.ConfigExists(),
 		DBTablesCreated: false,
 		AdminCreated:    false,
+	})
+
+	// Verificaciones en segundo plano: la configuración se recarga y se
+	// prueba en cada ciclo, y /api/status solo lee el resultado cacheado
+	checker := health.New(health.IntervalFromEnv())
+	checker.Register(checkSetupConfig, true, func(ctx context.Context) error {
+		return loadAndVerifyConfig()
+	})
+	checker.RegisterDefaults(database.Default)
+	checker.Start(context.Background())
+
+	// Cargar configuración con verificación de conexión
+	if result, _ := checker.Result(checkSetupConfig); !result.OK {
+		slog.Warn("Configuración de DB no disponible", "error", result.Error)
+	} else {
+		slog.Info("Configuración de DB cargada y verificada")
 	}
 
+	// Después de cambiar la configuración se refresca el cache sin esperar
+	// al siguiente ciclo
+	refreshHealth := func() { go checker.RunNow() }
+
+	r.HandleFunc("/healthz", checker.LivenessHandler()).Methods("GET")
+	r.HandleFunc("/readyz", checker.ReadinessHandler()).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Etapas del setup como gauges
+	metrics.RegisterSetupStages(func() map[string]bool {
+		status := config.Status()
+		return map[string]bool{
+			"db_configured":  status.DBConfigured,
+			"tables_created": status.DBTablesCreated,
+			"admin_created":  status.AdminCreated,
+		}
+	})
+
 	// Estado del sistema - ACTUALIZADO
-	// Endpoint para estado
-	r.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
-		// Actualizar estado verificando conexión REAL
-		loadAndVerifyConfig()
+	// Endpoint para estado (cacheado por el health checker)
+	r.HandleFunc("/api/status", auth.Optional(sessions, func(w http.ResponseWriter, r *http.Request) {
+		snap := checker.Snapshot()
 
 		setupComplete := config.IsSetupComplete()
-		jsonResponse(w, map[string]interface{}{
-			"setup": setupComplete,
-			"user":  config.SetupUser,
+		status := config.Status()
+		response := map[string]interface{}{
+			"setup":      setupComplete,
+			"user":       config.SetupUser,
+			"checked_at": snap.CheckedAt,
+			"health":     snap.Statuses(),
 			"setup_stages": map[string]bool{
-				"db_configured":     config.CurrentSetupStatus.DBConfigured,
-				"tables_created":    config.CurrentSetupStatus.DBTablesCreated,
-				"admin_created":     config.CurrentSetupStatus.AdminCreated,
+				"db_configured":     status.DBConfigured,
+				"tables_created":    status.DBTablesCreated,
+				"admin_created":     status.AdminCreated,
 				"allow_reconfigure": true,
 			},
-			// Información actual de conexión
-			"db_info": map[string]string{
-				"host":   config.GetCurrentConfig().Host,
-				"port":   config.GetCurrentConfig().Port,
-				"dbname": config.GetCurrentConfig().DBName,
-				"user":   config.GetCurrentConfig().User,
-			},
-		}, http.StatusOK)
-	}).Methods("GET", "OPTIONS")
+		}
+		// Información actual de conexión y detalle de las verificaciones, solo
+		// para operadores autenticados
+		if rbac.Can(r.Context(), rbac.PermSetupRead) {
+			response["db_info"] = config.GetDBConfig()
+			response["health"] = snap.Checks
+		}
+		jsonResponse(w, response, http.StatusOK)
+	})).Methods("GET")
 
 	// Login setup
-	r.HandleFunc("/api/login-setup", func(w http.ResponseWriter, r *http.Request) {
-		if config.ConfigExists() && !config.CurrentSetupStatus.DBConfigured {
-			jsonResponse(w, map[string]interface{}{
-				"error": "El sistema ya tiene configuración de DB",
-			}, http.StatusForbidden)
+	r.HandleFunc("/api/login-setup", audit.Wrap(audit.ActionSetupLogin, func(w http.ResponseWriter, r *http.Request) {
+		if config.ConfigExists() && !config.Status().DBConfigured {
+			apierror.Write(w, r, apierror.New(apierror.SetupLocked))
 			return
 		}
 
@@ -98,147 +148,124 @@ func SetupRouter() http.Handler {
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"error": "Formato JSON inválido",
-			}, http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadJSON(err))
 			return
 		}
 
-		if creds.User == config.SetupUser && creds.Pass == config.SetupPassword {
+		audit.SetActor(r.Context(), creds.User)
+		audit.SetTarget(r.Context(), "setup")
+		if op, err := config.AuthenticateSetup(creds.User, creds.Pass); err == nil {
+			principal := rbac.Principal{Subject: op.User, Role: operatorRole(op.Role)}
+			token, expires, err := sessions.Create(principal, auth.TTLFromEnv())
+			if err != nil {
+				apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
+				return
+			}
 			jsonResponse(w, map[string]interface{}{
-				"status": "success",
+				"status":      "success",
+				"role":        op.Role,
+				"token":       token,
+				"expires_at":  expires,
+				"permissions": rbac.Permissions(principal.Role),
 			}, http.StatusOK)
 			return
 		}
 
-		jsonResponse(w, map[string]interface{}{
-			"error": "Credenciales inválidas",
-		}, http.StatusUnauthorized)
-	}).Methods("POST", "OPTIONS")
+		apierror.Write(w, r, apierror.New(apierror.InvalidCredentials))
+	})).Methods("POST")
+
+	// Cerrar la sesión de setup
+	r.HandleFunc("/api/logout-setup", func(w http.ResponseWriter, r *http.Request) {
+		if token := auth.BearerToken(r); token != "" {
+			sessions.Revoke(token)
+		}
+		jsonResponse(w, map[string]interface{}{"status": "success"}, http.StatusOK)
+	}).Methods("POST")
 
 	// Configuración DB
-	r.HandleFunc("/api/setup-db", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("📡 /api/setup-db llamado desde:", r.RemoteAddr)
+	r.HandleFunc("/api/setup-db", audit.Wrap(audit.ActionSetupDB, auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context())
 
 		// Verificar si ya está configurado (a menos que permitamos reconfiguración)
-		if config.ConfigExists() && !config.CurrentSetupStatus.DBConfigured {
-			jsonResponse(w, map[string]interface{}{
-				"error": "El sistema ya tiene configuración de DB",
-			}, http.StatusForbidden)
+		if config.ConfigExists() && !config.Status().DBConfigured {
+			apierror.Write(w, r, apierror.New(apierror.SetupLocked))
 			return
 		}
 
 		// Decodificar el JSON recibido
 		var cfg config.DBConfig
 		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"error": "Error en formato de configuración: " + err.Error(),
-			}, http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadJSON(err))
 			return
 		}
+		audit.SetTarget(r.Context(), auditTarget(cfg))
 
-		// Validar campos obligatorios
-		if cfg.Host == "" || cfg.User == "" || cfg.DBName == "" || cfg.Port == "" {
-			jsonResponse(w, map[string]interface{}{
-				"error": "Faltan campos obligatorios: host, user, dbname o port",
-			}, http.StatusBadRequest)
+		// Validar campos obligatorios y opciones de conexión
+		if err := cfg.Validate(); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
 		// Probar conexión ANTES de guardar
 		testResult, err := cfg.TestConnection()
 		if err != nil {
-			fmt.Printf("❌ Error probando conexión: %v\n", err)
-			jsonResponse(w, map[string]interface{}{
-				"error": "Error probando conexión: " + err.Error(),
-				"details": map[string]interface{}{
-					"host":   cfg.Host,
-					"port":   cfg.Port,
-					"dbname": cfg.DBName,
-					"user":   cfg.User,
-				},
-			}, http.StatusBadRequest)
+			apierror.Write(w, r, apierror.Wrap(apierror.DBConnectionFailed, err))
 			return
 		}
 
-		fmt.Printf("✅ Conexión probada exitosamente: %+v\n", testResult)
+		logger.Info("Conexión probada", "db_type", testResult["db_type"], "version", testResult["version"])
 
-		// Guardar configuración
-		if err := config.SaveConfig(cfg); err != nil {
-			fmt.Printf("❌ Error guardando configuración: %v\n", err)
-			jsonResponse(w, map[string]interface{}{
-				"error": "Error guardando configuración: " + err.Error(),
-			}, http.StatusInternalServerError)
+		// Guardar configuración y cambiar el pool compartido
+		if err := config.UpdateDBConfig(cfg); err != nil {
+			apierror.Write(w, r, apierror.Wrap(apierror.ConfigSaveFailed, err))
 			return
 		}
 
-		// Actualizar estado
-		config.CurrentSetupStatus.DBConfigured = true
-
-		fmt.Println("🔧 Configuración guardada exitosamente")
+		logger.Info("Configuración de DB guardada", "db_type", cfg.Dialect().Name())
+		refreshHealth()
+		publishDBChanged(cfg)
 
 		// Responder con éxito
 		jsonResponse(w, map[string]interface{}{
 			"status":          "success",
-			"message":         "Configuración guardada y conexión verificada",
+			"message":         i18n.Text(r.Context(), "msg.setup_db_saved"),
 			"setup":           true,
 			"connection_test": testResult,
-			"config": map[string]interface{}{
-				"host":   cfg.Host,
-				"port":   cfg.Port,
-				"dbname": cfg.DBName,
-				"user":   cfg.User,
-			},
+			"config":          cfg.Public(),
 		}, http.StatusOK)
-	}).Methods("POST", "OPTIONS")
+	}))).Methods("POST")
 
 	// Endpoint para crear tablas
-	r.HandleFunc("/api/setup/create-tables", func(w http.ResponseWriter, r *http.Request) {
-		if !config.CurrentSetupStatus.DBConfigured {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   "Database connection not configured",
-			}, http.StatusBadRequest)
+	r.HandleFunc("/api/setup/create-tables", audit.Wrap(audit.ActionSetupTables, auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
+		if !config.Status().DBConfigured {
+			apierror.Write(w, r, apierror.New(apierror.DBNotConfigured))
 			return
 		}
 
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-			config.GetCurrentConfig().Host,
-			config.GetCurrentConfig().User,
-			config.GetCurrentConfig().Password,
-			config.GetCurrentConfig().DBName,
-			config.GetCurrentConfig().Port)
-
-		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		pool, release, err := database.Default.Acquire()
 		if err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.Unavailable, err))
 			return
 		}
+		defer release()
+		db := pool.Gorm
 
 		if err := config.InitializeDB(db); err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
 			return
 		}
 
+		refreshHealth()
 		jsonResponse(w, map[string]interface{}{
 			"success": true,
-			"message": "Tables created successfully",
+			"message": i18n.Text(r.Context(), "msg.setup_tables_created"),
 		}, http.StatusOK)
-	}).Methods("POST", "OPTIONS")
+	}))).Methods("POST")
 
 	// Endpoint para crear admin
-	r.HandleFunc("/api/setup/create-admin", func(w http.ResponseWriter, r *http.Request) {
-		if !config.CurrentSetupStatus.DBTablesCreated {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   "Database tables not created",
-			}, http.StatusBadRequest)
+	r.HandleFunc("/api/setup/create-admin", audit.Wrap(audit.ActionSetupAdmin, auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
+		if !config.Status().DBTablesCreated {
+			apierror.Write(w, r, apierror.New(apierror.DBTablesMissing))
 			return
 		}
 
@@ -248,85 +275,67 @@ func SetupRouter() http.Handler {
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   "Invalid request body",
-			}, http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadJSON(err))
 			return
 		}
 
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-			config.GetCurrentConfig().Host,
-			config.GetCurrentConfig().User,
-			config.GetCurrentConfig().Password,
-			config.GetCurrentConfig().DBName,
-			config.GetCurrentConfig().Port)
-
-		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		pool, release, err := database.Default.Acquire()
 		if err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.Unavailable, err))
 			return
 		}
+		defer release()
+		db := pool.Gorm
 
+		audit.SetTarget(r.Context(), request.Email)
 		created, err := config.CreateAdminUser(db, request.Email, request.Password)
 		if err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.Internal, err))
 			return
 		}
 
 		response := map[string]interface{}{
 			"success": true,
-			"message": "Admin user created successfully",
+			"message": i18n.Text(r.Context(), "msg.setup_admin_created"),
 		}
 
 		if !created {
-			response["message"] = "Admin user already exists, setup completed"
+			response["message"] = i18n.Text(r.Context(), "msg.setup_admin_exists")
 			response["already_exists"] = true
 		}
 
 		jsonResponse(w, response, http.StatusOK)
-	}).Methods("POST", "OPTIONS")
+	}))).Methods("POST")
 
 	// Nuevo endpoint para resetear configuración
 	// routes/router.go
-	r.HandleFunc("/api/setup/reset", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("🔄 Solicitud de reset recibida")
+	r.HandleFunc("/api/setup/reset", audit.Wrap(audit.ActionSetupReset, auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context())
+		logger.Info("Solicitud de reset de configuración")
 
 		if err := config.ResetConfig(); err != nil {
-			fmt.Printf("❌ Error en ResetConfig: %v\n", err)
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.ConfigResetFailed, err))
 			return
 		}
 
 		// Verificar eliminación usando el nombre del archivo directamente
 		if _, err := os.Stat("dbconfig.json"); !os.IsNotExist(err) {
-			fmt.Println("⚠️ Advertencia: dbconfig.json todavía existe")
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   "El archivo de configuración no pudo ser eliminado",
-			}, http.StatusInternalServerError)
+			logger.Warn("dbconfig.json todavía existe")
+			apierror.Write(w, r, apierror.New(apierror.ConfigResetFailed))
 			return
 		}
 
 		config.RefreshConfigState()
+		refreshHealth()
 
 		jsonResponse(w, map[string]interface{}{
 			"success": true,
-			"message": "Configuración completamente reseteada",
+			"message": i18n.Text(r.Context(), "msg.setup_reset"),
 		}, http.StatusOK)
-	}).Methods("POST")
+	}))).Methods("POST")
 
 	//endpoint temporar para verificar rutas y permisos
-	r.HandleFunc("/api/debug/config-path", func(w http.ResponseWriter, r *http.Request) {
+	r.HandleFunc("/api/debug/config-path", auth.Require(sessions, rbac.PermSetupRead, func(w http.ResponseWriter, r *http.Request) {
 		path := config.GetConfigPath()
 		info := make(map[string]interface{})
 
@@ -352,25 +361,24 @@ func SetupRouter() http.Handler {
 		}
 
 		jsonResponse(w, info, http.StatusOK)
-	}).Methods("GET")
+	})).Methods("GET")
 
 	// Endpoint para probar configuración temporal
-	r.HandleFunc("/api/db/test-config", func(w http.ResponseWriter, r *http.Request) {
+	r.HandleFunc("/api/db/test-config", auth.Require(sessions, rbac.PermSetupWrite, func(w http.ResponseWriter, r *http.Request) {
 		var testConfig config.DBConfig
 		if err := json.NewDecoder(r.Body).Decode(&testConfig); err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   "Formato de configuración inválido",
-			}, http.StatusBadRequest)
+			apierror.Write(w, r, apierror.BadJSON(err))
+			return
+		}
+
+		if err := testConfig.Validate(); err != nil {
+			apierror.Write(w, r, err)
 			return
 		}
 
 		result, err := testConfig.TestConnection()
 		if err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.DBConnectionFailed, err))
 			return
 		}
 
@@ -378,17 +386,14 @@ func SetupRouter() http.Handler {
 			"success": true,
 			"result":  result,
 		}, http.StatusOK)
-	}).Methods("POST", "OPTIONS")
+	})).Methods("POST")
 
 	// Endpoint para probar conexión DB
-	r.HandleFunc("/api/db/test", func(w http.ResponseWriter, r *http.Request) {
+	r.HandleFunc("/api/db/test", auth.Require(sessions, rbac.PermSetupRead, func(w http.ResponseWriter, r *http.Request) {
 		currentConfig := config.GetCurrentConfig()
 		result, err := currentConfig.TestConnection()
 		if err != nil {
-			jsonResponse(w, map[string]interface{}{
-				"success": false,
-				"error":   err.Error(),
-			}, http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.Wrap(apierror.DBConnectionFailed, err))
 			return
 		}
 
@@ -400,66 +405,151 @@ func SetupRouter() http.Handler {
 			"success": true,
 			"result":  result,
 		}, http.StatusOK)
-	}).Methods("POST", "OPTIONS")
+	})).Methods("POST")
 
-	// Endpoint para obtener/configurar DB
-	r.HandleFunc("/api/db/config", func(w http.ResponseWriter, r *http.Request) {
+	// Estadísticas del pool de conexiones compartido
+	r.HandleFunc("/api/db/pool", auth.Require(sessions, rbac.PermSetupRead, func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, map[string]interface{}{
+			"success": true,
+			"pool":    database.Default.Stats(),
+		}, http.StatusOK)
+	})).Methods("GET")
+
+	// Destinos externos de las contraseñas: modo conector y LDAP
+	registerConnectorRoutes(r, sessions)
+	registerLDAPRoutes(r, sessions)
+
+	// Endpoint para obtener/configurar DB; la lectura también se audita
+	// porque muestra a dónde se conecta el servicio
+	dbConfigHandler := auth.Require(sessions, rbac.PermSetupRead, func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
+			audit.SetTarget(r.Context(), auditTarget(config.GetCurrentConfig()))
 			jsonResponse(w, map[string]interface{}{
 				"success": true,
 				"config":  config.GetDBConfig(),
 			}, http.StatusOK)
 
 		case "PUT":
+			if !rbac.Can(r.Context(), rbac.PermSetupWrite) {
+				apierror.Write(w, r, apierror.New(apierror.PermissionDenied).With("permission", rbac.PermSetupWrite))
+				return
+			}
+
 			var newConfig config.DBConfig
 			if err := json.NewDecoder(r.Body).Decode(&newConfig); err != nil {
-				jsonResponse(w, map[string]interface{}{
-					"success": false,
-					"error":   "Formato de configuración inválido",
-				}, http.StatusBadRequest)
+				apierror.Write(w, r, apierror.BadJSON(err))
+				return
+			}
+			audit.SetTarget(r.Context(), auditTarget(newConfig))
+
+			if err := newConfig.Validate(); err != nil {
+				apierror.Write(w, r, err)
 				return
 			}
 
 			if err := config.UpdateDBConfig(newConfig); err != nil {
-				jsonResponse(w, map[string]interface{}{
-					"success": false,
-					"error":   err.Error(),
-				}, http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.Wrap(apierror.ConfigSaveFailed, err))
 				return
 			}
 
 			// Actualizar estado
-			config.CurrentSetupStatus.DBConfigured = true
+			config.SetDBConfigured(true)
+			refreshHealth()
+			publishDBChanged(newConfig)
 
 			jsonResponse(w, map[string]interface{}{
 				"success": true,
-				"message": "Configuración actualizada",
+				"message": i18n.Text(r.Context(), "msg.setup_db_updated"),
 				"config":  config.GetDBConfig(),
 			}, http.StatusOK)
 
 		default:
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			apierror.Write(w, r, apierror.New(apierror.MethodNotAllowed))
 		}
-	}).Methods("GET", "PUT", "OPTIONS")
+	})
+	r.HandleFunc("/api/db/config", audit.Wrap(audit.ActionDBConfigRead, dbConfigHandler)).Methods("GET")
+	r.HandleFunc("/api/db/config", audit.Wrap(audit.ActionDBConfigSaved, dbConfigHandler)).Methods("PUT")
 
+	if missing := spec.Undocumented(openapi.MuxRoutes(r)); len(missing) > 0 {
+		slog.Warn("Rutas sin documentar en openapi/spec.json", "routes", missing)
+	}
 	return r
 }
+// operatorSessions cierra las sesiones de un operador que se deshabilitó,
+// se borró o cambió de rol después de iniciar sesión
+type operatorSessions struct {
+	*auth.MemoryStore
+}
+
+func (s operatorSessions) Lookup(token string) (rbac.Principal, error) {
+	p, err := s.MemoryStore.Lookup(token)
+	if err != nil {
+		return p, err
+	}
+	if op, ok := config.SetupOperator(p.Subject); !ok || operatorRole(op.Role) != p.Role {
+		s.RevokeSubject(p.Subject)
+		return rbac.Principal{}, auth.ErrInvalidToken
+	}
+	return p, nil
+}
+
+// operatorRole traduce el rol de un operador de setup a un rol de rbac
+func operatorRole(role string) string {
+	if role == config.OperatorRoleAdmin {
+		return rbac.RoleAdmin
+	}
+	return rbac.RoleOperator
+}
+
+// auditTarget describe una configuración de DB sin credenciales
+func auditTarget(cfg config.DBConfig) string {
+	if cfg.Dialect().Name() == database.SQLite {
+		return database.SQLite + ":" + cfg.Path
+	}
+	return cfg.Dialect().Name() + ":" + cfg.Host + "/" + cfg.DBName
+}
+
+// publishDBChanged avisa a los webhooks del tenant por defecto de la base
+// nueva (si ya tiene tablas); el proceso principal los entrega en su
+// siguiente ciclo
+func publishDBChanged(cfg config.DBConfig) {
+	if !config.Status().DBTablesCreated {
+		return
+	}
+	webhook.Publish(tenant.DefaultID, webhook.EventDBChanged, map[string]interface{}{
+		"db_type": cfg.Dialect().Name(),
+		"target":  auditTarget(cfg),
+	})
+}
+
+// checkSetupConfig es la verificación que recarga dbconfig.json
+const checkSetupConfig = "config"
+
 func loadAndVerifyConfig() error {
 	// Cargar configuración desde archivo
 	cfg, err := config.LoadDBConfig()
 	if err != nil {
-		config.CurrentSetupStatus.DBConfigured = false
+		config.SetDBConfigured(false)
 		return err
 	}
 
-	// Probar conexión real
-	_, err = cfg.TestConnection()
+	// Conectar el pool compartido (solo si la configuración cambió) y probarlo
+	if err = config.Connect(cfg); err == nil {
+		_, err = cfg.TestConnection()
+	}
 	if err != nil {
-		config.CurrentSetupStatus.DBConfigured = false
+		config.SetDBConfigured(false)
 		return fmt.Errorf("error verificando conexión: %v", err)
 	}
 
-	config.CurrentSetupStatus.DBConfigured = true
+	config.SetDBConfigured(true)
+
+	// Si el esquema ya está al día, las tablas se dan por creadas
+	if pool := database.Default.Current(); pool != nil {
+		if pending, err := database.PendingMigrations(pool.SQL); err == nil && pending == 0 {
+			config.SetTablesCreated(true)
+		}
+	}
 	return nil
 }
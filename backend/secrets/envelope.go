@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Formato del archivo cifrado:
+//
+//	"RPE1" | len(keyID) (1 byte) | keyID | nonce (12 bytes) | ciphertext
+//
+// La cabecera completa se autentica como datos adicionales de GCM. Los
+// archivos sin cabecera (formato anterior: nonce | ciphertext) se siguen
+// leyendo para no romper instalaciones existentes.
+var magic = []byte("RPE1")
+
+const nonceSize = 12
+
+// Keyring agrupa las llaves que pueden descifrar un artefacto. La primera
+// es la llave primaria y es la que se usa para cifrar.
+type Keyring []*MasterKey
+
+// Primary devuelve la llave con la que se cifra
+func (k Keyring) Primary() *MasterKey {
+	if len(k) == 0 {
+		return nil
+	}
+	return k[0]
+}
+
+func (k Keyring) find(id string) *MasterKey {
+	for _, key := range k {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// Encrypt cifra con AES-256-GCM y antepone la cabecera con el ID de la llave
+func Encrypt(plaintext []byte, key *MasterKey) ([]byte, error) {
+	if key == nil {
+		return nil, ErrNoMasterKey
+	}
+	if len(key.ID) > 255 {
+		return nil, errors.New("ID de llave demasiado largo")
+	}
+
+	aesgcm, err := newGCM(key.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(magic)+1+len(key.ID))
+	header = append(header, magic...)
+	header = append(header, byte(len(key.ID)))
+	header = append(header, key.ID...)
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(header, nonce...)
+	return aesgcm.Seal(out, nonce, plaintext, header), nil
+}
+
+// Decrypt descifra datos producidos por Encrypt o en el formato anterior
+func Decrypt(data []byte, keys Keyring) ([]byte, error) {
+	keyID, header, body, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if header == nil {
+		// Formato anterior: se prueba con cada llave del keyring
+		for _, key := range keys {
+			if plaintext, err := open(key.Key, body, nil); err == nil {
+				return plaintext, nil
+			}
+		}
+		return nil, errors.New("no se pudo descifrar: ninguna llave coincide")
+	}
+
+	key := keys.find(keyID)
+	if key == nil {
+		return nil, fmt.Errorf("llave %s no disponible para descifrar", keyID)
+	}
+	return open(key.Key, body, header)
+}
+
+// KeyIDOf devuelve el ID de llave de la cabecera ("" para el formato anterior)
+func KeyIDOf(data []byte) (string, error) {
+	keyID, _, _, err := parseHeader(data)
+	return keyID, err
+}
+
+// EncryptFile cifra y escribe un archivo con permisos 0600
+func EncryptFile(path string, plaintext []byte, key *MasterKey) error {
+	data, err := Encrypt(plaintext, key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// DecryptFile lee y descifra un archivo
+func DecryptFile(path string, keys Keyring) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data, keys)
+}
+
+func parseHeader(data []byte) (keyID string, header, body []byte, err error) {
+	if !bytes.HasPrefix(data, magic) {
+		if len(data) < nonceSize {
+			return "", nil, nil, errors.New("datos cifrados incompletos")
+		}
+		return "", nil, data, nil
+	}
+
+	if len(data) < len(magic)+1 {
+		return "", nil, nil, errors.New("cabecera cifrada incompleta")
+	}
+	idLen := int(data[len(magic)])
+	headerLen := len(magic) + 1 + idLen
+	if len(data) < headerLen+nonceSize {
+		return "", nil, nil, errors.New("datos cifrados incompletos")
+	}
+	return string(data[len(magic)+1 : headerLen]), data[:headerLen], data[headerLen:], nil
+}
+
+func open(key, body, header []byte) ([]byte, error) {
+	aesgcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aesgcm.Open(nil, body[:nonceSize], body[nonceSize:], header)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(t *testing.T) *MasterKey {
+	t.Helper()
+	raw, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewMasterKey(raw, SourceEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEnvelopeAcrossRotation(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	plaintext := []byte(`{"operators":[]}`)
+	dir := t.TempDir()
+
+	current := filepath.Join(dir, "config.json.enc")
+	if err := EncryptFile(current, plaintext, oldKey); err != nil {
+		t.Fatal(err)
+	}
+	// Formato anterior: nonce | ciphertext, sin cabecera ni datos adicionales
+	legacy := filepath.Join(dir, "legacy.enc")
+	aesgcm, err := newGCM(oldKey.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, nonceSize)
+	if err := os.WriteFile(legacy, aesgcm.Seal(nonce, nonce, plaintext, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "no-existe.enc")
+
+	results, err := Rotate([]string{current, legacy, missing}, Keyring{oldKey}, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RotationResult{{Path: current, OldKey: oldKey.ID}, {Path: legacy}, {Path: missing, Skipped: true}}
+	if len(results) != len(want) {
+		t.Fatalf("resultados = %+v", results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("resultado %d = %+v, se esperaba %+v", i, results[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		name string
+		keys Keyring
+		ok   bool
+	}{
+		{"llave nueva", Keyring{newKey}, true},
+		{"llave nueva y anterior", Keyring{oldKey, newKey}, true},
+		{"solo la anterior", Keyring{oldKey}, false},
+		{"sin llaves", nil, false},
+	}
+	for _, path := range []string{current, legacy} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, err := KeyIDOf(data); err != nil || id != newKey.ID {
+			t.Errorf("%s: ID de llave = %q (%v), se esperaba %s", filepath.Base(path), id, err, newKey.ID)
+		}
+		for _, tt := range tests {
+			got, err := Decrypt(data, tt.keys)
+			if tt.ok && (err != nil || !bytes.Equal(got, plaintext)) {
+				t.Errorf("%s con %s: %v", filepath.Base(path), tt.name, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("%s se descifró con %s", filepath.Base(path), tt.name)
+			}
+		}
+	}
+}
+
+// La cabecera (con el ID de la llave) se autentica: cambiarla invalida el
+// archivo aunque la llave sea la correcta
+func TestEnvelopeHeaderIsAuthenticated(t *testing.T) {
+	key := testKey(t)
+	data, err := Encrypt([]byte("secreto"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, append(append([]byte("RPE1"), byte(len(key.ID))), key.ID...)) {
+		t.Fatalf("cabecera inesperada: %q", data[:len(magic)+1+len(key.ID)])
+	}
+
+	// La misma llave registrada con otro ID del mismo largo
+	relabeled := &MasterKey{ID: "deadbeef", Key: key.Key}
+	if len(relabeled.ID) != len(key.ID) {
+		t.Fatalf("el ID de prueba debe tener %d caracteres", len(key.ID))
+	}
+	idStart := len(magic) + 1
+
+	tests := []struct {
+		name   string
+		tamper func([]byte) []byte
+		keys   Keyring
+	}{
+		{"ID cambiado", func(b []byte) []byte { copy(b[idStart:], relabeled.ID); return b }, Keyring{relabeled, key}},
+		{"versión cambiada", func(b []byte) []byte { b[3] = '2'; return b }, Keyring{key}},
+		{"sin cabecera", func(b []byte) []byte { return b[idStart+len(key.ID):] }, Keyring{key}},
+		{"texto cifrado alterado", func(b []byte) []byte { b[len(b)-1] ^= 1; return b }, Keyring{key}},
+		{"nonce alterado", func(b []byte) []byte { b[idStart+len(key.ID)] ^= 1; return b }, Keyring{key}},
+		{"truncado", func(b []byte) []byte { return b[:idStart+len(key.ID)+nonceSize-1] }, Keyring{key}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := tt.tamper(append([]byte(nil), data...))
+			if _, err := Decrypt(tampered, tt.keys); err == nil {
+				t.Error("se aceptó un archivo alterado")
+			}
+		})
+	}
+
+	if got, err := Decrypt(data, Keyring{relabeled, key}); err != nil || string(got) != "secreto" {
+		t.Errorf("el original no se descifró: %v", err)
+	}
+}
+
+// Si un artefacto no se puede descifrar no se reescribe ninguno
+func TestRotateIsAllOrNothing(t *testing.T) {
+	oldKey, newKey, otherKey := testKey(t), testKey(t), testKey(t)
+	dir := t.TempDir()
+	good := filepath.Join(dir, "a.enc")
+	bad := filepath.Join(dir, "b.enc")
+	if err := EncryptFile(good, []byte("a"), oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFile(bad, []byte("b"), otherKey); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(good)
+
+	if _, err := Rotate([]string{good, bad}, Keyring{oldKey}, newKey); err == nil {
+		t.Fatal("Rotate aceptó un artefacto que no descifra")
+	}
+	after, _ := os.ReadFile(good)
+	if !bytes.Equal(before, after) {
+		t.Error("Rotate reescribió un archivo aunque otro falló")
+	}
+}
@@ -0,0 +1,251 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// Prefijo de las variables de entorno de la llave maestra actual
+	EnvPrefix = "RESET_MASTER"
+	// Prefijo de las variables de entorno de la llave nueva (rotación)
+	NewEnvPrefix = "RESET_NEW_MASTER"
+
+	// Archivo por defecto donde se guarda la sal del KDF
+	DefaultSaltFile = "master.salt"
+
+	keySize = 32
+)
+
+// DevelopmentKey es la llave de pruebas histórica con la que se generó
+// config.json.enc. Solo se usa como último recurso fuera de producción.
+var DevelopmentKey = []byte("12345678901234567890123456789012")
+
+var ErrNoMasterKey = errors.New("no se configuró ninguna llave maestra")
+
+// Origen de la llave maestra
+const (
+	SourceEnv         = "env"
+	SourceFile        = "file"
+	SourcePassphrase  = "passphrase"
+	SourceDevelopment = "development"
+)
+
+// MasterKey es una llave AES-256 identificada por su ID
+type MasterKey struct {
+	ID     string
+	Key    []byte
+	Source string
+}
+
+// KDFParams se guarda junto a la sal para poder derivar la misma llave
+type KDFParams struct {
+	KDF     string `json:"kdf"` // argon2id | scrypt
+	Salt    string `json:"salt"`
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+}
+
+// Validate rechaza parámetros con los que el KDF fallaría o entraría en
+// pánico (argon2 con t=0 o p=0, scrypt con N que no es potencia de 2)
+func (p KDFParams) Validate() error {
+	switch p.KDF {
+	case "argon2id", "":
+		if p.Time == 0 || p.Threads == 0 {
+			return errors.New("argon2id: time y threads deben ser mayores que 0")
+		}
+		if p.Memory < 8*uint32(p.Threads) {
+			return fmt.Errorf("argon2id: memory debe ser al menos %d KiB", 8*uint32(p.Threads))
+		}
+	case "scrypt":
+		if p.N <= 1 || p.N&(p.N-1) != 0 {
+			return errors.New("scrypt: N debe ser una potencia de 2 mayor que 1")
+		}
+		if p.R <= 0 || p.P <= 0 || uint64(p.R)*uint64(p.P) >= 1<<30 {
+			return errors.New("scrypt: r y p deben ser mayores que 0 y r*p menor que 2^30")
+		}
+	default:
+		return fmt.Errorf("KDF no soportado: %s", p.KDF)
+	}
+	return nil
+}
+
+// NewMasterKey construye una llave y calcula su ID
+func NewMasterKey(key []byte, source string) (*MasterKey, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("la llave maestra debe tener %d bytes (tiene %d)", keySize, len(key))
+	}
+	return &MasterKey{ID: KeyID(key), Key: key, Source: source}, nil
+}
+
+// KeyID devuelve un identificador corto y no reversible de la llave
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// IsDevelopment indica si la llave es la llave de pruebas
+func (k *MasterKey) IsDevelopment() bool {
+	return subtle.ConstantTimeCompare(k.Key, DevelopmentKey) == 1
+}
+
+// IsProduction indica si el proceso corre en modo producción (APP_ENV)
+func IsProduction() bool {
+	env := strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV")))
+	return env == "production" || env == "prod"
+}
+
+// LoadMasterKey carga la llave maestra actual desde el entorno
+func LoadMasterKey() (*MasterKey, error) {
+	key, err := LoadMasterKeyFrom(EnvPrefix)
+	if errors.Is(err, ErrNoMasterKey) && !IsProduction() {
+		return NewMasterKey(DevelopmentKey, SourceDevelopment)
+	}
+	return key, err
+}
+
+// LoadMasterKeyFrom busca la llave en este orden:
+//   - <prefix>_KEY: llave en hex o base64
+//   - <prefix>_KEY_FILE: archivo con la llave (binaria, hex o base64)
+//   - <prefix>_PASSPHRASE: frase derivada con el KDF de <prefix>_SALT_FILE
+func LoadMasterKeyFrom(prefix string) (*MasterKey, error) {
+	if value := os.Getenv(prefix + "_KEY"); value != "" {
+		key, err := decodeKey([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s_KEY inválida: %v", prefix, err)
+		}
+		return NewMasterKey(key, SourceEnv)
+	}
+
+	if path := os.Getenv(prefix + "_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo %s_KEY_FILE: %v", prefix, err)
+		}
+		key, err := decodeKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s_KEY_FILE inválida: %v", prefix, err)
+		}
+		return NewMasterKey(key, SourceFile)
+	}
+
+	if passphrase := os.Getenv(prefix + "_PASSPHRASE"); passphrase != "" {
+		saltFile := os.Getenv(prefix + "_SALT_FILE")
+		if saltFile == "" {
+			saltFile = DefaultSaltFile
+		}
+		params, err := LoadKDFParams(saltFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := DeriveKey(passphrase, params)
+		if err != nil {
+			return nil, err
+		}
+		return NewMasterKey(key, SourcePassphrase)
+	}
+
+	return nil, ErrNoMasterKey
+}
+
+// GenerateKey crea una llave aleatoria de 32 bytes
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewKDFParams genera una sal nueva con parámetros por defecto
+func NewKDFParams(kdf string) (KDFParams, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return KDFParams{}, err
+	}
+
+	params := KDFParams{KDF: kdf, Salt: base64.StdEncoding.EncodeToString(salt)}
+	switch kdf {
+	case "argon2id":
+		params.Time, params.Memory, params.Threads = 3, 64*1024, 4
+	case "scrypt":
+		params.N, params.R, params.P = 1<<15, 8, 1
+	default:
+		return KDFParams{}, fmt.Errorf("KDF no soportado: %s", kdf)
+	}
+	return params, nil
+}
+
+// LoadKDFParams lee la sal y los parámetros del KDF
+func LoadKDFParams(path string) (KDFParams, error) {
+	var params KDFParams
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return params, fmt.Errorf("error leyendo archivo de sal %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return params, fmt.Errorf("archivo de sal inválido: %v", err)
+	}
+	if err := params.Validate(); err != nil {
+		return params, fmt.Errorf("archivo de sal %s: %v", path, err)
+	}
+	return params, nil
+}
+
+// SaveKDFParams guarda la sal y los parámetros del KDF
+func SaveKDFParams(path string, params KDFParams) error {
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// DeriveKey deriva una llave de 32 bytes a partir de una frase
+func DeriveKey(passphrase string, params KDFParams) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("sal del KDF inválida")
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	if params.KDF == "scrypt" {
+		return scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, keySize)
+	}
+	return argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, keySize), nil
+}
+
+func decodeKey(data []byte) ([]byte, error) {
+	if len(data) == keySize {
+		return data, nil
+	}
+
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	if len(text) == keySize {
+		return []byte(text), nil
+	}
+	return nil, fmt.Errorf("se esperaban %d bytes en hex, base64 o binario", keySize)
+}
@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeriveKeyRejectsBadParams(t *testing.T) {
+	const salt = "c2FsLWRlLXBydWViYQ=="
+	tests := []struct {
+		name   string
+		params KDFParams
+		ok     bool
+	}{
+		{"argon2id", KDFParams{KDF: "argon2id", Salt: salt, Time: 1, Memory: 64, Threads: 1}, true},
+		{"sin KDF usa argon2id", KDFParams{Salt: salt, Time: 1, Memory: 64, Threads: 1}, true},
+		{"sin KDF ni parámetros", KDFParams{Salt: salt}, false},
+		{"argon2id t=0", KDFParams{KDF: "argon2id", Salt: salt, Time: 0, Memory: 64, Threads: 1}, false},
+		{"argon2id p=0", KDFParams{KDF: "argon2id", Salt: salt, Time: 1, Memory: 64, Threads: 0}, false},
+		{"argon2id memoria menor que 8*p", KDFParams{KDF: "argon2id", Salt: salt, Time: 1, Memory: 31, Threads: 4}, false},
+		{"scrypt", KDFParams{KDF: "scrypt", Salt: salt, N: 16, R: 1, P: 1}, true},
+		{"scrypt N no potencia de 2", KDFParams{KDF: "scrypt", Salt: salt, N: 1000, R: 8, P: 1}, false},
+		{"scrypt N=1", KDFParams{KDF: "scrypt", Salt: salt, N: 1, R: 8, P: 1}, false},
+		{"scrypt r=0", KDFParams{KDF: "scrypt", Salt: salt, N: 16, R: 0, P: 1}, false},
+		{"scrypt p negativo", KDFParams{KDF: "scrypt", Salt: salt, N: 16, R: 8, P: -1}, false},
+		{"scrypt r*p demasiado grande", KDFParams{KDF: "scrypt", Salt: salt, N: 16, R: 1 << 15, P: 1 << 15}, false},
+		{"KDF desconocido", KDFParams{KDF: "pbkdf2", Salt: salt}, false},
+		{"sin sal", KDFParams{KDF: "argon2id", Time: 1, Memory: 64, Threads: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := DeriveKey("frase de prueba", tt.params)
+			if tt.ok && (err != nil || len(key) != keySize) {
+				t.Fatalf("DeriveKey: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("DeriveKey aceptó parámetros inválidos")
+			}
+		})
+	}
+}
+
+func TestLoadKDFParamsValidates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "salt.json")
+	if err := os.WriteFile(path, []byte(`{"salt": "c2Fs"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKDFParams(path); err == nil {
+		t.Error("LoadKDFParams aceptó un archivo sin parámetros")
+	}
+
+	params, err := NewKDFParams("scrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKDFParams(path, params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKDFParams(path); err != nil {
+		t.Errorf("LoadKDFParams con parámetros por defecto: %v", err)
+	}
+}
+
+func TestVerifyPasswordRejectsBadParams(t *testing.T) {
+	const tail = "$c2FsLWRlLXBydWViYQ$aGFzaC1kZS1wcnVlYmE"
+	for _, params := range []string{"m=65536,t=0,p=4", "m=65536,t=3,p=0", "m=16,t=3,p=4", "m=65536,t=3,p=256"} {
+		ok, err := VerifyPassword("$argon2id$v=19$"+params+tail, "clave")
+		if ok || !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("%s: ok=%v err=%v", params, ok, err)
+		}
+	}
+
+	hash, err := HashPassword("clave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := VerifyPassword(hash, "clave"); err != nil || !ok {
+		t.Errorf("VerifyPassword con un hash válido: %v, %v", ok, err)
+	}
+}
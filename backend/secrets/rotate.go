@@ -0,0 +1,91 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	protectedMutex sync.Mutex
+	protectedFiles []string
+)
+
+// RegisterProtected agrega un archivo a la lista de artefactos cifrados con
+// la llave maestra, para que la rotación lo incluya.
+func RegisterProtected(path string) {
+	protectedMutex.Lock()
+	defer protectedMutex.Unlock()
+
+	for _, p := range protectedFiles {
+		if p == path {
+			return
+		}
+	}
+	protectedFiles = append(protectedFiles, path)
+}
+
+// ProtectedFiles devuelve los artefactos registrados
+func ProtectedFiles() []string {
+	protectedMutex.Lock()
+	defer protectedMutex.Unlock()
+	return append([]string(nil), protectedFiles...)
+}
+
+// RotationResult describe lo que se hizo con cada artefacto
+type RotationResult struct {
+	Path    string
+	OldKey  string
+	Skipped bool
+}
+
+// Rotate vuelve a cifrar cada artefacto con la llave nueva. Primero descifra
+// todos; si alguno falla no se modifica ninguno.
+func Rotate(paths []string, oldKeys Keyring, newKey *MasterKey) ([]RotationResult, error) {
+	type pending struct {
+		result    RotationResult
+		plaintext []byte
+	}
+
+	var work []pending
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			work = append(work, pending{result: RotationResult{Path: path, Skipped: true}})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo %s: %v", path, err)
+		}
+
+		oldID, err := KeyIDOf(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		plaintext, err := Decrypt(data, oldKeys)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		work = append(work, pending{result: RotationResult{Path: path, OldKey: oldID}, plaintext: plaintext})
+	}
+
+	results := make([]RotationResult, 0, len(work))
+	for _, item := range work {
+		if !item.result.Skipped {
+			if err := writeAtomic(item.result.Path, item.plaintext, newKey); err != nil {
+				return results, fmt.Errorf("error reescribiendo %s: %v", item.result.Path, err)
+			}
+		}
+		results = append(results, item.result)
+	}
+	return results, nil
+}
+
+func writeAtomic(path string, plaintext []byte, key *MasterKey) error {
+	tmp := path + ".tmp"
+	if err := EncryptFile(tmp, plaintext, key); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,118 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"password-recovery/apierror"
+	"password-recovery/database"
+	"password-recovery/i18n"
+)
+
+// Headers con los que un cliente indica su tenant
+const (
+	HeaderAPIKey = "X-API-Key"
+	HeaderTenant = "X-Tenant"
+)
+
+var ErrMismatch = errors.New("X-Tenant no corresponde a la API key")
+
+// Resolve identifica el tenant de la petición, en este orden: la API key,
+// el header X-Tenant, el host (si algún tenant lo declara) y por último el
+// tenant por defecto
+func Resolve(db Querier, d database.Dialect, r *http.Request) (*Tenant, error) {
+	var t *Tenant
+	var err error
+
+	key := strings.TrimSpace(r.Header.Get(HeaderAPIKey))
+	slug := strings.TrimSpace(r.Header.Get(HeaderTenant))
+	switch {
+	case key != "":
+		t, err = ByAPIKey(db, d, key)
+		if err == nil && slug != "" && !strings.EqualFold(slug, t.Slug) {
+			err = ErrMismatch
+		}
+	case slug != "":
+		t, err = BySlug(db, d, slug)
+	default:
+		host := r.Host
+		if h, _, splitErr := net.SplitHostPort(host); splitErr == nil {
+			host = h
+		}
+		t, err = ByHost(db, d, host)
+		if err == nil && t == nil {
+			t, err = Get(db, d, DefaultID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !t.IsActive {
+		return nil, ErrInactive
+	}
+	return t, nil
+}
+
+type ctxKey struct{}
+
+// WithTenant guarda el tenant en el contexto; los textos de la petición
+// pasan a ser los del tenant (i18n)
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	if t != nil {
+		ctx = i18n.WithTenant(ctx, t.ID)
+	}
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// From devuelve el tenant de la petición, si se resolvió
+func From(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(ctxKey{}).(*Tenant)
+	return t, ok && t != nil
+}
+
+// Gin resuelve el tenant y lo deja en el contexto de la petición. Si un
+// middleware anterior ya lo fijó (una API key de servicio), se respeta.
+func Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := From(c.Request.Context()); ok {
+			c.Next()
+			return
+		}
+		t, err := resolveCurrent(c.Request)
+		if err != nil {
+			apierror.Abort(c, apiError(err))
+			return
+		}
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), t))
+		c.Next()
+	}
+}
+
+func resolveCurrent(r *http.Request) (*Tenant, error) {
+	pool, release, err := database.Default.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return Resolve(pool.SQL, pool.Dialect, r)
+}
+
+// apiError traduce un error de resolución
+func apiError(err error) *apierror.Error {
+	switch {
+	case errors.Is(err, ErrInvalidAPIKey):
+		return apierror.New(apierror.TenantAPIKeyInvalid)
+	case errors.Is(err, ErrNotFound):
+		return apierror.New(apierror.TenantNotFound)
+	case errors.Is(err, ErrInactive):
+		return apierror.New(apierror.TenantDisabled)
+	case errors.Is(err, ErrMismatch):
+		return apierror.New(apierror.TenantMismatch)
+	}
+	return apierror.Wrap(apierror.Unavailable, err)
+}
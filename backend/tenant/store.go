@@ -0,0 +1,249 @@
+package tenant
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/fielderr"
+)
+
+// APIKeyPrefix identifica las llaves de tenant en los logs y en los
+// escáneres de secretos
+const APIKeyPrefix = "rt_"
+
+const columns = "id, slug, name, hosts, api_key_hash, code_length, code_ttl_seconds, redirect_allowlist, is_active, created_at, updated_at"
+
+// Querier es *sql.DB o *sql.Tx
+type Querier interface {
+	database.Execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row rowScanner) (*Tenant, error) {
+	var t Tenant
+	var hosts, allowlist string
+	var keyHash sql.NullString
+	err := row.Scan(&t.ID, &t.Slug, &t.Name, &hosts, &keyHash, &t.CodeLength, &t.CodeTTLSeconds,
+		&allowlist, &t.IsActive, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Hosts = splitList(hosts)
+	t.RedirectAllowlist = splitList(allowlist)
+	t.HasAPIKey = keyHash.Valid && keyHash.String != ""
+	return &t, nil
+}
+
+// Get busca un tenant por id
+func Get(db database.Execer, d database.Dialect, id int64) (*Tenant, error) {
+	return scan(db.QueryRow(d.Rebind("SELECT "+columns+" FROM tenants WHERE id = $1"), id))
+}
+
+// BySlug busca un tenant por su identificador
+func BySlug(db database.Execer, d database.Dialect, slug string) (*Tenant, error) {
+	return scan(db.QueryRow(d.Rebind("SELECT "+columns+" FROM tenants WHERE slug = $1"),
+		strings.TrimSpace(strings.ToLower(slug))))
+}
+
+// ByHost busca el tenant que declara ese nombre de host; nil si ninguno
+func ByHost(db Querier, d database.Dialect, host string) (*Tenant, error) {
+	host = strings.ToLower(host)
+	// La lista es corta y el filtro LIKE solo descarta candidatos; la
+	// coincidencia exacta se revisa aquí
+	rows, err := db.Query(d.Rebind("SELECT "+columns+" FROM tenants WHERE hosts LIKE $1 ORDER BY id"), "%"+host+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range t.Hosts {
+			if h == host {
+				return t, nil
+			}
+		}
+	}
+	return nil, rows.Err()
+}
+
+// ByAPIKey busca el tenant dueño de la llave
+func ByAPIKey(db database.Execer, d database.Dialect, key string) (*Tenant, error) {
+	t, err := scan(db.QueryRow(d.Rebind("SELECT "+columns+" FROM tenants WHERE api_key_hash = $1"), hashAPIKey(key)))
+	if err == ErrNotFound {
+		return nil, ErrInvalidAPIKey
+	}
+	return t, err
+}
+
+// List devuelve los tenants indicados (todos si ids es nil) ordenados por id
+func List(db Querier, d database.Dialect, ids []int64) ([]*Tenant, error) {
+	query := "SELECT " + columns + " FROM tenants"
+	var args []interface{}
+	if ids != nil {
+		if len(ids) == 0 {
+			return []*Tenant{}, nil
+		}
+		marks := make([]string, len(ids))
+		for i, id := range ids {
+			args = append(args, id)
+			marks[i] = "$" + strconv.Itoa(i+1)
+		}
+		query += " WHERE id IN (" + strings.Join(marks, ", ") + ")"
+	}
+	rows, err := db.Query(d.Rebind(query+" ORDER BY id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Tenant{}
+	for rows.Next() {
+		t, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// Create guarda un tenant nuevo; el slug debe ser único
+func Create(db database.Execer, d database.Dialect, t *Tenant) error {
+	t.Normalize()
+	t.IsActive = true
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if existing, err := BySlug(db, d, t.Slug); err == nil && existing != nil {
+		return fielderr.Field("slug", "ya existe")
+	} else if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	now := time.Now().UTC()
+	id, err := database.InsertID(db, d, `INSERT INTO tenants
+		(slug, name, hosts, code_length, code_ttl_seconds, redirect_allowlist, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		t.Slug, t.Name, strings.Join(t.Hosts, ","), t.CodeLength, t.CodeTTLSeconds,
+		strings.Join(t.RedirectAllowlist, ","), t.IsActive, now, now)
+	if err != nil {
+		return err
+	}
+	t.ID, t.CreatedAt, t.UpdatedAt = id, now, now
+	return nil
+}
+
+// Update guarda nombre, hosts, política, lista de redirección y estado. El
+// slug no cambia porque los clientes lo envían en X-Tenant.
+func Update(db database.Execer, d database.Dialect, t *Tenant) error {
+	t.Normalize()
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	result, err := db.Exec(d.Rebind(`UPDATE tenants SET name = $1, hosts = $2, code_length = $3, code_ttl_seconds = $4,
+		redirect_allowlist = $5, is_active = $6, updated_at = $7 WHERE id = $8`),
+		t.Name, strings.Join(t.Hosts, ","), t.CodeLength, t.CodeTTLSeconds,
+		strings.Join(t.RedirectAllowlist, ","), t.IsActive, now, t.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	t.UpdatedAt = now
+	return nil
+}
+
+// RotateAPIKey genera una llave nueva (la anterior deja de valer) y la
+// devuelve; solo se guarda su hash
+func RotateAPIKey(db database.Execer, d database.Dialect, id int64) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := APIKeyPrefix + hex.EncodeToString(b)
+	if err := setAPIKeyHash(db, d, id, hashAPIKey(key)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey borra la llave del tenant
+func RevokeAPIKey(db database.Execer, d database.Dialect, id int64) error {
+	return setAPIKeyHash(db, d, id, nil)
+}
+
+func setAPIKeyHash(db database.Execer, d database.Dialect, id int64, hash interface{}) error {
+	result, err := db.Exec(d.Rebind("UPDATE tenants SET api_key_hash = $1, updated_at = $2 WHERE id = $3"),
+		hash, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// AdminTenants devuelve los tenants que puede administrar una cuenta: el
+// suyo y los que se le asignaron en admin_tenants
+func AdminTenants(db Querier, d database.Dialect, userID int64) ([]int64, error) {
+	rows, err := db.Query(d.Rebind(`SELECT tenant_id FROM users WHERE id = $1
+		UNION SELECT tenant_id FROM admin_tenants WHERE user_id = $2`), userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GrantAdmin da a la cuenta acceso administrativo al tenant
+func GrantAdmin(db database.Execer, d database.Dialect, tenantID, userID int64) error {
+	var n int
+	err := db.QueryRow(d.Rebind("SELECT COUNT(*) FROM admin_tenants WHERE tenant_id = $1 AND user_id = $2"),
+		tenantID, userID).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(d.Rebind("INSERT INTO admin_tenants (tenant_id, user_id, created_at) VALUES ($1, $2, $3)"),
+		tenantID, userID, time.Now().UTC())
+	return err
+}
+
+// RevokeAdmin quita el acceso asignado (el tenant propio de la cuenta no se
+// puede quitar)
+func RevokeAdmin(db database.Execer, d database.Dialect, tenantID, userID int64) error {
+	_, err := db.Exec(d.Rebind("DELETE FROM admin_tenants WHERE tenant_id = $1 AND user_id = $2"), tenantID, userID)
+	return err
+}
@@ -0,0 +1,179 @@
+package tenant
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/fielderr"
+	"password-recovery/i18n"
+)
+
+// Tipos de correo con plantilla
+const (
+	TemplateResetCode  = "reset_code"
+	TemplateInvitation = "invitation"
+)
+
+// Variables que se reemplazan en asunto y cuerpo
+const (
+	VarCode    = "{code}"    // el código
+	VarExpires = "{expires}" // vigencia legible ("5 minutos")
+	VarTenant  = "{tenant}"  // nombre del tenant
+	VarLink    = "{link}"    // redirect_url de la solicitud (puede ir vacío)
+)
+
+var ErrUnknownTemplate = errors.New("tipo de plantilla desconocido")
+
+// Template es el asunto y el cuerpo de un tipo de correo en un idioma.
+// Custom indica si el tenant la personalizó o es la predeterminada.
+type Template struct {
+	Kind      string     `json:"kind"`
+	Locale    string     `json:"locale"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	Custom    bool       `json:"custom"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// defaultTemplates son las plantillas predeterminadas por idioma
+var defaultTemplates = map[string]map[string]Template{
+	i18n.Spanish: {
+		TemplateResetCode: {
+			Subject: "Restablecimiento de contraseña",
+			Body:    "Tu código de restablecimiento de contraseña es: {code}",
+		},
+		TemplateInvitation: {
+			Subject: "Bienvenido: activa tu cuenta",
+			Body:    "Se creó una cuenta para este correo. Usa el código {code} para definir tu contraseña (vigente {expires}).",
+		},
+	},
+	i18n.English: {
+		TemplateResetCode: {
+			Subject: "Password reset",
+			Body:    "Your password reset code is: {code}",
+		},
+		TemplateInvitation: {
+			Subject: "Welcome: activate your account",
+			Body:    "An account was created for this email address. Use the code {code} to set your password (valid for {expires}).",
+		},
+	},
+}
+
+// defaultTemplate es la plantilla predeterminada del tipo en el idioma
+func defaultTemplate(kind, locale string) (Template, bool) {
+	t, ok := defaultTemplates[locale][kind]
+	t.Kind, t.Locale = kind, locale
+	return t, ok
+}
+
+// TemplateKinds lista los tipos en orden
+func TemplateKinds() []string {
+	return []string{TemplateResetCode, TemplateInvitation}
+}
+
+// Validate revisa que la plantilla tenga asunto y que el cuerpo incluya el
+// código; sin idioma se toma el predeterminado
+func (t *Template) Validate() error {
+	fields := map[string]string{}
+	if t.Locale == "" {
+		t.Locale = i18n.Default
+	}
+	if !i18n.Valid(t.Locale) {
+		fields["locale"] = "use " + strings.Join(i18n.Locales(), " o ")
+	} else if _, ok := defaultTemplate(t.Kind, t.Locale); !ok {
+		fields["kind"] = "use " + strings.Join(TemplateKinds(), " o ")
+	}
+	t.Subject = strings.TrimSpace(t.Subject)
+	if t.Subject == "" || len(t.Subject) > 200 || strings.ContainsAny(t.Subject, "\r\n") {
+		fields["subject"] = "es obligatorio, de una línea y máximo 200 caracteres"
+	}
+	if !strings.Contains(t.Body, VarCode) {
+		fields["body"] = "debe contener " + VarCode
+	}
+	if len(fields) > 0 {
+		return fielderr.New(fields)
+	}
+	return nil
+}
+
+// Render reemplaza las variables en asunto y cuerpo
+func (t Template) Render(vars map[string]string) (subject, body string) {
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, name, value)
+	}
+	r := strings.NewReplacer(pairs...)
+	// El asunto va en un encabezado: nunca puede cortar la línea
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(r.Replace(t.Subject))
+	return subject, r.Replace(t.Body)
+}
+
+// TemplateFor devuelve la plantilla del tenant en el idioma o la
+// predeterminada de ese idioma
+func TemplateFor(db database.Execer, d database.Dialect, tenantID int64, kind, locale string) (Template, error) {
+	def, ok := defaultTemplate(kind, locale)
+	if !ok {
+		return Template{}, ErrUnknownTemplate
+	}
+	t := Template{Kind: kind, Locale: locale, Custom: true}
+	var updated time.Time
+	err := db.QueryRow(d.Rebind("SELECT subject, body, updated_at FROM email_templates WHERE tenant_id = $1 AND kind = $2 AND locale = $3"),
+		tenantID, kind, locale).Scan(&t.Subject, &t.Body, &updated)
+	if err == sql.ErrNoRows {
+		return def, nil
+	}
+	if err != nil {
+		return Template{}, err
+	}
+	t.UpdatedAt = &updated
+	return t, nil
+}
+
+// Templates devuelve todas las plantillas del tenant en el idioma
+func Templates(db database.Execer, d database.Dialect, tenantID int64, locale string) ([]Template, error) {
+	list := make([]Template, 0, len(TemplateKinds()))
+	for _, kind := range TemplateKinds() {
+		t, err := TemplateFor(db, d, tenantID, kind, locale)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// SaveTemplate personaliza una plantilla del tenant
+func SaveTemplate(db *sql.DB, d database.Dialect, tenantID int64, t *Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(d.Rebind("DELETE FROM email_templates WHERE tenant_id = $1 AND kind = $2 AND locale = $3"), tenantID, t.Kind, t.Locale); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(d.Rebind("INSERT INTO email_templates (tenant_id, kind, locale, subject, body, updated_at) VALUES ($1, $2, $3, $4, $5, $6)"),
+		tenantID, t.Kind, t.Locale, t.Subject, t.Body, now); err != nil {
+		return err
+	}
+	t.Custom, t.UpdatedAt = true, &now
+	return tx.Commit()
+}
+
+// ResetTemplate vuelve a la plantilla predeterminada del idioma
+func ResetTemplate(db database.Execer, d database.Dialect, tenantID int64, kind, locale string) (Template, error) {
+	def, ok := defaultTemplate(kind, locale)
+	if !ok {
+		return Template{}, ErrUnknownTemplate
+	}
+	_, err := db.Exec(d.Rebind("DELETE FROM email_templates WHERE tenant_id = $1 AND kind = $2 AND locale = $3"), tenantID, kind, locale)
+	return def, err
+}
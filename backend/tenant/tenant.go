@@ -0,0 +1,198 @@
+// Package tenant separa las aplicaciones cliente que comparten el servicio.
+// Cada tenant tiene sus propios usuarios, códigos, configuración SMTP y
+// plantillas de correo, además de su política de códigos y la lista de URLs
+// a las que se permite redirigir después del restablecimiento.
+//
+// El correo de una cuenta es único dentro de su tenant: el mismo correo
+// puede existir en varios tenants como cuentas independientes. El directorio
+// LDAP y el conector son de la instalación y solo los usa el tenant por
+// defecto.
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"password-recovery/fielderr"
+)
+
+// DefaultID es el tenant que crea la migración; lo usan las peticiones que
+// no indican otro y las filas anteriores al soporte multi-tenant
+const DefaultID int64 = 1
+
+// DefaultSlug es el identificador del tenant por defecto
+const DefaultSlug = "default"
+
+// Política de códigos por defecto (la que tenía el servicio antes de los
+// tenants) y sus límites. reset_codes.code admite hasta 10 caracteres.
+const (
+	DefaultCodeLength     = 8
+	DefaultCodeTTLSeconds = 300
+	MinCodeLength         = 6
+	MaxCodeLength         = 10
+	MinCodeTTLSeconds     = 60
+	MaxCodeTTLSeconds     = 24 * 60 * 60
+)
+
+var (
+	ErrNotFound      = errors.New("tenant no encontrado")
+	ErrInactive      = errors.New("tenant deshabilitado")
+	ErrInvalidAPIKey = errors.New("API key inválida")
+
+	slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+	hostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?$`)
+)
+
+// Tenant es una aplicación cliente
+type Tenant struct {
+	ID             int64    `json:"id"`
+	Slug           string   `json:"slug"`
+	Name           string   `json:"name"`
+	Hosts          []string `json:"hosts"`
+	CodeLength     int      `json:"code_length"`
+	CodeTTLSeconds int      `json:"code_ttl_seconds"`
+	// RedirectAllowlist son los prefijos de URL permitidos en redirect_url
+	// (https://app.example.com/login, https://*.example.com)
+	RedirectAllowlist []string  `json:"redirect_allowlist"`
+	IsActive          bool      `json:"is_active"`
+	HasAPIKey         bool      `json:"has_api_key"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Normalize limpia los valores y completa la política por defecto
+func (t *Tenant) Normalize() {
+	t.Slug = strings.TrimSpace(strings.ToLower(t.Slug))
+	t.Name = strings.TrimSpace(t.Name)
+	t.Hosts = cleanList(t.Hosts, true)
+	t.RedirectAllowlist = cleanList(t.RedirectAllowlist, false)
+	if t.CodeLength == 0 {
+		t.CodeLength = DefaultCodeLength
+	}
+	if t.CodeTTLSeconds == 0 {
+		t.CodeTTLSeconds = DefaultCodeTTLSeconds
+	}
+}
+
+// Validate revisa el tenant (después de Normalize)
+func (t *Tenant) Validate() error {
+	fields := map[string]string{}
+	if !slugPattern.MatchString(t.Slug) {
+		fields["slug"] = "use minúsculas, números y guiones (máximo 50)"
+	}
+	if t.Name == "" || len(t.Name) > 100 {
+		fields["name"] = "es obligatorio (máximo 100 caracteres)"
+	}
+	for _, host := range t.Hosts {
+		if !hostPattern.MatchString(host) {
+			fields["hosts"] = "host inválido: " + host
+			break
+		}
+	}
+	if t.CodeLength < MinCodeLength || t.CodeLength > MaxCodeLength {
+		fields["code_length"] = fmt.Sprintf("debe estar entre %d y %d", MinCodeLength, MaxCodeLength)
+	}
+	if t.CodeTTLSeconds < MinCodeTTLSeconds || t.CodeTTLSeconds > MaxCodeTTLSeconds {
+		fields["code_ttl_seconds"] = fmt.Sprintf("debe estar entre %d y %d", MinCodeTTLSeconds, MaxCodeTTLSeconds)
+	}
+	for _, entry := range t.RedirectAllowlist {
+		if _, err := parseAllowed(entry); err != nil {
+			fields["redirect_allowlist"] = entry + ": " + err.Error()
+			break
+		}
+	}
+	if len(strings.Join(t.Hosts, ",")) > 500 {
+		fields["hosts"] = "la lista supera 500 caracteres"
+	}
+	if len(strings.Join(t.RedirectAllowlist, ",")) > 1000 {
+		fields["redirect_allowlist"] = "la lista supera 1000 caracteres"
+	}
+	if t.ID == DefaultID && !t.IsActive {
+		fields["is_active"] = "el tenant por defecto no se puede deshabilitar"
+	}
+
+	if len(fields) > 0 {
+		return fielderr.New(fields)
+	}
+	return nil
+}
+
+// CodeTTL es la vigencia de los códigos de restablecimiento
+func (t *Tenant) CodeTTL() time.Duration {
+	return time.Duration(t.CodeTTLSeconds) * time.Second
+}
+
+// AllowsRedirect indica si raw coincide con alguna entrada de la lista:
+// mismo esquema, mismo host (o subdominio si la entrada empieza con "*.") y
+// una ruta que empieza con la de la entrada
+func (t *Tenant) AllowsRedirect(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	for _, entry := range t.RedirectAllowlist {
+		allowed, err := parseAllowed(entry)
+		if err != nil || allowed.Scheme != u.Scheme {
+			continue
+		}
+		pattern := strings.ToLower(allowed.Host)
+		if strings.HasPrefix(pattern, "*.") {
+			if !strings.HasSuffix(host, pattern[1:]) {
+				continue
+			}
+		} else if host != pattern {
+			continue
+		}
+		if strings.HasPrefix(u.Path, allowed.Path) &&
+			(len(u.Path) == len(allowed.Path) || strings.HasSuffix(allowed.Path, "/") || u.Path[len(allowed.Path)] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAllowed(entry string) (*url.URL, error) {
+	if strings.Contains(entry, ",") {
+		return nil, errors.New("no puede contener comas")
+	}
+	u, err := url.Parse(entry)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, errors.New("use http:// o https://")
+	}
+	if u.Host == "" || u.Host == "*." || strings.Contains(strings.TrimPrefix(u.Host, "*."), "*") {
+		return nil, errors.New("host inválido")
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return nil, errors.New("solo esquema, host y ruta")
+	}
+	return u, nil
+}
+
+// cleanList quita espacios, vacíos y repetidos
+func cleanList(values []string, lower bool) []string {
+	list := []string{}
+	seen := map[string]bool{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if lower {
+			v = strings.ToLower(v)
+		}
+		if v != "" && !seen[v] {
+			seen[v] = true
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func splitList(value string) []string {
+	return cleanList(strings.Split(value, ","), false)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"password-recovery/i18n"
+)
+
+const defaultTextsInterval = time.Minute
+
+// runTexts vuelve a leer los textos personalizados cada interval para ver
+// los cambios hechos desde otras instancias; los de esta instancia se
+// aplican al guardarlos
+func runTexts(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultTextsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := i18n.Load(db); err != nil {
+			slog.Warn("No se pudieron recargar los textos personalizados", "error", err)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package userimport
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"password-recovery/auth"
+	"password-recovery/database"
+	"password-recovery/rbac"
+)
+
+// Acciones aplicadas a cada fila
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+)
+
+// Estado con el que se crean las cuentas importadas: no tienen contraseña
+// conocida y deben definirla con un código (invitación o /send-code)
+const statusResetRequired = "reset_required"
+
+const maxNameLength = 100
+
+var (
+	ErrLastAdmin = errors.New("la importación dejaría el tenant sin administradores activos")
+	// errPrivilegedTarget: sin roles:assign no se puede cambiar el rol de
+	// una cuenta con acceso administrativo (por ejemplo degradarla a user)
+	errPrivilegedTarget = errors.New("cambiar el rol de una cuenta con rol distinto de user requiere el permiso " + string(rbac.PermRolesAssign))
+
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2}|-[0-9]{3})?$`)
+)
+
+// Options controla cómo se aplica el lote
+type Options struct {
+	// TenantID es el tenant de las cuentas; las filas se comparan por
+	// correo solo con las cuentas de ese tenant
+	TenantID int64
+	// DryRun valida y calcula el resultado sin guardar nada
+	DryRun bool
+	// Invite encola una invitación por cada cuenta creada
+	Invite bool
+	// AllowPrivileged permite filas con un rol distinto de "user" y cambiar
+	// el rol de cuentas que no son "user" (quien importa necesita
+	// roles:assign)
+	AllowPrivileged bool
+}
+
+// RowError es un problema de validación de una fila
+type RowError struct {
+	Line    int    `json:"line"`
+	Email   string `json:"email,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// RowResult es lo que se hizo (o se haría, en dry-run) con una fila
+type RowResult struct {
+	Line    int    `json:"line"`
+	Email   string `json:"email"`
+	Action  string `json:"action"`
+	UserID  int64  `json:"user_id,omitempty"`
+	Invited bool   `json:"invited,omitempty"`
+}
+
+// Report resume el lote. Si Errors no está vacío no se aplicó ninguna fila.
+type Report struct {
+	DryRun    bool        `json:"dry_run"`
+	Total     int         `json:"total"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Unchanged int         `json:"unchanged"`
+	Invited   int         `json:"invited"`
+	Errors    []RowError  `json:"errors"`
+	Rows      []RowResult `json:"rows"`
+}
+
+// Validate normaliza las filas (correo en minúsculas, rol por defecto
+// "user") y devuelve los errores por fila. Es la primera pasada de Import.
+func Validate(rows []Row, opts Options) []RowError {
+	errs := []RowError{}
+	seen := map[string]int{}
+	add := func(row Row, field, msg string) {
+		errs = append(errs, RowError{Line: row.Line, Email: row.Email, Field: field, Message: msg})
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		row.Email = strings.TrimSpace(strings.ToLower(row.Email))
+		row.Name = strings.TrimSpace(row.Name)
+		row.Role = strings.TrimSpace(strings.ToLower(row.Role))
+		row.Locale = strings.TrimSpace(row.Locale)
+
+		if addr, err := mail.ParseAddress(row.Email); row.Email == "" || err != nil || addr.Address != row.Email {
+			add(*row, "email", "correo inválido")
+		} else if first, dup := seen[row.Email]; dup {
+			add(*row, "email", fmt.Sprintf("correo repetido (línea %d)", first))
+		} else {
+			seen[row.Email] = row.Line
+		}
+
+		if len(row.Name) > maxNameLength {
+			add(*row, "name", fmt.Sprintf("el nombre supera %d caracteres", maxNameLength))
+		}
+
+		if row.Role != "" && !rbac.ValidAccountRole(row.Role) {
+			add(*row, "role", "rol inválido (use "+strings.Join(rbac.AccountRoles(), ", ")+")")
+		} else if row.Role != "" && row.Role != rbac.RoleUser && !opts.AllowPrivileged {
+			add(*row, "role", "asignar el rol "+row.Role+" requiere el permiso "+string(rbac.PermRolesAssign))
+		}
+
+		if row.Locale != "" && !localePattern.MatchString(row.Locale) {
+			add(*row, "locale", "locale inválido (por ejemplo es-MX o en)")
+		}
+	}
+	return errs
+}
+
+// Import valida el lote y, si no hay errores, lo aplica en una sola
+// transacción: crea las cuentas nuevas y actualiza nombre, rol y locale de
+// las existentes (los campos vacíos no se modifican). Volver a importar el
+// mismo archivo no cambia nada. En dry-run la transacción se descarta.
+func Import(db *sql.DB, d database.Dialect, rows []Row, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Total: len(rows), Rows: []RowResult{}}
+	report.Errors = Validate(rows, opts)
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	adminsBefore, err := countActiveAdmins(tx, d, opts.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for _, row := range rows {
+		result, err := upsert(tx, d, row, opts, now)
+		if err == errPrivilegedTarget {
+			report.Errors = append(report.Errors, RowError{Line: row.Line, Email: row.Email, Field: "role", Message: err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("línea %d (%s): %v", row.Line, row.Email, err)
+		}
+		switch result.Action {
+		case ActionCreated:
+			report.Created++
+		case ActionUpdated:
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+		if result.Invited {
+			report.Invited++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if len(report.Errors) > 0 {
+		report.Rows = []RowResult{}
+		report.Created, report.Updated, report.Unchanged, report.Invited = 0, 0, 0, 0
+		return report, nil
+	}
+
+	if adminsBefore > 0 {
+		adminsAfter, err := countActiveAdmins(tx, d, opts.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		if adminsAfter == 0 {
+			return nil, ErrLastAdmin
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func upsert(tx *sql.Tx, d database.Dialect, row Row, opts Options, now time.Time) (RowResult, error) {
+	result := RowResult{Line: row.Line, Email: row.Email}
+
+	var id int64
+	var name, role, locale string
+	err := tx.QueryRow(d.Rebind("SELECT id, name, role, locale FROM users WHERE tenant_id = $1 AND email = $2"), opts.TenantID, row.Email).
+		Scan(&id, &name, &role, &locale)
+	if err == sql.ErrNoRows {
+		return create(tx, d, row, opts, now)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.UserID = id
+
+	changed := false
+	if row.Name != "" && row.Name != name {
+		name, changed = row.Name, true
+	}
+	if row.Locale != "" && row.Locale != locale {
+		locale, changed = row.Locale, true
+	}
+	roleChanged := row.Role != "" && row.Role != role
+	if roleChanged && role != rbac.RoleUser && !opts.AllowPrivileged {
+		return result, errPrivilegedTarget
+	}
+	if roleChanged {
+		role, changed = row.Role, true
+	}
+	if !changed {
+		result.Action = ActionUnchanged
+		return result, nil
+	}
+
+	if _, err := tx.Exec(d.Rebind("UPDATE users SET name = $1, role = $2, locale = $3, updated_at = $4 WHERE id = $5"),
+		name, role, locale, now, id); err != nil {
+		return result, err
+	}
+	// Un cambio de rol cierra las sesiones abiertas, igual que en /admin
+	if roleChanged {
+		if err := auth.RevokeUserSessions(tx, d, id); err != nil {
+			return result, err
+		}
+	}
+	result.Action = ActionUpdated
+	return result, nil
+}
+
+func create(tx *sql.Tx, d database.Dialect, row Row, opts Options, now time.Time) (RowResult, error) {
+	result := RowResult{Line: row.Line, Email: row.Email, Action: ActionCreated}
+
+	role := row.Role
+	if role == "" {
+		role = rbac.RoleUser
+	}
+	password, err := unusablePassword()
+	if err != nil {
+		return result, err
+	}
+
+	id, err := database.InsertID(tx, d,
+		"INSERT INTO users (tenant_id, email, password, name, role, locale, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		opts.TenantID, row.Email, password, row.Name, role, row.Locale, statusResetRequired, now, now)
+	if err != nil {
+		return result, err
+	}
+	result.UserID = id
+
+	if opts.Invite {
+		if _, err := tx.Exec(d.Rebind("INSERT INTO invitations (user_id, email, status, attempts, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)"),
+			id, row.Email, InvitationPending, 0, now, now); err != nil {
+			return result, err
+		}
+		result.Invited = true
+	}
+	return result, nil
+}
+
+func countActiveAdmins(tx *sql.Tx, d database.Dialect, tenantID int64) (int, error) {
+	var n int
+	err := tx.QueryRow(d.Rebind("SELECT COUNT(*) FROM users WHERE tenant_id = $1 AND role = $2 AND status <> $3"),
+		tenantID, rbac.RoleAdmin, "disabled").Scan(&n)
+	return n, err
+}
+
+// unusablePassword genera una contraseña aleatoria que nadie conoce; la
+// cuenta solo se puede usar después de definir una con un código
+func unusablePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "!" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,75 @@
+package userimport
+
+import (
+	"database/sql"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/retry"
+)
+
+// Estados de invitations.status
+const (
+	InvitationPending = "pending"
+	InvitationSent    = "sent"
+	InvitationFailed  = "failed" // agotó los reintentos
+)
+
+// MaxInvitationAttempts es el número de envíos antes de marcarla fallida
+const MaxInvitationAttempts = 5
+
+// invitationRetry espera 1, 2, 4, 8... minutos entre envíos
+var invitationRetry = retry.Policy{MaxAttempts: MaxInvitationAttempts, Base: time.Minute}
+
+// Invitation es un correo de bienvenida pendiente de enviar
+type Invitation struct {
+	ID       int64
+	UserID   int64
+	TenantID int64
+	Email    string
+	Attempts int
+}
+
+// DueInvitations devuelve las invitaciones pendientes cuyo siguiente
+// intento ya venció
+func DueInvitations(db *sql.DB, d database.Dialect, now time.Time, limit int) ([]Invitation, error) {
+	rows, err := db.Query(d.Rebind(
+		`SELECT i.id, i.user_id, u.tenant_id, i.email, i.attempts FROM invitations i JOIN users u ON u.id = i.user_id
+		WHERE i.status = $1 AND i.next_attempt_at <= $2 ORDER BY i.id LIMIT $3`),
+		InvitationPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Invitation
+	for rows.Next() {
+		var inv Invitation
+		if err := rows.Scan(&inv.ID, &inv.UserID, &inv.TenantID, &inv.Email, &inv.Attempts); err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
+
+// MarkSent registra el envío exitoso
+func MarkSent(db *sql.DB, d database.Dialect, id int64, now time.Time) error {
+	_, err := db.Exec(d.Rebind("UPDATE invitations SET status = $1, attempts = attempts + 1, last_error = '', sent_at = $2 WHERE id = $3"),
+		InvitationSent, now, id)
+	return err
+}
+
+// MarkFailed registra un intento fallido y programa el siguiente con
+// espera exponencial (1, 2, 4, 8... minutos); al agotar los intentos la
+// invitación queda como fallida
+func MarkFailed(db *sql.DB, d database.Dialect, inv Invitation, cause error, now time.Time) error {
+	attempt := invitationRetry.Failed(inv.Attempts, now)
+	status := InvitationPending
+	if attempt.Exhausted {
+		status = InvitationFailed
+	}
+	_, err := db.Exec(d.Rebind("UPDATE invitations SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $5"),
+		status, attempt.Number, retry.ErrorMessage(cause), attempt.Next, inv.ID)
+	return err
+}
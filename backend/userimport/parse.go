@@ -0,0 +1,142 @@
+// Package userimport carga usuarios en lote desde CSV o JSON: valida cada
+// fila, hace upsert por correo y opcionalmente encola invitaciones para
+// las cuentas nuevas. Lo usan el endpoint /admin/users/import y el comando
+// "key import-users".
+package userimport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formatos de archivo aceptados
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// MaxRows limita el tamaño de un lote
+const MaxRows = 5000
+
+var ErrUnknownFormat = errors.New("formato desconocido (use csv o json)")
+
+// Row es una fila del archivo. Line es la línea del CSV (contando el
+// encabezado) o la posición en el arreglo JSON empezando en 1.
+type Row struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Locale string `json:"locale"`
+}
+
+// FormatFromName deduce el formato por la extensión o el Content-Type
+func FormatFromName(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".json"), strings.Contains(name, "application/json"):
+		return FormatJSON
+	case strings.HasSuffix(name, ".csv"), strings.Contains(name, "text/csv"):
+		return FormatCSV
+	}
+	return ""
+}
+
+// Parse lee las filas en el formato indicado
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSON:
+		return parseJSON(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// parseCSV requiere un encabezado con al menos la columna email; name, role
+// y locale son opcionales y el orden es libre
+func parseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("el archivo está vacío")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encabezado inválido: %v", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("falta la columna email en el encabezado")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV inválido: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("el archivo supera el máximo de %d filas", MaxRows)
+		}
+		rows = append(rows, Row{
+			Line:   line,
+			Email:  field(record, "email"),
+			Name:   field(record, "name"),
+			Role:   field(record, "role"),
+			Locale: field(record, "locale"),
+		})
+	}
+	return rows, nil
+}
+
+// parseJSON acepta un arreglo de objetos o {"users": [...]}
+func parseJSON(r io.Reader) ([]Row, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var wrapped struct {
+			Users []Row `json:"users"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil || wrapped.Users == nil {
+			return nil, fmt.Errorf("JSON inválido: %v", err)
+		}
+		rows = wrapped.Users
+	}
+	if len(rows) > MaxRows {
+		return nil, fmt.Errorf("el archivo supera el máximo de %d filas", MaxRows)
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
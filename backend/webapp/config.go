@@ -0,0 +1,91 @@
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"password-recovery/httpserver"
+)
+
+// Config es la configuración que el frontend lee al cargar (/config.js),
+// así el mismo build sirve en cualquier entorno:
+//
+//	APP_API_BASE_URL   URL del backend ("" si es el mismo servidor)
+//	APP_NAME           nombre que muestra la aplicación
+//	APP_LOGO_URL       logo de la pantalla de inicio
+//	APP_PRIMARY_COLOR  color principal (#rrggbb)
+type Config struct {
+	APIBaseURL   string `json:"apiBaseUrl"`
+	AppName      string `json:"appName"`
+	LogoURL      string `json:"logoUrl"`
+	PrimaryColor string `json:"primaryColor"`
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ConfigFromEnv lee la configuración del frontend
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		APIBaseURL:   strings.TrimRight(strings.TrimSpace(os.Getenv("APP_API_BASE_URL")), "/"),
+		AppName:      strings.TrimSpace(os.Getenv("APP_NAME")),
+		LogoURL:      strings.TrimSpace(os.Getenv("APP_LOGO_URL")),
+		PrimaryColor: strings.TrimSpace(os.Getenv("APP_PRIMARY_COLOR")),
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "Sistema de Autenticación"
+	}
+	if cfg.APIBaseURL != "" && origin(cfg.APIBaseURL) == "" {
+		return cfg, errors.New("APP_API_BASE_URL debe ser una URL http:// o https://")
+	}
+	if cfg.LogoURL != "" && !strings.HasPrefix(cfg.LogoURL, "/") && origin(cfg.LogoURL) == "" {
+		return cfg, errors.New("APP_LOGO_URL debe ser una ruta (/logo.png) o una URL http:// o https://")
+	}
+	if cfg.PrimaryColor != "" && !hexColor.MatchString(cfg.PrimaryColor) {
+		return cfg, errors.New("APP_PRIMARY_COLOR debe ser un color #rrggbb")
+	}
+	return cfg, nil
+}
+
+// origin devuelve "https://host[:puerto]" de una URL absoluta
+func origin(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// CSP es la política de las páginas del frontend: la de la API más el
+// backend y el logo si están en otro origen y las imágenes y fuentes data:
+// de los íconos. El build no tiene scripts en línea (INLINE_RUNTIME_CHUNK
+// en frontend/.env).
+func (c Config) CSP() string {
+	connect, img := "'self'", "'self' data:"
+	if o := origin(c.APIBaseURL); o != "" {
+		connect += " " + o
+	}
+	if o := origin(c.LogoURL); o != "" {
+		img += " " + o
+	}
+	headers := httpserver.HeadersFromEnv()
+	return headers.Policy(headers.CSP +
+		"; script-src 'self'; style-src 'self'; font-src 'self' data:" +
+		"; connect-src " + connect + "; img-src " + img)
+}
+
+// ConfigHandler responde /config.js; no se guarda en caché para que un
+// cambio de configuración se vea al recargar
+func (a *App) ConfigHandler() http.HandlerFunc {
+	body, _ := json.MarshalIndent(a.cfg, "", "  ")
+	script := []byte("window.APP_CONFIG = " + string(body) + ";\n")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(script)
+	}
+}
@@ -0,0 +1,209 @@
+// Package webapp sirve el frontend de React embebido en el binario: los
+// archivos del build con caché (los de /static/ llevan hash en el nombre y
+// son inmutables), versiones comprimidas (.br o .gz del build, o gzip
+// generado al arrancar), index.html para las rutas del SPA y /config.js con
+// la configuración del entorno.
+//
+// El build se copia a webapp/dist con go generate:
+//
+//	go generate ./webapp && go build .
+//
+// Sin build el binario funciona igual, solo con la API.
+package webapp
+
+//go:generate sh -c "cd ../../frontend && npm run build && find ../backend/webapp/dist -mindepth 1 ! -name .gitkeep -delete && cp -R build/. ../backend/webapp/dist/"
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+const (
+	immutableCache = "public, max-age=31536000, immutable"
+	revalidate     = "no-cache"
+	// minCompressSize evita comprimir archivos que ya son pequeños
+	minCompressSize = 1024
+)
+
+// asset es un archivo del build listo para servir
+type asset struct {
+	name        string
+	data        []byte
+	gzip        []byte
+	brotli      []byte
+	etag        string
+	contentType string
+	cache       string
+}
+
+// App sirve el frontend
+type App struct {
+	cfg    Config
+	assets map[string]*asset
+	index  *asset
+	csp    string
+}
+
+// FromEnv lee la configuración del entorno y el build embebido
+func FromEnv() (*App, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// New lee el build embebido
+func New(cfg Config) (*App, error) {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil, err
+	}
+	app := &App{cfg: cfg, assets: map[string]*asset{}, csp: cfg.CSP()}
+	err = fs.WalkDir(sub, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(path.Base(name), ".") {
+			return err
+		}
+		if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".br") {
+			return nil // se leen junto con el original
+		}
+		if name == "config.js" {
+			return nil // la de desarrollo; la responde ConfigHandler
+		}
+		data, err := fs.ReadFile(sub, name)
+		if err != nil {
+			return err
+		}
+		a := &asset{name: name, data: data, cache: revalidate}
+		if strings.HasPrefix(name, "static/") {
+			a.cache = immutableCache
+		}
+		a.contentType = mime.TypeByExtension(path.Ext(name))
+		if a.contentType == "" {
+			a.contentType = http.DetectContentType(data)
+		}
+		sum := sha256.Sum256(data)
+		a.etag = hex.EncodeToString(sum[:8])
+		a.brotli, _ = fs.ReadFile(sub, name+".br")
+		if a.gzip, _ = fs.ReadFile(sub, name+".gz"); a.gzip == nil && compressible(a.contentType, len(data)) {
+			if a.gzip, err = gzipBytes(data); err != nil {
+				return err
+			}
+		}
+		app.assets["/"+name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	app.index = app.assets["/index.html"]
+	return app, nil
+}
+
+func compressible(contentType string, size int) bool {
+	if size < minCompressSize {
+		return false
+	}
+	for _, prefix := range []string{"text/", "application/javascript", "application/json", "application/manifest+json", "image/svg+xml"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Built indica si el binario incluye el frontend
+func (a *App) Built() bool {
+	return a != nil && a.index != nil
+}
+
+// Handler sirve los archivos del build y, para la navegación del SPA
+// (GET de una ruta sin extensión que acepta HTML), index.html. El resto va
+// a fallback, que responde el 404 de la API.
+func (a *App) Handler(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Built() || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		if name == "/" {
+			name = "/index.html"
+		}
+		if file, ok := a.assets[name]; ok {
+			a.serve(w, r, file)
+			return
+		}
+		if path.Ext(name) == "" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			a.serve(w, r, a.index)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// serve responde el archivo en la mejor codificación que acepta el cliente
+func (a *App) serve(w http.ResponseWriter, r *http.Request, file *asset) {
+	header := w.Header()
+	header.Set("Content-Type", file.contentType)
+	header.Set("Cache-Control", file.cache)
+	if file == a.index && a.csp != "" {
+		header.Set("Content-Security-Policy", a.csp)
+	}
+
+	data, etag := file.data, file.etag
+	if file.brotli != nil || file.gzip != nil {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	switch accepts := r.Header.Get("Accept-Encoding"); {
+	case file.brotli != nil && acceptsEncoding(accepts, "br"):
+		header.Set("Content-Encoding", "br")
+		data, etag = file.brotli, etag+"-br"
+	case file.gzip != nil && acceptsEncoding(accepts, "gzip"):
+		header.Set("Content-Encoding", "gzip")
+		data, etag = file.gzip, etag+"-gz"
+	}
+	header.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, file.name, time.Time{}, bytes.NewReader(data))
+}
+
+// acceptsEncoding revisa Accept-Encoding ("gzip, deflate, br;q=0.5"); q=0
+// rechaza la codificación
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"password-recovery/secrets"
+)
+
+// ErrBlockedAddress indica que la URL apunta a una red interna
+var ErrBlockedAddress = errors.New("la dirección no es pública")
+
+// blockedPrefixes son rangos que no son IsPrivate/IsLoopback/IsLinkLocal
+// pero tampoco son destinos válidos de un webhook
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),         // "esta red"
+	netip.MustParsePrefix("100.64.0.0/10"),     // NAT de operador (incluye metadatos de Alibaba)
+	netip.MustParsePrefix("192.0.0.0/24"),      // asignaciones de protocolo IETF
+	netip.MustParsePrefix("198.18.0.0/15"),     // pruebas de rendimiento
+	netip.MustParsePrefix("240.0.0.0/4"),       // reservado y broadcast
+	netip.MustParsePrefix("64:ff9b:1::/48"),    // NAT64 local
+	netip.MustParsePrefix("2001:db8::/32"),     // documentación
+	netip.MustParsePrefix("fec0::/10"),         // site-local (obsoleto)
+	netip.MustParsePrefix("100::/64"),          // descarte
+	netip.MustParsePrefix("2001::/23"),         // asignaciones de protocolo IETF
+	netip.MustParsePrefix("::/128"),            // sin especificar
+	netip.MustParsePrefix("169.254.0.0/16"),    // link-local (metadatos de AWS, GCP y Azure)
+	netip.MustParsePrefix("fd00:ec2::254/128"), // metadatos de AWS por IPv6
+}
+
+// PublicAddress indica si un webhook puede conectarse a ip: no se permiten
+// loopback, redes privadas, link-local (donde están los servicios de
+// metadatos de la nube), multicast ni rangos reservados
+func PublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowPrivateNetworks indica si WEBHOOK_ALLOW_PRIVATE_NETWORKS permite
+// entregar a redes internas (solo fuera de producción, para probar con un
+// receptor local)
+func AllowPrivateNetworks() bool {
+	allow := strings.EqualFold(strings.TrimSpace(os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS")), "true")
+	return allow && !secrets.IsProduction()
+}
+
+// NewClient arma el cliente de las entregas. La dirección se revisa al
+// conectar, después de resolver el nombre, así que un DNS que cambia entre
+// la validación y el envío no sirve para llegar a la red interna. No sigue
+// redirecciones (un 3xx cuenta como fallo) ni usa el proxy del entorno, que
+// haría que se revisara la IP del proxy y no la del destino.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !AllowPrivateNetworks() {
+		dialer.Control = dialControl
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		ForceAttemptHTTP2:   true,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialControl rechaza la conexión si la IP ya resuelta no es pública
+func dialControl(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if !PublicAddress(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ap.Addr())
+	}
+	return nil
+}
+
+// internalHost detecta al crear la suscripción los hosts que nunca serán
+// públicos; los nombres que resuelven a una red interna se rechazan al
+// conectar
+func internalHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return !PublicAddress(ip)
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::6810:84e5", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fd00:ec2::254", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := PublicAddress(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("PublicAddress(%s) = %v, se esperaba %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRejectsInternalURL(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "")
+	for _, u := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"https://[::1]/hook",
+	} {
+		w := &Webhook{URL: u, Events: []string{AllEvents}}
+		w.Normalize()
+		if err := w.Validate(); err == nil {
+			t.Errorf("Validate aceptó %s", u)
+		}
+	}
+
+	w := &Webhook{URL: "http://hooks.example.com/x", Events: []string{AllEvents}}
+	if err := w.Validate(); err != nil {
+		t.Errorf("Validate fuera de producción: %v", err)
+	}
+	t.Setenv("APP_ENV", "production")
+	if err := w.Validate(); err == nil {
+		t.Error("Validate aceptó http:// en producción")
+	}
+}
+
+func TestClientBlocksInternalAddresses(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("el cliente llegó a una dirección interna")
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(5 * time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Get a loopback: %v, se esperaba ErrBlockedAddress", err)
+	}
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	// Se permite loopback solo para levantar el servidor de prueba
+	t.Setenv("APP_ENV", "")
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Error("el cliente siguió la redirección")
+		}
+		http.Redirect(w, r, "/metadata", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(5 * time.Second).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("status %d, se esperaba 302", resp.StatusCode)
+	}
+}
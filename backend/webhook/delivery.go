@@ -0,0 +1,305 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/retry"
+	"password-recovery/tenant"
+)
+
+// Estados de webhook_deliveries.status
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // agotó los reintentos
+)
+
+// MaxAttempts es el número de envíos antes de marcar la entrega fallida
+const MaxAttempts = 8
+
+// retryPolicy espera 1, 2, 4... minutos entre envíos
+var retryPolicy = retry.Policy{MaxAttempts: MaxAttempts, Base: time.Minute}
+
+// Headers de cada entrega
+const (
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Event es el cuerpo JSON que recibe el suscriptor. El ID se repite en los
+// reintentos y en los reenvíos, así que sirve para descartar duplicados.
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	TenantID  int64                  `json:"tenant_id"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// Delivery es un envío de un evento a una suscripción
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus int             `json:"response_status"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+
+	url    string
+	secret string
+}
+
+// wake avisa al proceso que entrega que hay eventos nuevos (ver Wake)
+var wake = make(chan struct{}, 1)
+
+// Publish encola el evento para cada suscripción activa del tenant que lo
+// recibe. Los errores solo se registran: un webhook nunca hace fallar la
+// operación que lo originó.
+func Publish(tenantID int64, eventType string, data map[string]interface{}) {
+	pool, release, err := database.Default.Acquire()
+	if err != nil {
+		slog.Warn("No se pudo publicar el webhook", "event", eventType, "error", err)
+		return
+	}
+	defer release()
+
+	n, err := publish(pool.SQL, pool.Dialect, tenantID, eventType, data, time.Now().UTC())
+	if err != nil {
+		slog.Warn("No se pudo publicar el webhook", "event", eventType, "error", err)
+		return
+	}
+	if n > 0 {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func publish(db *sql.DB, d database.Dialect, tenantID int64, eventType string, data map[string]interface{}, now time.Time) (int, error) {
+	hooks, err := List(db, d, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	var targets []*Webhook
+	for _, w := range hooks {
+		if w.IsActive && w.Subscribes(eventType) {
+			targets = append(targets, w)
+		}
+	}
+	if len(targets) == 0 {
+		return 0, nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+	event := Event{ID: "evt_" + hex.EncodeToString(b), Type: eventType, TenantID: tenantID, CreatedAt: now, Data: data}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	for _, w := range targets {
+		if err := enqueue(db, d, w.ID, event.ID, eventType, string(payload), now); err != nil {
+			return 0, err
+		}
+	}
+	return len(targets), nil
+}
+
+func enqueue(db database.Execer, d database.Dialect, webhookID int64, eventID, eventType, payload string, now time.Time) error {
+	_, err := db.Exec(d.Rebind(`INSERT INTO webhook_deliveries
+		(webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7)`),
+		webhookID, eventID, eventType, payload, DeliveryPending, now, now)
+	return err
+}
+
+const deliveryColumns = `dl.id, dl.webhook_id, dl.event_id, dl.event_type, dl.payload, dl.status, dl.attempts,
+	dl.next_attempt_at, dl.response_status, dl.last_error, dl.created_at, dl.delivered_at`
+
+func scanDelivery(row rowScanner, extra ...interface{}) (*Delivery, error) {
+	var dl Delivery
+	var payload string
+	var delivered sql.NullTime
+	dest := append([]interface{}{&dl.ID, &dl.WebhookID, &dl.EventID, &dl.EventType, &payload, &dl.Status, &dl.Attempts,
+		&dl.NextAttemptAt, &dl.ResponseStatus, &dl.LastError, &dl.CreatedAt, &delivered}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	dl.Payload = json.RawMessage(payload)
+	if delivered.Valid {
+		dl.DeliveredAt = &delivered.Time
+	}
+	return &dl, nil
+}
+
+// Deliveries devuelve las últimas entregas de la suscripción, opcionalmente
+// filtradas por estado
+func Deliveries(db tenant.Querier, d database.Dialect, webhookID int64, status string, limit int) ([]*Delivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries dl WHERE dl.webhook_id = $1"
+	args := []interface{}{webhookID}
+	if status != "" {
+		query += " AND dl.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY dl.id DESC LIMIT " + strconv.Itoa(limit)
+
+	rows, err := db.Query(d.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Delivery{}
+	for rows.Next() {
+		dl, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, dl)
+	}
+	return list, rows.Err()
+}
+
+// GetDelivery busca una entrega de la suscripción
+func GetDelivery(db database.Execer, d database.Dialect, webhookID, id int64) (*Delivery, error) {
+	dl, err := scanDelivery(db.QueryRow(d.Rebind("SELECT "+deliveryColumns+" FROM webhook_deliveries dl WHERE dl.id = $1 AND dl.webhook_id = $2"),
+		id, webhookID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDeliveryNotFound
+	}
+	return dl, err
+}
+
+// Replay vuelve a encolar el evento de una entrega como una entrega nueva
+// (con el mismo ID de evento); la original queda en el historial
+func Replay(db database.Execer, d database.Dialect, dl *Delivery) (int64, error) {
+	now := time.Now().UTC()
+	id, err := database.InsertID(db, d, `INSERT INTO webhook_deliveries
+		(webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7)`,
+		dl.WebhookID, dl.EventID, dl.EventType, string(dl.Payload), DeliveryPending, now, now)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// Due devuelve las entregas pendientes cuyo siguiente intento ya venció,
+// de suscripciones activas
+func Due(db *sql.DB, d database.Dialect, now time.Time, limit int) ([]*Delivery, error) {
+	rows, err := db.Query(d.Rebind(`SELECT `+deliveryColumns+`, w.url, w.secret
+		FROM webhook_deliveries dl JOIN webhooks w ON w.id = dl.webhook_id
+		WHERE dl.status = $1 AND dl.next_attempt_at <= $2 AND w.is_active = TRUE
+		ORDER BY dl.id LIMIT $3`), DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Delivery
+	for rows.Next() {
+		var url, secret string
+		dl, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		dl.url, dl.secret = url, secret
+		list = append(list, dl)
+	}
+	return list, rows.Err()
+}
+
+// Sign calcula la firma que va en X-Webhook-Signature (sin el prefijo
+// "sha256="): HMAC-SHA256 con el secreto de "<timestamp>.<cuerpo>"
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send hace el POST firmado; cualquier respuesta 2xx cuenta como entregada
+func Send(ctx context.Context, client *http.Client, dl *Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.url, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "password-recovery-webhooks/1")
+	req.Header.Set(HeaderEventID, dl.EventID)
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(dl.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(dl.secret, ts, dl.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("respuesta HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// MarkDelivered registra la entrega exitosa
+func MarkDelivered(db *sql.DB, d database.Dialect, dl *Delivery, status int, now time.Time) error {
+	_, err := db.Exec(d.Rebind(`UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3,
+		last_error = '', delivered_at = $4 WHERE id = $5`),
+		DeliveryDelivered, dl.Attempts+1, status, now, dl.ID)
+	return err
+}
+
+// MarkFailed registra un intento fallido y programa el siguiente con espera
+// exponencial (1, 2, 4... minutos); al agotar los intentos la entrega queda
+// como fallida y solo se reenvía con Replay
+func MarkFailed(db *sql.DB, d database.Dialect, dl *Delivery, status int, cause error, now time.Time) error {
+	attempt := retryPolicy.Failed(dl.Attempts, now)
+	state := DeliveryPending
+	if attempt.Exhausted {
+		state = DeliveryFailed
+	}
+	_, err := db.Exec(d.Rebind(`UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3,
+		last_error = $4, next_attempt_at = $5 WHERE id = $6`),
+		state, attempt.Number, status, retry.ErrorMessage(cause), attempt.Next, dl.ID)
+	return err
+}
+
+// Wake devuelve el canal por el que Publish y Replay avisan que hay
+// entregas nuevas en este proceso
+func Wake() <-chan struct{} {
+	return wake
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"password-recovery/database"
+	"password-recovery/tenant"
+)
+
+const columns = "id, tenant_id, url, description, secret, events, is_active, created_at, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row rowScanner) (*Webhook, error) {
+	var w Webhook
+	var events string
+	err := row.Scan(&w.ID, &w.TenantID, &w.URL, &w.Description, &w.secret, &events, &w.IsActive, &w.CreatedAt, &w.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.Events = []string{}
+	for _, e := range strings.Split(events, ",") {
+		if e != "" {
+			w.Events = append(w.Events, e)
+		}
+	}
+	return &w, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// Get busca una suscripción del tenant
+func Get(db database.Execer, d database.Dialect, tenantID, id int64) (*Webhook, error) {
+	return scan(db.QueryRow(d.Rebind("SELECT "+columns+" FROM webhooks WHERE id = $1 AND tenant_id = $2"), id, tenantID))
+}
+
+// List devuelve las suscripciones del tenant
+func List(db tenant.Querier, d database.Dialect, tenantID int64) ([]*Webhook, error) {
+	rows, err := db.Query(d.Rebind("SELECT "+columns+" FROM webhooks WHERE tenant_id = $1 ORDER BY id"), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Webhook{}
+	for rows.Next() {
+		w, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+	return list, rows.Err()
+}
+
+// Create guarda la suscripción y devuelve su secreto; no se vuelve a
+// mostrar
+func Create(db database.Execer, d database.Dialect, w *Webhook) (string, error) {
+	w.Normalize()
+	if err := w.Validate(); err != nil {
+		return "", err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	id, err := database.InsertID(db, d, `INSERT INTO webhooks
+		(tenant_id, url, description, secret, events, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		w.TenantID, w.URL, w.Description, secret, strings.Join(w.Events, ","), w.IsActive, now, now)
+	if err != nil {
+		return "", err
+	}
+	w.ID, w.CreatedAt, w.UpdatedAt, w.secret = id, now, now, secret
+	return secret, nil
+}
+
+// Update guarda URL, descripción, eventos y estado
+func Update(db database.Execer, d database.Dialect, w *Webhook) error {
+	w.Normalize()
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	result, err := db.Exec(d.Rebind(`UPDATE webhooks SET url = $1, description = $2, events = $3, is_active = $4, updated_at = $5
+		WHERE id = $6`), w.URL, w.Description, strings.Join(w.Events, ","), w.IsActive, now, w.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	w.UpdatedAt = now
+	return nil
+}
+
+// RotateSecret genera un secreto nuevo; las entregas pendientes se firman
+// con él
+func RotateSecret(db database.Execer, d database.Dialect, w *Webhook) (string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	if _, err := db.Exec(d.Rebind("UPDATE webhooks SET secret = $1, updated_at = $2 WHERE id = $3"), secret, now, w.ID); err != nil {
+		return "", err
+	}
+	w.secret, w.UpdatedAt = secret, now
+	return secret, nil
+}
+
+// Delete borra la suscripción y su historial de entregas
+func Delete(db database.Execer, d database.Dialect, w *Webhook) error {
+	if _, err := db.Exec(d.Rebind("DELETE FROM webhook_deliveries WHERE webhook_id = $1"), w.ID); err != nil {
+		return err
+	}
+	_, err := db.Exec(d.Rebind("DELETE FROM webhooks WHERE id = $1"), w.ID)
+	return err
+}
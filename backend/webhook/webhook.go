@@ -0,0 +1,131 @@
+// Package webhook avisa a otros sistemas de los eventos de recuperación y de
+// configuración. Cada suscripción pertenece a un tenant y tiene su URL, su
+// secreto de firma y los tipos de evento que le interesan.
+//
+// Las entregas se guardan en webhook_deliveries antes de enviarse, así que
+// sobreviven a un reinicio; las fallidas se reintentan con espera
+// exponencial y se pueden volver a enviar desde la API.
+package webhook
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"password-recovery/fielderr"
+	"password-recovery/secrets"
+)
+
+// Tipos de evento
+const (
+	EventCodeRequested = "code.requested"
+	EventPasswordReset = "password.reset_completed"
+	EventAccountLocked = "account.locked" // un administrador deshabilitó la cuenta
+	EventSMTPChanged   = "smtp.settings_changed"
+	EventDBChanged     = "db.settings_changed"
+
+	// AllEvents suscribe a todos los tipos, incluidos los que se agreguen
+	AllEvents = "*"
+)
+
+const (
+	secretPrefix        = "whsec_"
+	maxURLLength        = 500
+	maxDescriptionChars = 200
+)
+
+// EventTypes lista los tipos de evento en orden
+func EventTypes() []string {
+	return []string{EventCodeRequested, EventPasswordReset, EventAccountLocked, EventSMTPChanged, EventDBChanged}
+}
+
+var (
+	ErrNotFound         = errors.New("webhook no encontrado")
+	ErrDeliveryNotFound = errors.New("entrega no encontrada")
+)
+
+// Webhook es una suscripción. El secreto solo se devuelve al crearla o
+// rotarlo.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	TenantID    int64     `json:"tenant_id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	secret string
+}
+
+// Subscribes indica si la suscripción recibe ese tipo de evento
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == AllEvents || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize limpia la URL y deja los eventos ordenados y sin repetidos
+func (w *Webhook) Normalize() {
+	w.URL = strings.TrimSpace(w.URL)
+	w.Description = strings.TrimSpace(w.Description)
+	seen := map[string]bool{}
+	events := []string{}
+	for _, e := range w.Events {
+		e = strings.TrimSpace(strings.ToLower(e))
+		if e != "" && !seen[e] {
+			seen[e] = true
+			events = append(events, e)
+		}
+	}
+	sort.Strings(events)
+	w.Events = events
+}
+
+// Validate revisa la suscripción (después de Normalize)
+func (w *Webhook) Validate() error {
+	fields := map[string]string{}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		fields["url"] = "use una URL http:// o https:// sin credenciales"
+	} else if len(w.URL) > maxURLLength {
+		fields["url"] = "máximo 500 caracteres"
+	} else if u.Scheme != "https" && secrets.IsProduction() {
+		fields["url"] = "APP_ENV=production exige una URL https://"
+	} else if internalHost(u.Hostname()) && !AllowPrivateNetworks() {
+		fields["url"] = "la URL apunta a una red interna"
+	}
+	if len(w.Description) > maxDescriptionChars {
+		fields["description"] = "máximo 200 caracteres"
+	}
+	if len(w.Events) == 0 {
+		fields["events"] = "indique al menos un tipo de evento o \"*\""
+	}
+	for _, e := range w.Events {
+		if !knownEvent(e) {
+			fields["events"] = "evento desconocido: " + e + " (use " + strings.Join(EventTypes(), ", ") + " o \"*\")"
+			break
+		}
+	}
+	if len(fields) > 0 {
+		return fielderr.New(fields)
+	}
+	return nil
+}
+
+func knownEvent(e string) bool {
+	if e == AllEvents {
+		return true
+	}
+	for _, t := range EventTypes() {
+		if t == e {
+			return true
+		}
+	}
+	return false
+}
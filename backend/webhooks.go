@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"password-recovery/retry"
+	"password-recovery/webhook"
+)
+
+const (
+	defaultWebhookInterval = 15 * time.Second
+	webhookBatchSize       = 20
+	webhookTimeout         = 10 * time.Second
+)
+
+// runWebhooks entrega en segundo plano los eventos encolados por
+// webhook.Publish. Revisa la cola cada interval y en cuanto este proceso
+// publica un evento; los publicados por el servidor de setup esperan al
+// siguiente ciclo.
+func runWebhooks(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWebhookInterval
+	}
+	client := webhook.NewClient(webhookTimeout)
+	retry.Run(ctx, interval, webhook.Wake(), func() { sendDueWebhooks(ctx, client) })
+}
+
+func sendDueWebhooks(ctx context.Context, client *http.Client) {
+	due, err := webhook.Due(db, dbDialect, time.Now().UTC(), webhookBatchSize)
+	if err != nil {
+		slog.Warn("No se pudieron leer las entregas de webhooks pendientes", "error", err)
+		return
+	}
+
+	for _, dl := range due {
+		status, err := webhook.Send(ctx, client, dl, time.Now())
+		if err != nil {
+			slog.Warn("Error al entregar webhook", "delivery_id", dl.ID, "event", dl.EventType, "attempt", dl.Attempts+1, "error", err)
+			err = webhook.MarkFailed(db, dbDialect, dl, status, err, time.Now().UTC())
+		} else {
+			slog.Info("Webhook entregado", "delivery_id", dl.ID, "event", dl.EventType, "status", status)
+			err = webhook.MarkDelivered(db, dbDialect, dl, status, time.Now().UTC())
+		}
+		if err != nil {
+			slog.Error("No se pudo actualizar la entrega del webhook", "delivery_id", dl.ID, "error", err)
+		}
+	}
+}
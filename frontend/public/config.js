@@ -0,0 +1,9 @@
+// Configuración para desarrollo (npm start). En producción el servidor Go
+// responde /config.js con los valores de APP_API_BASE_URL, APP_NAME,
+// APP_LOGO_URL y APP_PRIMARY_COLOR.
+window.APP_CONFIG = {
+  apiBaseUrl: "http://localhost:8080",
+  appName: "Sistema de Autenticación",
+  logoUrl: "",
+  primaryColor: "",
+};
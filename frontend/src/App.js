@@ -2,6 +2,7 @@
 import { BrowserRouter as Router, Routes, Route, Navigate } from "react-router-dom";
 import HomeMenu from "./components/HomeMenu";
 import SMTPConfigView from "./components/SMTPConfigView";
+import AdminLogin from "./components/AdminLogin";
 import PasswordRecovery from "./components/PasswordRecovery";
 import LoginSetup from "./components/Setup/LoginSetup";
 import SetupDBForm from "./components/Setup/SetupDBForm";
@@ -24,6 +25,7 @@ function MainApp() {
   return (
     <Routes>
       <Route path="/" element={<HomeMenu />} />
+      <Route path="/admin-login" element={<AdminLogin />} />
       <Route path="/smtp-config" element={<SMTPConfigView />} />
       <Route path="/recover-password" element={<PasswordRecovery />} />
       <Route path="*" element={<Navigate to="/" />} />
@@ -0,0 +1,70 @@
+import { useState } from "react";
+import { useNavigate } from "react-router-dom";
+import { loginAdmin } from "../services/api";
+
+export default function AdminLogin() {
+  const navigate = useNavigate();
+  const [formData, setFormData] = useState({
+    email: "",
+    password: ""
+  });
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError("");
+    setLoading(true);
+
+    try {
+      await loginAdmin(formData.email, formData.password);
+      navigate("/smtp-config");
+    } catch (err) {
+      setError(err.message);
+      console.error("Error en login:", err);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div style={{ maxWidth: "400px", margin: "2rem auto", padding: "1rem" }}>
+      <h2>Acceso de Administración</h2>
+      <form onSubmit={handleSubmit}>
+        <div style={{ marginBottom: "1rem" }}>
+          <label style={{ display: "block", marginBottom: "0.5rem" }}>Email:</label>
+          <input
+            type="email"
+            value={formData.email}
+            onChange={(e) => setFormData({...formData, email: e.target.value})}
+            style={{ width: "100%", padding: "0.5rem" }}
+          />
+        </div>
+        <div style={{ marginBottom: "1rem" }}>
+          <label style={{ display: "block", marginBottom: "0.5rem" }}>Contraseña:</label>
+          <input
+            type="password"
+            value={formData.password}
+            onChange={(e) => setFormData({...formData, password: e.target.value})}
+            style={{ width: "100%", padding: "0.5rem" }}
+          />
+        </div>
+        <button
+          type="submit"
+          disabled={loading}
+          style={{
+            padding: "0.5rem 1rem",
+            background: loading ? "#ccc" : "#007bff",
+            color: "white",
+            border: "none",
+            borderRadius: "4px",
+            cursor: "pointer"
+          }}
+        >
+          {loading ? "Verificando..." : "Ingresar"}
+        </button>
+        {error && <p style={{ color: "red", marginTop: "1rem" }}>{error}</p>}
+      </form>
+    </div>
+  );
+}
@@ -1,5 +1,6 @@
 import { useNavigate } from 'react-router-dom';
 
+import config from '../config';
 import './HomeMenu.css';
 
 const HomeMenu = () => {
@@ -7,7 +8,8 @@ const HomeMenu = () => {
 
   return (
     <div className="home-menu">
-      <h2>Sistema de Autenticación</h2>
+      {config.logoUrl && <img src={config.logoUrl} alt={config.appName} className="home-logo" />}
+      <h2>{config.appName}</h2>
       
       <div className="menu-options">
         <button
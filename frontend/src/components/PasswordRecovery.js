@@ -2,6 +2,8 @@
 import React, { useState } from "react";
 import { useNavigate, useLocation } from "react-router-dom";
 import axios from "axios";
+import { API_BASE_URL } from "../services/api";
+import { solveChallenge } from "../services/challenge";
 import './PasswordRecovery.css'
 
 const PasswordRecovery = () => {
@@ -29,8 +31,11 @@ const PasswordRecovery = () => {
 
     setLoading(true);
     try {
-      const response = await axios.post("http://localhost:8080/send-code", { 
-        email: formData.email.toLowerCase().trim()
+      // Prueba de trabajo que pide el servidor antes de enviar el código
+      const proof = await solveChallenge();
+      const response = await axios.post(`${API_BASE_URL}/send-code`, { 
+        email: formData.email.toLowerCase().trim(),
+        proof
       });
       
       if (response.status === 200) {
@@ -52,7 +57,7 @@ const PasswordRecovery = () => {
 
     setLoading(true);
     try {
-      const response = await axios.post("http://localhost:8080/verify-code", { 
+      const response = await axios.post(`${API_BASE_URL}/verify-code`, { 
         email: formData.email,
         code: formData.code
       });
@@ -75,7 +80,7 @@ const PasswordRecovery = () => {
 
     setLoading(true);
     try {
-      const response = await axios.post("http://localhost:8080/reset-password", { 
+      const response = await axios.post(`${API_BASE_URL}/reset-password`, { 
         email: formData.email,
         code: formData.code,
         newPassword: formData.newPassword
@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import axios from 'axios';
 import { useNavigate } from 'react-router-dom';
+import { API_BASE_URL, getAdminToken, clearAdminToken } from '../services/api';
 import './SMTPConfigView.css';
 
 function SMTPConfigView() {
@@ -22,8 +23,14 @@ function SMTPConfigView() {
     const navigate = useNavigate();
 
     // Configurar axios
-    axios.defaults.baseURL = 'http://localhost:8080';
+    axios.defaults.baseURL = API_BASE_URL;
     axios.defaults.headers.post['Content-Type'] = 'application/json';
+    const adminToken = getAdminToken();
+    if (adminToken) {
+        axios.defaults.headers.common['Authorization'] = `Bearer ${adminToken}`;
+    } else {
+        delete axios.defaults.headers.common['Authorization'];
+    }
 
     const loadSMTPConfig = async () => {
         setLoading(true);
@@ -39,6 +46,11 @@ function SMTPConfigView() {
         } catch (error) {
             if (error.response && error.response.status === 404) {
                 setConfigExists(false);
+            } else if (error.response && error.response.status === 401) {
+                clearAdminToken();
+                navigate('/admin-login');
+            } else if (error.response && error.response.status === 403) {
+                setError('Your role does not allow viewing the SMTP configuration');
             } else {
                 setError('Error loading SMTP configuration');
                 console.error('Error:', error);
@@ -150,9 +162,9 @@ function SMTPConfigView() {
                 errorDetails = {
                     ...errorDetails,
                     message: error.response.data?.error || 'Server error',
-                    details: error.response.data?.details || error.response.statusText,
-                    suggestion: error.response.data?.suggestion || errorDetails.suggestion,
-                    field: error.response.data?.field // Campo específico con error
+                    details: error.response.data?.code || error.response.statusText,
+                    // Campo específico con error (por ejemplo port)
+                    field: Object.keys(error.response.data?.fields || {})[0]
                 };
             } else if (error.request) {
                 // No se recibió respuesta
@@ -0,0 +1,149 @@
+import { useEffect, useState } from "react";
+import { API_BASE_URL, authHeaders } from "../../services/api";
+
+const emptyConnector = {
+    schema: "",
+    table: "",
+    identifier_column: "",
+    password_column: "",
+    hash_format: "bcrypt",
+    updated_at_columns: "",
+};
+
+// Modo conector: restablecer contraseñas en la tabla de usuarios de otra
+// aplicación que vive en la misma base de datos
+export default function ConnectorForm() {
+    const [form, setForm] = useState(emptyConnector);
+    const [formats, setFormats] = useState(["bcrypt"]);
+    const [enabled, setEnabled] = useState(false);
+    const [testEmail, setTestEmail] = useState("");
+    const [message, setMessage] = useState(null);
+    const [loading, setLoading] = useState(false);
+
+    const load = async () => {
+        const response = await fetch(`${API_BASE_URL}/api/connector`, { headers: authHeaders() });
+        const data = await response.json().catch(() => ({}));
+        if (!response.ok) return;
+        setFormats(data.hash_formats || ["bcrypt"]);
+        setEnabled(data.enabled);
+        if (data.connector) {
+            setForm({
+                ...data.connector,
+                updated_at_columns: (data.connector.updated_at_columns || []).join(", "),
+            });
+        }
+    };
+
+    useEffect(() => {
+        load();
+    }, []);
+
+    const payload = () => ({
+        ...form,
+        updated_at_columns: form.updated_at_columns
+            .split(",")
+            .map((c) => c.trim())
+            .filter(Boolean),
+    });
+
+    const send = async (path, method, body) => {
+        setLoading(true);
+        setMessage(null);
+        try {
+            const response = await fetch(`${API_BASE_URL}${path}`, {
+                method,
+                headers: authHeaders({ "Content-Type": "application/json" }),
+                body: body && JSON.stringify(body),
+            });
+            const data = await response.json().catch(() => ({}));
+            if (!response.ok) {
+                throw new Error(data.error || "Error en el modo conector");
+            }
+            return data;
+        } catch (err) {
+            setMessage({ type: "error", text: err.message });
+            return null;
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const test = async () => {
+        const data = await send("/api/connector/test", "POST", { ...payload(), email: testEmail });
+        if (!data) return;
+        let text = data.message;
+        if (testEmail) {
+            text += data.account_found ? " · cuenta encontrada" : " · cuenta no encontrada";
+        }
+        setMessage({ type: "success", text });
+    };
+
+    const save = async () => {
+        const data = await send("/api/connector", "PUT", payload());
+        if (!data) return;
+        setEnabled(true);
+        setMessage({ type: "success", text: data.message });
+    };
+
+    const disable = async () => {
+        const data = await send("/api/connector", "DELETE");
+        if (!data) return;
+        setEnabled(false);
+        setForm(emptyConnector);
+        setMessage({ type: "success", text: data.message });
+    };
+
+    const field = (name, label, placeholder) => (
+        <div className="form-group">
+            <label>{label}</label>
+            <input
+                type="text"
+                value={form[name]}
+                placeholder={placeholder}
+                onChange={(e) => setForm({ ...form, [name]: e.target.value })}
+            />
+        </div>
+    );
+
+    return (
+        <div className="connector-form">
+            <p>
+                {enabled
+                    ? "✔️ Las contraseñas se escriben en la tabla de la aplicación externa"
+                    : "Las contraseñas se guardan en la tabla users de este servicio"}
+            </p>
+            {field("schema", "Esquema (opcional)", "public")}
+            {field("table", "Tabla", "auth_user")}
+            {field("identifier_column", "Columna de correo", "email")}
+            {field("password_column", "Columna de contraseña", "password")}
+            <div className="form-group">
+                <label>Formato de hash</label>
+                <select
+                    value={form.hash_format}
+                    onChange={(e) => setForm({ ...form, hash_format: e.target.value })}
+                >
+                    {formats.map((f) => (
+                        <option key={f} value={f}>{f}</option>
+                    ))}
+                </select>
+            </div>
+            {field("updated_at_columns", "Columnas de fecha (opcional, separadas por coma)", "updated_at")}
+            <div className="form-group">
+                <label>Correo de prueba (opcional)</label>
+                <input type="email" value={testEmail} onChange={(e) => setTestEmail(e.target.value)} />
+            </div>
+            <div className="action-buttons">
+                <button type="button" onClick={test} disabled={loading}>Probar</button>
+                <button type="button" className="btn-success" onClick={save} disabled={loading}>Guardar</button>
+                {enabled && (
+                    <button type="button" className="btn-warning" onClick={disable} disabled={loading}>
+                        Desactivar
+                    </button>
+                )}
+            </div>
+            {message && (
+                <p style={{ color: message.type === "error" ? "red" : "green" }}>{message.text}</p>
+            )}
+        </div>
+    );
+}
@@ -1,6 +1,8 @@
 import { useEffect, useState } from "react";
 import { useNavigate } from "react-router-dom";
-import { API_BASE_URL } from "../../services/api";
+import { API_BASE_URL, authHeaders } from "../../services/api";
+import ConnectorForm from "./ConnectorForm";
+import LDAPForm from "./LDAPForm";
 import "./Dashboard.css";
 
 export default function Dashboard() {
@@ -65,7 +67,9 @@ export default function Dashboard() {
             setLoading(true);
             setError(null);
 
-            const response = await fetch(`${API_BASE_URL}/api/status`);
+            const response = await fetch(`${API_BASE_URL}/api/status`, {
+                headers: authHeaders(),
+            });
 
             if (!response.ok) {
                 throw new Error(`HTTP error! status: ${response.status}`);
@@ -92,11 +96,15 @@ export default function Dashboard() {
                 setDbInfo(data.db_info);
             }
 
-            // Actualizar estado de conexión
+            // Actualizar estado de conexión (resultado cacheado por el
+            // health checker del backend)
             setSystemStatus(prev => ({
                 ...prev,
-                dbConnected: data.setup_stages.db_configured,
-                dbType: "PostgreSQL",
+                dbConnected: data.health?.database
+                    ? data.health.database.ok
+                    : data.setup_stages.db_configured,
+                dbType: data.db_info?.db_type || "PostgreSQL",
+                checkedAt: data.checked_at,
                 dbUser: data.db_info?.user || "N/A",
                 dbName: data.db_info?.dbname || "N/A"
             }));
@@ -118,9 +126,9 @@ export default function Dashboard() {
 
             const response = await fetch(`${API_BASE_URL}/api/db/test`, {
                 method: 'POST',
-                headers: {
+                headers: authHeaders({
                     'Content-Type': 'application/json',
-                },
+                }),
             });
 
             const result = await response.json();
@@ -175,9 +183,9 @@ export default function Dashboard() {
 
             const response = await fetch(`${API_BASE_URL}/api/setup/create-tables`, {
                 method: 'POST',
-                headers: {
+                headers: authHeaders({
                     'Content-Type': 'application/json',
-                },
+                }),
             });
 
             const result = await response.json();
@@ -231,9 +239,9 @@ export default function Dashboard() {
 
             const response = await fetch(`${API_BASE_URL}/api/setup/create-admin`, {
                 method: 'POST',
-                headers: {
+                headers: authHeaders({
                     'Content-Type': 'application/json',
-                },
+                }),
                 body: JSON.stringify({
                     email: adminForm.email,
                     password: adminForm.password
@@ -297,7 +305,8 @@ export default function Dashboard() {
 
             // 1. Ejecutar reset en el backend
             const response = await fetch(`${API_BASE_URL}/api/setup/reset`, {
-                method: 'POST'
+                method: 'POST',
+                headers: authHeaders(),
             });
 
             if (!response.ok) {
@@ -409,6 +418,11 @@ export default function Dashboard() {
                             value={dbInfo.user}
                         />
                     </div>
+                    {systemStatus.checkedAt && (
+                        <p className="status-checked-at">
+                            Última verificación: {new Date(systemStatus.checkedAt).toLocaleTimeString()}
+                        </p>
+                    )}
                 </section>
 
                 <section className="setup-stages">
@@ -577,6 +591,22 @@ export default function Dashboard() {
                     )}
                 </section>
 
+                {/* Modo conector (opcional) */}
+                {setupStages.tablesCreated && (
+                    <section className="setup-stages">
+                        <h2>Modo Conector (opcional)</h2>
+                        <ConnectorForm />
+                    </section>
+                )}
+
+                {/* Directorio LDAP / Active Directory (opcional) */}
+                {setupStages.tablesCreated && (
+                    <section className="setup-stages">
+                        <h2>Directorio LDAP (opcional)</h2>
+                        <LDAPForm />
+                    </section>
+                )}
+
                 {/* Botón de finalización */}
                 {setupStages.adminCreated && (
                     <section className="finalization-section">
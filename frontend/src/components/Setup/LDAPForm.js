@@ -0,0 +1,157 @@
+import { useEffect, useState } from "react";
+import { API_BASE_URL, authHeaders } from "../../services/api";
+
+const emptyLDAP = {
+    url: "",
+    start_tls: false,
+    insecure_skip_verify: false,
+    ca_cert_file: "",
+    bind_dn: "",
+    bind_password: "",
+    base_dn: "",
+    filter: "(mail={email})",
+    mode: "openldap",
+};
+
+// Destino LDAP: restablecer contraseñas en OpenLDAP o Active Directory
+export default function LDAPForm() {
+    const [form, setForm] = useState(emptyLDAP);
+    const [modes, setModes] = useState(["openldap", "ad"]);
+    const [enabled, setEnabled] = useState(false);
+    const [testEmail, setTestEmail] = useState("");
+    const [message, setMessage] = useState(null);
+    const [loading, setLoading] = useState(false);
+
+    const load = async () => {
+        const response = await fetch(`${API_BASE_URL}/api/ldap`, { headers: authHeaders() });
+        const data = await response.json().catch(() => ({}));
+        if (!response.ok) return;
+        setModes(data.modes || ["openldap", "ad"]);
+        setEnabled(data.enabled);
+        if (data.ldap) {
+            setForm({ ...emptyLDAP, ...data.ldap });
+        }
+    };
+
+    useEffect(() => {
+        load();
+    }, []);
+
+    const send = async (path, method, body) => {
+        setLoading(true);
+        setMessage(null);
+        try {
+            const response = await fetch(`${API_BASE_URL}${path}`, {
+                method,
+                headers: authHeaders({ "Content-Type": "application/json" }),
+                body: body && JSON.stringify(body),
+            });
+            const data = await response.json().catch(() => ({}));
+            if (!response.ok) {
+                throw new Error(data.error || "Error en el destino LDAP");
+            }
+            return data;
+        } catch (err) {
+            setMessage({ type: "error", text: err.message });
+            return null;
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const test = async () => {
+        const data = await send("/api/ldap/test", "POST", { ...form, email: testEmail });
+        if (!data) return;
+        let text = data.message;
+        if (testEmail) {
+            text += data.account_found ? ` · cuenta encontrada (${data.dn})` : " · cuenta no encontrada";
+        }
+        setMessage({ type: "success", text });
+    };
+
+    const save = async () => {
+        const data = await send("/api/ldap", "PUT", form);
+        if (!data) return;
+        setEnabled(true);
+        setForm({ ...emptyLDAP, ...data.ldap });
+        setMessage({ type: "success", text: data.message });
+    };
+
+    const disable = async () => {
+        const data = await send("/api/ldap", "DELETE");
+        if (!data) return;
+        setEnabled(false);
+        setForm(emptyLDAP);
+        setMessage({ type: "success", text: data.message });
+    };
+
+    const field = (name, label, placeholder, type = "text") => (
+        <div className="form-group">
+            <label>{label}</label>
+            <input
+                type={type}
+                value={form[name]}
+                placeholder={placeholder}
+                onChange={(e) => setForm({ ...form, [name]: e.target.value })}
+            />
+        </div>
+    );
+
+    const checkbox = (name, label) => (
+        <div className="form-group">
+            <label>
+                <input
+                    type="checkbox"
+                    checked={form[name]}
+                    onChange={(e) => setForm({ ...form, [name]: e.target.checked })}
+                />{" "}
+                {label}
+            </label>
+        </div>
+    );
+
+    return (
+        <div className="ldap-form">
+            <p>
+                {enabled
+                    ? "✔️ Las contraseñas se cambian en el directorio LDAP"
+                    : "El directorio LDAP no está configurado"}
+            </p>
+            <div className="form-group">
+                <label>Tipo de servidor</label>
+                <select value={form.mode} onChange={(e) => setForm({ ...form, mode: e.target.value })}>
+                    {modes.map((m) => (
+                        <option key={m} value={m}>{m === "ad" ? "Active Directory" : "OpenLDAP"}</option>
+                    ))}
+                </select>
+            </div>
+            {field("url", "URL", "ldaps://dc.example.com:636")}
+            {checkbox("start_tls", "Usar StartTLS (solo con ldap://)")}
+            {checkbox("insecure_skip_verify", "No verificar el certificado (solo pruebas)")}
+            {field("ca_cert_file", "Archivo de la CA (opcional)", "/etc/ssl/certs/ldap-ca.pem")}
+            {field("bind_dn", "DN de la cuenta de servicio", "cn=reset,ou=service,dc=example,dc=com")}
+            {field("bind_password", "Contraseña de la cuenta de servicio", "", "password")}
+            {field("base_dn", "Base de búsqueda", "ou=people,dc=example,dc=com")}
+            {field("filter", "Filtro ({email} se reemplaza por el correo)", "(mail={email})")}
+            {form.mode === "ad" && (
+                <p>Active Directory solo acepta cambios de contraseña sobre LDAPS o StartTLS.</p>
+            )}
+            <div className="form-group">
+                <label>Correo de prueba (opcional)</label>
+                <input type="email" value={testEmail} onChange={(e) => setTestEmail(e.target.value)} />
+            </div>
+            <div className="action-buttons">
+                <button type="button" onClick={test} disabled={loading}>Probar</button>
+                <button type="button" className="btn-success" onClick={save} disabled={loading}>Guardar</button>
+                {enabled && (
+                    <button type="button" className="btn-warning" onClick={disable} disabled={loading}>
+                        Desactivar
+                    </button>
+                )}
+            </div>
+            {message && (
+                <p style={{ color: message.type === "error" ? "red" : "green" }}>{message.text}</p>
+            )}
+        </div>
+    );
+}
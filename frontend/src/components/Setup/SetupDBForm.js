@@ -2,14 +2,28 @@ import { useState } from "react";
 import { useNavigate } from "react-router-dom";
 import { saveDBConfig, testDBConnection } from "../../services/api"; // Nueva función
 
+// Convierte los campos opcionales vacíos para que el backend use sus valores por defecto
+function toPayload(formData) {
+  const payload = { ...formData };
+  payload.connect_timeout = parseInt(formData.connect_timeout, 10) || 0;
+  if (!payload.sslrootcert) delete payload.sslrootcert;
+  if (payload.db_type !== "sqlite") delete payload.path;
+  return payload;
+}
+
 export default function SetupDBForm() {
   const navigate = useNavigate();
   const [formData, setFormData] = useState({
+    db_type: "postgres",
+    path: "",
     host: "localhost",
     port: "5432",
     user: "",
     password: "",
-    dbname: ""
+    dbname: "",
+    sslmode: "disable",
+    sslrootcert: "",
+    connect_timeout: ""
   });
   const [error, setError] = useState("");
   const [loading, setLoading] = useState(false);
@@ -21,7 +35,7 @@ export default function SetupDBForm() {
     setTestResult(null);
 
     try {
-      const result = await testDBConnection(formData);
+      const result = await testDBConnection(toPayload(formData));
       setTestResult({
         success: true,
         message: "Conexión exitosa!",
@@ -35,6 +49,13 @@ export default function SetupDBForm() {
     }
   };
 
+  // Cambia el motor y ajusta el puerto por defecto
+  const handleDBTypeChange = (dbType) => {
+    const defaultPorts = { postgres: "5432", mysql: "3306" };
+    setFormData({ ...formData, db_type: dbType, port: defaultPorts[dbType] || formData.port });
+    setTestResult(null);
+  };
+
   const handleSubmit = async (e) => {
     e.preventDefault();
     setError("");
@@ -48,7 +69,7 @@ export default function SetupDBForm() {
     setLoading(true);
 
     try {
-      await saveDBConfig(formData);
+      await saveDBConfig(toPayload(formData));
       navigate("/dashboard");
     } catch (err) {
       setError(err.message || "Error al guardar la configuración");
@@ -62,6 +83,33 @@ export default function SetupDBForm() {
       {error && <div style={{ color: "red", marginBottom: "1rem" }}>{error}</div>}
 
       <form onSubmit={handleSubmit}>
+        <div style={{ marginBottom: "1rem" }}>
+          <label style={{ display: "block", marginBottom: "0.5rem" }}>Motor:</label>
+          <select
+            value={formData.db_type}
+            onChange={(e) => handleDBTypeChange(e.target.value)}
+            style={{ width: "100%", padding: "0.5rem" }}
+          >
+            <option value="postgres">PostgreSQL</option>
+            <option value="mysql">MySQL / MariaDB</option>
+            <option value="sqlite">SQLite (sin servidor)</option>
+          </select>
+        </div>
+
+        {formData.db_type === "sqlite" ? (
+        <div style={{ marginBottom: "1rem" }}>
+          <label style={{ display: "block", marginBottom: "0.5rem" }}>Archivo de BD (ruta en el servidor):</label>
+          <input
+            type="text"
+            value={formData.path}
+            placeholder="/var/lib/reset/reset.db"
+            onChange={(e) => setFormData({ ...formData, path: e.target.value })}
+            style={{ width: "100%", padding: "0.5rem" }}
+            required
+          />
+        </div>
+        ) : (
+        <>
         <div style={{ display: "flex", gap: "1rem", marginBottom: "1rem" }}>
           <div style={{ flex: 1 }}>
             <label style={{ display: "block", marginBottom: "0.5rem" }}>Host:</label>
@@ -118,6 +166,50 @@ export default function SetupDBForm() {
           />
         </div>
 
+        </>
+        )}
+
+        {formData.db_type !== "sqlite" && (
+        <div style={{ display: "flex", gap: "1rem", marginBottom: "1rem" }}>
+          <div style={{ flex: 1 }}>
+            <label style={{ display: "block", marginBottom: "0.5rem" }}>Modo SSL:</label>
+            <select
+              value={formData.sslmode}
+              onChange={(e) => setFormData({ ...formData, sslmode: e.target.value })}
+              style={{ width: "100%", padding: "0.5rem" }}
+            >
+              <option value="disable">disable</option>
+              <option value="prefer">prefer</option>
+              <option value="require">require</option>
+              <option value="verify-ca">verify-ca</option>
+              <option value="verify-full">verify-full</option>
+            </select>
+          </div>
+          <div style={{ width: "160px" }}>
+            <label style={{ display: "block", marginBottom: "0.5rem" }}>Timeout (s):</label>
+            <input
+              type="number"
+              min="0"
+              value={formData.connect_timeout}
+              onChange={(e) => setFormData({ ...formData, connect_timeout: e.target.value })}
+              style={{ width: "100%", padding: "0.5rem" }}
+            />
+          </div>
+        </div>
+        )}
+
+        {formData.db_type !== "sqlite" && formData.sslmode.startsWith("verify") && (
+          <div style={{ marginBottom: "1rem" }}>
+            <label style={{ display: "block", marginBottom: "0.5rem" }}>Certificado CA (ruta en el servidor):</label>
+            <input
+              type="text"
+              value={formData.sslrootcert}
+              onChange={(e) => setFormData({ ...formData, sslrootcert: e.target.value })}
+              style={{ width: "100%", padding: "0.5rem" }}
+            />
+          </div>
+        )}
+
         <div style={{ marginTop: "1rem", display: "flex", gap: "1rem" }}>
           <button
             type="button"
@@ -0,0 +1,15 @@
+// src/config.js
+// Configuración en tiempo de ejecución. El servidor la entrega en /config.js
+// (ver backend/webapp) para que el mismo build sirva en cualquier entorno;
+// con `npm start` se usa public/config.js.
+const runtime = window.APP_CONFIG || {};
+
+const config = {
+  // "" usa el mismo origen que sirve el frontend
+  apiBaseUrl: runtime.apiBaseUrl ?? "http://localhost:8080",
+  appName: runtime.appName || "Sistema de Autenticación",
+  logoUrl: runtime.logoUrl || "",
+  primaryColor: runtime.primaryColor || "",
+};
+
+export default config;
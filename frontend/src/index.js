@@ -1,9 +1,17 @@
 import React from 'react';
 import ReactDOM from 'react-dom/client';
+import 'bootstrap-icons/font/bootstrap-icons.css';
 import './index.css';
+import config from './config';
 import App from './App';
 import reportWebVitals from './reportWebVitals';
 
+// Marca configurable (APP_NAME, APP_PRIMARY_COLOR)
+document.title = config.appName;
+if (config.primaryColor) {
+  document.documentElement.style.setProperty('--primary-color', config.primaryColor);
+}
+
 const root = ReactDOM.createRoot(document.getElementById('root'));
 root.render(
   <React.StrictMode>
@@ -1,8 +1,30 @@
 // src/services/api.js
-export const API_BASE_URL = "http://localhost:8080"; // Asegúrate que coincida con tu puerto backend
+import config from "../config";
+
+// URL del backend; viene de /config.js (APP_API_BASE_URL)
+export const API_BASE_URL = config.apiBaseUrl;
+
+const SETUP_TOKEN_KEY = "setupToken";
+
+// Token de la sesión de setup (se pierde al cerrar la pestaña)
+export function getSetupToken() {
+  return sessionStorage.getItem(SETUP_TOKEN_KEY);
+}
+
+export function clearSetupToken() {
+  sessionStorage.removeItem(SETUP_TOKEN_KEY);
+}
+
+// Encabezados con el token de setup para los endpoints protegidos
+export function authHeaders(extra = {}) {
+  const token = getSetupToken();
+  return token ? { ...extra, Authorization: `Bearer ${token}` } : extra;
+}
 
 export async function checkSetupStatus() {
-  const response = await fetch(`${API_BASE_URL}/api/status`);
+  const response = await fetch(`${API_BASE_URL}/api/status`, {
+    headers: authHeaders(),
+  });
   if (!response.ok) {
     throw new Error("Error verificando estado del sistema");
   }
@@ -24,16 +46,20 @@ export async function loginSetup(user, pass) {
     throw new Error(errorData.message || "Credenciales inválidas");
   }
 
-  return await response.json();
+  const data = await response.json();
+  if (data.token) {
+    sessionStorage.setItem(SETUP_TOKEN_KEY, data.token);
+  }
+  return data;
 }
 
 // Función para guardar configuración
 export async function saveDBConfig(config) {
   const response = await fetch(`${API_BASE_URL}/api/setup-db`, {
     method: 'POST',
-    headers: {
+    headers: authHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     body: JSON.stringify(config),
   });
 
@@ -52,7 +78,8 @@ export async function saveDBConfig(config) {
 //resetean la configuración del sistema
 export async function resetConfiguration() {
   const response = await fetch(`${API_BASE_URL}/api/setup/reset`, {
-    method: 'POST'
+    method: 'POST',
+    headers: authHeaders(),
   });
 
   if (!response.ok) {
@@ -63,7 +90,8 @@ export async function resetConfiguration() {
   // Forzar recarga del estado en el backend
   await fetch(`${API_BASE_URL}/api/status`, {
     method: 'GET',
-    cache: 'no-cache'
+    cache: 'no-cache',
+    headers: authHeaders(),
   });
 
   return await response.json();
@@ -73,9 +101,9 @@ export async function resetConfiguration() {
 export async function testDBConnection(config) {
   const response = await fetch(`${API_BASE_URL}/api/db/test-config`, {
     method: 'POST',
-    headers: {
+    headers: authHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     body: JSON.stringify(config),
   });
 
@@ -87,3 +115,32 @@ export async function testDBConnection(config) {
 
   return result;
 }
+
+const ADMIN_TOKEN_KEY = "adminToken";
+
+// Token de la sesión administrativa (/admin/*)
+export function getAdminToken() {
+  return sessionStorage.getItem(ADMIN_TOKEN_KEY);
+}
+
+export function clearAdminToken() {
+  sessionStorage.removeItem(ADMIN_TOKEN_KEY);
+}
+
+export async function loginAdmin(email, password) {
+  const response = await fetch(`${API_BASE_URL}/admin/login`, {
+    method: "POST",
+    headers: {
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify({ email, password }),
+  });
+
+  const data = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    throw new Error(data.error || "Credenciales inválidas");
+  }
+
+  sessionStorage.setItem(ADMIN_TOKEN_KEY, data.token);
+  return data;
+}
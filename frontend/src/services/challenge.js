@@ -0,0 +1,49 @@
+// src/services/challenge.js
+// Reto que exige /send-code (ver backend/challenge). Con type "pow" se busca
+// un nonce tal que SHA-256(challenge + nonce) empiece con `difficulty` bits
+// en cero; se calcula por lotes para no congelar la página.
+import axios from "axios";
+import { API_BASE_URL } from "./api";
+
+const BATCH = 2000;
+
+function leadingZeroBits(bytes) {
+  let count = 0;
+  for (const b of bytes) {
+    if (b === 0) {
+      count += 8;
+      continue;
+    }
+    return count + Math.clz32(b) - 24;
+  }
+  return count;
+}
+
+async function solvePow(challenge, difficulty) {
+  const encoder = new TextEncoder();
+  for (let nonce = 0; ; nonce += BATCH) {
+    for (let i = nonce; i < nonce + BATCH; i++) {
+      const digest = await crypto.subtle.digest("SHA-256", encoder.encode(challenge + i));
+      if (leadingZeroBits(new Uint8Array(digest)) >= difficulty) {
+        return String(i);
+      }
+    }
+    await new Promise((resolve) => setTimeout(resolve, 0));
+  }
+}
+
+// Obtiene y resuelve el reto; devuelve el campo `proof` para /send-code
+export async function solveChallenge() {
+  const { data } = await axios.get(`${API_BASE_URL}/challenge`);
+  switch (data.type) {
+    case "none":
+      return undefined;
+    case "pow":
+      return {
+        challenge: data.challenge,
+        nonce: await solvePow(data.challenge, data.difficulty),
+      };
+    default:
+      throw new Error(`Tipo de reto no soportado: ${data.type}`);
+  }
+}